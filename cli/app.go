@@ -3,7 +3,6 @@ package cli
 
 import (
 	"context"
-	"net/http"
 	"os"
 
 	"github.com/pkg/errors"
@@ -50,7 +49,17 @@ func noRepositoryAction(act func(ctx context.Context) error) func(ctx *kingpin.P
 
 func serverAction(act func(ctx context.Context, cli *serverapi.Client) error) func(ctx *kingpin.ParseContext) error {
 	return func(_ *kingpin.ParseContext) error {
-		return act(context.Background(), serverapi.NewClient(*serverAddress, http.DefaultClient))
+		apiClient, err := serverapi.NewClient(serverapi.ClientOptions{
+			BaseURL:                             *serverAddress,
+			Username:                            *serverUsername,
+			Password:                            *serverPassword,
+			TrustedServerCertificateFingerprint: *serverCertFingerprint,
+		})
+		if err != nil {
+			return errors.Wrap(err, "unable to create API client")
+		}
+
+		return act(context.Background(), apiClient)
 	}
 }
 
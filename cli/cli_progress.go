@@ -86,17 +86,15 @@ func (mp *multiProgress) Report(desc string, progress, total int64) {
 
 	found.update(progress, total)
 
-	var segments []string
-	for i, p := range mp.items {
-		segments = append(segments, p.toString(i > 0))
-	}
-	if found.progress >= found.total && foundPos == len(segments)-1 {
+	// multiple directories can be in progress at the same time, the one that was just
+	// updated is logged and removed when it completes, regardless of its position.
+	msg := found.toString(foundPos > 0)
+
+	if found.progress >= found.total {
 		mp.items = append(mp.items[0:foundPos], mp.items[foundPos+1:]...)
-		if len(segments) > 0 {
-			log.Notice(segments[len(segments)-1])
-		}
-	} else if len(segments) > 0 {
-		log.Info(segments[len(segments)-1])
+		log.Notice(msg)
+	} else {
+		log.Info(msg)
 	}
 }
 
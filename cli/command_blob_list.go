@@ -25,6 +25,10 @@ func runBlobList(ctx context.Context, rep *repo.Repository) error {
 			return nil
 		}
 
+		if *jsonOutput {
+			return jsonLines.write(b)
+		}
+
 		fmt.Printf("%-70v %10v %v\n", b.BlobID, b.Length, formatTimestamp(b.Timestamp))
 		return nil
 	})
@@ -8,6 +8,7 @@ import (
 
 	"github.com/pkg/errors"
 
+	"github.com/kopia/kopia/internal/serverapi"
 	"github.com/kopia/kopia/internal/units"
 	"github.com/kopia/kopia/repo"
 )
@@ -33,6 +34,11 @@ func runCacheInfoCommand(ctx context.Context, rep *repo.Repository) error {
 		"metadata": rep.Content.CachingOptions.MaxMetadataCacheSizeBytes,
 	}
 
+	info := &serverapi.CacheInfoResponse{
+		Path:        rep.Content.CachingOptions.CacheDirectory,
+		Directories: []*serverapi.CacheDirectoryInfo{},
+	}
+
 	for _, ent := range entries {
 		if !ent.IsDir() {
 			continue
@@ -43,6 +49,17 @@ func runCacheInfoCommand(ctx context.Context, rep *repo.Repository) error {
 			return err
 		}
 
+		if *jsonOutput {
+			info.Directories = append(info.Directories, &serverapi.CacheDirectoryInfo{
+				Path:      subdir,
+				Files:     fileCount,
+				TotalSize: totalFileSize,
+				MaxSize:   path2Limit[ent.Name()],
+			})
+
+			continue
+		}
+
 		maybeLimit := ""
 		if l, ok := path2Limit[ent.Name()]; ok {
 			maybeLimit = fmt.Sprintf(" (limit %v)", units.BytesStringBase10(l))
@@ -50,6 +67,10 @@ func runCacheInfoCommand(ctx context.Context, rep *repo.Repository) error {
 		fmt.Printf("%v: %v files %v%v\n", subdir, fileCount, units.BytesStringBase10(totalFileSize), maybeLimit)
 	}
 
+	if *jsonOutput {
+		return printJSON(info)
+	}
+
 	return nil
 }
 
@@ -35,6 +35,11 @@ func runContentListCommand(ctx context.Context, rep *repo.Repository) error {
 			}
 			atomic.AddInt64(&totalSize, int64(b.Length))
 			atomic.AddInt32(&count, 1)
+
+			if *jsonOutput {
+				return jsonLines.write(b)
+			}
+
 			if *contentListLong {
 				optionalDeleted := ""
 				if b.Deleted {
@@ -59,7 +64,12 @@ func runContentListCommand(ctx context.Context, rep *repo.Repository) error {
 	}
 
 	if *contentListSummary {
-		fmt.Printf("Total: %v contents, %v total size\n",
+		printSummary := printStdout
+		if *jsonOutput {
+			printSummary = printStderr
+		}
+
+		printSummary("Total: %v contents, %v total size\n",
 			maybeHumanReadableCount(*contentListHuman, int64(count)),
 			maybeHumanReadableBytes(*contentListHuman, totalSize))
 	}
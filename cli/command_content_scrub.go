@@ -0,0 +1,97 @@
+package cli
+
+import (
+	"context"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/units"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/content"
+	"github.com/kopia/kopia/snapshot/scrub"
+)
+
+const scrubStateFileName = "scrub-state.json"
+
+var (
+	contentScrubCommand    = contentCommands.Command("scrub", "Download and verify a subset of packs, cycling through the entire repository over a number of runs")
+	contentScrubPercent    = contentScrubCommand.Flag("percent", "Percentage of packs to verify (default is based on --cycle-runs)").Float64()
+	contentScrubCycleRuns  = contentScrubCommand.Flag("cycle-runs", "Number of runs over which all packs are verified").Default("10").Int()
+	contentScrubTimeBudget = contentScrubCommand.Flag("time-budget", "Stop verifying new packs after the given duration").Duration()
+	contentScrubParallel   = contentScrubCommand.Flag("parallel", "Number of packs to verify in parallel").Default("4").Int()
+	contentScrubStateFile  = contentScrubCommand.Flag("state-file", "File where verification state is kept (defaults to cache directory)").String()
+)
+
+func runContentScrubCommand(ctx context.Context, rep *repo.Repository) error {
+	stateFile := *contentScrubStateFile
+	if stateFile == "" {
+		cacheDir := rep.Content.CachingOptions.CacheDirectory
+		if cacheDir == "" {
+			return errors.New("caching is disabled, must specify --state-file")
+		}
+
+		stateFile = filepath.Join(cacheDir, scrubStateFileName)
+	}
+
+	percent := *contentScrubPercent
+	if percent == 0 {
+		if *contentScrubCycleRuns <= 0 {
+			return errors.New("--cycle-runs must be positive")
+		}
+
+		percent = 100 / float64(*contentScrubCycleRuns)
+	}
+
+	state, err := scrub.LoadState(stateFile)
+	if err != nil {
+		return err
+	}
+
+	res, err := scrub.Run(ctx, rep, state, scrub.Options{
+		Percent:    percent,
+		TimeBudget: *contentScrubTimeBudget,
+		Parallel:   *contentScrubParallel,
+	})
+
+	// save the state even if some packs could not be verified.
+	if serr := state.Save(stateFile); serr != nil {
+		return errors.Wrap(serr, "unable to save scrub state")
+	}
+
+	if err != nil {
+		return errors.Wrap(err, "scrub failed")
+	}
+
+	printStderr("Verified %v out of %v packs (%v contents, %v), %v packs were never verified.\n",
+		res.VerifiedPacks, res.TotalPacks, res.VerifiedContents, units.BytesStringBase10(res.VerifiedBytes), res.NeverVerified)
+
+	if len(res.Corrupt) == 0 {
+		return nil
+	}
+
+	var ids []content.ID
+
+	for _, c := range res.Corrupt {
+		printStdout("corrupt content %v in pack %v: %v\n", c.ContentID, c.PackID, c.Error)
+		ids = append(ids, c.ContentID)
+	}
+
+	printStderr("Looking for snapshots referencing corrupt contents...\n")
+
+	refs, err := scrub.FindReferences(ctx, rep, ids)
+	if err != nil {
+		return errors.Wrap(err, "unable to find references")
+	}
+
+	for _, r := range refs {
+		printStdout("content %v is referenced by %v in snapshot %v of %v at %v\n",
+			r.ContentID, r.Path, r.SnapshotID, r.Source, formatTimestamp(r.StartTime))
+	}
+
+	return errors.Errorf("found %v corrupt contents", len(res.Corrupt))
+}
+
+func init() {
+	contentScrubCommand.Action(repositoryAction(runContentScrubCommand))
+}
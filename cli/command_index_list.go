@@ -6,6 +6,7 @@ import (
 	"sort"
 
 	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/blob"
 )
 
 var (
@@ -36,11 +37,24 @@ func runListBlockIndexesAction(ctx context.Context, rep *repo.Repository) error
 	}
 
 	for _, b := range blks {
+		if *jsonOutput {
+			if err := jsonLines.write(blob.Metadata{BlobID: b.BlobID, Length: b.Length, Timestamp: b.Timestamp}); err != nil {
+				return err
+			}
+
+			continue
+		}
+
 		fmt.Printf("%-70v %10v %v\n", b.BlobID, b.Length, formatTimestampPrecise(b.Timestamp))
 	}
 
 	if *blockIndexListSummary {
-		fmt.Printf("total %v blocks\n", len(blks))
+		printSummary := printStdout
+		if *jsonOutput {
+			printSummary = printStderr
+		}
+
+		printSummary("total %v blocks\n", len(blks))
 	}
 
 	return nil
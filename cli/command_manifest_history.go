@@ -0,0 +1,81 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/manifest"
+)
+
+var (
+	manifestHistoryCommand = manifestCommands.Command("history", "Show retained versions of manifest items, including deleted ones")
+	manifestHistoryItems   = manifestHistoryCommand.Arg("item", "Manifest IDs or key:value label filters").Required().Strings()
+)
+
+func init() {
+	manifestHistoryCommand.Action(repositoryAction(showManifestHistory))
+}
+
+func showManifestHistory(ctx context.Context, rep *repo.Repository) error {
+	var (
+		ids []manifest.ID
+		q   = manifest.Query{IncludeDeleted: true}
+	)
+
+	for _, it := range *manifestHistoryItems {
+		p := strings.Index(it, ":")
+		if p < 0 {
+			ids = append(ids, manifest.ID(it))
+			continue
+		}
+
+		if p == 0 {
+			return errors.Errorf("invalid label filter %q", it)
+		}
+
+		q.Labels = append(q.Labels, manifest.LabelEquals(it[0:p], it[p+1:]))
+	}
+
+	if len(q.Labels) > 0 {
+		entries, err := rep.Manifests.Query(ctx, q)
+		if err != nil {
+			return err
+		}
+
+		for _, e := range entries {
+			ids = append(ids, e.ID)
+		}
+	}
+
+	for _, id := range ids {
+		versions, err := rep.Manifests.History(ctx, id)
+		if err != nil {
+			return errors.Wrapf(err, "error getting history of %q", id)
+		}
+
+		fmt.Printf("%v type:%v %v\n", id, versions[len(versions)-1].Labels["type"], sortedMapValues(versions[len(versions)-1].Labels))
+
+		for i, v := range versions {
+			fmt.Printf("  %v %10v %v\n", formatTimestamp(v.ModTime.Local()), v.Length, manifestVersionStatus(v, i == len(versions)-1))
+		}
+	}
+
+	return nil
+}
+
+func manifestVersionStatus(v *manifest.EntryMetadata, isLatest bool) string {
+	switch {
+	case v.Deleted && isLatest:
+		return "deleted (use 'kopia manifest undelete " + string(v.ID) + "' to restore)"
+	case v.Deleted:
+		return "deleted"
+	case isLatest:
+		return "current"
+	default:
+		return "superseded"
+	}
+}
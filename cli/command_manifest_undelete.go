@@ -0,0 +1,30 @@
+package cli
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo"
+)
+
+var (
+	manifestUndeleteCommand = manifestCommands.Command("undelete", "Restore deleted manifest items that are still retained")
+	manifestUndeleteItems   = manifestUndeleteCommand.Arg("item", "Items to restore").Required().Strings()
+)
+
+func runManifestUndeleteCommand(ctx context.Context, rep *repo.Repository) error {
+	for _, it := range toManifestIDs(*manifestUndeleteItems) {
+		if err := rep.Manifests.Undelete(ctx, it); err != nil {
+			return errors.Wrapf(err, "unable to undelete %q", it)
+		}
+
+		printStderr("Restored %v\n", it)
+	}
+
+	return nil
+}
+
+func init() {
+	manifestUndeleteCommand.Action(repositoryAction(runManifestUndeleteCommand))
+}
@@ -10,6 +10,7 @@ import (
 
 	"github.com/kopia/kopia/fs/ignorefs"
 	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/snapshot/changedetection"
 	"github.com/kopia/kopia/snapshot/policy"
 )
 
@@ -19,8 +20,13 @@ var (
 	policySetGlobal  = policySetCommand.Flag("global", "Set global policy").Bool()
 
 	// Frequency
-	policySetInterval   = policySetCommand.Flag("snapshot-interval", "Interval between snapshots").DurationList()
-	policySetTimesOfDay = policySetCommand.Flag("snapshot-time", "Times of day when to take snapshot (HH:mm)").Strings()
+	policySetInterval    = policySetCommand.Flag("snapshot-interval", "Interval between snapshots").DurationList()
+	policySetTimesOfDay  = policySetCommand.Flag("snapshot-time", "Times of day when to take snapshot (HH:mm)").Strings()
+	policySetDaysOfWeek  = policySetCommand.Flag("snapshot-days-of-week", "Days of week when snapshot times apply, e.g. 'mon-fri' (or 'inherit')").String()
+	policySetDaysOfMonth = policySetCommand.Flag("snapshot-days-of-month", "Days of month when snapshot times apply, e.g. '1,15' (or 'inherit')").String()
+	policySetCron        = policySetCommand.Flag("snapshot-cron", "Cron expressions when to take snapshot, e.g. '0 2 * * 1-5' (or 'inherit')").Strings()
+	policySetTimeZone    = policySetCommand.Flag("snapshot-time-zone", "Time zone of snapshot times and cron expressions, e.g. 'Europe/Berlin' (or 'inherit')").String()
+	policySetCatchUp     = policySetCommand.Flag("snapshot-catch-up-window", "Take missed snapshots if they were missed by no more than the given duration").DurationList()
 
 	// Expiration policies.
 	policySetKeepLatest  = policySetCommand.Flag("keep-latest", "Number of most recent backups to keep per source (or 'inherit')").PlaceHolder("N").String()
@@ -41,6 +47,12 @@ var (
 	policySetClearDotIgnore  = policySetCommand.Flag("clear-dot-ignore", "Clear list of paths in the dot-ignore list").Bool()
 	policySetMaxFileSize     = policySetCommand.Flag("max-file-size", "Exclude files above given size").PlaceHolder("N").String()
 
+	// Error handling.
+	policySetMaxFileErrors = policySetCommand.Flag("max-file-errors", "Fail snapshots when more than N files can't be read (or 'inherit')").PlaceHolder("N").String()
+
+	// Hashing.
+	policySetChangeDetection = policySetCommand.Flag("change-detection", "How to detect changed files: trust-mtime, trust-ctime or always-hash (or 'inherit')").PlaceHolder("MODE").String()
+
 	// General policy.
 	policySetInherit = policySetCommand.Flag(inheritPolicyString, "Enable or disable inheriting policies from the parent").BoolList()
 )
@@ -99,6 +111,12 @@ func setPolicyFromFlags(p *policy.Policy, changeCount *int) error {
 		return errors.Wrap(err, "maximum file size")
 	}
 
+	if err := applyPolicyNumber("maximum number of file errors", &p.ErrorHandlingPolicy.MaxFileErrors, *policySetMaxFileErrors, changeCount); err != nil {
+		return errors.Wrap(err, "error handling policy")
+	}
+
+	setHashingPolicyFromFlags(&p.HashingPolicy, changeCount)
+
 	// It's not really a list, just optional boolean, last one wins.
 	for _, inherit := range *policySetInherit {
 		*changeCount++
@@ -125,6 +143,20 @@ func setFilesPolicyFromFlags(fp *ignorefs.FilesPolicy, changeCount *int) {
 	}
 }
 
+func setHashingPolicyFromFlags(hp *policy.HashingPolicy, changeCount *int) {
+	switch m := *policySetChangeDetection; m {
+	case "":
+	case inheritPolicyString:
+		*changeCount++
+		hp.ChangeDetection = ""
+		printStderr(" - resetting change detection to default\n")
+	default:
+		*changeCount++
+		hp.ChangeDetection = changedetection.Mode(m)
+		printStderr(" - setting change detection to %v\n", m)
+	}
+}
+
 func setRetentionPolicyFromFlags(rp *policy.RetentionPolicy, changeCount *int) error {
 	cases := []struct {
 		desc      string
@@ -184,6 +216,84 @@ func setSchedulingPolicyFromFlags(sp *policy.SchedulingPolicy, changeCount *int)
 		}
 	}
 
+	return setCalendarSchedulingPolicyFromFlags(sp, changeCount)
+}
+
+func setCalendarSchedulingPolicyFromFlags(sp *policy.SchedulingPolicy, changeCount *int) error {
+	if err := applyPolicyDays("days of week", &sp.DaysOfWeek, *policySetDaysOfWeek, policy.ParseDaysOfWeek, changeCount); err != nil {
+		return err
+	}
+
+	if err := applyPolicyDays("days of month", &sp.DaysOfMonth, *policySetDaysOfMonth, policy.ParseDaysOfMonth, changeCount); err != nil {
+		return err
+	}
+
+	if len(*policySetCron) > 0 {
+		var cron []string
+
+		for _, expr := range *policySetCron {
+			if expr == inheritPolicyString {
+				cron = nil
+				break
+			}
+
+			cron = append(cron, expr)
+		}
+		*changeCount++
+
+		sp.Cron = policy.SortAndDedupeStrings(cron)
+
+		if cron == nil {
+			printStderr(" - resetting snapshot cron expressions to default\n")
+		} else {
+			printStderr(" - setting snapshot cron expressions to %q\n", cron)
+		}
+	}
+
+	switch tz := *policySetTimeZone; tz {
+	case "":
+	case inheritPolicyString:
+		*changeCount++
+		sp.TimeZone = ""
+		printStderr(" - resetting snapshot time zone to default\n")
+	default:
+		*changeCount++
+		sp.TimeZone = tz
+		printStderr(" - setting snapshot time zone to %v\n", tz)
+	}
+
+	// It's not really a list, just optional value.
+	for _, window := range *policySetCatchUp {
+		*changeCount++
+		sp.SetCatchUpWindow(window)
+		printStderr(" - setting snapshot catch-up window to %v\n", sp.CatchUpWindow())
+		break
+	}
+
+	return nil
+}
+
+func applyPolicyDays(desc string, val *[]int, str string, parse func(string) ([]int, error), changeCount *int) error {
+	if str == "" {
+		// not changed
+		return nil
+	}
+
+	if str == inheritPolicyString {
+		*changeCount++
+		printStderr(" - resetting %v to a default value inherited from parent.\n", desc)
+		*val = nil
+		return nil
+	}
+
+	v, err := parse(str)
+	if err != nil {
+		return errors.Wrapf(err, "can't parse the %v %q", desc, str)
+	}
+
+	*changeCount++
+	printStderr(" - setting %v to %v.\n", desc, v)
+	*val = v
 	return nil
 }
 
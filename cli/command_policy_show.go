@@ -6,6 +6,7 @@ import (
 
 	"github.com/pkg/errors"
 
+	"github.com/kopia/kopia/internal/serverapi"
 	"github.com/kopia/kopia/internal/units"
 	"github.com/kopia/kopia/repo"
 	"github.com/kopia/kopia/snapshot/policy"
@@ -15,7 +16,6 @@ var (
 	policyShowCommand = policyCommands.Command("show", "Show snapshot policy.").Alias("get")
 	policyShowGlobal  = policyShowCommand.Flag("global", "Get global policy").Bool()
 	policyShowTargets = policyShowCommand.Arg("target", "Target to show the policy for").Strings()
-	policyShowJSON    = policyShowCommand.Flag("json", "Show JSON").Short('j').Bool()
 )
 
 func init() {
@@ -34,11 +34,24 @@ func showPolicy(ctx context.Context, rep *repo.Repository) error {
 			return errors.Wrapf(err, "can't get effective policy for %q", target)
 		}
 
-		if *policyShowJSON {
-			fmt.Println(effective)
-		} else {
-			printPolicy(effective, policies)
+		if *jsonOutput {
+			def, err := policy.DefinitionPoints(policies)
+			if err != nil {
+				return errors.Wrapf(err, "can't determine policy definition points for %q", target)
+			}
+
+			if err := jsonLines.write(&serverapi.EffectivePolicyResponse{
+				Target:     target,
+				Effective:  effective,
+				Definition: def,
+			}); err != nil {
+				return err
+			}
+
+			continue
 		}
+
+		printPolicy(effective, policies)
 	}
 
 	return nil
@@ -78,6 +91,10 @@ func printPolicy(p *policy.Policy, parents []*policy.Policy) {
 	printStdout("\n")
 	printFilesPolicy(p, parents)
 	printStdout("\n")
+	printErrorHandlingPolicy(p, parents)
+	printStdout("\n")
+	printHashingPolicy(p, parents)
+	printStdout("\n")
 	printSchedulingPolicy(p, parents)
 }
 
@@ -147,6 +164,24 @@ func printFilesPolicy(p *policy.Policy, parents []*policy.Policy) {
 	}
 }
 
+func printErrorHandlingPolicy(p *policy.Policy, parents []*policy.Policy) {
+	printStdout("Error handling:\n")
+	printStdout("  Max file errors:   %3v           %v\n",
+		valueOrNotSet(p.ErrorHandlingPolicy.MaxFileErrors),
+		getDefinitionPoint(parents, func(pol *policy.Policy) bool {
+			return pol.ErrorHandlingPolicy.MaxFileErrors != nil
+		}))
+}
+
+func printHashingPolicy(p *policy.Policy, parents []*policy.Policy) {
+	printStdout("Hashing:\n")
+	printStdout("  Change detection:  %-13v %v\n",
+		p.HashingPolicy.ChangeDetection,
+		getDefinitionPoint(parents, func(pol *policy.Policy) bool {
+			return pol.HashingPolicy.ChangeDetection != ""
+		}))
+}
+
 func printSchedulingPolicy(p *policy.Policy, parents []*policy.Policy) {
 	if p.SchedulingPolicy.Interval() != 0 {
 		printStdout("Snapshot interval:     %10v  %v\n", p.SchedulingPolicy.Interval(), getDefinitionPoint(parents, func(pol *policy.Policy) bool {
@@ -169,6 +204,52 @@ func printSchedulingPolicy(p *policy.Policy, parents []*policy.Policy) {
 			}))
 		}
 	}
+
+	printCalendarSchedulingPolicy(p, parents)
+}
+
+func printCalendarSchedulingPolicy(p *policy.Policy, parents []*policy.Policy) {
+	sp := p.SchedulingPolicy
+
+	if len(sp.DaysOfWeek) > 0 {
+		printStdout("Snapshot days of week: %10v  %v\n", sp.DaysOfWeek, getDefinitionPoint(parents, func(pol *policy.Policy) bool {
+			return len(pol.SchedulingPolicy.DaysOfWeek) > 0
+		}))
+	}
+
+	if len(sp.DaysOfMonth) > 0 {
+		printStdout("Snapshot days of month:%10v  %v\n", sp.DaysOfMonth, getDefinitionPoint(parents, func(pol *policy.Policy) bool {
+			return len(pol.SchedulingPolicy.DaysOfMonth) > 0
+		}))
+	}
+
+	if len(sp.Cron) > 0 {
+		printStdout("Snapshot cron expressions:\n")
+		for _, expr := range sp.Cron {
+			expr := expr
+			printStdout("  %-30v %v\n", expr, getDefinitionPoint(parents, func(pol *policy.Policy) bool {
+				for _, e := range pol.SchedulingPolicy.Cron {
+					if e == expr {
+						return true
+					}
+				}
+
+				return false
+			}))
+		}
+	}
+
+	if sp.TimeZone != "" {
+		printStdout("Snapshot time zone:    %10v  %v\n", sp.TimeZone, getDefinitionPoint(parents, func(pol *policy.Policy) bool {
+			return pol.SchedulingPolicy.TimeZone != ""
+		}))
+	}
+
+	if sp.CatchUpWindow() != 0 {
+		printStdout("Catch-up window:       %10v  %v\n", sp.CatchUpWindow(), getDefinitionPoint(parents, func(pol *policy.Policy) bool {
+			return pol.SchedulingPolicy.CatchUpWindow() != 0
+		}))
+	}
 }
 
 func valueOrNotSet(p *int) string {
@@ -6,8 +6,10 @@ import (
 
 	"github.com/pkg/errors"
 
+	"github.com/kopia/kopia/internal/retry"
 	"github.com/kopia/kopia/repo"
 	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/blob/throttling"
 	"github.com/kopia/kopia/repo/content"
 
 	"gopkg.in/alecthomas/kingpin.v2"
@@ -20,6 +22,15 @@ var (
 	connectMaxCacheSizeMB         int64
 	connectMaxMetadataCacheSizeMB int64
 	connectMaxListCacheDuration   time.Duration
+	connectMaxUploadSpeed         int64
+	connectMaxDownloadSpeed       int64
+	connectMaxConcurrentOps       int
+	connectThrottleSchedule       []string
+	connectRetryMaxAttempts       int
+	connectRetryMaxDuration       time.Duration
+	connectRetryInitialBackoff    time.Duration
+	connectRetryMaxBackoff        time.Duration
+	connectRetryJitter            float64
 )
 
 func setupConnectOptions(cmd *kingpin.CmdClause) {
@@ -30,16 +41,65 @@ func setupConnectOptions(cmd *kingpin.CmdClause) {
 	cmd.Flag("content-cache-size-mb", "Size of local content cache").PlaceHolder("MB").Default("5000").Int64Var(&connectMaxCacheSizeMB)
 	cmd.Flag("metadata-cache-size-mb", "Size of local metadata cache").PlaceHolder("MB").Default("500").Int64Var(&connectMaxMetadataCacheSizeMB)
 	cmd.Flag("max-list-cache-duration", "Duration of index cache").Default("600s").Hidden().DurationVar(&connectMaxListCacheDuration)
+	cmd.Flag("throttle-max-upload-speed", "Limit the upload speed to storage for all providers").PlaceHolder("BYTES_PER_SEC").Int64Var(&connectMaxUploadSpeed)
+	cmd.Flag("throttle-max-download-speed", "Limit the download speed from storage for all providers").PlaceHolder("BYTES_PER_SEC").Int64Var(&connectMaxDownloadSpeed)
+	cmd.Flag("throttle-max-concurrent-ops", "Limit the number of concurrent storage operations").PlaceHolder("N").IntVar(&connectMaxConcurrentOps)
+	cmd.Flag("throttle-schedule", "Limits during given times of day, e.g. 'mon-fri 09:00-17:00 upload=1000000 download=5000000 ops=2'").PlaceHolder("SCHEDULE").StringsVar(&connectThrottleSchedule)
+	cmd.Flag("retry-max-attempts", "Maximum number of attempts of failed storage operations").PlaceHolder("N").IntVar(&connectRetryMaxAttempts)
+	cmd.Flag("retry-max-duration", "Maximum total duration of retrying failed storage operations").PlaceHolder("DURATION").DurationVar(&connectRetryMaxDuration)
+	cmd.Flag("retry-initial-backoff", "Delay after the first failed attempt of a storage operation, doubled after each subsequent one").PlaceHolder("DURATION").DurationVar(&connectRetryInitialBackoff)
+	cmd.Flag("retry-max-backoff", "Maximum delay between attempts of failed storage operations").PlaceHolder("DURATION").DurationVar(&connectRetryMaxBackoff)
+	cmd.Flag("retry-jitter", "Randomize delays between attempts by up to the given fraction (0..1)").PlaceHolder("FRACTION").Float64Var(&connectRetryJitter)
 }
 
-func connectOptions() repo.ConnectOptions {
+// retryPolicyFromFlags returns the retry policy specified by connect flags or nil if the default policy should be used.
+func retryPolicyFromFlags() *retry.Policy {
+	if connectRetryMaxAttempts == 0 && connectRetryMaxDuration == 0 &&
+		connectRetryInitialBackoff == 0 && connectRetryMaxBackoff == 0 && connectRetryJitter == 0 {
+		return nil
+	}
+
+	p := retry.DefaultPolicy()
+	if connectRetryMaxAttempts != 0 || connectRetryMaxDuration != 0 {
+		p.MaxAttempts = connectRetryMaxAttempts
+		p.MaxDuration = connectRetryMaxDuration
+	}
+
+	if connectRetryInitialBackoff != 0 {
+		p.InitialBackoff = connectRetryInitialBackoff
+	}
+
+	if connectRetryMaxBackoff != 0 {
+		p.MaxBackoff = connectRetryMaxBackoff
+	}
+
+	p.Jitter = connectRetryJitter
+
+	return &p
+}
+
+func connectOptions() (repo.ConnectOptions, error) {
+	schedule, err := parseThrottleSchedule(connectThrottleSchedule)
+	if err != nil {
+		return repo.ConnectOptions{}, err
+	}
+
 	return repo.ConnectOptions{
 		CachingOptions: content.CachingOptions{
 			CacheDirectory:          connectCacheDirectory,
 			MaxCacheSizeBytes:       connectMaxCacheSizeMB << 20,
 			MaxListCacheDurationSec: int(connectMaxListCacheDuration.Seconds()),
 		},
-	}
+		Throttling: throttling.Options{
+			Limits: throttling.Limits{
+				UploadBytesPerSecond:   connectMaxUploadSpeed,
+				DownloadBytesPerSecond: connectMaxDownloadSpeed,
+				MaxConcurrentOps:       connectMaxConcurrentOps,
+			},
+			Schedule: schedule,
+		},
+		Retry: retryPolicyFromFlags(),
+	}, nil
 }
 
 func init() {
@@ -55,8 +115,13 @@ func runConnectCommandWithStorage(ctx context.Context, st blob.Storage) error {
 }
 
 func runConnectCommandWithStorageAndPassword(ctx context.Context, st blob.Storage, password string) error {
+	opt, err := connectOptions()
+	if err != nil {
+		return err
+	}
+
 	configFile := repositoryConfigFileName()
-	if err := repo.Connect(ctx, configFile, st, password, connectOptions()); err != nil {
+	if err := repo.Connect(ctx, configFile, st, password, opt); err != nil {
 		return err
 	}
 
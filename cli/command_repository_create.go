@@ -9,6 +9,7 @@ import (
 	"github.com/kopia/kopia/fs/ignorefs"
 	"github.com/kopia/kopia/repo"
 	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/blob/parity"
 	"github.com/kopia/kopia/repo/content"
 	"github.com/kopia/kopia/repo/object"
 	"github.com/kopia/kopia/snapshot/policy"
@@ -21,6 +22,11 @@ var (
 	createBlockEncryptionFormat = createCommand.Flag("encryption", "Block encryption algorithm.").PlaceHolder("ALGO").Default(content.DefaultEncryption).Enum(content.SupportedEncryptionAlgorithms()...)
 	createSplitter              = createCommand.Flag("object-splitter", "The splitter to use for new objects in the repository").Default(object.DefaultSplitter).Enum(object.SupportedSplitters...)
 
+	createParityOverhead = createCommand.Flag("parity-overhead", "Percentage of additional space used for Reed-Solomon parity of pack blobs, which allows damaged packs to be repaired (0 disables)").PlaceHolder("PERCENT").Default("0").Int()
+
+	createObfuscateBlobNames = createCommand.Flag("obfuscate-blob-names", "Hide names of blobs from the storage provider").Bool()
+	createPadBlobSizes       = createCommand.Flag("pad-blob-sizes", "Pad blobs to size buckets to hide their approximate sizes from the storage provider (requires --obfuscate-blob-names)").Bool()
+
 	createOnly = createCommand.Flag("create-only", "Create repository, but don't connect to it.").Short('c').Bool()
 
 	createGlobalPolicyKeepLatest  = createCommand.Flag("keep-latest", "Number of most recent backups to keep per source").PlaceHolder("N").Default("10").Int()
@@ -40,7 +46,12 @@ func init() {
 	setupConnectOptions(createCommand)
 }
 
-func newRepositoryOptionsFromFlags() *repo.NewRepositoryOptions {
+func newRepositoryOptionsFromFlags() (*repo.NewRepositoryOptions, error) {
+	parityOptions, err := parity.OptionsForOverhead(*createParityOverhead)
+	if err != nil {
+		return nil, err
+	}
+
 	return &repo.NewRepositoryOptions{
 		BlockFormat: content.FormattingOptions{
 			Hash:       *createBlockHashFormat,
@@ -50,7 +61,12 @@ func newRepositoryOptionsFromFlags() *repo.NewRepositoryOptions {
 		ObjectFormat: object.Format{
 			Splitter: *createSplitter,
 		},
-	}
+
+		Parity: parityOptions,
+
+		ObfuscateBlobNames: *createObfuscateBlobNames,
+		PadBlobSizes:       *createPadBlobSizes,
+	}, nil
 }
 
 func ensureEmpty(ctx context.Context, s blob.Storage) error {
@@ -71,7 +87,10 @@ func runCreateCommandWithStorage(ctx context.Context, st blob.Storage) error {
 		return errors.Wrap(err, "unable to get repository storage")
 	}
 
-	options := newRepositoryOptionsFromFlags()
+	options, err := newRepositoryOptionsFromFlags()
+	if err != nil {
+		return err
+	}
 
 	password, err := getPasswordFromFlags(true, false)
 	if err != nil {
@@ -83,6 +102,14 @@ func runCreateCommandWithStorage(ctx context.Context, st blob.Storage) error {
 	printStderr("  encryption:          %v\n", options.BlockFormat.Encryption)
 	printStderr("  splitter:            %v\n", options.ObjectFormat.Splitter)
 
+	if p := options.Parity; p != nil {
+		printStderr("  pack parity:         %v data + %v parity shards\n", p.DataShards, p.ParityShards)
+	}
+
+	if options.ObfuscateBlobNames {
+		printStderr("  blob names:          obfuscated (padded sizes: %v)\n", options.PadBlobSizes)
+	}
+
 	if err := repo.Initialize(ctx, st, options, password); err != nil {
 		return errors.Wrap(err, "cannot initialize repository")
 	}
@@ -0,0 +1,190 @@
+package cli
+
+import (
+	"context"
+	"io/ioutil"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/blob/obfuscate"
+)
+
+var (
+	exportRecoveryKitCommand             = repositoryCommands.Command("export-recovery-kit", "Export a printable recovery kit, which allows the repository format blob to be restored.")
+	exportRecoveryKitOutput              = exportRecoveryKitCommand.Flag("output", "Output file").Short('o').Required().String()
+	exportRecoveryKitIncludeKeys         = exportRecoveryKitCommand.Flag("include-keys", "Include key material that allows resetting a lost repository password with 'repository recover --reset-password'").Bool()
+	exportRecoveryKitRecoveryPassphrase  = exportRecoveryKitCommand.Flag("recovery-passphrase", "Passphrase protecting the key material").Envar("KOPIA_RECOVERY_PASSPHRASE").String()
+	exportRecoveryKitNoRecoveryPassphase = exportRecoveryKitCommand.Flag("no-recovery-passphrase", "Store key material unprotected").Bool()
+
+	recoverCommand                   = repositoryCommands.Command("recover", "Restore repository format blob from a recovery kit.")
+	recoverCommandKit                = recoverCommand.Flag("kit", "Recovery kit file").Required().ExistingFile()
+	recoverCommandRecoveryPassphrase = recoverCommand.Flag("recovery-passphrase", "Passphrase protecting the key material").Envar("KOPIA_RECOVERY_PASSPHRASE").String()
+	recoverCommandMaxPacks           = recoverCommand.Flag("verify-packs", "Maximum number of packs to verify the recovery kit against").Default("10").Int()
+	recoverCommandOverwrite          = recoverCommand.Flag("overwrite", "Overwrite the existing format blob if it differs from the recovery kit").Bool()
+	recoverCommandDryRun             = recoverCommand.Flag("dry-run", "Do not modify repository").Short('n').Bool()
+	recoverCommandResetPassword      = recoverCommand.Flag("reset-password", "Use key material from the recovery kit to set a new repository password").Bool()
+)
+
+func runExportRecoveryKitCommand(ctx context.Context, rep *repo.Repository) error {
+	passphrase := *exportRecoveryKitRecoveryPassphrase
+
+	if *exportRecoveryKitIncludeKeys && passphrase == "" && !*exportRecoveryKitNoRecoveryPassphase {
+		p1, err := askPass("Enter recovery passphrase: ")
+		if err != nil {
+			return errors.Wrap(err, "passphrase entry")
+		}
+
+		p2, err := askPass("Re-enter recovery passphrase for verification: ")
+		if err != nil {
+			return errors.Wrap(err, "passphrase verification")
+		}
+
+		if p1 != p2 {
+			return errors.New("passphrases don't match")
+		}
+
+		passphrase = p1
+	}
+
+	kit, err := rep.RecoveryKit(*exportRecoveryKitIncludeKeys, passphrase)
+	if err != nil {
+		return err
+	}
+
+	b, err := kit.Encode()
+	if err != nil {
+		return err
+	}
+
+	if err := ioutil.WriteFile(*exportRecoveryKitOutput, b, 0600); err != nil {
+		return errors.Wrap(err, "unable to write recovery kit")
+	}
+
+	printStderr("Wrote recovery kit to %v.\n", *exportRecoveryKitOutput)
+
+	if kit.HasKeyMaterial() && !kit.IsKeyMaterialProtected() {
+		printStderr("WARNING: The recovery kit contains unprotected key material, anyone who has it can read the repository.\n")
+	}
+
+	return nil
+}
+
+func runRecoverCommandWithStorage(ctx context.Context, st blob.Storage) error {
+	b, err := ioutil.ReadFile(*recoverCommandKit)
+	if err != nil {
+		return errors.Wrap(err, "unable to read recovery kit")
+	}
+
+	kit, err := repo.DecodeRecoveryKit(b)
+	if err != nil {
+		return err
+	}
+
+	printStderr("Recovery kit for repository %x created at %v.\n", kit.UniqueID, formatTimestamp(kit.Created))
+
+	if *recoverCommandResetPassword && !kit.HasKeyMaterial() {
+		return errors.New("recovery kit does not include key material, unable to reset password")
+	}
+
+	passphrase := *recoverCommandRecoveryPassphrase
+
+	if kit.HasKeyMaterial() {
+		if kit.IsKeyMaterialProtected() && passphrase == "" {
+			if passphrase, err = askPass("Enter recovery passphrase: "); err != nil {
+				return errors.Wrap(err, "passphrase entry")
+			}
+		}
+
+		if _, err = kit.MasterKey(passphrase); err != nil {
+			return err
+		}
+
+		printStderr("Key material matches the format blob.\n")
+	}
+
+	if *recoverCommandResetPassword {
+		// obfuscated blob names are derived from the lost password, so they can't be found with the new one.
+		if _, err = obfuscate.ReadParameters(ctx, st); err != blob.ErrBlobNotFound {
+			if err != nil {
+				return errors.Wrap(err, "unable to read obfuscation parameters")
+			}
+
+			return errors.New("blob names are obfuscated using the repository password, which can't be reset")
+		}
+	} else {
+		st, err = maybeDeobfuscateStorage(ctx, st)
+		if err != nil {
+			return err
+		}
+	}
+
+	v, err := repo.VerifyRecoveryKit(ctx, st, kit, *recoverCommandMaxPacks)
+	if err != nil {
+		return errors.Wrap(err, "recovery kit does not match the repository")
+	}
+
+	if v.CheckedPacks == 0 {
+		printStderr("WARNING: No packs found, unable to verify that the recovery kit matches the repository.\n")
+	} else {
+		printStderr("Verified %v packs: %v match the recovery kit, %v have an older format blob of the same repository.\n",
+			v.CheckedPacks, v.MatchingPacks, v.OutdatedPacks)
+	}
+
+	existing, err := st.GetBlob(ctx, repo.FormatBlobID, 0, -1)
+	switch {
+	case err == blob.ErrBlobNotFound:
+		// restore below
+	case err != nil:
+		return errors.Wrap(err, "unable to read format blob")
+	case string(existing) == string(kit.FormatBlob):
+		if !*recoverCommandResetPassword {
+			printStderr("Format blob is intact, nothing to recover.\n")
+			return nil
+		}
+	case !*recoverCommandOverwrite:
+		return errors.New("format blob exists and differs from the recovery kit, pass --overwrite to replace it")
+	}
+
+	if *recoverCommandResetPassword {
+		return resetPasswordFromRecoveryKit(ctx, st, kit, passphrase)
+	}
+
+	if *recoverCommandDryRun {
+		printStderr("Format blob would be restored (dry run).\n")
+		return nil
+	}
+
+	if err := repo.RestoreFormatBlob(ctx, st, kit); err != nil {
+		return err
+	}
+
+	printStderr("Format blob restored.\n")
+
+	return nil
+}
+
+func resetPasswordFromRecoveryKit(ctx context.Context, st blob.Storage, kit *repo.RecoveryKit, recoveryPassphrase string) error {
+	newPassword, err := getPasswordFromFlags(true, false)
+	if err != nil {
+		return errors.Wrap(err, "getting new password")
+	}
+
+	if *recoverCommandDryRun {
+		printStderr("Format blob would be restored with the new password (dry run).\n")
+		return nil
+	}
+
+	if err := repo.ResetPassword(ctx, st, kit, recoveryPassphrase, newPassword); err != nil {
+		return err
+	}
+
+	printStderr("Format blob restored with the new password, clients must reconnect using it.\n")
+
+	return nil
+}
+
+func init() {
+	exportRecoveryKitCommand.Action(repositoryAction(runExportRecoveryKitCommand))
+}
@@ -7,6 +7,8 @@ import (
 
 	"github.com/kopia/kopia/repo"
 	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/blob/obfuscate"
+	"github.com/kopia/kopia/repo/blob/parity"
 	"github.com/kopia/kopia/repo/content"
 )
 
@@ -15,6 +17,7 @@ var (
 
 	repairCommandRecoverFormatBlob         = repairCommand.Flag("recover-format", "Recover format blob from a copy").Default("auto").Enum("auto", "yes", "no")
 	repairCommandRecoverFormatBlobPrefixes = repairCommand.Flag("recover-format-block-prefixes", "Prefixes of file names").Strings()
+	repairCommandRepairPacks               = repairCommand.Flag("repair-packs", "Repair damaged pack blobs using parity data").Default("auto").Enum("auto", "yes", "no")
 	repairDryDrun                          = repairCommand.Flag("dry-run", "Do not modify repository").Short('n').Bool()
 )
 
@@ -27,12 +30,48 @@ func packBlockPrefixes() []string {
 
 }
 func runRepairCommandWithStorage(ctx context.Context, st blob.Storage) error {
+	st, err := maybeDeobfuscateStorage(ctx, st)
+	if err != nil {
+		return err
+	}
+
 	if err := maybeRecoverFormatBlob(ctx, st); err != nil {
 		return err
 	}
+
+	if err := maybeRepairPacks(ctx, st); err != nil {
+		return err
+	}
+
 	return nil
 }
 
+// maybeDeobfuscateStorage returns storage with original blob names if blob names are obfuscated.
+func maybeDeobfuscateStorage(ctx context.Context, st blob.Storage) (blob.Storage, error) {
+	p, err := obfuscate.ReadParameters(ctx, st)
+	if err == blob.ErrBlobNotFound {
+		return st, nil
+	}
+
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read obfuscation parameters")
+	}
+
+	log.Infof("blob names are obfuscated, password is required")
+
+	pass, err := getPasswordFromFlags(false, false)
+	if err != nil {
+		return nil, errors.Wrap(err, "getting password")
+	}
+
+	key, err := p.DeriveKey(pass)
+	if err != nil {
+		return nil, err
+	}
+
+	return obfuscate.NewWrapper(st, p, key)
+}
+
 func maybeRecoverFormatBlob(ctx context.Context, st blob.Storage) error {
 	switch *repairCommandRecoverFormatBlob {
 	case "auto":
@@ -87,3 +126,65 @@ func recoverFormatBlob(ctx context.Context, st blob.Storage, prefixes []string)
 
 	return errors.New("could not find a replica of a format blob")
 }
+
+func maybeRepairPacks(ctx context.Context, st blob.Storage) error {
+	if *repairCommandRepairPacks == "no" {
+		return nil
+	}
+
+	log.Infof("looking for pack parity data...")
+
+	sidecars, err := blob.ListAllBlobs(ctx, st, parity.BlobIDPrefix)
+	if err != nil {
+		return errors.Wrap(err, "unable to list parity blobs")
+	}
+
+	if len(sidecars) == 0 {
+		if *repairCommandRepairPacks == "yes" {
+			return errors.New("repository does not have parity data for packs")
+		}
+
+		log.Infof("no parity data found, not repairing packs")
+
+		return nil
+	}
+
+	var checked, repaired, damaged, failed int
+
+	log.Infof("checking %v packs with parity data...", len(sidecars))
+
+	for _, sc := range sidecars {
+		packID := sc.BlobID[len(parity.BlobIDPrefix):]
+
+		res, err := parity.Repair(ctx, st, packID, *repairDryDrun)
+		if err != nil {
+			printStderr("unable to repair pack %v: %v\n", packID, err)
+			failed++
+
+			continue
+		}
+
+		checked++
+
+		if res.CorruptShards == 0 {
+			continue
+		}
+
+		damaged++
+
+		if res.Repaired {
+			printStderr("repaired pack %v (%v damaged shards)\n", packID, res.CorruptShards)
+			repaired++
+		} else {
+			printStderr("pack %v is damaged (%v damaged shards), can be repaired\n", packID, res.CorruptShards)
+		}
+	}
+
+	printStderr("Checked %v packs: %v damaged, %v repaired, %v could not be repaired.\n", checked+failed, damaged, repaired, failed)
+
+	if failed > 0 {
+		return errors.Errorf("unable to repair %v packs", failed)
+	}
+
+	return nil
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/pkg/errors"
 
 	"github.com/kopia/kopia/internal/scrubber"
+	"github.com/kopia/kopia/internal/serverapi"
 	"github.com/kopia/kopia/internal/units"
 	"github.com/kopia/kopia/repo"
 )
@@ -22,6 +23,14 @@ var (
 )
 
 func runStatusCommand(ctx context.Context, rep *repo.Repository) error {
+	if *jsonOutput {
+		if *statusReconnectToken {
+			return errors.New("--reconnect-token is not supported with --json")
+		}
+
+		return printJSON(serverapi.NewStatusResponse(rep))
+	}
+
 	fmt.Printf("Config file:         %v\n", rep.ConfigFile)
 
 	ci := rep.Blobs.ConnectionInfo()
@@ -42,6 +51,10 @@ func runStatusCommand(ctx context.Context, rep *repo.Repository) error {
 	fmt.Printf("Max pack length:     %v\n", units.BytesStringBase2(int64(rep.Content.Format.MaxPackSize)))
 	fmt.Printf("Splitter:            %v\n", rep.Objects.Format.Splitter)
 
+	if p := rep.Parity; p != nil {
+		fmt.Printf("Pack parity:         %v data + %v parity shards of up to %v\n", p.DataShards, p.ParityShards, units.BytesStringBase2(int64(p.ShardSize)))
+	}
+
 	if *statusReconnectToken {
 		pass := ""
 
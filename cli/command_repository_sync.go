@@ -0,0 +1,323 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/efarrer/iothrottler"
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/units"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/blob/obfuscate"
+)
+
+var (
+	syncToCommand = repositoryCommands.Command("sync-to", "Synchronizes contents of this repository to another location, making it a replica that can be opened using the same password.")
+
+	syncToDelete        = syncToCommand.Flag("delete", "Delete blobs in the destination that don't exist in the source").Bool()
+	syncToDryRun        = syncToCommand.Flag("dry-run", "Do not modify the destination").Short('n').Bool()
+	syncToParallel      = syncToCommand.Flag("parallel", "Number of blobs to copy in parallel").Default("4").Int()
+	syncToMaxThroughput = syncToCommand.Flag("max-throughput", "Limit the copy throughput.").PlaceHolder("BYTES_PER_SEC").Int()
+	syncToForce         = syncToCommand.Flag("force", "Synchronize even if the destination contains a different repository").Bool()
+)
+
+// syncMarkerBlobs are copied after all other blobs in this order, since the destination can be opened
+// as a repository once they are present. Repositories with obfuscated blob names store the format blob
+// under an obfuscated name and are opened using the obfuscation parameters blob.
+var syncMarkerBlobs = []blob.ID{repo.FormatBlobID, obfuscate.ParametersBlobID}
+
+// runSyncWithStorage copies blobs missing in the destination (or having a different length) from the source
+// and optionally deletes extraneous ones. Since blobs are compared by ID and length, interrupted
+// synchronization can be resumed by running the command again.
+func runSyncWithStorage(ctx context.Context, src, dst blob.Storage) error {
+	printStderr("Looking for blobs to synchronize...\n")
+
+	srcBlobs, err := listBlobsByID(ctx, src)
+	if err != nil {
+		return errors.Wrap(err, "unable to list blobs in the source")
+	}
+
+	dstBlobs, err := listBlobsByID(ctx, dst)
+	if err != nil {
+		return errors.Wrap(err, "unable to list blobs in the destination")
+	}
+
+	if err := ensureSyncDestinationCompatible(ctx, src, dst, srcBlobs, dstBlobs); err != nil {
+		return err
+	}
+
+	var (
+		toCopy    []blob.Metadata
+		toDelete  []blob.Metadata
+		copyBytes int64
+	)
+
+	for id, bm := range srcBlobs {
+		if isSyncMarkerBlob(id) {
+			// copied last, so the destination only becomes openable after all data is in place.
+			continue
+		}
+
+		if d, ok := dstBlobs[id]; !ok || d.Length != bm.Length {
+			toCopy = append(toCopy, bm)
+			copyBytes += bm.Length
+		}
+	}
+
+	for id, bm := range dstBlobs {
+		if _, ok := srcBlobs[id]; !ok {
+			toDelete = append(toDelete, bm)
+		}
+	}
+
+	// copy pack blobs before index blobs that reference them.
+	sort.Slice(toCopy, func(i, j int) bool {
+		if a, b := isIndexBlob(toCopy[i].BlobID), isIndexBlob(toCopy[j].BlobID); a != b {
+			return b
+		}
+
+		return toCopy[i].BlobID < toCopy[j].BlobID
+	})
+
+	printStderr("Found %v blobs in the source and %v in the destination.\n", len(srcBlobs), len(dstBlobs))
+	printStderr("  %v blobs to copy (%v)\n", len(toCopy), units.BytesStringBase10(copyBytes))
+	printStderr("  %v extraneous blobs in the destination\n", len(toDelete))
+
+	if *syncToDryRun {
+		printStderr("Not modifying the destination because of --dry-run.\n")
+		return nil
+	}
+
+	if err := copyBlobsInParallel(ctx, src, dst, toCopy, copyBytes); err != nil {
+		return err
+	}
+
+	if *syncToDelete {
+		for _, bm := range toDelete {
+			log.Debugf("deleting %v", bm.BlobID)
+
+			if err := dst.DeleteBlob(ctx, bm.BlobID); err != nil {
+				return errors.Wrapf(err, "unable to delete blob %v", bm.BlobID)
+			}
+		}
+
+		printStderr("Deleted %v extraneous blobs.\n", len(toDelete))
+	} else if len(toDelete) > 0 {
+		printStderr("Pass --delete to remove extraneous blobs from the destination.\n")
+	}
+
+	for _, id := range syncMarkerBlobs {
+		if _, ok := srcBlobs[id]; !ok {
+			continue
+		}
+
+		if err := syncMarkerBlob(ctx, src, dst, id); err != nil {
+			return err
+		}
+	}
+
+	printStderr("Synchronization completed.\n")
+
+	return nil
+}
+
+func listBlobsByID(ctx context.Context, st blob.Storage) (map[blob.ID]blob.Metadata, error) {
+	result := map[blob.ID]blob.Metadata{}
+
+	if err := st.ListBlobs(ctx, "", func(bm blob.Metadata) error {
+		result[bm.BlobID] = bm
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func isIndexBlob(id blob.ID) bool {
+	return len(id) > 0 && id[0] == 'n'
+}
+
+func isSyncMarkerBlob(id blob.ID) bool {
+	for _, m := range syncMarkerBlobs {
+		if id == m {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ensureSyncDestinationCompatible returns an error if the destination already contains a different repository.
+func ensureSyncDestinationCompatible(ctx context.Context, src, dst blob.Storage, srcBlobs, dstBlobs map[blob.ID]blob.Metadata) error {
+	if *syncToForce {
+		return nil
+	}
+
+	for _, id := range syncMarkerBlobs {
+		if _, ok := dstBlobs[id]; !ok {
+			continue
+		}
+
+		if _, ok := srcBlobs[id]; !ok {
+			return errors.New("destination contains a different repository, pass --force to overwrite it")
+		}
+
+		same, err := sameRepositoryMarker(ctx, src, dst, id)
+		if err != nil {
+			return err
+		}
+
+		if !same {
+			return errors.New("destination contains a different repository, pass --force to overwrite it")
+		}
+	}
+
+	return nil
+}
+
+// sameRepositoryMarker determines whether a given marker blob in the source and destination belongs to the same repository.
+func sameRepositoryMarker(ctx context.Context, src, dst blob.Storage, id blob.ID) (bool, error) {
+	srcData, err := src.GetBlob(ctx, id, 0, -1)
+	if err != nil {
+		return false, errors.Wrapf(err, "unable to read source blob %v", id)
+	}
+
+	dstData, err := dst.GetBlob(ctx, id, 0, -1)
+	if err != nil {
+		return false, errors.Wrapf(err, "unable to read destination blob %v", id)
+	}
+
+	if id != repo.FormatBlobID {
+		// obfuscation parameters include random salt, which is unique to the repository.
+		return bytes.Equal(srcData, dstData), nil
+	}
+
+	srcID, err := repo.FormatBlobUniqueID(srcData)
+	if err != nil {
+		return false, errors.Wrap(err, "unable to read source format blob")
+	}
+
+	dstID, err := repo.FormatBlobUniqueID(dstData)
+	if err != nil {
+		return false, errors.Wrap(err, "unable to read destination format blob")
+	}
+
+	return bytes.Equal(srcID, dstID), nil
+}
+
+func copyBlobsInParallel(ctx context.Context, src, dst blob.Storage, blobs []blob.Metadata, totalBytes int64) error {
+	var bandwidth iothrottler.Bandwidth = iothrottler.Unlimited
+	if *syncToMaxThroughput > 0 {
+		bandwidth = iothrottler.Bandwidth(*syncToMaxThroughput) * iothrottler.BytesPerSecond
+	}
+
+	throttler := iothrottler.NewIOThrottlerPool(bandwidth)
+	defer throttler.ReleasePool()
+
+	ch := make(chan blob.Metadata)
+
+	go func() {
+		defer close(ch)
+
+		for _, bm := range blobs {
+			select {
+			case ch <- bm:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg          sync.WaitGroup
+		mu          sync.Mutex
+		firstErr    error
+		copiedBytes int64
+	)
+
+	parallel := *syncToParallel
+	if parallel < 1 {
+		parallel = 1
+	}
+
+	for i := 0; i < parallel; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for bm := range ch {
+				if err := copyBlob(ctx, src, dst, bm, throttler); err != nil {
+					mu.Lock()
+					if firstErr == nil {
+						firstErr = err
+					}
+					mu.Unlock()
+					cancel()
+
+					return
+				}
+
+				cliProgress.Report("sync", atomic.AddInt64(&copiedBytes, bm.Length), totalBytes)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	return firstErr
+}
+
+func copyBlob(ctx context.Context, src, dst blob.Storage, bm blob.Metadata, throttler *iothrottler.IOThrottlerPool) error {
+	log.Debugf("copying %v (%v bytes)", bm.BlobID, bm.Length)
+
+	data, err := src.GetBlob(ctx, bm.BlobID, 0, -1)
+	if err != nil {
+		return errors.Wrapf(err, "unable to read blob %v", bm.BlobID)
+	}
+
+	r, err := throttler.AddReader(ioutil.NopCloser(bytes.NewReader(data)))
+	if err != nil {
+		return errors.Wrap(err, "unable to throttle")
+	}
+	defer r.Close() //nolint:errcheck
+
+	if data, err = ioutil.ReadAll(r); err != nil {
+		return errors.Wrapf(err, "unable to read blob %v", bm.BlobID)
+	}
+
+	if err := dst.PutBlob(ctx, bm.BlobID, data); err != nil {
+		return errors.Wrapf(err, "unable to write blob %v", bm.BlobID)
+	}
+
+	return nil
+}
+
+// syncMarkerBlob copies the marker blob if it's missing or different in the destination.
+func syncMarkerBlob(ctx context.Context, src, dst blob.Storage, id blob.ID) error {
+	srcData, err := src.GetBlob(ctx, id, 0, -1)
+	if err != nil {
+		return errors.Wrapf(err, "unable to read source blob %v", id)
+	}
+
+	dstData, err := dst.GetBlob(ctx, id, 0, -1)
+	switch {
+	case err == nil && bytes.Equal(srcData, dstData):
+		return nil
+	case err == nil || err == blob.ErrBlobNotFound:
+		log.Debugf("copying %v", id)
+		return dst.PutBlob(ctx, id, srcData)
+	default:
+		return errors.Wrapf(err, "unable to read destination blob %v", id)
+	}
+}
@@ -0,0 +1,353 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/blob/filesystem"
+	"github.com/kopia/kopia/repo/blob/obfuscate"
+	"github.com/kopia/kopia/repo/content"
+	"github.com/kopia/kopia/repo/object"
+)
+
+const syncTestPassword = "sync-test-password"
+
+// recordingStorage records IDs of blobs written to the underlying storage.
+type recordingStorage struct {
+	blob.Storage
+
+	mu  sync.Mutex
+	put []blob.ID
+}
+
+func (s *recordingStorage) PutBlob(ctx context.Context, id blob.ID, data []byte) error {
+	s.mu.Lock()
+	s.put = append(s.put, id)
+	s.mu.Unlock()
+
+	return s.Storage.PutBlob(ctx, id, data)
+}
+
+func (s *recordingStorage) reset() []blob.ID {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := s.put
+	s.put = nil
+
+	return result
+}
+
+type syncTestEnv struct {
+	tmpDir string
+	rep    *repo.Repository
+	oid    object.ID
+}
+
+func newSyncTestEnv(t *testing.T, opt *repo.NewRepositoryOptions) *syncTestEnv {
+	ctx := context.Background()
+
+	tmpDir, err := ioutil.TempDir("", "kopia-sync")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+
+	e := &syncTestEnv{tmpDir: tmpDir}
+
+	st := e.storage(t, "source")
+
+	if err := repo.Initialize(ctx, st, opt, syncTestPassword); err != nil {
+		t.Fatalf("unable to initialize repository: %v", err)
+	}
+
+	cfg := filepath.Join(tmpDir, "source.config")
+	if err := repo.Connect(ctx, cfg, st, syncTestPassword, repo.ConnectOptions{}); err != nil {
+		t.Fatalf("unable to connect: %v", err)
+	}
+
+	if e.rep, err = repo.Open(ctx, cfg, syncTestPassword, nil); err != nil {
+		t.Fatalf("unable to open repository: %v", err)
+	}
+
+	e.oid = e.writeObject(t, "hello world")
+
+	return e
+}
+
+func (e *syncTestEnv) close(t *testing.T) {
+	if err := e.rep.Close(context.Background()); err != nil {
+		t.Errorf("unable to close repository: %v", err)
+	}
+
+	os.RemoveAll(e.tmpDir) //nolint:errcheck
+}
+
+func (e *syncTestEnv) storage(t *testing.T, name string) blob.Storage {
+	dir := filepath.Join(e.tmpDir, name)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		t.Fatalf("unable to create storage directory: %v", err)
+	}
+
+	st, err := filesystem.New(context.Background(), &filesystem.Options{Path: dir})
+	if err != nil {
+		t.Fatalf("unable to create storage: %v", err)
+	}
+
+	return st
+}
+
+func (e *syncTestEnv) writeObject(t *testing.T, data string) object.ID {
+	ctx := context.Background()
+
+	w := e.rep.Objects.NewWriter(ctx, object.WriterOptions{})
+	if _, err := w.Write([]byte(data)); err != nil {
+		t.Fatalf("unable to write object: %v", err)
+	}
+
+	oid, err := w.Result()
+	if err != nil {
+		t.Fatalf("unable to write object: %v", err)
+	}
+
+	if err := e.rep.Flush(ctx); err != nil {
+		t.Fatalf("unable to flush: %v", err)
+	}
+
+	return oid
+}
+
+func (e *syncTestEnv) sync(t *testing.T, dst blob.Storage) {
+	ctx := context.Background()
+
+	raw, err := e.rep.OpenRawStorage(ctx)
+	if err != nil {
+		t.Fatalf("unable to open raw storage: %v", err)
+	}
+	defer raw.Close(ctx) //nolint:errcheck
+
+	if err := runSyncWithStorage(ctx, raw, dst); err != nil {
+		t.Fatalf("sync failed: %v", err)
+	}
+}
+
+// verifyReplica connects to the replica in a given storage and verifies that objects can be read from it.
+func (e *syncTestEnv) verifyReplica(t *testing.T, dst blob.Storage, oids map[object.ID]string) {
+	ctx := context.Background()
+
+	cfg := filepath.Join(e.tmpDir, "replica.config")
+	if err := repo.Connect(ctx, cfg, dst, syncTestPassword, repo.ConnectOptions{}); err != nil {
+		t.Fatalf("unable to connect to replica: %v", err)
+	}
+	defer repo.Disconnect(cfg) //nolint:errcheck
+
+	r, err := repo.Open(ctx, cfg, syncTestPassword, nil)
+	if err != nil {
+		t.Fatalf("unable to open replica: %v", err)
+	}
+	defer r.Close(ctx) //nolint:errcheck
+
+	if r.UniqueID == nil || !bytes.Equal(r.UniqueID, e.rep.UniqueID) {
+		t.Errorf("replica has different unique ID")
+	}
+
+	for oid, want := range oids {
+		rd, err := r.Objects.Open(ctx, oid)
+		if err != nil {
+			t.Fatalf("unable to open object %v in replica: %v", oid, err)
+		}
+
+		got, err := ioutil.ReadAll(rd)
+		rd.Close() //nolint:errcheck
+
+		if err != nil || string(got) != want {
+			t.Errorf("unexpected contents of %v in replica: %q %v, want %q", oid, got, err, want)
+		}
+	}
+}
+
+func withSyncFlags(deleteExtraneous bool) func() {
+	prevDelete, prevParallel := *syncToDelete, *syncToParallel
+	*syncToDelete, *syncToParallel = deleteExtraneous, 2
+
+	return func() {
+		*syncToDelete, *syncToParallel = prevDelete, prevParallel
+	}
+}
+
+func obfuscatedRepositoryOptions() *repo.NewRepositoryOptions {
+	return &repo.NewRepositoryOptions{
+		BlockFormat: content.FormattingOptions{
+			Hash:       "HMAC-SHA256",
+			Encryption: "NONE",
+		},
+		ObfuscateBlobNames: true,
+		PadBlobSizes:       true,
+	}
+}
+
+func TestSyncObfuscatedRepository(t *testing.T) {
+	defer withSyncFlags(false)()
+
+	ctx := context.Background()
+	e := newSyncTestEnv(t, obfuscatedRepositoryOptions())
+	defer e.close(t)
+
+	dst := &recordingStorage{Storage: e.storage(t, "replica")}
+
+	// initial copy
+	e.sync(t, dst)
+
+	put := dst.reset()
+	if len(put) == 0 {
+		t.Fatalf("nothing was copied")
+	}
+
+	// the replica becomes openable when the obfuscation parameters are written.
+	if got, want := put[len(put)-1], obfuscate.ParametersBlobID; got != want {
+		t.Errorf("unexpected last blob copied: %v, want %v", got, want)
+	}
+
+	for _, id := range put {
+		if id == repo.FormatBlobID {
+			t.Errorf("format blob was written under unobfuscated name")
+		}
+	}
+
+	raw, err := e.rep.OpenRawStorage(ctx)
+	if err != nil {
+		t.Fatalf("unable to open raw storage: %v", err)
+	}
+	defer raw.Close(ctx) //nolint:errcheck
+
+	srcBlobs, err := listBlobsByID(ctx, raw)
+	if err != nil {
+		t.Fatalf("unable to list source blobs: %v", err)
+	}
+
+	dstBlobs, err := listBlobsByID(ctx, dst)
+	if err != nil {
+		t.Fatalf("unable to list destination blobs: %v", err)
+	}
+
+	if len(srcBlobs) != len(dstBlobs) {
+		t.Errorf("unexpected number of blobs in replica: %v, want %v", len(dstBlobs), len(srcBlobs))
+	}
+
+	for id, bm := range srcBlobs {
+		if d, ok := dstBlobs[id]; !ok || d.Length != bm.Length {
+			t.Errorf("blob %v not copied as stored: %+v, want %+v", id, d, bm)
+		}
+	}
+
+	e.verifyReplica(t, dst, map[object.ID]string{e.oid: "hello world"})
+
+	// incremental re-run copies nothing.
+	e.sync(t, dst)
+
+	if put := dst.reset(); len(put) != 0 {
+		t.Errorf("unexpected blobs copied by incremental sync: %v", put)
+	}
+
+	// new data is copied incrementally.
+	oid2 := e.writeObject(t, "another object")
+
+	e.sync(t, dst)
+
+	if put := dst.reset(); len(put) == 0 {
+		t.Errorf("new blobs were not copied")
+	}
+
+	e.verifyReplica(t, dst, map[object.ID]string{e.oid: "hello world", oid2: "another object"})
+}
+
+func TestSyncFormatBlobCopiedLast(t *testing.T) {
+	defer withSyncFlags(false)()
+
+	e := newSyncTestEnv(t, &repo.NewRepositoryOptions{})
+	defer e.close(t)
+
+	dst := &recordingStorage{Storage: e.storage(t, "replica")}
+
+	e.sync(t, dst)
+
+	put := dst.reset()
+	if len(put) < 2 {
+		t.Fatalf("unexpected blobs copied: %v", put)
+	}
+
+	if got, want := put[len(put)-1], blob.ID(repo.FormatBlobID); got != want {
+		t.Errorf("unexpected last blob copied: %v, want %v", got, want)
+	}
+
+	for _, id := range put[0 : len(put)-1] {
+		if id == repo.FormatBlobID {
+			t.Errorf("format blob copied before other blobs: %v", put)
+		}
+	}
+
+	e.verifyReplica(t, dst, map[object.ID]string{e.oid: "hello world"})
+}
+
+func TestSyncDelete(t *testing.T) {
+	ctx := context.Background()
+	e := newSyncTestEnv(t, &repo.NewRepositoryOptions{})
+	defer e.close(t)
+
+	dst := e.storage(t, "replica")
+
+	if err := dst.PutBlob(ctx, "extraneous", []byte{1, 2, 3}); err != nil {
+		t.Fatalf("unable to write blob: %v", err)
+	}
+
+	restore := withSyncFlags(false)
+	e.sync(t, dst)
+	restore()
+
+	if _, err := dst.GetBlob(ctx, "extraneous", 0, -1); err != nil {
+		t.Errorf("extraneous blob was deleted without --delete: %v", err)
+	}
+
+	restore = withSyncFlags(true)
+	e.sync(t, dst)
+	restore()
+
+	if _, err := dst.GetBlob(ctx, "extraneous", 0, -1); err != blob.ErrBlobNotFound {
+		t.Errorf("extraneous blob was not deleted with --delete: %v", err)
+	}
+
+	e.verifyReplica(t, dst, map[object.ID]string{e.oid: "hello world"})
+}
+
+func TestSyncRejectsDifferentRepository(t *testing.T) {
+	defer withSyncFlags(false)()
+
+	ctx := context.Background()
+
+	for _, opt := range []*repo.NewRepositoryOptions{{}, obfuscatedRepositoryOptions()} {
+		e1 := newSyncTestEnv(t, opt)
+		e2 := newSyncTestEnv(t, opt)
+
+		dst := e1.storage(t, "replica")
+		e1.sync(t, dst)
+
+		raw, err := e2.rep.OpenRawStorage(ctx)
+		if err != nil {
+			t.Fatalf("unable to open raw storage: %v", err)
+		}
+
+		if err := runSyncWithStorage(ctx, raw, dst); err == nil {
+			t.Errorf("expected error when synchronizing to a replica of a different repository")
+		}
+
+		raw.Close(ctx) //nolint:errcheck
+		e1.close(t)
+		e2.close(t)
+	}
+}
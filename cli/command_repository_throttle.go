@@ -0,0 +1,109 @@
+package cli
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/blob/throttling"
+)
+
+var (
+	throttleCommands = repositoryCommands.Command("throttle", "Commands to manage storage throttling.")
+
+	throttleShowCommand = throttleCommands.Command("show", "Show storage throttling settings.").Default()
+
+	throttleSetCommand          = throttleCommands.Command("set", "Change storage throttling settings, which take effect the next time the repository is opened.")
+	throttleSetMaxUploadSpeed   = throttleSetCommand.Flag("max-upload-speed", "Limit the upload speed to storage (0 means unlimited)").PlaceHolder("BYTES_PER_SEC").Default("-1").Int64()
+	throttleSetMaxDownloadSpeed = throttleSetCommand.Flag("max-download-speed", "Limit the download speed from storage (0 means unlimited)").PlaceHolder("BYTES_PER_SEC").Default("-1").Int64()
+	throttleSetMaxConcurrentOps = throttleSetCommand.Flag("max-concurrent-storage-ops", "Limit the number of concurrent storage operations (0 means unlimited)").PlaceHolder("N").Default("-1").Int()
+	throttleSetAddSchedule      = throttleSetCommand.Flag("add-schedule", "Add limits during given times of day, e.g. 'mon-fri 09:00-17:00 upload=1000000 download=5000000 ops=2'").PlaceHolder("SCHEDULE").Strings()
+	throttleSetClearSchedule    = throttleSetCommand.Flag("clear-schedule", "Remove all scheduled limits").Bool()
+)
+
+func parseThrottleSchedule(specs []string) ([]throttling.ScheduledLimits, error) {
+	var result []throttling.ScheduledLimits
+
+	for _, s := range specs {
+		sl, err := throttling.ParseScheduledLimits(s)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid throttle schedule %q", s)
+		}
+
+		result = append(result, sl)
+	}
+
+	return result, nil
+}
+
+func runThrottleShowCommand(ctx context.Context, rep *repo.Repository) error {
+	opt := rep.Throttling
+	if opt == nil {
+		printStdout("Storage throttling is disabled.\n")
+		return nil
+	}
+
+	printStdout("Default limits: %v\n", opt.Limits)
+
+	for _, sl := range opt.Schedule {
+		printStdout("Scheduled:      %v\n", sl)
+	}
+
+	return nil
+}
+
+func runThrottleSetCommand(ctx context.Context, rep *repo.Repository) error {
+	var opt throttling.Options
+	if rep.Throttling != nil {
+		opt = *rep.Throttling
+	}
+
+	changed := 0
+
+	if v := *throttleSetMaxUploadSpeed; v != -1 {
+		log.Infof("changing max upload speed to %v", v)
+		opt.UploadBytesPerSecond = v
+		changed++
+	}
+
+	if v := *throttleSetMaxDownloadSpeed; v != -1 {
+		log.Infof("changing max download speed to %v", v)
+		opt.DownloadBytesPerSecond = v
+		changed++
+	}
+
+	if v := *throttleSetMaxConcurrentOps; v != -1 {
+		log.Infof("changing max concurrent storage operations to %v", v)
+		opt.MaxConcurrentOps = v
+		changed++
+	}
+
+	if *throttleSetClearSchedule {
+		log.Infof("removing scheduled limits")
+		opt.Schedule = nil
+		changed++
+	}
+
+	schedule, err := parseThrottleSchedule(*throttleSetAddSchedule)
+	if err != nil {
+		return err
+	}
+
+	for _, sl := range schedule {
+		log.Infof("adding scheduled limits %v", sl)
+		opt.Schedule = append(opt.Schedule, sl)
+		changed++
+	}
+
+	if changed == 0 {
+		return errors.Errorf("no changes")
+	}
+
+	return rep.SetThrottlingConfig(&opt)
+}
+
+func init() {
+	throttleShowCommand.Action(repositoryAction(runThrottleShowCommand))
+	throttleSetCommand.Action(repositoryAction(runThrottleSetCommand))
+}
@@ -0,0 +1,52 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	"github.com/kopia/kopia/internal/serverapi"
+)
+
+var (
+	serverSourceCommands = serverCommands.Command("source", "Manage snapshot sources of Kopia server")
+
+	serverSourceAddCommand = serverSourceCommands.Command("add", "Add a directory to be snapshotted by the server")
+	serverSourceAddPath    = serverSourceAddCommand.Arg("path", "Absolute path of the directory on the server").Required().String()
+	serverSourceAddNow     = serverSourceAddCommand.Flag("snapshot-now", "Create the first snapshot immediately").Bool()
+
+	serverSourceRemoveCommand      = serverSourceCommands.Command("remove", "Stop snapshotting a directory on the server").Alias("rm")
+	serverSourceRemovePath         = serverSourceRemoveCommand.Arg("path", "Absolute path of the directory on the server").Required().String()
+	serverSourceRemoveDeletePolicy = serverSourceRemoveCommand.Flag("delete-policy", "Also delete the policy defined for the directory").Bool()
+)
+
+func init() {
+	serverSourceAddCommand.Action(serverAction(runServerSourceAdd))
+	serverSourceRemoveCommand.Action(serverAction(runServerSourceRemove))
+}
+
+func runServerSourceAdd(ctx context.Context, cli *serverapi.Client) error {
+	var resp serverapi.SourceStatus
+
+	if err := cli.Post("sources", &serverapi.AddSourceRequest{
+		Path:           *serverSourceAddPath,
+		CreateSnapshot: *serverSourceAddNow,
+	}, &resp); err != nil {
+		return err
+	}
+
+	fmt.Printf("%15v %v\n", resp.Status, resp.Source)
+
+	return nil
+}
+
+func runServerSourceRemove(ctx context.Context, cli *serverapi.Client) error {
+	q := url.Values{}
+	q.Set("path", *serverSourceRemovePath)
+
+	if *serverSourceRemoveDeletePolicy {
+		q.Set("deletePolicy", "true")
+	}
+
+	return cli.Delete("sources?"+q.Encode(), &serverapi.Empty{})
+}
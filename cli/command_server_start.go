@@ -1,8 +1,12 @@
 package cli
 
 import (
+	"bufio"
 	"context"
+	"crypto/subtle"
 	"net/http"
+	"os"
+	"strings"
 	"time"
 
 	"github.com/pkg/errors"
@@ -14,8 +18,17 @@ import (
 var (
 	serverAddress = serverCommands.Flag("address", "Server address").Default("127.0.0.1:51515").String()
 
+	serverUsername = serverCommands.Flag("server-username", "HTTP server username (basic auth)").Envar("KOPIA_SERVER_USERNAME").Default("kopia").String()
+	serverPassword = serverCommands.Flag("server-password", "HTTP server password (basic auth or bearer token)").Envar("KOPIA_SERVER_PASSWORD").String()
+
+	serverCertFingerprint = serverCommands.Flag("server-cert-fingerprint", "Server certificate fingerprint (SHA256), enables TLS").Envar("KOPIA_SERVER_CERT_FINGERPRINT").String()
+
 	serverStartCommand  = serverCommands.Command("start", "Start Kopia server").Default()
 	serverStartHTMLPath = serverStartCommand.Flag("html", "Server the provided HTML at the root URL").ExistingDir()
+
+	serverStartPasswordFile = serverStartCommand.Flag("password-file", "Path to a file containing 'username:password' lines of allowed users").ExistingFile()
+	serverStartWatchChanges = serverStartCommand.Flag("watch-changes", "Watch local sources for changes between snapshots to avoid scanning unchanged directories (Linux only)").Bool()
+	serverStartParallelDirs = serverStartCommand.Flag("parallel-dirs", "Traverse N directories in parallel when snapshotting, 0 uses the number of CPUs").PlaceHolder("N").Default("0").Int()
 )
 
 func init() {
@@ -23,19 +36,114 @@ func init() {
 }
 
 func runServer(ctx context.Context, rep *repo.Repository) error {
-	srv, err := server.New(ctx, rep, getHostName(), getUserName())
+	srv, err := server.New(ctx, rep, getHostName(), getUserName(), server.Options{
+		WatchChanges:        *serverStartWatchChanges,
+		ParallelDirectories: *serverStartParallelDirs,
+	})
 	if err != nil {
 		return errors.Wrap(err, "unable to initialize server")
 	}
 
 	go rep.RefreshPeriodically(ctx, 10*time.Second)
 
-	url := "http://" + *serverAddress
-	log.Infof("starting server on %v", url)
-	http.Handle("/api/", srv.APIHandlers())
+	mux := http.NewServeMux()
+	mux.Handle("/api/", srv.APIHandlers())
 	if *serverStartHTMLPath != "" {
 		fileServer := http.FileServer(http.Dir(*serverStartHTMLPath))
-		http.Handle("/", fileServer)
+		mux.Handle("/", fileServer)
+	}
+
+	users, err := serverUsersFromFlags()
+	if err != nil {
+		return err
+	}
+
+	var handler http.Handler = mux
+	if len(users) > 0 {
+		handler = requireCredentials(handler, users)
+	} else {
+		log.Warningf("server is running without authentication, use --server-password or --password-file to enable it")
+	}
+
+	return startServerWithOptionalTLS(ctx, &http.Server{
+		Addr:    *serverAddress,
+		Handler: handler,
+	})
+}
+
+// serverUsersFromFlags returns the map of usernames to passwords allowed to access the server.
+func serverUsersFromFlags() (map[string]string, error) {
+	users := map[string]string{}
+
+	if *serverPassword != "" {
+		users[*serverUsername] = *serverPassword
+	}
+
+	if *serverStartPasswordFile != "" {
+		f, err := os.Open(*serverStartPasswordFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to open password file")
+		}
+		defer f.Close() //nolint:errcheck
+
+		s := bufio.NewScanner(f)
+		for s.Scan() {
+			line := strings.TrimSpace(s.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+
+			parts := strings.SplitN(line, ":", 2)
+			if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+				return nil, errors.Errorf("invalid password file entry, expected 'username:password'")
+			}
+
+			users[parts[0]] = parts[1]
+		}
+
+		if err := s.Err(); err != nil {
+			return nil, errors.Wrap(err, "unable to read password file")
+		}
+	}
+
+	return users, nil
+}
+
+// requireCredentials wraps the provided handler and rejects requests that don't carry valid
+// basic authentication credentials or a bearer token matching one of the passwords.
+func requireCredentials(handler http.Handler, users map[string]string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !isAuthorized(r, users) {
+			w.Header().Set("WWW-Authenticate", `Basic realm="Kopia"`)
+			http.Error(w, "access denied", http.StatusUnauthorized)
+			return
+		}
+
+		handler.ServeHTTP(w, r)
+	})
+}
+
+func isAuthorized(r *http.Request, users map[string]string) bool {
+	if user, pass, ok := r.BasicAuth(); ok {
+		expected, found := users[user]
+		return found && subtle.ConstantTimeCompare([]byte(expected), []byte(pass)) == 1
+	}
+
+	const bearerPrefix = "Bearer "
+
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, bearerPrefix) {
+		token := []byte(strings.TrimPrefix(auth, bearerPrefix))
+
+		authorized := false
+		for _, pass := range users {
+			// check all entries to avoid leaking information through timing.
+			if subtle.ConstantTimeCompare([]byte(pass), token) == 1 {
+				authorized = true
+			}
+		}
+
+		return authorized
 	}
-	return http.ListenAndServe(*serverAddress, nil)
+
+	return false
 }
@@ -0,0 +1,170 @@
+package cli
+
+import (
+	"context"
+	"crypto/tls"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kopia/kopia/internal/serverapi"
+)
+
+var testServerUsers = map[string]string{
+	"user1": "pass1",
+	"user2": "pass2",
+}
+
+func newAuthTestServer() *httptest.Server {
+	return httptest.NewServer(requireCredentials(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{}`)) //nolint:errcheck
+	}), testServerUsers))
+}
+
+func TestRequireCredentials(t *testing.T) {
+	ts := newAuthTestServer()
+	defer ts.Close()
+
+	cases := []struct {
+		desc       string
+		setAuth    func(r *http.Request)
+		wantStatus int
+	}{
+		{"no credentials", func(r *http.Request) {}, http.StatusUnauthorized},
+		{"valid basic auth", func(r *http.Request) { r.SetBasicAuth("user1", "pass1") }, http.StatusOK},
+		{"valid basic auth of another user", func(r *http.Request) { r.SetBasicAuth("user2", "pass2") }, http.StatusOK},
+		{"wrong password", func(r *http.Request) { r.SetBasicAuth("user1", "pass2") }, http.StatusUnauthorized},
+		{"unknown user", func(r *http.Request) { r.SetBasicAuth("user3", "pass1") }, http.StatusUnauthorized},
+		{"empty password", func(r *http.Request) { r.SetBasicAuth("user1", "") }, http.StatusUnauthorized},
+		{"valid bearer token", func(r *http.Request) { r.Header.Set("Authorization", "Bearer pass2") }, http.StatusOK},
+		{"wrong bearer token", func(r *http.Request) { r.Header.Set("Authorization", "Bearer wrong") }, http.StatusUnauthorized},
+		{"empty bearer token", func(r *http.Request) { r.Header.Set("Authorization", "Bearer ") }, http.StatusUnauthorized},
+		{"unsupported scheme", func(r *http.Request) { r.Header.Set("Authorization", "Digest pass1") }, http.StatusUnauthorized},
+	}
+
+	for _, tc := range cases {
+		req, err := http.NewRequest(http.MethodGet, ts.URL+"/api/v1/status", nil)
+		if err != nil {
+			t.Fatalf("unable to create request: %v", err)
+		}
+
+		tc.setAuth(req)
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%v: request failed: %v", tc.desc, err)
+		}
+		resp.Body.Close() //nolint:errcheck
+
+		if resp.StatusCode != tc.wantStatus {
+			t.Errorf("%v: unexpected status %v, want %v", tc.desc, resp.StatusCode, tc.wantStatus)
+		}
+
+		if resp.StatusCode == http.StatusUnauthorized && resp.Header.Get("WWW-Authenticate") == "" {
+			t.Errorf("%v: missing WWW-Authenticate header", tc.desc)
+		}
+	}
+}
+
+func TestServerUsersFromFlags(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "kopia-server-users")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	defer func(u, p, f string) {
+		*serverUsername, *serverPassword, *serverStartPasswordFile = u, p, f
+	}(*serverUsername, *serverPassword, *serverStartPasswordFile)
+
+	fname := filepath.Join(tmpDir, "passwords")
+	if err := ioutil.WriteFile(fname, []byte("# comment\n\nuser1:pass:with:colons\n  user2:pass2  \n"), 0600); err != nil {
+		t.Fatalf("unable to write password file: %v", err)
+	}
+
+	*serverUsername, *serverPassword, *serverStartPasswordFile = "kopia", "secret", fname
+
+	users, err := serverUsersFromFlags()
+	if err != nil {
+		t.Fatalf("unable to get users: %v", err)
+	}
+
+	want := map[string]string{"kopia": "secret", "user1": "pass:with:colons", "user2": "pass2"}
+	if len(users) != len(want) {
+		t.Errorf("unexpected users: %v, want %v", users, want)
+	}
+
+	for u, p := range want {
+		if users[u] != p {
+			t.Errorf("unexpected password of %v: %q, want %q", u, users[u], p)
+		}
+	}
+
+	for _, invalid := range []string{"no-colon\n", ":pass\n", "user:\n"} {
+		if err := ioutil.WriteFile(fname, []byte(invalid), 0600); err != nil {
+			t.Fatalf("unable to write password file: %v", err)
+		}
+
+		if _, err := serverUsersFromFlags(); err == nil {
+			t.Errorf("expected error for password file entry %q", strings.TrimSpace(invalid))
+		}
+	}
+}
+
+func TestServerCertificateFingerprint(t *testing.T) {
+	ctx := context.Background()
+
+	cert, priv, err := generateServerCertificate(ctx, 1024, time.Hour)
+	if err != nil {
+		t.Fatalf("unable to generate certificate: %v", err)
+	}
+
+	ts := httptest.NewUnstartedServer(requireCredentials(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{}`)) //nolint:errcheck
+	}), testServerUsers))
+	ts.TLS = &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{cert.Raw}, PrivateKey: priv, Leaf: cert}},
+	}
+	ts.StartTLS()
+	defer ts.Close()
+
+	otherCert, _, err := generateServerCertificate(ctx, 1024, time.Hour)
+	if err != nil {
+		t.Fatalf("unable to generate certificate: %v", err)
+	}
+
+	cases := []struct {
+		desc        string
+		fingerprint string
+		password    string
+		wantErr     bool
+	}{
+		{"matching fingerprint", serverapi.CertificateFingerprint(cert), "pass1", false},
+		{"fingerprint of another certificate", serverapi.CertificateFingerprint(otherCert), "pass1", true},
+		{"matching fingerprint, wrong password", serverapi.CertificateFingerprint(cert), "wrong", true},
+	}
+
+	for _, tc := range cases {
+		cli, err := serverapi.NewClient(serverapi.ClientOptions{
+			BaseURL:                             strings.TrimPrefix(ts.URL, "https://"),
+			Username:                            "user1",
+			Password:                            tc.password,
+			TrustedServerCertificateFingerprint: tc.fingerprint,
+		})
+		if err != nil {
+			t.Fatalf("%v: unable to create client: %v", tc.desc, err)
+		}
+
+		var resp serverapi.StatusResponse
+
+		err = cli.Get("status", &resp)
+		if gotErr := err != nil; gotErr != tc.wantErr {
+			t.Errorf("%v: unexpected error %v, want error: %v", tc.desc, err, tc.wantErr)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package cli
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/serverapi"
+)
+
+var (
+	serverStartTLSCertFile     = serverStartCommand.Flag("tls-cert-file", "TLS certificate PEM").String()
+	serverStartTLSKeyFile      = serverStartCommand.Flag("tls-key-file", "TLS key PEM file").String()
+	serverStartTLSGenerateCert = serverStartCommand.Flag("tls-generate-cert", "Generate TLS certificate (saved to --tls-cert-file/--tls-key-file, if provided)").Bool()
+
+	serverStartTLSCertValidity       = serverStartCommand.Flag("tls-generate-cert-validity", "Validity of the generated TLS certificate").Default("8760h").Duration()
+	serverStartTLSGenerateRSAKeySize = serverStartCommand.Flag("tls-generate-rsa-key-size", "TLS RSA Key size (bits)").Default("4096").Int()
+)
+
+func generateServerCertificate(ctx context.Context, keySize int, certValid time.Duration) (*x509.Certificate, *rsa.PrivateKey, error) {
+	priv, err := rsa.GenerateKey(rand.Reader, keySize)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "unable to generate RSA key")
+	}
+
+	notBefore := time.Now()
+	notAfter := notBefore.Add(certValid)
+
+	serialNumber, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "unable to generate serial number")
+	}
+
+	template := x509.Certificate{
+		SerialNumber: serialNumber,
+		Subject: pkix.Name{
+			Organization: []string{"Kopia"},
+			CommonName:   getHostName(),
+		},
+		DNSNames:              []string{getHostName(), "localhost"},
+		NotBefore:             notBefore,
+		NotAfter:              notAfter,
+		KeyUsage:              x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+	}
+
+	derBytes, err := x509.CreateCertificate(rand.Reader, &template, &template, &priv.PublicKey, priv)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "unable to create certificate")
+	}
+
+	cert, err := x509.ParseCertificate(derBytes)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "unable to parse generated certificate")
+	}
+
+	return cert, priv, nil
+}
+
+func writeServerCertificateFiles(cert *x509.Certificate, priv *rsa.PrivateKey) error {
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+	if err := ioutil.WriteFile(*serverStartTLSCertFile, certPEM, 0600); err != nil {
+		return errors.Wrap(err, "unable to write certificate file")
+	}
+
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(priv)})
+	if err := ioutil.WriteFile(*serverStartTLSKeyFile, keyPEM, 0600); err != nil {
+		return errors.Wrap(err, "unable to write private key file")
+	}
+
+	return nil
+}
+
+func fileExists(fname string) bool {
+	_, err := os.Stat(fname)
+	return err == nil
+}
+
+// serverTLSCertificateFromFlags returns the TLS certificate to use or nil if the server should not use TLS.
+func serverTLSCertificateFromFlags(ctx context.Context) (*tls.Certificate, error) {
+	certFile, keyFile := *serverStartTLSCertFile, *serverStartTLSKeyFile
+
+	if (certFile == "") != (keyFile == "") {
+		return nil, errors.New("--tls-cert-file and --tls-key-file must be specified together")
+	}
+
+	if *serverStartTLSGenerateCert {
+		if certFile != "" && (fileExists(certFile) || fileExists(keyFile)) {
+			return nil, errors.New("TLS certificate or key file already exists, remove them or don't use --tls-generate-cert")
+		}
+
+		cert, priv, err := generateServerCertificate(ctx, *serverStartTLSGenerateRSAKeySize, *serverStartTLSCertValidity)
+		if err != nil {
+			return nil, err
+		}
+
+		if certFile != "" {
+			if err := writeServerCertificateFiles(cert, priv); err != nil {
+				return nil, err
+			}
+
+			log.Infof("generated TLS certificate saved to %v and %v", certFile, keyFile)
+		}
+
+		return &tls.Certificate{
+			Certificate: [][]byte{cert.Raw},
+			PrivateKey:  priv,
+			Leaf:        cert,
+		}, nil
+	}
+
+	if certFile == "" {
+		return nil, nil
+	}
+
+	c, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to load TLS certificate")
+	}
+
+	if c.Leaf == nil {
+		c.Leaf, err = x509.ParseCertificate(c.Certificate[0])
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to parse TLS certificate")
+		}
+	}
+
+	return &c, nil
+}
+
+func startServerWithOptionalTLS(ctx context.Context, httpServer *http.Server) error {
+	cert, err := serverTLSCertificateFromFlags(ctx)
+	if err != nil {
+		return err
+	}
+
+	if cert == nil {
+		log.Infof("starting server on http://%v", httpServer.Addr)
+		return httpServer.ListenAndServe()
+	}
+
+	httpServer.TLSConfig = &tls.Config{
+		Certificates: []tls.Certificate{*cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	fingerprint := serverapi.CertificateFingerprint(cert.Leaf)
+	printStderr("SERVER CERTIFICATE SHA256: %v\n", fingerprint)
+	printStderr("Use --server-cert-fingerprint=%v to connect.\n", fingerprint)
+
+	log.Infof("starting server on https://%v", httpServer.Addr)
+	return httpServer.ListenAndServeTLS("", "")
+}
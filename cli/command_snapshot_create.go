@@ -11,7 +11,11 @@ import (
 
 	"github.com/pkg/errors"
 
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/internal/fssnapshot"
+	"github.com/kopia/kopia/internal/hashcache"
 	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/manifest"
 	"github.com/kopia/kopia/snapshot"
 	"github.com/kopia/kopia/snapshot/policy"
 	"github.com/kopia/kopia/snapshot/snapshotfs"
@@ -30,8 +34,29 @@ var (
 	snapshotCreateDescription             = snapshotCreateCommand.Flag("description", "Free-form snapshot description.").String()
 	snapshotCreateForceHash               = snapshotCreateCommand.Flag("force-hash", "Force hashing of source files for a given percentage of files [0..100]").Default("0").Int()
 	snapshotCreateParallelUploads         = snapshotCreateCommand.Flag("parallel", "Upload N files in parallel").PlaceHolder("N").Default("0").Int()
+	snapshotCreateParallelDirs            = snapshotCreateCommand.Flag("parallel-dirs", "Traverse N directories in parallel, 0 uses the number of CPUs").PlaceHolder("N").Default("0").Int()
+	snapshotCreateCheckpointInterval      = snapshotCreateCommand.Flag("checkpoint-interval", "Interval between checkpoints which allow interrupted uploads to resume (0 disables)").Default(snapshotfs.DefaultCheckpointInterval.String()).Duration()
+	snapshotCreateFSSnapshot              = snapshotCreateCommand.Flag("fs-snapshot", "Upload from a read-only filesystem snapshot of the source, created and deleted automatically (Linux only)").Enum(fssnapshot.DriverBtrfs, fssnapshot.DriverLVM)
+	snapshotCreateFSSnapshotSize          = snapshotCreateCommand.Flag("fs-snapshot-size", "Size of copy-on-write area of LVM snapshots").Default(fssnapshot.DefaultLVMSnapshotSize).String()
+	snapshotCreateFSSnapshotDir           = snapshotCreateCommand.Flag("fs-snapshot-dir", "Directory on the same filesystem and outside of the source where btrfs snapshots are created").PlaceHolder("PATH").String()
+	snapshotCreateProgressFormat          = snapshotCreateCommand.Flag("progress-format", "Format of progress reporting: human-readable log messages or JSON lines with upload events").Default("text").Enum("text", "jsonl")
+	snapshotCreateProgressFD              = snapshotCreateCommand.Flag("progress-fd", "File descriptor to write JSON progress events to").Default("1").Int()
+	snapshotCreateProgressInterval        = snapshotCreateCommand.Flag("progress-interval", "Minimum interval between JSON progress events").Default(snapshotfs.DefaultUploadEventInterval.String()).Duration()
 )
 
+// uploadEventsWriter returns the writer of JSON upload events or nil if they're not enabled.
+func uploadEventsWriter() *jsonLinesWriter {
+	if *snapshotCreateProgressFormat != "jsonl" {
+		return nil
+	}
+
+	if *snapshotCreateProgressFD == 1 {
+		return jsonLines
+	}
+
+	return &jsonLinesWriter{out: os.NewFile(uintptr(*snapshotCreateProgressFD), "progress")}
+}
+
 func runBackupCommand(ctx context.Context, rep *repo.Repository) error {
 	sources := *snapshotCreateSources
 	if *snapshotCreateAll {
@@ -50,6 +75,8 @@ func runBackupCommand(ctx context.Context, rep *repo.Repository) error {
 	u.MaxUploadBytes = *snapshotCreateCheckpointUploadLimitMB * 1024 * 1024
 	u.ForceHashPercentage = *snapshotCreateForceHash
 	u.ParallelUploads = *snapshotCreateParallelUploads
+	u.ParallelDirectories = *snapshotCreateParallelDirs
+	u.CheckpointInterval = *snapshotCreateCheckpointInterval
 	onCtrlC(u.Cancel)
 
 	u.Progress = cliProgress
@@ -58,6 +85,8 @@ func runBackupCommand(ctx context.Context, rep *repo.Repository) error {
 		return errors.New("description too long")
 	}
 
+	events := uploadEventsWriter()
+
 	var finalErrors []string
 
 	for _, snapshotDir := range sources {
@@ -69,7 +98,7 @@ func runBackupCommand(ctx context.Context, rep *repo.Repository) error {
 
 		sourceInfo := snapshot.SourceInfo{Path: filepath.Clean(dir), Host: getHostName(), UserName: getUserName()}
 		log.Infof("snapshotting %v", sourceInfo)
-		if err := snapshotSingleSource(ctx, rep, u, sourceInfo); err != nil {
+		if err := snapshotSingleSource(ctx, rep, u, sourceInfo, events); err != nil {
 			finalErrors = append(finalErrors, err.Error())
 		}
 	}
@@ -81,42 +110,165 @@ func runBackupCommand(ctx context.Context, rep *repo.Repository) error {
 	return errors.Errorf("encountered %v errors:\n%v", len(finalErrors), strings.Join(finalErrors, "\n"))
 }
 
-func snapshotSingleSource(ctx context.Context, rep *repo.Repository, u *snapshotfs.Uploader, sourceInfo snapshot.SourceInfo) error {
+func snapshotSingleSource(ctx context.Context, rep *repo.Repository, u *snapshotfs.Uploader, sourceInfo snapshot.SourceInfo, events *jsonLinesWriter) error {
 	t0 := time.Now()
 	rep.Content.ResetStats()
 
-	localEntry, err := getLocalFSEntry(sourceInfo.Path)
+	var tracker *snapshotfs.UploadEventTracker
+
+	if events != nil {
+		tracker = snapshotfs.NewUploadEventTracker(sourceInfo, rep.Content.Stats, func(ev *snapshotfs.UploadEvent) {
+			if err := events.write(ev); err != nil {
+				log.Warningf("unable to write progress event: %v", err)
+			}
+		})
+		tracker.Interval = *snapshotCreateProgressInterval
+		u.Progress = tracker
+	}
+
+	snapID, man, err := uploadSingleSource(ctx, rep, u, sourceInfo, tracker)
 	if err != nil {
-		return errors.Wrap(err, "unable to get local filesystem entry")
+		if tracker != nil {
+			tracker.Failed(err)
+		}
+
+		return err
+	}
+
+	if tracker != nil {
+		tracker.Finished(snapID, man)
+	}
+
+	printStderr("uploaded snapshot %v (root %v) in %v\n", snapID, man.RootObjectID(), time.Since(t0))
+
+	if n := man.Stats.ReadErrors; n > 0 {
+		printStderr("%v files could not be read, use 'kopia snapshot errors %v' to list them\n", n, snapID)
 	}
 
+	_, err = policy.ApplyRetentionPolicy(ctx, rep, sourceInfo, true)
+	return err
+}
+
+func uploadSingleSource(ctx context.Context, rep *repo.Repository, u *snapshotfs.Uploader, sourceInfo snapshot.SourceInfo, tracker *snapshotfs.UploadEventTracker) (manifest.ID, *snapshot.Manifest, error) {
+	localEntry, release, err := getUploadSourceEntry(ctx, sourceInfo.Path)
+	if err != nil {
+		return "", nil, err
+	}
+	defer release()
+
 	previous, err := findPreviousSnapshotManifest(ctx, rep, sourceInfo, nil)
 	if err != nil {
-		return err
+		return "", nil, err
 	}
 
 	u.FilesPolicy, err = policy.FilesPolicyGetter(ctx, rep, sourceInfo)
 	if err != nil {
-		return err
+		return "", nil, err
+	}
+
+	pol, _, err := policy.GetEffectivePolicy(ctx, rep, sourceInfo)
+	if err != nil {
+		return "", nil, errors.Wrap(err, "unable to get effective policy")
+	}
+
+	u.MaxFileErrors = pol.ErrorHandlingPolicy.FileErrorLimit()
+
+	u.ChangeDetection, err = policy.ChangeDetectionMap(ctx, rep, sourceInfo)
+	if err != nil {
+		return "", nil, err
+	}
+
+	u.PolicyFingerprint, err = policy.UploadPolicyFingerprint(ctx, rep, sourceInfo)
+	if err != nil {
+		return "", nil, err
+	}
+
+	u.HashCache = nil
+	if cacheDir := rep.Content.CachingOptions.CacheDirectory; cacheDir != "" && u.ChangeDetection.UsesHashCache() {
+		hc, hcErr := hashcache.ForSource(cacheDir, sourceInfo)
+		if hcErr != nil {
+			log.Warningf("unable to open hash cache: %v", hcErr)
+		}
+
+		u.HashCache = hc
+	}
+
+	if tracker != nil {
+		if len(previous) > 0 {
+			tracker.EstimatedBytes = previous[0].Stats.TotalFileSize
+		}
+
+		tracker.Started()
 	}
 
 	log.Infof("uploading %v using %v previous manifests", sourceInfo, len(previous))
-	manifest, err := u.Upload(ctx, localEntry, sourceInfo, previous...)
+	man, err := u.Upload(ctx, localEntry, sourceInfo, previous...)
 	if err != nil {
-		return err
+		return "", nil, err
 	}
 
-	manifest.Description = *snapshotCreateDescription
+	man.Description = *snapshotCreateDescription
 
-	snapID, err := snapshot.SaveSnapshot(ctx, rep, manifest)
+	snapID, err := snapshot.SaveSnapshot(ctx, rep, man)
 	if err != nil {
-		return errors.Wrap(err, "cannot save manifest")
+		return "", nil, errors.Wrap(err, "cannot save manifest")
 	}
 
-	printStderr("uploaded snapshot %v (root %v) in %v\n", snapID, manifest.RootObjectID(), time.Since(t0))
+	if man.IncompleteReason == "" {
+		if err := snapshot.DeleteCheckpoints(ctx, rep, man); err != nil {
+			return "", nil, errors.Wrap(err, "unable to delete checkpoints")
+		}
+	}
 
-	_, err = policy.ApplyRetentionPolicy(ctx, rep, sourceInfo, true)
-	return err
+	return snapID, man, nil
+}
+
+// getUploadSourceEntry returns the filesystem entry to upload for a given source path, which is located
+// in a filesystem snapshot when requested, and the function which releases the snapshot.
+func getUploadSourceEntry(ctx context.Context, path string) (fs.Entry, func(), error) {
+	if *snapshotCreateFSSnapshot == "" {
+		e, err := getLocalFSEntry(path)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "unable to get local filesystem entry")
+		}
+
+		return e, func() {}, nil
+	}
+
+	driver, err := fssnapshot.NewDriver(*snapshotCreateFSSnapshot, fssnapshot.Options{
+		LVMSnapshotSize:  *snapshotCreateFSSnapshotSize,
+		BtrfsSnapshotDir: *snapshotCreateFSSnapshotDir,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "evaluate symlink")
+	}
+
+	snap, err := driver.Create(ctx, resolved)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "unable to create filesystem snapshot")
+	}
+
+	release := func() {
+		// the snapshot must be removed even if the upload was canceled.
+		if err := snap.Release(context.Background()); err != nil {
+			log.Warningf("unable to release filesystem snapshot: %v", err)
+		}
+	}
+
+	log.Infof("uploading %v from filesystem snapshot at %v", path, snap.Path())
+
+	e, err := getLocalFSEntry(snap.Path())
+	if err != nil {
+		release()
+		return nil, nil, errors.Wrap(err, "unable to get filesystem snapshot entry")
+	}
+
+	return e, release, nil
 }
 
 // findPreviousSnapshotManifest returns the list of previous snapshots for a given source, including
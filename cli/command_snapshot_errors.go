@@ -0,0 +1,75 @@
+package cli
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/manifest"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/snapshotfs"
+)
+
+var (
+	snapshotErrorsCommand = snapshotCommands.Command("errors", "List files that could not be read when creating snapshots.")
+	snapshotErrorsIDs     = snapshotErrorsCommand.Arg("snapshot-id", "Snapshot manifest IDs").Required().Strings()
+)
+
+func runSnapshotErrorsCommand(ctx context.Context, rep *repo.Repository) error {
+	for _, id := range *snapshotErrorsIDs {
+		if err := showSnapshotErrors(ctx, rep, manifest.ID(id)); err != nil {
+			return errors.Wrapf(err, "unable to list errors of snapshot %v", id)
+		}
+	}
+
+	return nil
+}
+
+func showSnapshotErrors(ctx context.Context, rep *repo.Repository, id manifest.ID) error {
+	man, err := snapshot.LoadSnapshot(ctx, rep, id)
+	if err != nil {
+		return errors.Wrap(err, "unable to load snapshot")
+	}
+
+	root, err := snapshotfs.SnapshotRoot(rep, man)
+	if err != nil {
+		return err
+	}
+
+	dir, ok := root.(fs.Directory)
+	if !ok || dir.Summary() == nil {
+		printStderr("Snapshot %v of %v has no recorded errors.\n", id, man.Source)
+		return nil
+	}
+
+	failed, err := snapshotfs.FailedEntries(ctx, dir)
+	if err != nil {
+		return err
+	}
+
+	printStderr("Snapshot %v of %v: %v files could not be read.\n", id, man.Source, dir.Summary().NumFailed)
+
+	for _, e := range failed {
+		if *jsonOutput {
+			if err := jsonLines.write(e); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		printStdout("%v: %v\n", e.EntryPath, e.Error)
+	}
+
+	if n := dir.Summary().NumFailed - len(failed); n > 0 {
+		printStderr("%v more errors were not recorded.\n", n)
+	}
+
+	return nil
+}
+
+func init() {
+	snapshotErrorsCommand.Action(repositoryAction(runSnapshotErrorsCommand))
+}
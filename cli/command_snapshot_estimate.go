@@ -3,6 +3,7 @@ package cli
 import (
 	"context"
 	"fmt"
+	"math/rand"
 	"path/filepath"
 	"time"
 
@@ -16,6 +17,7 @@ import (
 	"github.com/kopia/kopia/fs"
 	"github.com/kopia/kopia/internal/units"
 	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/snapshotfs"
 )
 
 var (
@@ -24,6 +26,7 @@ var (
 	snapshotEstimateShowFiles   = snapshotEstimate.Flag("show-files", "Show files").Bool()
 	snapshotEstimateQuiet       = snapshotEstimate.Flag("quiet", "Do not display scanning progress").Short('q').Bool()
 	snapshotEstimateUploadSpeed = snapshotEstimate.Flag("upload-speed", "Upload speed to use for estimation").Default("10").PlaceHolder("mbit/s").Float64()
+	snapshotEstimateDedupSample = snapshotEstimate.Flag("dedup-sample-percent", "Percentage of files to read to predict deduplication against the repository (0 disables)").Default("0").Float64()
 )
 
 type bucket struct {
@@ -97,7 +100,13 @@ func runSnapshotEstimateCommand(ctx context.Context, rep *repo.Repository) error
 		}
 		entry = ignorefs.New(dir, ignorePolicy, ignorefs.ReportIgnoredFiles(onIgnoredFile))
 	}
-	if err := estimate(ctx, ".", entry, &stats, ib, eb); err != nil {
+
+	var de *snapshotfs.DedupEstimator
+	if *snapshotEstimateDedupSample > 0 {
+		de = snapshotfs.NewDedupEstimator(rep)
+	}
+
+	if err := estimate(ctx, ".", entry, &stats, ib, eb, de); err != nil {
 		return err
 	}
 
@@ -108,7 +117,18 @@ func runSnapshotEstimateCommand(ctx context.Context, rep *repo.Repository) error
 	fmt.Printf("Snapshot excludes %v directories and %v files with total size %v\n", stats.ExcludedDirCount, stats.ExcludedFileCount, units.BytesStringBase10(stats.ExcludedTotalFileSize))
 	showBuckets(eb)
 
-	megabits := float64(stats.TotalFileSize) * 8 / 1000000
+	uploadBytes := stats.TotalFileSize
+
+	if de != nil {
+		uploadBytes = int64(float64(stats.TotalFileSize) * de.NewBytesRatio())
+
+		fmt.Println()
+		fmt.Printf("Sampled %v files (%v) in %v chunks: %v already in repository, %v duplicated within sample, %v new\n",
+			de.Files, units.BytesStringBase10(de.Bytes), de.Chunks, de.ExistingChunks, de.DuplicateChunks, units.BytesStringBase10(de.NewBytes))
+		fmt.Printf("Predicted upload size after deduplication: %v (%.1f%% of total)\n", units.BytesStringBase10(uploadBytes), 100*de.NewBytesRatio())
+	}
+
+	megabits := float64(uploadBytes) * 8 / 1000000
 	seconds := megabits / *snapshotEstimateUploadSpeed
 
 	fmt.Println()
@@ -130,7 +150,7 @@ func showBuckets(b buckets) {
 		}
 	}
 }
-func estimate(ctx context.Context, relativePath string, entry fs.Entry, stats *snapshot.Stats, ib, eb buckets) error {
+func estimate(ctx context.Context, relativePath string, entry fs.Entry, stats *snapshot.Stats, ib, eb buckets, de *snapshotfs.DedupEstimator) error {
 	switch entry := entry.(type) {
 	case fs.Directory:
 		if !*snapshotEstimateQuiet {
@@ -142,7 +162,7 @@ func estimate(ctx context.Context, relativePath string, entry fs.Entry, stats *s
 		}
 
 		for _, child := range children {
-			if err := estimate(ctx, filepath.Join(relativePath, child.Name()), child, stats, ib, eb); err != nil {
+			if err := estimate(ctx, filepath.Join(relativePath, child.Name()), child, stats, ib, eb, de); err != nil {
 				return err
 			}
 		}
@@ -151,6 +171,12 @@ func estimate(ctx context.Context, relativePath string, entry fs.Entry, stats *s
 		ib.add(relativePath, entry.Size())
 		stats.TotalFileCount++
 		stats.TotalFileSize += entry.Size()
+
+		if de != nil && rand.Float64()*100 < *snapshotEstimateDedupSample { //nolint:gosec
+			if err := de.AddFile(ctx, entry); err != nil {
+				log.Warningf("unable to sample %v: %v", relativePath, err)
+			}
+		}
 	}
 	return nil
 }
@@ -10,6 +10,7 @@ import (
 	"github.com/pkg/errors"
 
 	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/internal/serverapi"
 	"github.com/kopia/kopia/internal/units"
 	"github.com/kopia/kopia/repo"
 	"github.com/kopia/kopia/repo/manifest"
@@ -32,6 +33,7 @@ var (
 	snapshotListShowIdentical        = snapshotListCommand.Flag("show-identical", "Show identical snapshots").Short('l').Bool()
 	snapshotListShowAll              = snapshotListCommand.Flag("all", "Show all shapshots (not just current username/host)").Short('a').Bool()
 	maxResultsPerPath                = snapshotListCommand.Flag("max-results", "Maximum number of entries per source.").Default("100").Short('n').Int()
+	snapshotListDeleted              = snapshotListCommand.Flag("deleted", "List recently deleted snapshots that can be undeleted.").Bool()
 )
 
 func findSnapshotsForSource(ctx context.Context, rep *repo.Repository, sourceInfo snapshot.SourceInfo) (manifestIDs []manifest.ID, relPath string, err error) {
@@ -83,6 +85,10 @@ func findManifestIDs(ctx context.Context, rep *repo.Repository, source string) (
 }
 
 func runSnapshotsCommand(ctx context.Context, rep *repo.Repository) error {
+	if *snapshotListDeleted {
+		return listDeletedSnapshots(ctx, rep)
+	}
+
 	manifestIDs, relPath, err := findManifestIDs(ctx, rep, *snapshotListPath)
 	if err != nil {
 		return err
@@ -96,6 +102,83 @@ func runSnapshotsCommand(ctx context.Context, rep *repo.Repository) error {
 	return outputManifestGroups(ctx, rep, manifests, strings.Split(relPath, "/"))
 }
 
+func listDeletedSnapshots(ctx context.Context, rep *repo.Repository) error {
+	var src *snapshot.SourceInfo
+
+	if *snapshotListPath != "" {
+		si, err := snapshot.ParseSourceInfo(*snapshotListPath, getHostName(), getUserName())
+		if err != nil {
+			return errors.Errorf("invalid directory: '%s': %s", *snapshotListPath, err)
+		}
+
+		src = &si
+	}
+
+	entries, err := snapshot.ListDeletedSnapshotManifests(ctx, rep, src)
+	if err != nil {
+		return err
+	}
+
+	deletionTimes := map[manifest.ID]time.Time{}
+
+	var manifests []*snapshot.Manifest
+
+	for _, e := range entries {
+		m, err := snapshot.LoadDeletedSnapshot(ctx, rep, e.ID)
+		if err != nil {
+			log.Warningf("unable to load deleted snapshot %v: %v", e.ID, err)
+			continue
+		}
+
+		deletionTimes[e.ID] = e.ModTime
+		manifests = append(manifests, m)
+	}
+
+	separator := ""
+
+	var anyOutput bool
+
+	for _, snapshotGroup := range snapshot.GroupBySource(manifests) {
+		src := snapshotGroup[0].Source
+		if !shouldOutputSnapshotSource(src) {
+			continue
+		}
+
+		anyOutput = true
+
+		if *jsonOutput {
+			if err := outputManifestsJSON(snapshotGroup); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		fmt.Printf("%v%v\n", separator, src)
+		separator = "\n"
+
+		for _, m := range snapshot.SortByTime(snapshotGroup, false) {
+			fmt.Printf(
+				"  %v %v %v deleted:%v manifest:%v\n",
+				formatTimestamp(m.StartTime),
+				m.RootObjectID(),
+				maybeHumanReadableBytes(*snapshotListShowHumanReadable, m.Stats.TotalFileSize),
+				formatTimestamp(deletionTimes[m.ID]),
+				m.ID,
+			)
+		}
+	}
+
+	if !anyOutput {
+		printStderr("No deleted snapshots found.\n")
+		return nil
+	}
+
+	printStderr("\nTo restore a deleted snapshot, run 'kopia manifest undelete <manifest>'.\n")
+
+	return nil
+}
+
 func shouldOutputSnapshotSource(src snapshot.SourceInfo) bool {
 	if *snapshotListShowAll {
 		return true
@@ -118,8 +201,6 @@ func outputManifestGroups(ctx context.Context, rep *repo.Repository, manifests [
 			log.Debugf("skipping %v", src)
 			continue
 		}
-		fmt.Printf("%v%v\n", separator, src)
-		separator = "\n"
 		anyOutput = true
 
 		pol, _, err := policy.GetEffectivePolicy(ctx, rep, src)
@@ -128,6 +209,18 @@ func outputManifestGroups(ctx context.Context, rep *repo.Repository, manifests [
 		} else {
 			pol.RetentionPolicy.ComputeRetentionReasons(snapshotGroup)
 		}
+
+		if *jsonOutput {
+			if err := outputManifestsJSON(snapshotGroup); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		fmt.Printf("%v%v\n", separator, src)
+		separator = "\n"
+
 		if err := outputManifestFromSingleSource(ctx, rep, snapshotGroup, relPathParts); err != nil {
 			return err
 		}
@@ -140,6 +233,25 @@ func outputManifestGroups(ctx context.Context, rep *repo.Repository, manifests [
 	return nil
 }
 
+func outputManifestsJSON(manifests []*snapshot.Manifest) error {
+	manifests = snapshot.SortByTime(manifests, false)
+	if len(manifests) > *maxResultsPerPath {
+		manifests = manifests[len(manifests)-*maxResultsPerPath:]
+	}
+
+	for _, m := range manifests {
+		if m.IncompleteReason != "" && !*snapshotListIncludeIncomplete {
+			continue
+		}
+
+		if err := jsonLines.write(serverapi.NewSnapshot(m)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 //nolint:gocyclo
 func outputManifestFromSingleSource(ctx context.Context, rep *repo.Repository, manifests []*snapshot.Manifest, parts []string) error {
 	var count int
@@ -170,7 +282,7 @@ func outputManifestFromSingleSource(ctx context.Context, rep *repo.Repository, m
 			fmt.Printf("  %v <ERROR> %v\n", formatTimestamp(m.StartTime), err)
 			continue
 		}
-		ent, err := getNestedEntry(ctx, root, parts)
+		ent, err := snapshotfs.GetNestedEntry(ctx, root, parts)
 		if err != nil {
 			fmt.Printf("  %v <ERROR> %v\n", formatTimestamp(m.StartTime), err)
 			continue
@@ -215,6 +327,10 @@ func outputManifestFromSingleSource(ctx context.Context, rep *repo.Repository, m
 				bits = append(bits,
 					fmt.Sprintf("files:%v", s.TotalFileCount),
 					fmt.Sprintf("dirs:%v", s.TotalDirCount))
+
+				if s.NumFailed > 0 {
+					bits = append(bits, fmt.Sprintf("errors:%v", s.NumFailed))
+				}
 			}
 		}
 
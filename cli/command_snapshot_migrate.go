@@ -3,6 +3,7 @@ package cli
 import (
 	"context"
 	"sort"
+	"strconv"
 	"sync"
 	"time"
 
@@ -21,6 +22,12 @@ var (
 	migrateLatestOnly   = migrateCommand.Flag("latest-only", "Only migrate the latest snapshot").Bool()
 	migrateIgnoreErrors = migrateCommand.Flag("ignore-errors", "Ignore errors when reading source backup").Bool()
 	migrateParallelism  = migrateCommand.Flag("parallelism", "Number of sources to migrate in parallel").Default("1").Int()
+
+	migrateCopy                   = migrateCommand.Flag("copy", "Copy snapshot contents directly between repositories instead of uploading files again").Bool()
+	migrateCopyParallelism        = migrateCommand.Flag("copy-parallelism", "Number of objects to copy in parallel").Default("8").Int()
+	migrateOverwritePolicies      = migrateCommand.Flag("overwrite-policies", "Overwrite policies already defined in this repository when copying").Bool()
+	migrateCopyCheckpointInterval = migrateCommand.Flag("copy-checkpoint-interval", "Interval between checkpoints which allow interrupted copy to resume (0 disables)").Default(snapshotfs.DefaultCheckpointInterval.String()).Duration()
+	migrateCopyCheckpointBytes    = migrateCommand.Flag("copy-checkpoint-bytes", "Number of bytes copied between checkpoints which allow interrupted copy to resume (0 disables)").PlaceHolder("BYTES").Default(strconv.Itoa(snapshotfs.DefaultCopyCheckpointBytes)).Int64()
 )
 
 func runMigrateCommand(ctx context.Context, destRepo *repo.Repository) error {
@@ -39,6 +46,10 @@ func runMigrateCommand(ctx context.Context, destRepo *repo.Repository) error {
 		return errors.Wrap(err, "can't retrieve sources")
 	}
 
+	if *migrateCopy {
+		return runMigrateCopy(ctx, sourceRepo, destRepo, sources)
+	}
+
 	semaphore := make(chan struct{}, *migrateParallelism)
 	var wg sync.WaitGroup
 
@@ -0,0 +1,143 @@
+package cli
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/units"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/policy"
+	"github.com/kopia/kopia/snapshot/snapshotfs"
+)
+
+const migrateCopyProgressInterval = 5 * time.Second
+
+// runMigrateCopy copies snapshots of the provided sources and their policies by copying the objects they reference.
+// Snapshots already present in the destination are skipped and the destination is flushed after each snapshot
+// and by periodic checkpoints, so an interrupted copy resumes where it left off.
+func runMigrateCopy(ctx context.Context, sourceRepo, destRepo *repo.Repository, sources []snapshot.SourceInfo) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	onCtrlC(func() {
+		log.Warningf("canceling copy")
+		cancel()
+	})
+
+	var (
+		mu           sync.Mutex
+		lastProgress time.Time
+	)
+
+	copier := snapshotfs.NewCopier(sourceRepo, destRepo)
+	copier.Parallelism = *migrateCopyParallelism
+	copier.CheckpointInterval = *migrateCopyCheckpointInterval
+	copier.CheckpointBytes = *migrateCopyCheckpointBytes
+	copier.ProgressCallback = func(s snapshotfs.CopyStats) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if time.Since(lastProgress) < migrateCopyProgressInterval {
+			return
+		}
+
+		lastProgress = time.Now()
+		printCopyStats(s)
+	}
+
+	for _, s := range sources {
+		if err := copyPolicy(ctx, sourceRepo, destRepo, s); err != nil {
+			return err
+		}
+
+		if err := copySingleSource(ctx, copier, sourceRepo, destRepo, s); err != nil {
+			return err
+		}
+	}
+
+	printCopyStats(copier.Stats())
+
+	return nil
+}
+
+func printCopyStats(s snapshotfs.CopyStats) {
+	printStderr("Copied %v contents (%v), skipped %v existing contents, %v files, %v directories.\n",
+		s.CopiedContents, units.BytesStringBase10(s.CopiedBytes), s.SkippedContents, s.Files, s.Directories)
+}
+
+func copyPolicy(ctx context.Context, sourceRepo, destRepo *repo.Repository, s snapshot.SourceInfo) error {
+	pol, err := policy.GetDefinedPolicy(ctx, sourceRepo, s)
+	if err == policy.ErrPolicyNotFound {
+		return nil
+	}
+
+	if err != nil {
+		return errors.Wrapf(err, "unable to get policy for %v", s)
+	}
+
+	if !*migrateOverwritePolicies {
+		_, err := policy.GetDefinedPolicy(ctx, destRepo, s)
+		if err == nil {
+			log.Infof("policy for %v already defined, not copying", s)
+			return nil
+		}
+
+		if err != policy.ErrPolicyNotFound {
+			return errors.Wrapf(err, "unable to get destination policy for %v", s)
+		}
+	}
+
+	log.Infof("copying policy for %v", s)
+
+	return policy.SetPolicy(ctx, destRepo, s, pol)
+}
+
+func copySingleSource(ctx context.Context, copier *snapshotfs.Copier, sourceRepo, destRepo *repo.Repository, s snapshot.SourceInfo) error {
+	manifests, err := snapshot.ListSnapshotManifests(ctx, sourceRepo, &s)
+	if err != nil {
+		return err
+	}
+
+	snapshots, err := snapshot.LoadSnapshots(ctx, sourceRepo, manifests)
+	if err != nil {
+		return errors.Wrapf(err, "unable to load snapshot manifests for %v", s)
+	}
+
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].StartTime.Before(snapshots[j].StartTime)
+	})
+
+	for _, m := range filterSnapshotsToMigrate(snapshots) {
+		if m.IncompleteReason != "" {
+			log.Infof("ignoring incomplete %v at %v", s, formatTimestamp(m.StartTime))
+			continue
+		}
+
+		existing, err := findPreviousSnapshotManifestWithStartTime(ctx, destRepo, m.Source, m.StartTime)
+		if err != nil {
+			return err
+		}
+
+		if existing != nil {
+			log.Infof("already copied %v at %v", s, formatTimestamp(m.StartTime))
+			continue
+		}
+
+		log.Infof("copying snapshot of %v at %v", s, formatTimestamp(m.StartTime))
+
+		if _, err := copier.CopySnapshot(ctx, m); err != nil {
+			return errors.Wrapf(err, "error copying snapshot %v @ %v", m.Source, m.StartTime)
+		}
+
+		if err := destRepo.Flush(ctx); err != nil {
+			return errors.Wrap(err, "unable to flush repository")
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,175 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/fs/localfs"
+	"github.com/kopia/kopia/internal/units"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/manifest"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/restore"
+	"github.com/kopia/kopia/snapshot/snapshotfs"
+)
+
+var (
+	snapshotVerifyCommand       = snapshotCommands.Command("verify", "Verify that snapshots can be restored and match their sources.")
+	snapshotVerifyIDs           = snapshotVerifyCommand.Arg("snapshot-id", "Snapshot manifest IDs to verify").Required().Strings()
+	snapshotVerifyRestoreTest   = snapshotVerifyCommand.Flag("restore-test", "Restore files into a scratch directory and verify the restored files").Bool()
+	snapshotVerifyScratchDir    = snapshotVerifyCommand.Flag("scratch-dir", "Scratch directory for --restore-test (defaults to a temporary directory)").String()
+	snapshotVerifyPercent       = snapshotVerifyCommand.Flag("percent", "Percentage of files to verify").Default("100").Float64()
+	snapshotVerifyCompareSource = snapshotVerifyCommand.Flag("compare-source", "Compare with the original source").Default("auto").Enum("auto", "yes", "no")
+	snapshotVerifySourcePath    = snapshotVerifyCommand.Flag("source-path", "Path of the original source (defaults to snapshot source path)").String()
+	snapshotVerifyReportFile    = snapshotVerifyCommand.Flag("report", "Write JSON report to the provided file").String()
+)
+
+type snapshotVerifyReport struct {
+	SnapshotID   manifest.ID         `json:"snapshotID"`
+	Source       snapshot.SourceInfo `json:"source"`
+	SnapshotTime time.Time           `json:"snapshotTime"`
+	SourcePath   string              `json:"sourcePath,omitempty"`
+
+	*restore.VerifyReport
+}
+
+func runSnapshotVerifyCommand(ctx context.Context, rep *repo.Repository) error {
+	opt := restore.VerifyOptions{SamplePercent: *snapshotVerifyPercent}
+
+	if *snapshotVerifyRestoreTest {
+		opt.ScratchDirectory = *snapshotVerifyScratchDir
+
+		if opt.ScratchDirectory == "" {
+			td, err := ioutil.TempDir("", "kopia-restore-test")
+			if err != nil {
+				return errors.Wrap(err, "unable to create scratch directory")
+			}
+			defer os.RemoveAll(td) //nolint:errcheck
+
+			opt.ScratchDirectory = td
+		}
+	}
+
+	var (
+		reports []snapshotVerifyReport
+		failed  int
+	)
+
+	for _, id := range *snapshotVerifyIDs {
+		r, err := verifySnapshot(ctx, rep, manifest.ID(id), opt)
+		if err != nil {
+			return errors.Wrapf(err, "unable to verify snapshot %v", id)
+		}
+
+		printStderr("Snapshot %v of %v: verified %v out of %v files (%v), %v compared with source, %v content mismatches, %v metadata mismatches, %v changed in source, %v errors.\n",
+			r.SnapshotID, r.Source, r.VerifiedFiles, r.TotalFiles, units.BytesStringBase10(r.VerifiedBytes), r.ComparedWithSource,
+			r.ContentMismatches, r.MetadataMismatches, r.SourceChanged, r.Errors)
+
+		for _, f := range r.Files {
+			switch {
+			case f.Error != "":
+				printStdout("%v %v: %v\n", f.Status, f.Path, f.Error)
+			case f.Status != restore.FileOK:
+				printStdout("%v %v: %v\n", f.Status, f.Path, f.Mismatches)
+			}
+		}
+
+		if r.Failed() {
+			failed++
+		}
+
+		reports = append(reports, *r)
+	}
+
+	if *snapshotVerifyReportFile != "" {
+		b, err := json.MarshalIndent(reports, "", "  ")
+		if err != nil {
+			return errors.Wrap(err, "unable to marshal report")
+		}
+
+		if err := ioutil.WriteFile(*snapshotVerifyReportFile, b, 0600); err != nil {
+			return errors.Wrap(err, "unable to write report")
+		}
+	}
+
+	if failed > 0 {
+		return errors.Errorf("verification of %v snapshots failed", failed)
+	}
+
+	return nil
+}
+
+func verifySnapshot(ctx context.Context, rep *repo.Repository, id manifest.ID, opt restore.VerifyOptions) (*snapshotVerifyReport, error) {
+	man, err := snapshot.LoadSnapshot(ctx, rep, id)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to load snapshot")
+	}
+
+	root, err := snapshotfs.SnapshotRoot(rep, man)
+	if err != nil {
+		return nil, err
+	}
+
+	sourcePath, source, err := snapshotVerifySource(man)
+	if err != nil {
+		return nil, err
+	}
+
+	opt.Source = source
+
+	r, err := restore.Verify(ctx, root, opt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &snapshotVerifyReport{
+		SnapshotID:   man.ID,
+		Source:       man.Source,
+		SnapshotTime: man.StartTime,
+		SourcePath:   sourcePath,
+		VerifyReport: r,
+	}, nil
+}
+
+// snapshotVerifySource returns the original source of the snapshot to compare with or nil if it's not available.
+func snapshotVerifySource(man *snapshot.Manifest) (string, fs.Entry, error) {
+	if *snapshotVerifyCompareSource == "no" {
+		return "", nil, nil
+	}
+
+	sourcePath := *snapshotVerifySourcePath
+	if sourcePath == "" && man.Source.Host == getHostName() {
+		sourcePath = man.Source.Path
+	}
+
+	if sourcePath == "" {
+		if *snapshotVerifyCompareSource == "yes" {
+			return "", nil, errors.Errorf("source of %v is on another host, must specify --source-path", man.Source)
+		}
+
+		return "", nil, nil
+	}
+
+	e, err := localfs.NewEntry(sourcePath)
+	if err != nil {
+		if *snapshotVerifyCompareSource == "yes" {
+			return "", nil, errors.Wrap(err, "unable to read source")
+		}
+
+		log.Warningf("source %v is not available, not comparing: %v", sourcePath, err)
+
+		return "", nil, nil
+	}
+
+	return sourcePath, e, nil
+}
+
+func init() {
+	snapshotVerifyCommand.Action(repositoryAction(runSnapshotVerifyCommand))
+}
@@ -15,6 +15,7 @@ import (
 	"github.com/kopia/kopia/fs/loggingfs"
 	"github.com/kopia/kopia/internal/ospath"
 	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/manifest"
 )
 
 var (
@@ -24,6 +25,8 @@ var (
 	enableCaching      = app.Flag("caching", "Enables caching of objects (disable with --no-caching)").Default("true").Hidden().Bool()
 	enableListCaching  = app.Flag("list-caching", "Enables caching of list results (disable with --no-list-caching)").Default("true").Hidden().Bool()
 
+	manifestHistoryRetention = app.Flag("manifest-history-retention", "How long deleted manifest entries can be undeleted, negative value disables history.").Default(manifest.DefaultHistoryRetention.String()).Envar("KOPIA_MANIFEST_HISTORY_RETENTION").Duration()
+
 	configPath = app.Flag("config-file", "Specify the config file to use.").Default(defaultConfigFileName()).Envar("KOPIA_CONFIG_PATH").String()
 )
 
@@ -84,6 +87,8 @@ func applyOptionsFromFlags(opts *repo.Options) *repo.Options {
 		opts.ObjectManagerOptions.Trace = log.Debugf
 	}
 
+	opts.ManifestOptions.HistoryRetention = *manifestHistoryRetention
+
 	return opts
 }
 
@@ -0,0 +1,55 @@
+package cli
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// jsonOutput enables machine-readable output of listing commands, which use the same structures as the HTTP API:
+// commands producing a single result print an indented JSON object, listing commands print one JSON object per line.
+var jsonOutput = app.Flag("json", "Output results as JSON (JSON lines for lists).").Short('j').Bool()
+
+// jsonLines is the writer used by listing commands in JSON output mode.
+var jsonLines = &jsonLinesWriter{}
+
+// printJSON writes the provided value to stdout as an indented JSON object.
+func printJSON(v interface{}) error {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "unable to encode JSON output")
+	}
+
+	b = append(b, '\n')
+
+	_, err = os.Stdout.Write(b)
+
+	return err
+}
+
+// jsonLinesWriter writes values as JSON lines to the provided output (stdout by default), it's safe for concurrent use.
+type jsonLinesWriter struct {
+	out io.Writer
+
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+func (w *jsonLinesWriter) write(v interface{}) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.enc == nil {
+		out := w.out
+		if out == nil {
+			out = os.Stdout
+		}
+
+		w.enc = json.NewEncoder(out)
+	}
+
+	return errors.Wrap(w.enc.Encode(v), "unable to encode JSON output")
+}
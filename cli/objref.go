@@ -28,35 +28,8 @@ func parseObjectID(ctx context.Context, rep *repo.Repository, id string) (object
 	return parseNestedObjectID(ctx, dir, parts[1:])
 }
 
-func getNestedEntry(ctx context.Context, startingDir fs.Entry, parts []string) (fs.Entry, error) {
-	current := startingDir
-	for _, part := range parts {
-		if part == "" {
-			continue
-		}
-		dir, ok := current.(fs.Directory)
-		if !ok {
-			return nil, errors.Errorf("entry not found %q: parent is not a directory", part)
-		}
-
-		entries, err := dir.Readdir(ctx)
-		if err != nil {
-			return nil, err
-		}
-
-		e := entries.FindByName(part)
-		if e == nil {
-			return nil, errors.Errorf("entry not found: %q", part)
-		}
-
-		current = e
-	}
-
-	return current, nil
-}
-
 func parseNestedObjectID(ctx context.Context, startingDir fs.Entry, parts []string) (object.ID, error) {
-	e, err := getNestedEntry(ctx, startingDir, parts)
+	e, err := snapshotfs.GetNestedEntry(ctx, startingDir, parts)
 	if err != nil {
 		return "", err
 	}
@@ -6,6 +6,7 @@ import (
 	"github.com/pkg/errors"
 	kingpin "gopkg.in/alecthomas/kingpin.v2"
 
+	"github.com/kopia/kopia/repo"
 	"github.com/kopia/kopia/repo/blob"
 )
 
@@ -22,9 +23,9 @@ func RegisterStorageConnectFlags(
 		flags(cc)
 		cc.Action(func(_ *kingpin.ParseContext) error {
 			ctx := context.Background()
-			st, err := connect(ctx, true)
+			st, err := connectWithRetries(ctx, connect, true)
 			if err != nil {
-				return errors.Wrap(err, "can't connect to storage")
+				return err
 			}
 
 			return runCreateCommandWithStorage(ctx, st)
@@ -36,24 +37,77 @@ func RegisterStorageConnectFlags(
 	flags(cc)
 	cc.Action(func(_ *kingpin.ParseContext) error {
 		ctx := context.Background()
-		st, err := connect(ctx, false)
+		st, err := connectWithRetries(ctx, connect, false)
 		if err != nil {
-			return errors.Wrap(err, "can't connect to storage")
+			return err
 		}
 
 		return runConnectCommandWithStorage(ctx, st)
 	})
 
+	// Set up 'sync-to' subcommand
+	cc = syncToCommand.Command(name, "Synchronize repository data to another repository in "+description)
+	flags(cc)
+	cc.Action(repositoryAction(func(ctx context.Context, rep *repo.Repository) error {
+		// destination may not exist yet.
+		st, err := connectWithRetries(ctx, connect, true)
+		if err != nil {
+			return err
+		}
+
+		// blobs are copied as stored, the replica must have the same obfuscated names, padding and parity.
+		raw, err := rep.OpenRawStorage(ctx)
+		if err != nil {
+			return errors.Wrap(err, "unable to open repository storage")
+		}
+		defer raw.Close(ctx) //nolint:errcheck
+
+		src, err := withRetries(raw)
+		if err != nil {
+			return err
+		}
+
+		return runSyncWithStorage(ctx, src, st)
+	}))
+
+	// Set up 'recover' subcommand
+	cc = recoverCommand.Command(name, "Recover repository in "+description)
+	flags(cc)
+	cc.Action(func(_ *kingpin.ParseContext) error {
+		ctx := context.Background()
+		st, err := connectWithRetries(ctx, connect, false)
+		if err != nil {
+			return err
+		}
+
+		return runRecoverCommandWithStorage(ctx, st)
+	})
+
 	// Set up 'repair' subcommand
 	cc = repairCommand.Command(name, "Repair repository in "+description)
 	flags(cc)
 	cc.Action(func(_ *kingpin.ParseContext) error {
 		ctx := context.Background()
-		st, err := connect(ctx, false)
+		st, err := connectWithRetries(ctx, connect, false)
 		if err != nil {
-			return errors.Wrap(err, "can't connect to storage")
+			return err
 		}
 
 		return runRepairCommandWithStorage(ctx, st)
 	})
 }
+
+// connectWithRetries connects to the storage and wraps it so that failed operations are retried.
+func connectWithRetries(ctx context.Context, connect func(ctx context.Context, isNew bool) (blob.Storage, error), isNew bool) (blob.Storage, error) {
+	st, err := connect(ctx, isNew)
+	if err != nil {
+		return nil, errors.Wrap(err, "can't connect to storage")
+	}
+
+	return withRetries(st)
+}
+
+// withRetries wraps the storage so that failed operations are retried according to the retry flags.
+func withRetries(st blob.Storage) (blob.Storage, error) {
+	return repo.WithRetries(st, retryPolicyFromFlags())
+}
@@ -20,6 +20,13 @@ type OwnerInfo struct {
 	GroupID uint32
 }
 
+// LocalFilesystemInfo is returned by Sys() of entries in the local filesystem on platforms which support it.
+type LocalFilesystemInfo struct {
+	Device     uint64
+	Inode      uint64
+	ChangeTime time.Time
+}
+
 // Entries is a list of entries sorted by name.
 type Entries []Entry
 
@@ -51,6 +58,17 @@ type DirectorySummary struct {
 	TotalDirCount    int64     `json:"dirs"`
 	MaxModTime       time.Time `json:"maxTime"`
 	IncompleteReason string    `json:"incomplete,omitempty"`
+
+	// Entries in this directory and its subdirectories that could not be snapshotted.
+	// FailedEntries holds up to a fixed number of them while NumFailed has their total count.
+	NumFailed     int               `json:"numFailed,omitempty"`
+	FailedEntries []*EntryWithError `json:"errors,omitempty"`
+}
+
+// EntryWithError describes an entry that could not be snapshotted and the reason.
+type EntryWithError struct {
+	EntryPath string `json:"path"`
+	Error     string `json:"error"`
 }
 
 // Symlink represents a symbolic link entry.
@@ -31,6 +31,7 @@ type filesystemEntry struct {
 	mtimeNanos int64
 	mode       os.FileMode
 	owner      fs.OwnerInfo
+	local      *fs.LocalFilesystemInfo
 
 	parentDir string
 }
@@ -56,7 +57,11 @@ func (e *filesystemEntry) ModTime() time.Time {
 }
 
 func (e *filesystemEntry) Sys() interface{} {
-	return nil
+	if e.local == nil {
+		return nil
+	}
+
+	return e.local
 }
 
 func (e *filesystemEntry) fullPath() string {
@@ -76,6 +81,7 @@ func newEntry(fi os.FileInfo, parentDir string) filesystemEntry {
 		fi.ModTime().UnixNano(),
 		fi.Mode(),
 		platformSpecificOwnerInfo(fi),
+		platformSpecificLocalInfo(fi),
 		parentDir,
 	}
 }
@@ -0,0 +1,22 @@
+package localfs
+
+import (
+	"os"
+	"syscall"
+	"time"
+
+	"github.com/kopia/kopia/fs"
+)
+
+func platformSpecificLocalInfo(fi os.FileInfo) *fs.LocalFilesystemInfo {
+	stat, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil
+	}
+
+	return &fs.LocalFilesystemInfo{
+		Device:     uint64(stat.Dev), // uint32 on some architectures
+		Inode:      uint64(stat.Ino),
+		ChangeTime: time.Unix(stat.Ctim.Unix()),
+	}
+}
@@ -0,0 +1,13 @@
+// +build !linux,!darwin
+
+package localfs
+
+import (
+	"os"
+
+	"github.com/kopia/kopia/fs"
+)
+
+func platformSpecificLocalInfo(fi os.FileInfo) *fs.LocalFilesystemInfo {
+	return nil
+}
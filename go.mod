@@ -15,6 +15,8 @@ require (
 	github.com/go-ini/ini v1.42.0 // indirect
 	github.com/godbus/dbus v4.1.0+incompatible // indirect
 	github.com/jpillora/go-ogle-analytics v0.0.0-20161213085824-14b04e0594ef
+	github.com/klauspost/cpuid v1.3.1 // indirect
+	github.com/klauspost/reedsolomon v1.9.3
 	github.com/kr/fs v0.1.0 // indirect
 	github.com/kylelemons/godebug v1.1.0
 	github.com/minio/minio-go v6.0.14+incompatible
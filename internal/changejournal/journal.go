@@ -0,0 +1,72 @@
+// Package changejournal records directories of a local filesystem tree which changed while being watched,
+// so that uploads can skip reading directories which are known to be unchanged.
+package changejournal
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// Journal records directories changed since StartTime. Directory paths are relative to the watched root,
+// in the format used by the uploader ("." for the root, "./a/b" for its subdirectories).
+type Journal struct {
+	StartTime time.Time
+
+	mu       sync.Mutex
+	dirty    map[string]bool // changed directories and all their ancestors
+	overflow bool            // some changes might not have been recorded
+}
+
+// NewJournal creates an empty Journal which starts at the current time.
+func NewJournal() *Journal {
+	return &Journal{
+		StartTime: time.Now(),
+		dirty:     map[string]bool{},
+	}
+}
+
+// MarkChanged records that the contents of the directory with the given relative path have changed.
+func (j *Journal) MarkChanged(relativePath string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	for p := relativePath; !j.dirty[p]; p = parentPath(p) {
+		j.dirty[p] = true
+	}
+}
+
+// MarkOverflow records that some changes might have been lost, which invalidates the journal.
+func (j *Journal) MarkOverflow() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.overflow = true
+}
+
+// IsValid returns true if the journal recorded all changes.
+func (j *Journal) IsValid() bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	return !j.overflow
+}
+
+// IsUnchanged returns true if neither the directory with the given relative path nor any of its
+// subdirectories were changed.
+func (j *Journal) IsUnchanged(relativePath string) bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	return !j.overflow && !j.dirty[relativePath]
+}
+
+// parentPath returns the relative path of the parent directory, the parent of the root is the root itself.
+func parentPath(p string) string {
+	i := strings.LastIndex(p, "/")
+	if i < 0 {
+		return "."
+	}
+
+	return p[:i]
+}
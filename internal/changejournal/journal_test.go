@@ -0,0 +1,40 @@
+package changejournal
+
+import (
+	"testing"
+)
+
+func TestJournal(t *testing.T) {
+	j := NewJournal()
+
+	if !j.IsUnchanged(".") {
+		t.Errorf("new journal should have no changes")
+	}
+
+	j.MarkChanged("./a/b")
+
+	cases := map[string]bool{
+		".":       false,
+		"./a":     false,
+		"./a/b":   false,
+		"./a/b/c": true,
+		"./a/c":   true,
+		"./b":     true,
+	}
+
+	for p, want := range cases {
+		if got := j.IsUnchanged(p); got != want {
+			t.Errorf("unexpected IsUnchanged(%q): %v, want %v", p, got, want)
+		}
+	}
+
+	j.MarkOverflow()
+
+	if j.IsValid() {
+		t.Errorf("journal should be invalid after overflow")
+	}
+
+	if j.IsUnchanged("./b") {
+		t.Errorf("journal should not report unchanged directories after overflow")
+	}
+}
@@ -0,0 +1,194 @@
+package changejournal
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"unsafe"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/kopialogging"
+)
+
+var log = kopialogging.Logger("kopia/changejournal")
+
+const watchMask = syscall.IN_MODIFY | syscall.IN_ATTRIB | syscall.IN_CREATE | syscall.IN_DELETE |
+	syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO | syscall.IN_DELETE_SELF | syscall.IN_MOVE_SELF | syscall.IN_ONLYDIR
+
+// Watcher watches a directory tree using inotify and records changed directories in the current Journal.
+type Watcher struct {
+	root string
+	file *os.File
+
+	mu      sync.Mutex
+	fd      int
+	watches map[int32]string // watch descriptors to relative paths
+	journal *Journal
+	closed  chan struct{}
+}
+
+// NewWatcher starts watching the directory tree at the provided root.
+// Changes are recorded in a Journal which starts once all directories are being watched.
+func NewWatcher(root string) (*Watcher, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_NONBLOCK | syscall.IN_CLOEXEC)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to initialize inotify")
+	}
+
+	w := &Watcher{
+		root:    filepath.Clean(root),
+		fd:      fd,
+		file:    os.NewFile(uintptr(fd), "inotify"),
+		watches: map[int32]string{},
+		closed:  make(chan struct{}),
+	}
+
+	// journal receiving changes made while directories are being added, it's never valid.
+	w.journal = NewJournal()
+	w.journal.MarkOverflow()
+
+	if err := w.addWatches(".", false); err != nil {
+		w.file.Close() //nolint:errcheck
+		return nil, err
+	}
+
+	w.Rotate()
+
+	go w.run()
+
+	return w, nil
+}
+
+// Rotate returns the journal of changes recorded so far and starts a new one.
+func (w *Watcher) Rotate() *Journal {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	j := w.journal
+	w.journal = NewJournal()
+
+	return j
+}
+
+// Close stops watching the tree.
+func (w *Watcher) Close() error {
+	close(w.closed)
+	return w.file.Close()
+}
+
+// addWatches adds watches for the directory with a given relative path and all its subdirectories,
+// optionally marking them as changed.
+func (w *Watcher) addWatches(relativePath string, markChanged bool) error {
+	return filepath.Walk(filepath.Join(w.root, relativePath), func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				// removed while walking
+				return nil
+			}
+
+			return err
+		}
+
+		if !fi.IsDir() {
+			return nil
+		}
+
+		wd, err := syscall.InotifyAddWatch(w.fd, p, watchMask)
+		if err != nil {
+			return errors.Wrapf(err, "unable to watch %v", p)
+		}
+
+		rel, err := filepath.Rel(w.root, p)
+		if err != nil {
+			return err
+		}
+
+		if rel != "." {
+			rel = "./" + filepath.ToSlash(rel)
+		}
+
+		w.mu.Lock()
+		w.watches[int32(wd)] = rel
+		if markChanged {
+			w.journal.MarkChanged(rel)
+		}
+		w.mu.Unlock()
+
+		return nil
+	})
+}
+
+func (w *Watcher) run() {
+	buf := make([]byte, 64*1024)
+
+	for {
+		n, err := w.file.Read(buf)
+		if err != nil {
+			select {
+			case <-w.closed:
+			default:
+				log.Warningf("unable to read inotify events: %v", err)
+				w.overflow()
+			}
+
+			return
+		}
+
+		for off := 0; off+syscall.SizeofInotifyEvent <= n; {
+			ev := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[off]))
+			nameBytes := buf[off+syscall.SizeofInotifyEvent : off+syscall.SizeofInotifyEvent+int(ev.Len)]
+			name := string(bytes.TrimRight(nameBytes, "\x00"))
+
+			w.handleEvent(ev.Wd, ev.Mask, name)
+
+			off += syscall.SizeofInotifyEvent + int(ev.Len)
+		}
+	}
+}
+
+func (w *Watcher) handleEvent(wd int32, mask uint32, name string) {
+	if mask&syscall.IN_Q_OVERFLOW != 0 {
+		log.Warningf("inotify queue overflow, changes were lost")
+		w.overflow()
+
+		return
+	}
+
+	// changes are recorded while holding the lock, so they are never recorded in a journal that was rotated.
+	w.mu.Lock()
+	dir, ok := w.watches[wd]
+
+	if mask&syscall.IN_IGNORED != 0 {
+		delete(w.watches, wd)
+	}
+
+	if ok {
+		if name != "" {
+			// entry inside the directory has changed.
+			w.journal.MarkChanged(dir)
+		} else {
+			// the directory itself has changed, which is also reflected in its parent.
+			w.journal.MarkChanged(parentPath(dir))
+			w.journal.MarkChanged(dir)
+		}
+	}
+	w.mu.Unlock()
+
+	if ok && name != "" && mask&syscall.IN_ISDIR != 0 && mask&(syscall.IN_CREATE|syscall.IN_MOVED_TO) != 0 {
+		if err := w.addWatches(dir+"/"+name, true); err != nil {
+			// typically the limit of watches was reached.
+			log.Warningf("unable to watch new directory: %v", err)
+			w.overflow()
+		}
+	}
+}
+
+func (w *Watcher) overflow() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.journal.MarkOverflow()
+}
@@ -0,0 +1,97 @@
+package changejournal
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatcher(t *testing.T) {
+	td, err := ioutil.TempDir("", "kopia-watcher")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(td) //nolint:errcheck
+
+	for _, d := range []string{"a/b", "c"} {
+		if err := os.MkdirAll(filepath.Join(td, d), 0700); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	w, err := NewWatcher(td)
+	if err != nil {
+		t.Fatalf("unable to create watcher: %v", err)
+	}
+	defer w.Close() //nolint:errcheck
+
+	if err := ioutil.WriteFile(filepath.Join(td, "a/b/f"), []byte("hello"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// new directories are watched as well.
+	if err := os.MkdirAll(filepath.Join(td, "d/e"), 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	waitForChange(t, w, "./a/b")
+	waitForChange(t, w, "./d/e")
+
+	j1 := w.Rotate()
+	if !j1.IsValid() {
+		t.Fatalf("journal is not valid")
+	}
+
+	verifyUnchanged(t, j1, map[string]bool{
+		".":     false,
+		"./a":   false,
+		"./a/b": false,
+		"./c":   true,
+		"./d":   false,
+	})
+
+	if err := ioutil.WriteFile(filepath.Join(td, "d/e/f"), []byte("hello"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	waitForChange(t, w, "./d/e")
+
+	j2 := w.Rotate()
+	verifyUnchanged(t, j2, map[string]bool{
+		"./a":   true,
+		"./a/b": true,
+		"./c":   true,
+		"./d/e": false,
+	})
+}
+
+func waitForChange(t *testing.T, w *Watcher, relativePath string) {
+	t.Helper()
+
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		w.mu.Lock()
+		changed := !w.journal.IsUnchanged(relativePath)
+		w.mu.Unlock()
+
+		if changed {
+			return
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Fatalf("change of %v was not recorded", relativePath)
+}
+
+func verifyUnchanged(t *testing.T, j *Journal, cases map[string]bool) {
+	t.Helper()
+
+	for p, want := range cases {
+		if got := j.IsUnchanged(p); got != want {
+			t.Errorf("unexpected IsUnchanged(%q): %v, want %v", p, got, want)
+		}
+	}
+}
@@ -0,0 +1,28 @@
+// +build !linux
+
+package changejournal
+
+import (
+	"github.com/pkg/errors"
+)
+
+// Watcher watches a directory tree and records changed directories in the current Journal.
+type Watcher struct{}
+
+// NewWatcher returns an error, watching directory trees is only supported on Linux.
+func NewWatcher(root string) (*Watcher, error) {
+	return nil, errors.New("watching for changes is not supported on this operating system")
+}
+
+// Rotate returns the journal of changes recorded so far and starts a new one.
+func (w *Watcher) Rotate() *Journal {
+	j := NewJournal()
+	j.MarkOverflow()
+
+	return j
+}
+
+// Close stops watching the tree.
+func (w *Watcher) Close() error {
+	return nil
+}
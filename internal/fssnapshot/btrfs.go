@@ -0,0 +1,119 @@
+// +build linux
+
+package fssnapshot
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	btrfsSuperMagic         = 0x9123683e
+	btrfsSubvolumeRootInode = 256
+	btrfsSnapshotNamePrefix = "."
+)
+
+// btrfsDriver creates read-only snapshots of the btrfs subvolume containing the path,
+// the snapshot is placed outside of the path so that it's not uploaded along with it.
+type btrfsDriver struct {
+	run           CommandRunner
+	subvolumeRoot func(path string) (string, error)
+	snapshotDir   string // defaults to the subvolume root or its parent
+}
+
+type btrfsSnapshot struct {
+	run  CommandRunner
+	dir  string
+	path string
+}
+
+func (d *btrfsDriver) Create(ctx context.Context, path string) (Snapshot, error) {
+	root, err := d.subvolumeRoot(path)
+	if err != nil {
+		return nil, err
+	}
+
+	rel, err := pathWithin(root, path)
+	if err != nil {
+		return nil, err
+	}
+
+	parent, err := d.snapshotParentDir(root, path)
+	if err != nil {
+		return nil, err
+	}
+
+	dir := filepath.Join(parent, btrfsSnapshotNamePrefix+snapshotName())
+	if _, err := d.run(ctx, "btrfs", "subvolume", "snapshot", "-r", root, dir); err != nil {
+		return nil, errors.Wrapf(err, "unable to create btrfs snapshot in %v, which must be on the same filesystem as %v", parent, path)
+	}
+
+	log.Infof("created btrfs snapshot %v of %v", dir, root)
+
+	return &btrfsSnapshot{run: d.run, dir: dir, path: filepath.Join(dir, rel)}, nil
+}
+
+// snapshotParentDir returns the directory where the snapshot of the subvolume is created, which must not
+// be inside the snapshotted path, otherwise the live tree would contain the snapshot.
+func (d *btrfsDriver) snapshotParentDir(root, path string) (string, error) {
+	parent := d.snapshotDir
+	if parent == "" {
+		parent = root
+		if _, err := pathWithin(path, root); err == nil {
+			parent = filepath.Dir(root)
+		}
+	}
+
+	if _, err := pathWithin(path, parent); err == nil {
+		return "", errors.Errorf("btrfs snapshot directory %v must be outside of %v", parent, path)
+	}
+
+	return parent, nil
+}
+
+func (s *btrfsSnapshot) Path() string {
+	return s.path
+}
+
+func (s *btrfsSnapshot) Release(ctx context.Context) error {
+	if _, err := s.run(ctx, "btrfs", "subvolume", "delete", s.dir); err != nil {
+		return errors.Wrap(err, "unable to delete btrfs snapshot")
+	}
+
+	log.Infof("deleted btrfs snapshot %v", s.dir)
+
+	return nil
+}
+
+// findBtrfsSubvolumeRoot returns the root of btrfs subvolume containing the provided path.
+// Subvolume roots are recognized by their fixed inode number.
+func findBtrfsSubvolumeRoot(path string) (string, error) {
+	var sfs syscall.Statfs_t
+	if err := syscall.Statfs(path, &sfs); err != nil {
+		return "", errors.Wrapf(err, "unable to determine filesystem of %v", path)
+	}
+
+	// the type is int32 on some platforms, where the magic number is negative.
+	if uint32(sfs.Type) != btrfsSuperMagic {
+		return "", errors.Errorf("%v is not on a btrfs filesystem", path)
+	}
+
+	for p := filepath.Clean(path); ; p = filepath.Dir(p) {
+		fi, err := os.Lstat(p)
+		if err != nil {
+			return "", err
+		}
+
+		if st, ok := fi.Sys().(*syscall.Stat_t); ok && fi.IsDir() && st.Ino == btrfsSubvolumeRootInode {
+			return p, nil
+		}
+
+		if filepath.Dir(p) == p {
+			return "", errors.Errorf("unable to find btrfs subvolume containing %v", path)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+// Package fssnapshot creates read-only point-in-time snapshots of local filesystems, which allows
+// consistent state of live data to be uploaded.
+package fssnapshot
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/kopialogging"
+)
+
+var log = kopialogging.Logger("kopia/fssnapshot")
+
+// Supported drivers.
+const (
+	DriverBtrfs = "btrfs" // read-only snapshot of btrfs subvolume
+	DriverLVM   = "lvm"   // LVM snapshot of logical volume, mounted read-only
+)
+
+// DefaultLVMSnapshotSize is the default size of copy-on-write area of LVM snapshots.
+const DefaultLVMSnapshotSize = "10%ORIGIN"
+
+// Snapshot is a read-only point-in-time snapshot of a filesystem.
+type Snapshot interface {
+	// Path returns the location of the snapshotted path inside the snapshot.
+	Path() string
+
+	// Release unmounts and deletes the snapshot.
+	Release(ctx context.Context) error
+}
+
+// Driver creates filesystem snapshots.
+type Driver interface {
+	// Create creates snapshot of the filesystem containing the provided path.
+	Create(ctx context.Context, path string) (Snapshot, error)
+}
+
+// Options provides options for filesystem snapshot drivers.
+type Options struct {
+	// Size of copy-on-write area of LVM snapshots, either absolute (e.g. "5G") or
+	// relative to the origin volume (e.g. "20%ORIGIN").
+	LVMSnapshotSize string
+
+	// Directory where btrfs snapshots are created, which must be on the same filesystem and outside
+	// of the snapshotted path. Defaults to the root of the subvolume or its parent directory when
+	// the snapshotted path is the root of the subvolume.
+	BtrfsSnapshotDir string
+}
+
+// CommandRunner runs an external command and returns its standard output.
+type CommandRunner func(ctx context.Context, name string, args ...string) (string, error)
+
+// NewDriver returns a driver with the provided name.
+func NewDriver(name string, opt Options) (Driver, error) {
+	return newDriver(name, opt, execCommand)
+}
+
+func execCommand(ctx context.Context, name string, args ...string) (string, error) {
+	var stderr bytes.Buffer
+
+	c := exec.CommandContext(ctx, name, args...)
+	c.Stderr = &stderr
+
+	log.Debugf("running %v %v", name, strings.Join(args, " "))
+
+	out, err := c.Output()
+	if err != nil {
+		return "", errors.Wrapf(err, "%v %v failed: %v", name, strings.Join(args, " "), strings.TrimSpace(stderr.String()))
+	}
+
+	return string(out), nil
+}
+
+// snapshotName returns the name of a new snapshot, unique enough for concurrent uploads on the same host.
+func snapshotName() string {
+	return fmt.Sprintf("kopia-snapshot-%v-%v", time.Now().UTC().Format("20060102150405"), os.Getpid())
+}
+
+// pathWithin returns the path relative to the root, which must contain it.
+func pathWithin(root, path string) (string, error) {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return "", err
+	}
+
+	if rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", errors.Errorf("%v is not inside %v", path, root)
+	}
+
+	return rel, nil
+}
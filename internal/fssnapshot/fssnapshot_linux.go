@@ -0,0 +1,23 @@
+package fssnapshot
+
+import (
+	"github.com/pkg/errors"
+)
+
+func newDriver(name string, opt Options, run CommandRunner) (Driver, error) {
+	switch name {
+	case DriverBtrfs:
+		return &btrfsDriver{run: run, subvolumeRoot: findBtrfsSubvolumeRoot, snapshotDir: opt.BtrfsSnapshotDir}, nil
+
+	case DriverLVM:
+		size := opt.LVMSnapshotSize
+		if size == "" {
+			size = DefaultLVMSnapshotSize
+		}
+
+		return &lvmDriver{run: run, size: size}, nil
+
+	default:
+		return nil, errors.Errorf("unsupported filesystem snapshot driver: %q", name)
+	}
+}
@@ -0,0 +1,11 @@
+// +build !linux
+
+package fssnapshot
+
+import (
+	"github.com/pkg/errors"
+)
+
+func newDriver(name string, opt Options, run CommandRunner) (Driver, error) {
+	return nil, errors.Errorf("filesystem snapshots are not supported on this operating system")
+}
@@ -0,0 +1,275 @@
+// +build linux
+
+package fssnapshot
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+// fakeRunner records commands and returns canned outputs instead of running them.
+type fakeRunner struct {
+	commands []string
+	outputs  map[string]string // by command name
+	fail     string            // command name which fails
+}
+
+func (r *fakeRunner) run(ctx context.Context, name string, args ...string) (string, error) {
+	r.commands = append(r.commands, name+" "+strings.Join(args, " "))
+
+	if name == r.fail {
+		return "", errors.Errorf("%v failed", name)
+	}
+
+	return r.outputs[name], nil
+}
+
+func TestBtrfsDriver(t *testing.T) {
+	ctx := context.Background()
+	r := &fakeRunner{}
+	d := &btrfsDriver{run: r.run, subvolumeRoot: func(path string) (string, error) {
+		return "/data", nil
+	}}
+
+	s, err := d.Create(ctx, "/data/home/user")
+	if err != nil {
+		t.Fatalf("unable to create snapshot: %v", err)
+	}
+
+	dir := s.(*btrfsSnapshot).dir
+	if got, want := filepath.Dir(dir), "/data"; got != want {
+		t.Errorf("unexpected snapshot location: %v, want %v", got, want)
+	}
+
+	if got, want := s.Path(), filepath.Join(dir, "home/user"); got != want {
+		t.Errorf("unexpected snapshot path: %v, want %v", got, want)
+	}
+
+	if err := s.Release(ctx); err != nil {
+		t.Fatalf("unable to release snapshot: %v", err)
+	}
+
+	want := []string{
+		"btrfs subvolume snapshot -r /data " + dir,
+		"btrfs subvolume delete " + dir,
+	}
+
+	if !reflect.DeepEqual(r.commands, want) {
+		t.Errorf("unexpected commands: %v, want %v", r.commands, want)
+	}
+}
+
+func TestBtrfsDriver_SnapshotDir(t *testing.T) {
+	cases := []struct {
+		desc        string
+		snapshotDir string
+		path        string
+		wantParent  string // empty if creation fails
+	}{
+		{"subdirectory of subvolume", "", "/data/home", "/data"},
+		{"root of subvolume", "", "/data", "/"},
+		{"explicit directory", "/snapshots", "/data", "/snapshots"},
+		{"explicit directory inside the path", "/data/snapshots", "/data", ""},
+		{"explicit directory equal to the path", "/data/home", "/data/home", ""},
+	}
+
+	for _, tc := range cases {
+		r := &fakeRunner{}
+		d := &btrfsDriver{run: r.run, snapshotDir: tc.snapshotDir, subvolumeRoot: func(path string) (string, error) {
+			return "/data", nil
+		}}
+
+		s, err := d.Create(context.Background(), tc.path)
+		if tc.wantParent == "" {
+			if err == nil {
+				t.Errorf("%v: unexpected success", tc.desc)
+			}
+
+			if len(r.commands) != 0 {
+				t.Errorf("%v: unexpected commands: %v", tc.desc, r.commands)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%v: unable to create snapshot: %v", tc.desc, err)
+			continue
+		}
+
+		if got := filepath.Dir(s.(*btrfsSnapshot).dir); got != tc.wantParent {
+			t.Errorf("%v: unexpected snapshot location: %v, want %v", tc.desc, got, tc.wantParent)
+		}
+	}
+}
+
+func TestLVMDriver(t *testing.T) {
+	ctx := context.Background()
+	r := &fakeRunner{outputs: map[string]string{
+		"findmnt": "/dev/mapper/vg0-data /mnt/data xfs\n",
+		"lvs":     "  vg0 data\n",
+	}}
+	d := &lvmDriver{run: r.run, size: "5G"}
+
+	s, err := d.Create(ctx, "/mnt/data/some/dir")
+	if err != nil {
+		t.Fatalf("unable to create snapshot: %v", err)
+	}
+
+	ls := s.(*lvmSnapshot)
+	if got, want := s.Path(), filepath.Join(ls.mountPoint, "some/dir"); got != want {
+		t.Errorf("unexpected snapshot path: %v, want %v", got, want)
+	}
+
+	if err := s.Release(ctx); err != nil {
+		t.Fatalf("unable to release snapshot: %v", err)
+	}
+
+	if _, err := os.Stat(ls.mountPoint); !os.IsNotExist(err) {
+		t.Errorf("mount point was not removed: %v", err)
+	}
+
+	name := strings.TrimPrefix(ls.volume, "vg0/")
+	want := []string{
+		"findmnt --noheadings --output SOURCE,TARGET,FSTYPE --target /mnt/data/some/dir",
+		"lvs --noheadings --options vg_name,lv_name /dev/mapper/vg0-data",
+		"lvcreate --snapshot --name " + name + " --size 5G vg0/data",
+		"mount -t xfs -o ro,nouuid /dev/vg0/" + name + " " + ls.mountPoint,
+		"umount " + ls.mountPoint,
+		"lvremove --force vg0/" + name,
+	}
+
+	if !reflect.DeepEqual(r.commands, want) {
+		t.Errorf("unexpected commands: %v, want %v", r.commands, want)
+	}
+}
+
+func TestLVMDriver_MountFailure(t *testing.T) {
+	ctx := context.Background()
+	r := &fakeRunner{
+		outputs: map[string]string{
+			"findmnt": "/dev/mapper/vg0-data /mnt/data ext4\n",
+			"lvs":     "  vg0 data\n",
+		},
+		fail: "mount",
+	}
+	d := &lvmDriver{run: r.run, size: DefaultLVMSnapshotSize}
+
+	if _, err := d.Create(ctx, "/mnt/data"); err == nil {
+		t.Fatalf("expected error")
+	}
+
+	// snapshot must be removed after the failure.
+	if got := r.commands[len(r.commands)-1]; !strings.HasPrefix(got, "lvremove --force vg0/kopia-snapshot-") {
+		t.Errorf("unexpected last command: %v", got)
+	}
+
+	if got := r.commands[2]; !strings.Contains(got, "--extents 10%ORIGIN") {
+		t.Errorf("unexpected lvcreate command: %v", got)
+	}
+}
+
+func TestLVMDriver_NotLogicalVolume(t *testing.T) {
+	r := &fakeRunner{
+		outputs: map[string]string{"findmnt": "/dev/sda1 / ext4\n"},
+		fail:    "lvs",
+	}
+	d := &lvmDriver{run: r.run, size: DefaultLVMSnapshotSize}
+
+	if _, err := d.Create(context.Background(), "/home"); err == nil {
+		t.Fatalf("expected error")
+	}
+
+	if got, want := len(r.commands), 2; got != want {
+		t.Errorf("unexpected commands: %v", r.commands)
+	}
+}
+
+// TestBtrfsLoopback creates a real btrfs filesystem in a loopback-mounted image when running as root
+// with btrfs tools available.
+func TestBtrfsLoopback(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("must be root")
+	}
+
+	if _, err := exec.LookPath("mkfs.btrfs"); err != nil {
+		t.Skip("mkfs.btrfs not available")
+	}
+
+	ctx := context.Background()
+
+	td, err := ioutil.TempDir("", "kopia-btrfs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(td) //nolint:errcheck
+
+	img := filepath.Join(td, "image")
+	mnt := filepath.Join(td, "mnt")
+
+	if err := os.Mkdir(mnt, 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ioutil.WriteFile(img, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Truncate(img, 128<<20); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := execCommand(ctx, "mkfs.btrfs", "-q", img); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := execCommand(ctx, "mount", "-o", "loop", img, mnt); err != nil {
+		t.Skipf("unable to mount loopback image: %v", err)
+	}
+	defer execCommand(ctx, "umount", mnt) //nolint:errcheck
+
+	src := filepath.Join(mnt, "dir")
+	if err := os.Mkdir(src, 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(src, "file"), []byte("original"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := NewDriver(DriverBtrfs, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := d.Create(ctx, src)
+	if err != nil {
+		t.Fatalf("unable to create snapshot: %v", err)
+	}
+
+	// changes after the snapshot was taken are not visible in the snapshot.
+	if err := ioutil.WriteFile(filepath.Join(src, "file"), []byte("modified"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := ioutil.ReadFile(filepath.Join(s.Path(), "file"))
+	if err != nil {
+		t.Fatalf("unable to read file from snapshot: %v", err)
+	}
+
+	if got, want := string(b), "original"; got != want {
+		t.Errorf("unexpected file contents in snapshot: %q, want %q", got, want)
+	}
+
+	if err := s.Release(ctx); err != nil {
+		t.Fatalf("unable to release snapshot: %v", err)
+	}
+}
@@ -0,0 +1,125 @@
+// +build linux
+
+package fssnapshot
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// lvmDriver creates LVM snapshots of the logical volume mounted at the path and mounts them read-only.
+type lvmDriver struct {
+	run  CommandRunner
+	size string
+}
+
+type lvmSnapshot struct {
+	run        CommandRunner
+	volume     string // vg/lv of the snapshot
+	mountPoint string
+	path       string
+}
+
+func (d *lvmDriver) Create(ctx context.Context, path string) (Snapshot, error) {
+	out, err := d.run(ctx, "findmnt", "--noheadings", "--output", "SOURCE,TARGET,FSTYPE", "--target", path)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to find mount point")
+	}
+
+	mnt := strings.Fields(out)
+	if len(mnt) != 3 {
+		return nil, errors.Errorf("unexpected output of findmnt: %q", out)
+	}
+
+	device, target, fsType := mnt[0], mnt[1], mnt[2]
+
+	rel, err := pathWithin(target, path)
+	if err != nil {
+		return nil, err
+	}
+
+	out, err = d.run(ctx, "lvs", "--noheadings", "--options", "vg_name,lv_name", device)
+	if err != nil {
+		return nil, errors.Wrapf(err, "%v is not a LVM logical volume", device)
+	}
+
+	lv := strings.Fields(out)
+	if len(lv) != 2 {
+		return nil, errors.Errorf("unexpected output of lvs: %q", out)
+	}
+
+	name := snapshotName()
+
+	sizeFlag := "--size"
+	if strings.Contains(d.size, "%") {
+		sizeFlag = "--extents"
+	}
+
+	if _, err := d.run(ctx, "lvcreate", "--snapshot", "--name", name, sizeFlag, d.size, lv[0]+"/"+lv[1]); err != nil {
+		return nil, errors.Wrap(err, "unable to create LVM snapshot")
+	}
+
+	s := &lvmSnapshot{run: d.run, volume: lv[0] + "/" + name}
+
+	log.Infof("created LVM snapshot %v of %v", s.volume, device)
+
+	s.mountPoint, err = ioutil.TempDir("", name)
+	if err != nil {
+		return nil, s.releaseAfterError(errors.Wrap(err, "unable to create mount point"))
+	}
+
+	opts := "ro"
+	if fsType == "xfs" {
+		// snapshot has the same UUID as the origin, which XFS refuses to mount by default.
+		opts += ",nouuid"
+	}
+
+	if _, err := d.run(ctx, "mount", "-t", fsType, "-o", opts, "/dev/"+s.volume, s.mountPoint); err != nil {
+		os.Remove(s.mountPoint) //nolint:errcheck
+		s.mountPoint = ""
+
+		return nil, s.releaseAfterError(errors.Wrap(err, "unable to mount LVM snapshot"))
+	}
+
+	s.path = filepath.Join(s.mountPoint, rel)
+
+	return s, nil
+}
+
+// releaseAfterError removes the partially created snapshot even if the context of its creation was canceled.
+func (s *lvmSnapshot) releaseAfterError(err error) error {
+	if rerr := s.Release(context.Background()); rerr != nil {
+		log.Warningf("unable to release LVM snapshot: %v", rerr)
+	}
+
+	return err
+}
+
+func (s *lvmSnapshot) Path() string {
+	return s.path
+}
+
+func (s *lvmSnapshot) Release(ctx context.Context) error {
+	if s.mountPoint != "" {
+		if _, err := s.run(ctx, "umount", s.mountPoint); err != nil {
+			return errors.Wrap(err, "unable to unmount LVM snapshot")
+		}
+
+		if err := os.Remove(s.mountPoint); err != nil {
+			log.Warningf("unable to remove mount point: %v", err)
+		}
+	}
+
+	if _, err := s.run(ctx, "lvremove", "--force", s.volume); err != nil {
+		return errors.Wrap(err, "unable to remove LVM snapshot")
+	}
+
+	log.Infof("removed LVM snapshot %v", s.volume)
+
+	return nil
+}
@@ -0,0 +1,180 @@
+// Package hashcache implements a local cache of object IDs of files identified by their device and inode numbers.
+package hashcache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/internal/kopialogging"
+	"github.com/kopia/kopia/repo/object"
+	"github.com/kopia/kopia/snapshot"
+)
+
+var log = kopialogging.Logger("kopia/hashcache")
+
+// files changed less than this long before the cache was opened are not cached, because they could
+// be changed again without affecting their change time, which has limited resolution.
+const racyChangeInterval = time.Second
+
+type key struct {
+	device uint64
+	inode  uint64
+}
+
+type entry struct {
+	Device     uint64    `json:"dev"`
+	Inode      uint64    `json:"ino"`
+	Size       int64     `json:"size"`
+	ModTime    int64     `json:"mtime"`
+	ChangeTime int64     `json:"ctime"`
+	ObjectID   object.ID `json:"oid"`
+}
+
+type cacheFile struct {
+	Entries []*entry `json:"entries"`
+}
+
+// Cache maps files in the local filesystem to object IDs of their contents, which remain valid
+// as long as size, modification and change times of the files are unchanged.
+type Cache struct {
+	filename string
+	openTime time.Time
+
+	mu      sync.Mutex
+	entries map[key]*entry // entries loaded from the file
+	added   map[key]*entry // entries added since the file was loaded
+}
+
+// ForSource opens the cache of a given snapshot source stored in the provided cache directory.
+func ForSource(cacheDir string, si snapshot.SourceInfo) (*Cache, error) {
+	h := sha256.New()
+	h.Write([]byte(si.String())) //nolint:errcheck
+
+	return Open(filepath.Join(cacheDir, "hashcache", hex.EncodeToString(h.Sum(nil))[0:16]+".json"))
+}
+
+// Open opens the cache stored in the provided file, which does not have to exist.
+func Open(filename string) (*Cache, error) {
+	c := &Cache{
+		filename: filename,
+		openTime: time.Now(),
+		entries:  map[key]*entry{},
+		added:    map[key]*entry{},
+	}
+
+	b, err := ioutil.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read hash cache")
+	}
+
+	var cf cacheFile
+	if err := json.Unmarshal(b, &cf); err != nil {
+		log.Warningf("ignoring invalid hash cache %v: %v", filename, err)
+		return c, nil
+	}
+
+	for _, e := range cf.Entries {
+		c.entries[key{e.Device, e.Inode}] = e
+	}
+
+	return c, nil
+}
+
+// Get returns the object ID of the contents of the provided file if it has not changed since it was cached
+// or an empty ID otherwise.
+func (c *Cache) Get(e fs.Entry) object.ID {
+	li, ok := e.Sys().(*fs.LocalFilesystemInfo)
+	if !ok {
+		return ""
+	}
+
+	c.mu.Lock()
+	ce := c.entries[key{li.Device, li.Inode}]
+	c.mu.Unlock()
+
+	if ce == nil || ce.Size != e.Size() || ce.ModTime != e.ModTime().UnixNano() || ce.ChangeTime != li.ChangeTime.UnixNano() {
+		return ""
+	}
+
+	return ce.ObjectID
+}
+
+// Put records the object ID of the contents of the provided file.
+func (c *Cache) Put(e fs.Entry, oid object.ID) {
+	li, ok := e.Sys().(*fs.LocalFilesystemInfo)
+	if !ok {
+		return
+	}
+
+	if li.ChangeTime.After(c.openTime.Add(-racyChangeInterval)) {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.added[key{li.Device, li.Inode}] = &entry{
+		Device:     li.Device,
+		Inode:      li.Inode,
+		Size:       e.Size(),
+		ModTime:    e.ModTime().UnixNano(),
+		ChangeTime: li.ChangeTime.UnixNano(),
+		ObjectID:   oid,
+	}
+}
+
+// Save writes the cache to its file. When prune is true only entries added since the cache was last loaded
+// are written, which removes files which no longer exist, otherwise they are merged with the loaded ones.
+func (c *Cache) Save(prune bool) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !prune {
+		for k, e := range c.entries {
+			if c.added[k] == nil {
+				c.added[k] = e
+			}
+		}
+	}
+
+	var cf cacheFile
+	for _, e := range c.added {
+		cf.Entries = append(cf.Entries, e)
+	}
+
+	b, err := json.Marshal(cf)
+	if err != nil {
+		return errors.Wrap(err, "unable to encode hash cache")
+	}
+
+	if err := os.MkdirAll(filepath.Dir(c.filename), 0700); err != nil {
+		return errors.Wrap(err, "unable to create hash cache directory")
+	}
+
+	tmpFile := c.filename + ".tmp"
+	if err := ioutil.WriteFile(tmpFile, b, 0600); err != nil {
+		return errors.Wrap(err, "unable to write hash cache")
+	}
+
+	if err := os.Rename(tmpFile, c.filename); err != nil {
+		return errors.Wrap(err, "unable to replace hash cache")
+	}
+
+	c.entries = c.added
+	c.added = map[key]*entry{}
+
+	return nil
+}
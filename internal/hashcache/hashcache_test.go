@@ -0,0 +1,144 @@
+package hashcache
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/repo/object"
+)
+
+type testEntry struct {
+	os.FileInfo // nil, only methods used by the cache are implemented
+
+	size    int64
+	modTime time.Time
+	sys     interface{}
+}
+
+func (e testEntry) Size() int64        { return e.size }
+func (e testEntry) ModTime() time.Time { return e.modTime }
+func (e testEntry) Sys() interface{}   { return e.sys }
+func (e testEntry) Owner() fs.OwnerInfo {
+	return fs.OwnerInfo{}
+}
+
+func newTestEntry(inode uint64, size int64, mtime, ctime time.Time) testEntry {
+	return testEntry{
+		size:    size,
+		modTime: mtime,
+		sys:     &fs.LocalFilesystemInfo{Device: 1, Inode: inode, ChangeTime: ctime},
+	}
+}
+
+func TestHashCache(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "hashcache")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	fname := filepath.Join(tmpDir, "subdir", "cache.json")
+
+	c, err := Open(fname)
+	if err != nil {
+		t.Fatalf("unable to open cache: %v", err)
+	}
+
+	t0 := time.Now().Add(-time.Hour)
+
+	f1 := newTestEntry(1, 100, t0, t0)
+	f2 := newTestEntry(2, 200, t0, t0)
+	racy := newTestEntry(3, 300, t0, time.Now())
+	noInfo := testEntry{size: 400, modTime: t0}
+
+	c.Put(f1, "oid1")
+	c.Put(f2, "oid2")
+	c.Put(racy, "oid3")
+	c.Put(noInfo, "oid4")
+
+	if err := c.Save(true); err != nil {
+		t.Fatalf("unable to save: %v", err)
+	}
+
+	c, err = Open(fname)
+	if err != nil {
+		t.Fatalf("unable to reopen cache: %v", err)
+	}
+
+	cases := []struct {
+		desc string
+		e    fs.Entry
+		want object.ID
+	}{
+		{"unchanged", f1, "oid1"},
+		{"unchanged", f2, "oid2"},
+		{"changed size", newTestEntry(1, 101, t0, t0), ""},
+		{"changed mtime", newTestEntry(1, 100, t0.Add(time.Second), t0), ""},
+		{"changed ctime", newTestEntry(1, 100, t0, t0.Add(time.Nanosecond)), ""},
+		{"racy change", racy, ""},
+		{"no local info", noInfo, ""},
+		{"unknown inode", newTestEntry(5, 100, t0, t0), ""},
+	}
+
+	for _, tc := range cases {
+		if got := c.Get(tc.e); got != tc.want {
+			t.Errorf("%v: got %q, want %q", tc.desc, got, tc.want)
+		}
+	}
+
+	// f2 is not put again, but it's kept unless pruning.
+	c.Put(f1, "oid1")
+
+	if err := c.Save(false); err != nil {
+		t.Fatalf("unable to save: %v", err)
+	}
+
+	if got, want := c.Get(f2), object.ID("oid2"); got != want {
+		t.Errorf("unexpected f2 after merging save: %q, want %q", got, want)
+	}
+
+	c.Put(f1, "oid1")
+
+	if err := c.Save(true); err != nil {
+		t.Fatalf("unable to save: %v", err)
+	}
+
+	c, err = Open(fname)
+	if err != nil {
+		t.Fatalf("unable to reopen cache: %v", err)
+	}
+
+	if got, want := c.Get(f1), object.ID("oid1"); got != want {
+		t.Errorf("unexpected f1 after pruning: %q, want %q", got, want)
+	}
+
+	if got := c.Get(f2); got != "" {
+		t.Errorf("unexpected f2 after pruning: %q", got)
+	}
+}
+
+func TestHashCacheInvalidFile(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "hashcache")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	fname := filepath.Join(tmpDir, "cache.json")
+	if err := ioutil.WriteFile(fname, []byte("not json"), 0600); err != nil {
+		t.Fatalf("unable to write file: %v", err)
+	}
+
+	c, err := Open(fname)
+	if err != nil {
+		t.Fatalf("invalid cache should be ignored, got: %v", err)
+	}
+
+	if got := c.Get(newTestEntry(1, 100, time.Time{}, time.Time{})); got != "" {
+		t.Errorf("unexpected entry: %q", got)
+	}
+}
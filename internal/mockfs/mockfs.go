@@ -34,6 +34,7 @@ type entry struct {
 	size    int64
 	modTime time.Time
 	owner   fs.OwnerInfo
+	sys     interface{}
 }
 
 func (e entry) Name() string {
@@ -57,7 +58,7 @@ func (e entry) Size() int64 {
 }
 
 func (e entry) Sys() interface{} {
-	return nil
+	return e.sys
 }
 
 func (e entry) Owner() fs.OwnerInfo {
@@ -192,6 +193,23 @@ func (imf *File) SetContents(b []byte) {
 
 }
 
+// SetSource changes the function which provides contents of the file each time it's opened.
+func (imf *File) SetSource(source func() (ReaderSeekerCloser, error)) {
+	imf.source = source
+}
+
+// FailOpen causes the subsequent Open() calls to fail with the specified error.
+func (imf *File) FailOpen(err error) {
+	imf.source = func() (ReaderSeekerCloser, error) {
+		return nil, err
+	}
+}
+
+// SetLocalFilesystemInfo sets the information returned by Sys(), which simulates a file in the local filesystem.
+func (imf *File) SetLocalFilesystemInfo(li *fs.LocalFilesystemInfo) {
+	imf.sys = li
+}
+
 type fileReader struct {
 	ReaderSeekerCloser
 	entry fs.Entry
@@ -2,6 +2,8 @@
 package retry
 
 import (
+	"context"
+	"math/rand"
 	"time"
 
 	"github.com/pkg/errors"
@@ -23,25 +25,97 @@ type AttemptFunc func() (interface{}, error)
 // IsRetriableFunc is a function that determines whether an error is retriable.
 type IsRetriableFunc func(err error) bool
 
-// WithExponentialBackoff runs the provided attempt until it succeeds, retrying on all errors that are
-// deemed retriable by the provided function. The delay between retries grows exponentially up to
-// a certain limit.
-func WithExponentialBackoff(desc string, attempt AttemptFunc, isRetriableError IsRetriableFunc) (interface{}, error) {
-	sleepAmount := retryInitialSleepAmount
-	for i := 0; i < maxAttempts; i++ {
+// Policy specifies how many times and how quickly operations are retried.
+type Policy struct {
+	MaxAttempts    int           `json:"maxAttempts,omitempty"`    // maximum number of attempts, 0 means no limit (requires MaxDuration)
+	InitialBackoff time.Duration `json:"initialBackoff,omitempty"` // delay after the first failed attempt
+	MaxBackoff     time.Duration `json:"maxBackoff,omitempty"`     // maximum delay between attempts
+	Jitter         float64       `json:"jitter,omitempty"`         // randomizes each delay by up to the given fraction (0..1)
+	MaxDuration    time.Duration `json:"maxDuration,omitempty"`    // no attempt is started after the given duration, 0 means no limit
+}
+
+// DefaultPolicy returns the default retry policy.
+func DefaultPolicy() Policy {
+	return Policy{
+		MaxAttempts:    maxAttempts,
+		InitialBackoff: retryInitialSleepAmount,
+		MaxBackoff:     retryMaxSleepAmount,
+	}
+}
+
+// Validate returns an error if the policy is invalid.
+func (p Policy) Validate() error {
+	if p.MaxAttempts < 0 || p.InitialBackoff < 0 || p.MaxBackoff < 0 || p.MaxDuration < 0 {
+		return errors.New("retry limits must not be negative")
+	}
+
+	if p.MaxAttempts == 0 && p.MaxDuration == 0 {
+		return errors.New("either maximum number of attempts or maximum duration must be specified")
+	}
+
+	if p.Jitter < 0 || p.Jitter > 1 {
+		return errors.Errorf("invalid jitter %v, must be between 0 and 1", p.Jitter)
+	}
+
+	return nil
+}
+
+// backoff returns the delay before the given attempt (1-based).
+func (p Policy) backoff(attempt int) time.Duration {
+	d := p.InitialBackoff
+	for i := 1; i < attempt && (p.MaxBackoff == 0 || d < p.MaxBackoff); i++ {
+		d *= 2
+	}
+
+	if p.MaxBackoff > 0 && d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+
+	if p.Jitter > 0 {
+		d += time.Duration((rand.Float64()*2 - 1) * p.Jitter * float64(d)) //nolint:gosec
+	}
+
+	return d
+}
+
+// Run runs the provided attempt until it succeeds, retrying on all errors that are deemed retriable by the provided
+// function, until the number of attempts or the total duration is exhausted or the context is canceled.
+// The delay between attempts grows exponentially up to a certain limit.
+func (p Policy) Run(ctx context.Context, desc string, attempt AttemptFunc, isRetriableError IsRetriableFunc) (interface{}, error) {
+	var deadline time.Time
+	if p.MaxDuration > 0 {
+		deadline = time.Now().Add(p.MaxDuration)
+	}
+
+	for i := 1; ; i++ {
 		v, err := attempt()
 		if !isRetriableError(err) {
 			return v, err
 		}
+
+		if p.MaxAttempts > 0 && i >= p.MaxAttempts {
+			return nil, errors.Wrapf(err, "unable to complete %v despite %v retries", desc, p.MaxAttempts)
+		}
+
+		sleepAmount := p.backoff(i)
+		if !deadline.IsZero() && time.Now().Add(sleepAmount).After(deadline) {
+			return nil, errors.Wrapf(err, "unable to complete %v within %v", desc, p.MaxDuration)
+		}
+
 		log.Debugf("got error %v when %v (#%v), sleeping for %v before retrying", err, desc, i, sleepAmount)
-		time.Sleep(sleepAmount)
-		sleepAmount *= 2
-		if sleepAmount > retryMaxSleepAmount {
-			sleepAmount = retryMaxSleepAmount
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(sleepAmount):
 		}
 	}
+}
 
-	return nil, errors.Errorf("unable to complete %v despite %v retries", desc, maxAttempts)
+// WithExponentialBackoff runs the provided attempt until it succeeds, retrying on all errors that are
+// deemed retriable by the provided function, using the default policy.
+func WithExponentialBackoff(desc string, attempt AttemptFunc, isRetriableError IsRetriableFunc) (interface{}, error) {
+	return DefaultPolicy().Run(context.Background(), desc, attempt, isRetriableError)
 }
 
 // WithExponentialBackoffNoValue is a shorthand for WithExponentialBackoff except the
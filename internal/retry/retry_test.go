@@ -1,6 +1,7 @@
 package retry
 
 import (
+	"context"
 	"testing"
 	"time"
 
@@ -50,9 +51,66 @@ func TestRetry(t *testing.T) {
 				t.Errorf("invalid error %q, wanted %q", err, tc.wantError)
 			}
 
+			if tc.wantError != nil && errors.Cause(err) != errRetriable {
+				t.Errorf("last error %q is not wrapped", err)
+			}
+
 			if got != tc.want {
 				t.Errorf("invalid value %v, wanted %v", got, tc.want)
 			}
 		})
 	}
 }
+
+func TestPolicy(t *testing.T) {
+	ctx := context.Background()
+	alwaysFails := func() (interface{}, error) { return nil, errRetriable }
+
+	// deadline is reached before the number of attempts is exhausted.
+	p := Policy{InitialBackoff: 10 * time.Millisecond, MaxBackoff: 10 * time.Millisecond, MaxDuration: 50 * time.Millisecond}
+	attempts := 0
+
+	t0 := time.Now()
+	_, err := p.Run(ctx, "deadline", func() (interface{}, error) {
+		attempts++
+		return alwaysFails()
+	}, isRetriable)
+
+	if errors.Cause(err) != errRetriable {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if dt := time.Since(t0); dt > time.Second || attempts < 2 {
+		t.Errorf("unexpected duration %v or number of attempts %v", dt, attempts)
+	}
+
+	// no delay after the final attempt.
+	ctx1, cancel1 := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel1()
+
+	p = Policy{MaxAttempts: 1, InitialBackoff: time.Hour}
+	if _, err = p.Run(ctx1, "single attempt", alwaysFails, isRetriable); errors.Cause(err) != errRetriable {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	// context cancellation interrupts waiting.
+	ctx2, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+
+	p = Policy{MaxAttempts: 3, InitialBackoff: time.Hour}
+	if _, err := p.Run(ctx2, "canceled", alwaysFails, isRetriable); err != context.DeadlineExceeded {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	// jitter stays within bounds.
+	p = Policy{MaxAttempts: 3, InitialBackoff: 100 * time.Millisecond, MaxBackoff: 300 * time.Millisecond, Jitter: 0.5}
+	for i := 0; i < 100; i++ {
+		if d := p.backoff(3); d < 150*time.Millisecond || d > 450*time.Millisecond {
+			t.Fatalf("backoff out of range: %v", d)
+		}
+	}
+
+	if err := (Policy{}).Validate(); err == nil {
+		t.Errorf("expected error for unbounded policy")
+	}
+}
@@ -12,3 +12,15 @@ type apiError struct {
 func internalServerError(err error) *apiError {
 	return &apiError{500, fmt.Sprintf("internal server error: %v", err)}
 }
+
+func notFoundError(message string) *apiError {
+	return &apiError{404, message}
+}
+
+func conflictError(message string) *apiError {
+	return &apiError{409, message}
+}
+
+func requestError(message string) *apiError {
+	return &apiError{400, message}
+}
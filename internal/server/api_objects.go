@@ -0,0 +1,150 @@
+package server
+
+import (
+	"compress/gzip"
+	"context"
+	"io"
+	"mime"
+	"net/http"
+	"time"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/internal/serverapi"
+	"github.com/kopia/kopia/repo/object"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/restore"
+	"github.com/kopia/kopia/snapshot/snapshotfs"
+)
+
+func (s *Server) handleDirectoryEntries(ctx context.Context, r *http.Request) (interface{}, *apiError) {
+	oid, err := object.ParseID(r.URL.Query().Get(":oid"))
+	if err != nil {
+		return nil, requestError("invalid object ID")
+	}
+
+	entries, err := snapshotfs.DirectoryEntry(s.rep, oid, nil).Readdir(ctx)
+	if err != nil {
+		return nil, internalServerError(err)
+	}
+
+	resp := &serverapi.DirectoryEntriesResponse{
+		Entries: []*snapshot.DirEntry{},
+	}
+
+	for _, e := range entries {
+		if de, ok := e.(snapshot.HasDirEntry); ok {
+			resp.Entries = append(resp.Entries, de.DirEntry())
+		}
+	}
+
+	return resp, nil
+}
+
+// handleObjectGet streams the contents of an object, supporting HTTP Range requests.
+// Optional 'fname' query parameter sets the file name used for Content-Type and Content-Disposition.
+func (s *Server) handleObjectGet(w http.ResponseWriter, r *http.Request) {
+	// reads are canceled when the client disconnects.
+	ctx := r.Context()
+
+	oid, err := object.ParseID(r.URL.Query().Get(":oid"))
+	if err != nil {
+		http.Error(w, "invalid object ID", http.StatusBadRequest)
+		return
+	}
+
+	var mtime time.Time
+	if v := r.URL.Query().Get("mtime"); v != "" {
+		if mtime, err = time.Parse(time.RFC3339Nano, v); err != nil {
+			http.Error(w, "invalid mtime", http.StatusBadRequest)
+			return
+		}
+	}
+
+	obj, err := s.rep.Objects.Open(ctx, oid)
+	if err == object.ErrObjectNotFound {
+		http.Error(w, "object not found", http.StatusNotFound)
+		return
+	}
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer obj.Close() //nolint:errcheck
+
+	fname := r.URL.Query().Get("fname")
+	if fname != "" {
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": fname}))
+	}
+
+	http.ServeContent(w, r, fname, mtime, obj)
+}
+
+// handleObjectArchive streams the contents of a directory object as a ZIP or TAR archive,
+// depending on the 'format' query parameter (zip, tar or tgz).
+func (s *Server) handleObjectArchive(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	oid, err := object.ParseID(r.URL.Query().Get(":oid"))
+	if err != nil {
+		http.Error(w, "invalid object ID", http.StatusBadRequest)
+		return
+	}
+
+	name := r.URL.Query().Get("name")
+	if name == "" {
+		name = oid.String()
+	}
+
+	var (
+		out         restore.Output
+		contentType string
+		closer      io.Closer
+	)
+
+	switch format := r.URL.Query().Get("format"); format {
+	case "", "zip":
+		name += ".zip"
+		contentType = "application/zip"
+		out = restore.NewZipOutput(w)
+
+	case "tar":
+		name += ".tar"
+		contentType = "application/x-tar"
+		out = restore.NewTarOutput(w)
+
+	case "tgz":
+		name += ".tar.gz"
+		contentType = "application/gzip"
+		gz := gzip.NewWriter(w)
+		closer = gz
+		out = restore.NewTarOutput(gz)
+
+	default:
+		http.Error(w, "unsupported archive format", http.StatusBadRequest)
+		return
+	}
+
+	var dir fs.Directory = snapshotfs.DirectoryEntry(s.rep, oid, nil)
+
+	// make sure the directory can be read before sending any headers.
+	if _, err := dir.Readdir(ctx); err != nil {
+		http.Error(w, "unable to read directory", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name}))
+
+	if _, err := restore.Entry(ctx, out, dir, restore.Options{}); err != nil {
+		// headers have already been sent, all we can do is log and abort the response.
+		log.Errorf("unable to stream archive of %v: %v", oid, err)
+		return
+	}
+
+	if closer != nil {
+		if err := closer.Close(); err != nil {
+			log.Errorf("unable to finish archive of %v: %v", oid, err)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package server
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/kopia/kopia/repo/object"
+)
+
+func TestObjectGet(t *testing.T) {
+	ctx := context.Background()
+	s := newTestServer(t)
+	defer s.close(t)
+
+	w := s.env.Repository.Objects.NewWriter(ctx, object.WriterOptions{})
+	if _, err := w.Write([]byte("hello world")); err != nil {
+		t.Fatalf("unable to write object: %v", err)
+	}
+
+	oid, err := w.Result()
+	if err != nil {
+		t.Fatalf("unable to write object: %v", err)
+	}
+
+	mtime := time.Date(2019, 10, 1, 12, 30, 0, 0, time.UTC)
+
+	cases := []struct {
+		desc       string
+		oid        string
+		query      url.Values
+		header     http.Header
+		wantStatus int
+		wantBody   string
+	}{
+		{"object", oid.String(), nil, nil, http.StatusOK, "hello world"},
+		{"object with mtime", oid.String(), url.Values{"mtime": {mtime.Format(time.RFC3339Nano)}}, nil, http.StatusOK, "hello world"},
+		{"range", oid.String(), nil, http.Header{"Range": {"bytes=6-"}}, http.StatusPartialContent, "world"},
+		{"invalid mtime", oid.String(), url.Values{"mtime": {"yesterday"}}, nil, http.StatusBadRequest, ""},
+		{"invalid object ID", "-invalid", nil, nil, http.StatusBadRequest, ""},
+		{"unknown object", "abcdef0123456789abcdef0123456789", nil, nil, http.StatusNotFound, ""},
+	}
+
+	for _, tc := range cases {
+		req, err := http.NewRequest(http.MethodGet, s.http.URL+"/api/v1/objects/"+tc.oid+"?"+tc.query.Encode(), nil)
+		if err != nil {
+			t.Fatalf("unable to create request: %v", err)
+		}
+
+		for k, v := range tc.header {
+			req.Header[k] = v
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%v: request failed: %v", tc.desc, err)
+		}
+
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close() //nolint:errcheck
+
+		if err != nil {
+			t.Fatalf("%v: unable to read response: %v", tc.desc, err)
+		}
+
+		if resp.StatusCode != tc.wantStatus {
+			t.Errorf("%v: unexpected status %v, want %v", tc.desc, resp.StatusCode, tc.wantStatus)
+			continue
+		}
+
+		if tc.wantBody != "" && string(body) != tc.wantBody {
+			t.Errorf("%v: unexpected body %q, want %q", tc.desc, body, tc.wantBody)
+		}
+
+		if tc.query.Get("mtime") != "" && resp.StatusCode == http.StatusOK {
+			if got, want := resp.Header.Get("Last-Modified"), mtime.Format(http.TimeFormat); got != want {
+				t.Errorf("%v: unexpected Last-Modified %q, want %q", tc.desc, got, want)
+			}
+		}
+	}
+}
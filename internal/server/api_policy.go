@@ -0,0 +1,168 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/serverapi"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/policy"
+)
+
+// policyTargetFromRequest parses the 'target' query parameter, which must be one of
+// 'global', '@host', 'user@host' or 'user@host:path'.
+func policyTargetFromRequest(r *http.Request) (snapshot.SourceInfo, *apiError) {
+	t := r.URL.Query().Get("target")
+	if t == "" {
+		return snapshot.SourceInfo{}, requestError("missing 'target' parameter")
+	}
+
+	if t == "global" || t == "(global)" {
+		return policy.GlobalPolicySourceInfo, nil
+	}
+
+	if !strings.Contains(t, "@") {
+		return snapshot.SourceInfo{}, requestError("target must be 'global', '@host', 'user@host' or 'user@host:path'")
+	}
+
+	si, err := snapshot.ParseSourceInfo(t, "", "")
+	if err != nil {
+		return snapshot.SourceInfo{}, requestError(err.Error())
+	}
+
+	return si, nil
+}
+
+func (s *Server) handlePolicyGet(ctx context.Context, r *http.Request) (interface{}, *apiError) {
+	target, apiErr := policyTargetFromRequest(r)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+
+	pol, err := policy.GetDefinedPolicy(ctx, s.rep, target)
+	if err == policy.ErrPolicyNotFound {
+		return nil, notFoundError("policy not found")
+	}
+
+	if err != nil {
+		return nil, internalServerError(err)
+	}
+
+	return pol, nil
+}
+
+func (s *Server) handlePolicyPut(ctx context.Context, r *http.Request) (interface{}, *apiError) {
+	target, apiErr := policyTargetFromRequest(r)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+
+	pol := &policy.Policy{}
+	if err := json.NewDecoder(r.Body).Decode(pol); err != nil {
+		return nil, requestError("unable to decode policy: " + err.Error())
+	}
+
+	if err := policy.ValidatePolicy(pol); err != nil {
+		return nil, requestError(err.Error())
+	}
+
+	normalizePolicy(pol)
+
+	if err := policy.SetPolicy(ctx, s.rep, target, pol); err != nil {
+		return nil, internalServerError(errors.Wrap(err, "unable to set policy"))
+	}
+
+	if err := s.rep.Flush(ctx); err != nil {
+		return nil, internalServerError(errors.Wrap(err, "unable to flush repository"))
+	}
+
+	s.refreshSourcesAffectedByPolicy(target)
+
+	return &serverapi.Empty{}, nil
+}
+
+// normalizePolicy sorts and removes duplicates from list fields, so that policies set through the API
+// are stored the same way as policies set with 'kopia policy set'.
+func normalizePolicy(pol *policy.Policy) {
+	pol.SchedulingPolicy.TimesOfDay = policy.SortAndDedupeTimesOfDay(pol.SchedulingPolicy.TimesOfDay)
+	pol.SchedulingPolicy.Cron = policy.SortAndDedupeStrings(pol.SchedulingPolicy.Cron)
+	pol.FilesPolicy.IgnoreRules = policy.SortAndDedupeStrings(pol.FilesPolicy.IgnoreRules)
+	pol.FilesPolicy.DotIgnoreFiles = policy.SortAndDedupeStrings(pol.FilesPolicy.DotIgnoreFiles)
+}
+
+func (s *Server) handlePolicyDelete(ctx context.Context, r *http.Request) (interface{}, *apiError) {
+	target, apiErr := policyTargetFromRequest(r)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+
+	if err := policy.RemovePolicy(ctx, s.rep, target); err != nil {
+		return nil, internalServerError(errors.Wrap(err, "unable to remove policy"))
+	}
+
+	if err := s.rep.Flush(ctx); err != nil {
+		return nil, internalServerError(errors.Wrap(err, "unable to flush repository"))
+	}
+
+	s.refreshSourcesAffectedByPolicy(target)
+
+	return &serverapi.Empty{}, nil
+}
+
+func (s *Server) handlePolicyEffective(ctx context.Context, r *http.Request) (interface{}, *apiError) {
+	target, apiErr := policyTargetFromRequest(r)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+
+	effective, policies, err := policy.GetEffectivePolicy(ctx, s.rep, target)
+	if err != nil {
+		return nil, internalServerError(err)
+	}
+
+	def, err := policy.DefinitionPoints(policies)
+	if err != nil {
+		return nil, internalServerError(err)
+	}
+
+	return &serverapi.EffectivePolicyResponse{
+		Target:     target,
+		Effective:  effective,
+		Definition: def,
+	}, nil
+}
+
+// refreshSourcesAffectedByPolicy triggers immediate refresh of all source managers whose
+// effective policy may depend on the policy defined for a given target.
+// Must be called with s.mu held.
+func (s *Server) refreshSourcesAffectedByPolicy(target snapshot.SourceInfo) {
+	for src, sm := range s.sourceManagers {
+		if policyAffectsSource(target, src) {
+			sm.requestRefresh()
+		}
+	}
+}
+
+func policyAffectsSource(target, src snapshot.SourceInfo) bool {
+	if target.Host != "" && target.Host != src.Host {
+		return false
+	}
+
+	if target.UserName != "" && target.UserName != src.UserName {
+		return false
+	}
+
+	if target.Path == "" {
+		return true
+	}
+
+	// policies on parent directories are inherited and policies on subdirectories
+	// affect files included in the snapshot.
+	return target.Path == src.Path ||
+		strings.HasPrefix(src.Path, strings.TrimSuffix(target.Path, "/")+"/") ||
+		strings.HasPrefix(target.Path, strings.TrimSuffix(src.Path, "/")+"/")
+}
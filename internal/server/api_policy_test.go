@@ -0,0 +1,78 @@
+package server
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/kopia/kopia/fs/ignorefs"
+	"github.com/kopia/kopia/internal/serverapi"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/policy"
+)
+
+func TestPolicyPutNormalizesPolicy(t *testing.T) {
+	s := newTestServer(t)
+	defer s.close(t)
+
+	target := "user@host:/some/path"
+
+	pol := &policy.Policy{
+		FilesPolicy: ignorefs.FilesPolicy{
+			IgnoreRules: []string{"*.tmp", "*.bak", "*.tmp"},
+		},
+		SchedulingPolicy: policy.SchedulingPolicy{
+			TimesOfDay: []policy.TimeOfDay{{Hour: 20}, {Hour: 8, Minute: 30}},
+			Cron:       []string{"0 1 * * *", "0 0 * * *", "0 1 * * *"},
+		},
+	}
+
+	if err := s.client.Put("policy?target="+target, pol, &serverapi.Empty{}); err != nil {
+		t.Fatalf("unable to set policy: %v", err)
+	}
+
+	var got policy.Policy
+	if err := s.client.Get("policy?target="+target, &got); err != nil {
+		t.Fatalf("unable to get policy: %v", err)
+	}
+
+	if want := []string{"*.bak", "*.tmp"}; !reflect.DeepEqual(got.FilesPolicy.IgnoreRules, want) {
+		t.Errorf("unexpected ignore rules: %v, want %v", got.FilesPolicy.IgnoreRules, want)
+	}
+
+	if want := []policy.TimeOfDay{{Hour: 8, Minute: 30}, {Hour: 20}}; !reflect.DeepEqual(got.SchedulingPolicy.TimesOfDay, want) {
+		t.Errorf("unexpected times of day: %v, want %v", got.SchedulingPolicy.TimesOfDay, want)
+	}
+
+	if want := []string{"0 0 * * *", "0 1 * * *"}; !reflect.DeepEqual(got.SchedulingPolicy.Cron, want) {
+		t.Errorf("unexpected cron: %v, want %v", got.SchedulingPolicy.Cron, want)
+	}
+}
+
+func TestPolicyEffectiveDefinition(t *testing.T) {
+	s := newTestServer(t)
+	defer s.close(t)
+
+	global := &policy.Policy{SchedulingPolicy: policy.SchedulingPolicy{TimesOfDay: []policy.TimeOfDay{{Hour: 20}}}}
+	if err := s.client.Put("policy?target=global", global, &serverapi.Empty{}); err != nil {
+		t.Fatalf("unable to set policy: %v", err)
+	}
+
+	dir := &policy.Policy{SchedulingPolicy: policy.SchedulingPolicy{TimesOfDay: []policy.TimeOfDay{{Hour: 8}}}}
+	if err := s.client.Put("policy?target=user@host:/some/path", dir, &serverapi.Empty{}); err != nil {
+		t.Fatalf("unable to set policy: %v", err)
+	}
+
+	var resp serverapi.EffectivePolicyResponse
+	if err := s.client.Get("policy/effective?target=user@host:/some/path", &resp); err != nil {
+		t.Fatalf("unable to get effective policy: %v", err)
+	}
+
+	want := []snapshot.SourceInfo{
+		{Host: "host", UserName: "user", Path: "/some/path"},
+		policy.GlobalPolicySourceInfo,
+	}
+
+	if got := resp.Definition["scheduling.timeOfDay"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected definition of times of day: %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,97 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/internal/serverapi"
+	"github.com/kopia/kopia/repo/object"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/restore"
+	"github.com/kopia/kopia/snapshot/snapshotfs"
+)
+
+func (s *Server) handleRestore(ctx context.Context, r *http.Request) (interface{}, *apiError) {
+	var req serverapi.RestoreRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return nil, requestError("malformed request body")
+	}
+
+	if req.TargetPath == "" || !filepath.IsAbs(req.TargetPath) {
+		return nil, requestError("target path must be absolute")
+	}
+
+	root, err := s.restoreRoot(ctx, &req)
+	if err != nil {
+		return nil, requestError(err.Error())
+	}
+
+	out := &restore.FilesystemOutput{
+		TargetPath:           req.TargetPath,
+		OverwriteFiles:       req.OverwriteFiles,
+		OverwriteDirectories: req.OverwriteDirectories,
+	}
+
+	t := s.startTask("restore", "restore to "+req.TargetPath, func(ctx context.Context, t *task) error {
+		_, err := restore.Entry(ctx, out, root, restore.Options{
+			ProgressCallback: func(stats restore.Stats) {
+				t.update(func(info *serverapi.TaskInfo) {
+					info.Restore = &stats
+				})
+			},
+		})
+
+		return err
+	})
+
+	if d, ok := root.(fs.Directory); ok {
+		if summ := d.Summary(); summ != nil {
+			t.update(func(info *serverapi.TaskInfo) {
+				info.Total = summ
+			})
+		}
+	}
+
+	return t.Info(), nil
+}
+
+// restoreRoot returns the entry to be restored for a given restore request.
+func (s *Server) restoreRoot(ctx context.Context, req *serverapi.RestoreRequest) (fs.Entry, error) {
+	var root fs.Entry
+
+	switch {
+	case req.SnapshotID != "" && req.ObjectID != "":
+		return nil, errors.New("snapshotID and objectID are mutually exclusive")
+
+	case req.SnapshotID != "":
+		man, err := snapshot.LoadSnapshot(ctx, s.rep, req.SnapshotID)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to load snapshot")
+		}
+
+		root, err = snapshotfs.SnapshotRoot(s.rep, man)
+		if err != nil {
+			return nil, err
+		}
+
+	case req.ObjectID != "":
+		oid, err := object.ParseID(req.ObjectID)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid object ID")
+		}
+
+		root = snapshotfs.DirectoryEntry(s.rep, oid, nil)
+
+	default:
+		return nil, errors.New("either snapshotID or objectID must be provided")
+	}
+
+	return snapshotfs.GetNestedEntry(ctx, root, strings.Split(req.Path, "/"))
+}
@@ -5,30 +5,12 @@ import (
 	"net/http"
 	"net/url"
 	"strings"
-	"time"
 
-	"github.com/kopia/kopia/fs"
-	"github.com/kopia/kopia/repo/manifest"
+	"github.com/kopia/kopia/internal/serverapi"
 	"github.com/kopia/kopia/snapshot"
 	"github.com/kopia/kopia/snapshot/policy"
 )
 
-type snapshotListEntry struct {
-	ID               manifest.ID          `json:"id"`
-	Source           snapshot.SourceInfo  `json:"source"`
-	Description      string               `json:"description"`
-	StartTime        time.Time            `json:"startTime"`
-	EndTime          time.Time            `json:"endTime"`
-	IncompleteReason string               `json:"incomplete,omitempty"`
-	Summary          *fs.DirectorySummary `json:"summary"`
-	RootEntry        string               `json:"rootID"`
-	RetentionReasons []string             `json:"retention"`
-}
-
-type snapshotListResponse struct {
-	Snapshots []*snapshotListEntry `json:"snapshots"`
-}
-
 func (s *Server) handleSourceSnapshotList(ctx context.Context, r *http.Request) (interface{}, *apiError) {
 	manifestIDs, err := snapshot.ListSnapshotManifests(ctx, s.rep, nil)
 	if err != nil {
@@ -40,8 +22,8 @@ func (s *Server) handleSourceSnapshotList(ctx context.Context, r *http.Request)
 		return nil, internalServerError(err)
 	}
 
-	resp := &snapshotListResponse{
-		Snapshots: []*snapshotListEntry{},
+	resp := &serverapi.SnapshotsResponse{
+		Snapshots: []*serverapi.Snapshot{},
 	}
 	groups := snapshot.GroupBySource(manifests)
 	for _, grp := range groups {
@@ -56,7 +38,7 @@ func (s *Server) handleSourceSnapshotList(ctx context.Context, r *http.Request)
 		}
 
 		for _, m := range grp {
-			resp.Snapshots = append(resp.Snapshots, convertSnapshotManifest(m))
+			resp.Snapshots = append(resp.Snapshots, serverapi.NewSnapshot(m))
 		}
 	}
 
@@ -76,22 +58,3 @@ func sourceMatchesURLFilter(src snapshot.SourceInfo, query url.Values) bool {
 
 	return true
 }
-
-func convertSnapshotManifest(m *snapshot.Manifest) *snapshotListEntry {
-	e := &snapshotListEntry{
-		ID:               m.ID,
-		Source:           m.Source,
-		Description:      m.Description,
-		StartTime:        m.StartTime,
-		EndTime:          m.EndTime,
-		IncompleteReason: m.IncompleteReason,
-		RootEntry:        m.RootObjectID().String(),
-		RetentionReasons: m.RetentionReasons,
-	}
-
-	if re := m.RootEntry; re != nil {
-		e.Summary = re.DirSummary
-	}
-
-	return e
-}
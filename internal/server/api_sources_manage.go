@@ -0,0 +1,149 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/serverapi"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/policy"
+)
+
+func (s *Server) handleSourcesAdd(ctx context.Context, r *http.Request) (interface{}, *apiError) {
+	var req serverapi.AddSourceRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return nil, requestError("malformed request body")
+	}
+
+	if !filepath.IsAbs(req.Path) {
+		return nil, requestError("source path must be absolute")
+	}
+
+	st, err := os.Stat(req.Path)
+	if err != nil {
+		return nil, requestError("unable to access source path: " + err.Error())
+	}
+
+	if !st.IsDir() {
+		return nil, requestError("source path must be a directory")
+	}
+
+	src := snapshot.SourceInfo{
+		Host:     s.hostname,
+		UserName: s.username,
+		Path:     filepath.Clean(req.Path),
+	}
+
+	if s.sourceManagers[src] != nil {
+		return nil, conflictError("source already exists")
+	}
+
+	if req.Policy != nil {
+		if err := policy.ValidatePolicy(req.Policy); err != nil {
+			return nil, requestError(err.Error())
+		}
+
+		normalizePolicy(req.Policy)
+	}
+
+	if err := s.ensureSourcePolicy(ctx, src, req.Policy); err != nil {
+		return nil, internalServerError(err)
+	}
+
+	cfg, err := s.loadSourceConfig(ctx)
+	if err != nil {
+		return nil, internalServerError(err)
+	}
+
+	cfg.add(src)
+
+	if err := s.saveSourceConfig(ctx, cfg); err != nil {
+		return nil, internalServerError(err)
+	}
+
+	log.Infof("adding source %v", src)
+
+	sm := newSourceManager(src, s)
+	s.sourceManagers[src] = sm
+
+	go sm.run(context.Background())
+
+	if req.CreateSnapshot {
+		sm.upload()
+	}
+
+	return sm.Status(), nil
+}
+
+// ensureSourcePolicy sets the provided policy on a given source or, if not provided, creates an empty
+// policy unless one already exists.
+func (s *Server) ensureSourcePolicy(ctx context.Context, src snapshot.SourceInfo, pol *policy.Policy) error {
+	if pol == nil {
+		_, err := policy.GetDefinedPolicy(ctx, s.rep, src)
+		if err == nil {
+			return nil
+		}
+
+		if err != policy.ErrPolicyNotFound {
+			return errors.Wrap(err, "unable to get policy")
+		}
+
+		pol = &policy.Policy{}
+	}
+
+	if err := policy.SetPolicy(ctx, s.rep, src, pol); err != nil {
+		return errors.Wrap(err, "unable to set policy")
+	}
+
+	return nil
+}
+
+// handleSourcesRemove stops managing the source with a given 'path' and optionally
+// deletes its policy when 'deletePolicy' is 'true'. Existing snapshots are retained.
+func (s *Server) handleSourcesRemove(ctx context.Context, r *http.Request) (interface{}, *apiError) {
+	path := r.URL.Query().Get("path")
+	if !filepath.IsAbs(path) {
+		return nil, requestError("source path must be absolute")
+	}
+
+	src := snapshot.SourceInfo{
+		Host:     s.hostname,
+		UserName: s.username,
+		Path:     filepath.Clean(path),
+	}
+
+	sm := s.sourceManagers[src]
+	if sm == nil {
+		return nil, notFoundError("source not found")
+	}
+
+	cfg, err := s.loadSourceConfig(ctx)
+	if err != nil {
+		return nil, internalServerError(err)
+	}
+
+	cfg.remove(src)
+
+	if r.URL.Query().Get("deletePolicy") == "true" {
+		if err := policy.RemovePolicy(ctx, s.rep, src); err != nil {
+			return nil, internalServerError(errors.Wrap(err, "unable to remove policy"))
+		}
+	}
+
+	if err := s.saveSourceConfig(ctx, cfg); err != nil {
+		return nil, internalServerError(err)
+	}
+
+	log.Infof("removing source %v", src)
+
+	sm.stop()
+	delete(s.sourceManagers, src)
+
+	return &serverapi.Empty{}, nil
+}
@@ -0,0 +1,225 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/kopia/kopia/internal/serverapi"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/policy"
+)
+
+// status sends the request to the test server and returns the HTTP status code of the response.
+func (s *testServer) status(t *testing.T, method, path string, payload interface{}) int {
+	var body bytes.Buffer
+
+	if payload != nil {
+		if err := json.NewEncoder(&body).Encode(payload); err != nil {
+			t.Fatalf("unable to encode request: %v", err)
+		}
+	}
+
+	req, err := http.NewRequest(method, s.http.URL+"/api/v1/"+path, &body)
+	if err != nil {
+		t.Fatalf("unable to create request: %v", err)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close() //nolint:errcheck
+
+	return resp.StatusCode
+}
+
+func (s *testServer) sources(t *testing.T) []snapshot.SourceInfo {
+	var resp serverapi.SourcesResponse
+	if err := s.client.Get("sources", &resp); err != nil {
+		t.Fatalf("unable to list sources: %v", err)
+	}
+
+	var result []snapshot.SourceInfo
+	for _, src := range resp.Sources {
+		result = append(result, src.Source)
+	}
+
+	return result
+}
+
+func TestSourcesAddRemove(t *testing.T) {
+	ctx := context.Background()
+	s := newTestServer(t)
+	defer s.close(t)
+
+	tmpDir, err := ioutil.TempDir("", "kopia-server-sources")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	dir1 := filepath.Join(tmpDir, "dir1")
+	dir2 := filepath.Join(tmpDir, "dir2")
+	dir3 := filepath.Join(tmpDir, "dir3")
+	file := filepath.Join(tmpDir, "file")
+
+	for _, d := range []string{dir1, dir2, dir3} {
+		if err := os.Mkdir(d, 0700); err != nil {
+			t.Fatalf("unable to create directory: %v", err)
+		}
+	}
+
+	if err := ioutil.WriteFile(file, []byte("hello"), 0600); err != nil {
+		t.Fatalf("unable to write file: %v", err)
+	}
+
+	src1 := snapshot.SourceInfo{Host: testHostname, UserName: testUsername, Path: dir1}
+	src2 := snapshot.SourceInfo{Host: testHostname, UserName: testUsername, Path: dir2}
+
+	var added serverapi.SourceStatus
+	if err := s.client.Post("sources", &serverapi.AddSourceRequest{Path: dir1}, &added); err != nil {
+		t.Fatalf("unable to add source: %v", err)
+	}
+
+	if added.Source != src1 {
+		t.Errorf("unexpected source added: %v, want %v", added.Source, src1)
+	}
+
+	keepLatest := 5
+	if err := s.client.Post("sources", &serverapi.AddSourceRequest{
+		Path:   dir2 + "/",
+		Policy: &policy.Policy{RetentionPolicy: policy.RetentionPolicy{KeepLatest: &keepLatest}},
+	}, &added); err != nil {
+		t.Fatalf("unable to add source: %v", err)
+	}
+
+	if added.Source != src2 {
+		t.Errorf("unexpected source added: %v, want %v", added.Source, src2)
+	}
+
+	pol, err := policy.GetDefinedPolicy(ctx, s.env.Repository, src2)
+	if err != nil || pol.RetentionPolicy.KeepLatest == nil || *pol.RetentionPolicy.KeepLatest != keepLatest {
+		t.Errorf("policy of the added source was not set: %v %v", pol, err)
+	}
+
+	invalidAdds := []struct {
+		desc       string
+		path       string
+		wantStatus int
+	}{
+		{"duplicate source", dir1, http.StatusConflict},
+		{"duplicate source with different spelling", dir1 + "/../dir1", http.StatusConflict},
+		{"relative path", "dir1", http.StatusBadRequest},
+		{"nonexistent path", filepath.Join(tmpDir, "no-such-dir"), http.StatusBadRequest},
+		{"file", file, http.StatusBadRequest},
+	}
+
+	for _, tc := range invalidAdds {
+		if got := s.status(t, http.MethodPost, "sources", &serverapi.AddSourceRequest{Path: tc.path}); got != tc.wantStatus {
+			t.Errorf("%v: unexpected status %v, want %v", tc.desc, got, tc.wantStatus)
+		}
+	}
+
+	if got := s.status(t, http.MethodPost, "sources", &serverapi.AddSourceRequest{
+		Path:   dir3,
+		Policy: &policy.Policy{SchedulingPolicy: policy.SchedulingPolicy{TimesOfDay: []policy.TimeOfDay{{Hour: 25}}}},
+	}); got != http.StatusBadRequest {
+		t.Errorf("invalid policy: unexpected status %v", got)
+	}
+
+	if got, want := s.sources(t), []snapshot.SourceInfo{src1, src2}; !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected sources: %v, want %v", got, want)
+	}
+
+	// added sources are persisted in the repository.
+	s.restart(t)
+
+	if got, want := s.sources(t), []snapshot.SourceInfo{src1, src2}; !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected sources after restart: %v, want %v", got, want)
+	}
+
+	if err := s.client.Delete("sources?path="+url.QueryEscape(dir1), &serverapi.Empty{}); err != nil {
+		t.Fatalf("unable to remove source: %v", err)
+	}
+
+	if err := s.client.Delete("sources?deletePolicy=true&path="+url.QueryEscape(dir2), &serverapi.Empty{}); err != nil {
+		t.Fatalf("unable to remove source: %v", err)
+	}
+
+	if _, err := policy.GetDefinedPolicy(ctx, s.env.Repository, src2); err != policy.ErrPolicyNotFound {
+		t.Errorf("policy of the removed source was not deleted: %v", err)
+	}
+
+	for _, p := range []string{dir1, filepath.Join(tmpDir, "no-such-dir")} {
+		if got, want := s.status(t, http.MethodDelete, "sources?path="+url.QueryEscape(p), nil), http.StatusNotFound; got != want {
+			t.Errorf("removing unknown source %v: unexpected status %v, want %v", p, got, want)
+		}
+	}
+
+	if got, want := s.status(t, http.MethodDelete, "sources?path=dir1", nil), http.StatusBadRequest; got != want {
+		t.Errorf("removing relative path: unexpected status %v, want %v", got, want)
+	}
+
+	if got := s.sources(t); len(got) != 0 {
+		t.Errorf("unexpected sources after removal: %v", got)
+	}
+
+	// removed sources are persisted in the repository.
+	s.restart(t)
+
+	if got := s.sources(t); len(got) != 0 {
+		t.Errorf("unexpected sources after removal and restart: %v", got)
+	}
+}
+
+func TestSourcesRemoveSourceWithSnapshots(t *testing.T) {
+	ctx := context.Background()
+	s := newTestServer(t)
+	defer s.close(t)
+
+	src := snapshot.SourceInfo{Host: testHostname, UserName: testUsername, Path: "/some/path"}
+
+	if _, err := snapshot.SaveSnapshot(ctx, s.env.Repository, &snapshot.Manifest{
+		Source:    src,
+		StartTime: time.Now(),
+		EndTime:   time.Now(),
+	}); err != nil {
+		t.Fatalf("unable to save snapshot: %v", err)
+	}
+
+	if err := s.env.Repository.Flush(ctx); err != nil {
+		t.Fatalf("unable to flush: %v", err)
+	}
+
+	// sources with snapshots are discovered on startup.
+	s.restart(t)
+
+	if got, want := s.sources(t), []snapshot.SourceInfo{src}; !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected sources: %v, want %v", got, want)
+	}
+
+	if err := s.client.Delete("sources?path=/some/path", &serverapi.Empty{}); err != nil {
+		t.Fatalf("unable to remove source: %v", err)
+	}
+
+	// snapshots are retained, but the source is no longer managed after restart.
+	s.restart(t)
+
+	if got := s.sources(t); len(got) != 0 {
+		t.Errorf("unexpected sources after removal and restart: %v", got)
+	}
+
+	snapshots, err := snapshot.ListSnapshots(ctx, s.env.Repository, src)
+	if err != nil || len(snapshots) != 1 {
+		t.Errorf("unexpected snapshots of removed source: %v %v", len(snapshots), err)
+	}
+}
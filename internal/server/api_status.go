@@ -8,14 +8,5 @@ import (
 )
 
 func (s *Server) handleStatus(ctx context.Context, r *http.Request) (interface{}, *apiError) {
-	bf := s.rep.Content.Format
-	bf.HMACSecret = nil
-	bf.MasterKey = nil
-
-	return &serverapi.StatusResponse{
-		ConfigFile:      s.rep.ConfigFile,
-		CacheDir:        s.rep.Content.CachingOptions.CacheDirectory,
-		BlockFormatting: bf,
-		Storage:         s.rep.Blobs.ConnectionInfo().Type,
-	}, nil
+	return serverapi.NewStatusResponse(s.rep), nil
 }
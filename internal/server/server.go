@@ -18,14 +18,31 @@ import (
 
 var log = kopialogging.Logger("kopia/server")
 
+// Options provides options for the Server.
+type Options struct {
+	// WatchChanges enables recording changed directories of local sources between snapshots,
+	// which allows uploads to skip directories that have not changed.
+	WatchChanges bool
+
+	// ParallelDirectories is the number of directories traversed in parallel, 0 uses the number of CPUs.
+	// Uploads are performed one at a time, so this is the limit for the entire server.
+	ParallelDirectories int
+}
+
 // Server exposes simple HTTP API for programmatically accessing Kopia features.
 type Server struct {
 	hostname        string
 	username        string
+	options         Options
 	rep             *repo.Repository
 	mu              sync.RWMutex
 	sourceManagers  map[snapshot.SourceInfo]*sourceManager
 	uploadSemaphore chan struct{}
+
+	tasks      map[string]*task
+	nextTaskID int
+
+	uploadEvents *uploadEventHub
 }
 
 // APIHandlers handles API requests.
@@ -33,14 +50,28 @@ func (s *Server) APIHandlers() http.Handler {
 	p := pat.New()
 	p.Get("/api/v1/status", s.handleAPI(s.handleStatus))
 	p.Get("/api/v1/sources", s.handleAPI(s.handleSourcesList))
+	p.Post("/api/v1/sources", s.handleAPI(s.handleSourcesAdd))
+	p.Del("/api/v1/sources", s.handleAPI(s.handleSourcesRemove))
 	p.Get("/api/v1/snapshots", s.handleAPI(s.handleSourceSnapshotList))
 	p.Get("/api/v1/policies", s.handleAPI(s.handlePolicyList))
+	p.Get("/api/v1/policy/effective", s.handleAPI(s.handlePolicyEffective))
+	p.Get("/api/v1/policy", s.handleAPI(s.handlePolicyGet))
+	p.Put("/api/v1/policy", s.handleAPI(s.handlePolicyPut))
+	p.Del("/api/v1/policy", s.handleAPI(s.handlePolicyDelete))
 	p.Post("/api/v1/refresh", s.handleAPI(s.handleRefresh))
 	p.Post("/api/v1/flush", s.handleAPI(s.handleFlush))
 	p.Post("/api/v1/sources/pause", s.handleAPI(s.handlePause))
 	p.Post("/api/v1/sources/resume", s.handleAPI(s.handleResume))
 	p.Post("/api/v1/sources/upload", s.handleAPI(s.handleUpload))
 	p.Post("/api/v1/sources/cancel", s.handleAPI(s.handleCancel))
+	p.Get("/api/v1/sources/events", http.HandlerFunc(s.handleSourceEvents))
+	p.Get("/api/v1/objects/:oid/entries", s.handleAPI(s.handleDirectoryEntries))
+	p.Get("/api/v1/objects/:oid/archive", http.HandlerFunc(s.handleObjectArchive))
+	p.Get("/api/v1/objects/:oid", http.HandlerFunc(s.handleObjectGet))
+	p.Post("/api/v1/restore", s.handleAPI(s.handleRestore))
+	p.Get("/api/v1/tasks", s.handleAPI(s.handleTaskList))
+	p.Get("/api/v1/tasks/:id", s.handleAPI(s.handleTaskInfo))
+	p.Post("/api/v1/tasks/:id/cancel", s.handleAPI(s.handleTaskCancel))
 	return p
 }
 
@@ -68,6 +99,11 @@ func (s *Server) handleAPI(f func(ctx context.Context, r *http.Request) (interfa
 
 func (s *Server) handleRefresh(ctx context.Context, r *http.Request) (interface{}, *apiError) {
 	log.Infof("refreshing")
+
+	for _, sm := range s.sourceManagers {
+		sm.requestRefresh()
+	}
+
 	return &serverapi.Empty{}, nil
 }
 
@@ -120,13 +156,16 @@ func (s *Server) endUpload(src snapshot.SourceInfo) {
 
 // New creates a Server on top of a given Repository.
 // The server will manage sources for a given username@hostname.
-func New(ctx context.Context, rep *repo.Repository, hostname, username string) (*Server, error) {
+func New(ctx context.Context, rep *repo.Repository, hostname, username string, opts Options) (*Server, error) {
 	s := &Server{
 		hostname:        hostname,
 		username:        username,
+		options:         opts,
 		rep:             rep,
 		sourceManagers:  map[snapshot.SourceInfo]*sourceManager{},
 		uploadSemaphore: make(chan struct{}, 1),
+		tasks:           map[string]*task{},
+		uploadEvents:    newUploadEventHub(),
 	}
 
 	sources, err := snapshot.ListSources(ctx, rep)
@@ -134,9 +173,17 @@ func New(ctx context.Context, rep *repo.Repository, hostname, username string) (
 		return nil, errors.Wrap(err, "unable to list sources")
 	}
 
-	for _, src := range sources {
-		sm := newSourceManager(src, s)
-		s.sourceManagers[src] = sm
+	cfg, err := s.loadSourceConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, src := range append(sources, cfg.Added...) {
+		if cfg.isRemoved(src) {
+			continue
+		}
+
+		s.sourceManagers[src] = newSourceManager(src, s)
 	}
 
 	for _, src := range s.sourceManagers {
@@ -0,0 +1,68 @@
+package server
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/kopia/kopia/internal/repotesting"
+	"github.com/kopia/kopia/internal/serverapi"
+)
+
+const (
+	testHostname = "test-host"
+	testUsername = "test-user"
+)
+
+type testServer struct {
+	env    repotesting.Environment
+	server *Server
+	http   *httptest.Server
+	client *serverapi.Client
+}
+
+func newTestServer(t *testing.T) *testServer {
+	s := &testServer{}
+	s.env.Setup(t)
+	s.start(t)
+
+	return s
+}
+
+// start creates the server on top of the test repository, loading persisted configuration.
+func (s *testServer) start(t *testing.T) {
+	srv, err := New(context.Background(), s.env.Repository, testHostname, testUsername, Options{})
+	if err != nil {
+		t.Fatalf("unable to create server: %v", err)
+	}
+
+	s.server = srv
+	s.http = httptest.NewServer(srv.APIHandlers())
+
+	s.client, err = serverapi.NewClient(serverapi.ClientOptions{BaseURL: s.http.URL})
+	if err != nil {
+		t.Fatalf("unable to create client: %v", err)
+	}
+}
+
+func (s *testServer) stop() {
+	s.http.Close()
+
+	s.server.mu.Lock()
+	defer s.server.mu.Unlock()
+
+	for _, sm := range s.server.sourceManagers {
+		sm.stop()
+	}
+}
+
+// restart simulates server restart with the same repository.
+func (s *testServer) restart(t *testing.T) {
+	s.stop()
+	s.start(t)
+}
+
+func (s *testServer) close(t *testing.T) {
+	s.stop()
+	s.env.Close(t)
+}
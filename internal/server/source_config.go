@@ -0,0 +1,103 @@
+package server
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/snapshot"
+)
+
+// sourceConfig is persisted in the repository as a manifest and records sources explicitly
+// added or removed via the API for a given username@hostname, so that they survive server restarts.
+type sourceConfig struct {
+	Added   []snapshot.SourceInfo `json:"added,omitempty"`
+	Removed []snapshot.SourceInfo `json:"removed,omitempty"`
+}
+
+func (c *sourceConfig) add(src snapshot.SourceInfo) {
+	c.Removed = withoutSource(c.Removed, src)
+	c.Added = append(withoutSource(c.Added, src), src)
+}
+
+func (c *sourceConfig) remove(src snapshot.SourceInfo) {
+	c.Added = withoutSource(c.Added, src)
+	c.Removed = append(withoutSource(c.Removed, src), src)
+}
+
+func (c *sourceConfig) isRemoved(src snapshot.SourceInfo) bool {
+	for _, r := range c.Removed {
+		if r == src {
+			return true
+		}
+	}
+
+	return false
+}
+
+func withoutSource(sources []snapshot.SourceInfo, src snapshot.SourceInfo) []snapshot.SourceInfo {
+	var result []snapshot.SourceInfo
+
+	for _, s := range sources {
+		if s != src {
+			result = append(result, s)
+		}
+	}
+
+	return result
+}
+
+func (s *Server) sourceConfigLabels() map[string]string {
+	return map[string]string{
+		"type":     "serverSources",
+		"hostname": s.hostname,
+		"username": s.username,
+	}
+}
+
+// loadSourceConfig loads the persisted source configuration, returning empty configuration if not found.
+func (s *Server) loadSourceConfig(ctx context.Context) (*sourceConfig, error) {
+	entries, err := s.rep.Manifests.Find(ctx, s.sourceConfigLabels())
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to find source configuration")
+	}
+
+	cfg := &sourceConfig{}
+	if len(entries) == 0 {
+		return cfg, nil
+	}
+
+	// in case of concurrent writes, use the most recent configuration.
+	latest := entries[0]
+	for _, e := range entries {
+		if e.ModTime.After(latest.ModTime) {
+			latest = e
+		}
+	}
+
+	if err := s.rep.Manifests.Get(ctx, latest.ID, cfg); err != nil {
+		return nil, errors.Wrap(err, "unable to load source configuration")
+	}
+
+	return cfg, nil
+}
+
+// saveSourceConfig persists the source configuration, replacing any previous one and flushes the repository.
+func (s *Server) saveSourceConfig(ctx context.Context, cfg *sourceConfig) error {
+	entries, err := s.rep.Manifests.Find(ctx, s.sourceConfigLabels())
+	if err != nil {
+		return errors.Wrap(err, "unable to find source configuration")
+	}
+
+	if _, err := s.rep.Manifests.Put(ctx, s.sourceConfigLabels(), cfg); err != nil {
+		return errors.Wrap(err, "unable to save source configuration")
+	}
+
+	for _, e := range entries {
+		if err := s.rep.Manifests.Delete(ctx, e.ID); err != nil {
+			return errors.Wrap(err, "unable to delete previous source configuration")
+		}
+	}
+
+	return s.rep.Flush(ctx)
+}
@@ -6,6 +6,8 @@ import (
 	"time"
 
 	"github.com/kopia/kopia/fs/localfs"
+	"github.com/kopia/kopia/internal/changejournal"
+	"github.com/kopia/kopia/internal/hashcache"
 	"github.com/kopia/kopia/internal/serverapi"
 	"github.com/kopia/kopia/snapshot"
 	"github.com/kopia/kopia/snapshot/policy"
@@ -21,9 +23,11 @@ import (
 // - FAILED - inactive
 // - UPLOADING - uploading a snapshot
 type sourceManager struct {
-	server *Server
-	src    snapshot.SourceInfo
-	closed chan struct{}
+	server          *Server
+	src             snapshot.SourceInfo
+	closed          chan struct{}
+	refresh         chan struct{}
+	uploadRequested chan struct{}
 
 	mu                   sync.RWMutex
 	pol                  *policy.Policy
@@ -31,11 +35,17 @@ type sourceManager struct {
 	nextSnapshotTime     time.Time
 	lastCompleteSnapshot *snapshot.Manifest
 	lastSnapshot         *snapshot.Manifest
+	lastAttemptTime      time.Time
 
 	// state of current upload
+	uploader            *snapshotfs.Uploader
+	uploadEvents        *snapshotfs.UploadEventTracker
 	uploadPath          string
 	uploadPathCompleted int64
 	uploadPathTotal     int64
+
+	// watcher of changes of local source, nil if not enabled or not supported
+	watcher *changejournal.Watcher
 }
 
 func (s *sourceManager) Status() *serverapi.SourceStatus {
@@ -45,12 +55,15 @@ func (s *sourceManager) Status() *serverapi.SourceStatus {
 	st := &serverapi.SourceStatus{
 		Source:           s.src,
 		Status:           s.state,
-		LastSnapshotSize: s.lastSnapshot.Stats.TotalFileSize,
-		LastSnapshotTime: s.lastSnapshot.StartTime,
 		NextSnapshotTime: s.nextSnapshotTime,
 		Policy:           s.pol,
 	}
 
+	if s.lastSnapshot != nil {
+		st.LastSnapshotSize = s.lastSnapshot.Stats.TotalFileSize
+		st.LastSnapshotTime = s.lastSnapshot.StartTime
+	}
+
 	st.UploadStatus.UploadingPath = s.uploadPath
 	st.UploadStatus.UploadingPathCompleted = s.uploadPathCompleted
 	st.UploadStatus.UploadingPathTotal = s.uploadPathTotal
@@ -76,6 +89,16 @@ func (s *sourceManager) run(ctx context.Context) {
 }
 
 func (s *sourceManager) runLocal(ctx context.Context) {
+	if s.server.options.WatchChanges {
+		w, err := changejournal.NewWatcher(s.src.Path)
+		if err != nil {
+			log.Warningf("unable to watch changes of %v, all directories will be scanned: %v", s.src, err)
+		} else {
+			s.watcher = w
+			defer w.Close() //nolint:errcheck
+		}
+	}
+
 	s.refreshStatus(ctx)
 	for {
 		var timeBeforeNextSnapshot time.Duration
@@ -91,7 +114,18 @@ func (s *sourceManager) runLocal(ctx context.Context) {
 		case <-s.closed:
 			return
 
+		case <-s.refresh:
+			s.refreshStatus(ctx)
+
+		case <-s.uploadRequested:
+			log.Infof("snapshotting %v on request", s.src)
+			s.setStatus("SNAPSHOTTING")
+			s.snapshot(ctx)
+			s.refreshStatus(ctx)
+
 		case <-time.After(15 * time.Second):
+			// timers don't advance while the machine is asleep, periodic refresh recomputes
+			// the next snapshot time based on wall clock, which catches up on missed snapshots.
 			s.refreshStatus(ctx)
 
 		case <-time.After(timeBeforeNextSnapshot):
@@ -110,12 +144,24 @@ func (s *sourceManager) runRemote(ctx context.Context) {
 		select {
 		case <-s.closed:
 			return
+		case <-s.refresh:
+			s.refreshStatus(ctx)
 		case <-time.After(15 * time.Second):
 			s.refreshStatus(ctx)
 		}
 	}
 }
 
+// requestRefresh asks the source manager to reload its policy and snapshots
+// and recompute the next snapshot time as soon as possible.
+func (s *sourceManager) requestRefresh() {
+	select {
+	case s.refresh <- struct{}{}:
+	default:
+		// refresh already pending
+	}
+}
+
 func (s *sourceManager) Progress(path string, numFiles int, pathCompleted, pathTotal int64, stats *snapshot.Stats) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -124,6 +170,10 @@ func (s *sourceManager) Progress(path string, numFiles int, pathCompleted, pathT
 	s.uploadPathCompleted = pathCompleted
 	s.uploadPathTotal = pathTotal
 	log.Debugf("path: %v %v/%v", path, pathCompleted, pathTotal)
+
+	if s.uploadEvents != nil {
+		s.uploadEvents.Progress(path, numFiles, pathCompleted, pathTotal, stats)
+	}
 }
 
 func (s *sourceManager) UploadFinished() {
@@ -137,14 +187,40 @@ func (s *sourceManager) UploadFinished() {
 
 func (s *sourceManager) upload() serverapi.SourceActionResponse {
 	log.Infof("upload triggered via API: %v", s.src)
+
+	if s.server.hostname != s.src.Host {
+		return serverapi.SourceActionResponse{Success: false}
+	}
+
+	select {
+	case s.uploadRequested <- struct{}{}:
+	default:
+		// upload already requested
+	}
+
 	return serverapi.SourceActionResponse{Success: true}
 }
 
 func (s *sourceManager) cancel() serverapi.SourceActionResponse {
 	log.Infof("cancel triggered via API: %v", s.src)
+
+	s.mu.RLock()
+	u := s.uploader
+	s.mu.RUnlock()
+
+	if u != nil {
+		u.Cancel()
+	}
+
 	return serverapi.SourceActionResponse{Success: true}
 }
 
+// stop cancels any upload in progress and terminates the source manager.
+func (s *sourceManager) stop() {
+	s.cancel()
+	close(s.closed)
+}
+
 func (s *sourceManager) pause() serverapi.SourceActionResponse {
 	log.Infof("pause triggered via API: %v", s.src)
 	return serverapi.SourceActionResponse{Success: true}
@@ -159,6 +235,8 @@ func (s *sourceManager) snapshot(ctx context.Context) {
 	s.server.beginUpload(s.src)
 	defer s.server.endUpload(s.src)
 
+	s.lastAttemptTime = time.Now()
+
 	localEntry, err := localfs.NewEntry(s.src.Path)
 	if err != nil {
 		log.Errorf("unable to create local filesystem: %v", err)
@@ -171,20 +249,80 @@ func (s *sourceManager) snapshot(ctx context.Context) {
 	}
 	u.FilesPolicy = polGetter
 	u.Progress = s
+	u.ParallelDirectories = s.server.options.ParallelDirectories
+
+	if s.pol != nil {
+		u.MaxFileErrors = s.pol.ErrorHandlingPolicy.FileErrorLimit()
+	}
+
+	changeDetection, err := policy.ChangeDetectionMap(ctx, s.server.rep, s.src)
+	if err != nil {
+		log.Errorf("unable to get change detection policy: %v", err)
+	}
+	u.ChangeDetection = changeDetection
+
+	if cacheDir := s.server.rep.Content.CachingOptions.CacheDirectory; cacheDir != "" && changeDetection.UsesHashCache() {
+		hc, hcErr := hashcache.ForSource(cacheDir, s.src)
+		if hcErr != nil {
+			log.Warningf("unable to open hash cache: %v", hcErr)
+		}
+		u.HashCache = hc
+	}
+
+	fingerprint, err := policy.UploadPolicyFingerprint(ctx, s.server.rep, s.src)
+	if err != nil {
+		log.Errorf("unable to compute policy fingerprint: %v", err)
+	}
+	u.PolicyFingerprint = fingerprint
+
+	if j, base := s.changeJournal(); j != nil {
+		u.ChangeJournal = j
+		u.ChangeJournalBase = base
+	}
+
+	s.server.rep.Content.ResetStats()
+	tracker := snapshotfs.NewUploadEventTracker(s.src, s.server.rep.Content.Stats, s.server.uploadEvents.publish)
+	if s.lastSnapshot != nil {
+		tracker.EstimatedBytes = s.lastSnapshot.Stats.TotalFileSize
+	}
+
+	s.mu.Lock()
+	s.uploader = u
+	s.uploadEvents = tracker
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		s.uploader = nil
+		s.uploadEvents = nil
+		s.mu.Unlock()
+	}()
 
 	log.Infof("starting upload of %v", s.src)
+	tracker.Started()
+
 	manifest, err := u.Upload(ctx, localEntry, s.src, s.lastCompleteSnapshot, s.lastSnapshot)
 	if err != nil {
 		log.Errorf("upload error: %v", err)
+		tracker.Failed(err)
 		return
 	}
 
 	snapshotID, err := snapshot.SaveSnapshot(ctx, s.server.rep, manifest)
 	if err != nil {
 		log.Errorf("unable to save snapshot: %v", err)
+		tracker.Failed(err)
 		return
 	}
 
+	if manifest.IncompleteReason == "" {
+		if err := snapshot.DeleteCheckpoints(ctx, s.server.rep, manifest); err != nil {
+			log.Errorf("unable to delete checkpoints: %v", err)
+		}
+	}
+
+	tracker.Finished(snapshotID, manifest)
+
 	if _, err := policy.ApplyRetentionPolicy(ctx, s.server.rep, s.src, true); err != nil {
 		log.Errorf("unable to apply retention policy: %v", err)
 		return
@@ -197,27 +335,44 @@ func (s *sourceManager) snapshot(ctx context.Context) {
 	}
 }
 
+// changeJournal starts a new journal of changes and returns the previous one, along with the snapshot
+// it applies to, if it recorded all changes made since the last snapshot was started.
+func (s *sourceManager) changeJournal() (*changejournal.Journal, *snapshot.Manifest) {
+	if s.watcher == nil {
+		return nil, nil
+	}
+
+	j := s.watcher.Rotate()
+	base := s.lastSnapshot
+
+	switch {
+	case base == nil || base.IncompleteReason != "":
+		log.Infof("no complete previous snapshot of %v, scanning all directories", s.src)
+	case base.StartTime.Before(j.StartTime):
+		log.Infof("changes of %v were not watched since the previous snapshot, scanning all directories", s.src)
+	case !j.IsValid():
+		log.Infof("some changes of %v were not recorded, scanning all directories", s.src)
+	default:
+		return j, base
+	}
+
+	return nil, nil
+}
+
 func (s *sourceManager) findClosestNextSnapshotTime() time.Time {
-	nextSnapshotTime := time.Now().Add(24 * time.Hour)
+	now := time.Now()
+	nextSnapshotTime := now.Add(24 * time.Hour)
+
 	if s.pol != nil {
-		// compute next snapshot time based on interval
-		if interval := s.pol.SchedulingPolicy.IntervalSeconds; interval != 0 {
-			interval := time.Duration(interval) * time.Second
-			nt := s.lastSnapshot.StartTime.Add(interval).Truncate(interval)
-			if nt.Before(nextSnapshotTime) {
-				nextSnapshotTime = nt
-			}
+		// failed snapshot attempts don't produce manifests, so also consider the last attempt
+		// to avoid retrying missed snapshots in a tight loop.
+		previousSnapshotTime := s.lastAttemptTime
+		if s.lastSnapshot != nil && s.lastSnapshot.StartTime.After(previousSnapshotTime) {
+			previousSnapshotTime = s.lastSnapshot.StartTime
 		}
 
-		for _, tod := range s.pol.SchedulingPolicy.TimesOfDay {
-			nowLocalTime := time.Now().Local()
-			localSnapshotTime := time.Date(nowLocalTime.Year(), nowLocalTime.Month(), nowLocalTime.Day(), tod.Hour, tod.Minute, 0, 0, time.Local)
-			if tod.Hour < nowLocalTime.Hour() || (tod.Hour == nowLocalTime.Hour() && tod.Minute < nowLocalTime.Minute()) {
-				localSnapshotTime = localSnapshotTime.Add(24 * time.Hour)
-			}
-			if localSnapshotTime.Before(nextSnapshotTime) {
-				nextSnapshotTime = localSnapshotTime
-			}
+		if nt, ok := s.pol.SchedulingPolicy.NextSnapshotTime(previousSnapshotTime, now); ok && nt.Before(nextSnapshotTime) {
+			nextSnapshotTime = nt
 		}
 	}
 
@@ -243,19 +398,21 @@ func (s *sourceManager) refreshStatus(ctx context.Context) {
 	snaps := snapshot.SortByTime(snapshots, true)
 	if len(snaps) > 0 {
 		s.lastSnapshot = snaps[0]
-		s.nextSnapshotTime = s.findClosestNextSnapshotTime()
 	} else {
-		s.nextSnapshotTime = time.Time{}
 		s.lastSnapshot = nil
 	}
+
+	s.nextSnapshotTime = s.findClosestNextSnapshotTime()
 }
 
 func newSourceManager(src snapshot.SourceInfo, server *Server) *sourceManager {
 	m := &sourceManager{
-		src:    src,
-		server: server,
-		state:  "UNKNOWN",
-		closed: make(chan struct{}),
+		src:             src,
+		server:          server,
+		state:           "UNKNOWN",
+		closed:          make(chan struct{}),
+		refresh:         make(chan struct{}, 1),
+		uploadRequested: make(chan struct{}, 1),
 	}
 
 	return m
@@ -0,0 +1,149 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/serverapi"
+)
+
+// maxFinishedTasks is the number of finished tasks whose status is retained.
+const maxFinishedTasks = 100
+
+// task tracks the state of a single long-running operation started via the API.
+type task struct {
+	cancel context.CancelFunc
+
+	mu   sync.Mutex
+	info serverapi.TaskInfo
+}
+
+func (t *task) Info() serverapi.TaskInfo {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.info
+}
+
+// update invokes the provided function with the task info locked.
+func (t *task) update(f func(info *serverapi.TaskInfo)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	f(&t.info)
+}
+
+func (t *task) finish(err error) {
+	t.update(func(info *serverapi.TaskInfo) {
+		now := time.Now()
+		info.EndTime = &now
+
+		switch {
+		case err == nil:
+			info.Status = serverapi.TaskStatusSuccess
+		case errors.Cause(err) == context.Canceled:
+			info.Status = serverapi.TaskStatusCanceled
+		default:
+			info.Status = serverapi.TaskStatusFailed
+			info.Error = err.Error()
+		}
+	})
+}
+
+// startTask starts the provided function in a goroutine and tracks it as a task.
+// Must be called with s.mu held.
+func (s *Server) startTask(kind, description string, run func(ctx context.Context, t *task) error) *task {
+	s.nextTaskID++
+	ctx, cancel := context.WithCancel(context.Background())
+
+	t := &task{
+		cancel: cancel,
+		info: serverapi.TaskInfo{
+			ID:          strconv.Itoa(s.nextTaskID),
+			Kind:        kind,
+			Description: description,
+			Status:      serverapi.TaskStatusRunning,
+			StartTime:   time.Now(),
+		},
+	}
+
+	s.pruneFinishedTasks()
+	s.tasks[t.info.ID] = t
+
+	go func() {
+		defer cancel()
+
+		log.Infof("starting task %v: %v", t.info.ID, description)
+		err := run(ctx, t)
+		t.finish(err)
+		log.Infof("finished task %v: %v", t.info.ID, err)
+	}()
+
+	return t
+}
+
+// pruneFinishedTasks removes the oldest finished tasks so that at most maxFinishedTasks remain.
+// Must be called with s.mu held.
+func (s *Server) pruneFinishedTasks() {
+	var finished []serverapi.TaskInfo
+
+	for _, t := range s.tasks {
+		if ti := t.Info(); ti.EndTime != nil {
+			finished = append(finished, ti)
+		}
+	}
+
+	if len(finished) <= maxFinishedTasks {
+		return
+	}
+
+	sort.Slice(finished, func(i, j int) bool {
+		return finished[i].EndTime.Before(*finished[j].EndTime)
+	})
+
+	for _, ti := range finished[0 : len(finished)-maxFinishedTasks] {
+		delete(s.tasks, ti.ID)
+	}
+}
+
+func (s *Server) handleTaskList(ctx context.Context, r *http.Request) (interface{}, *apiError) {
+	resp := &serverapi.TasksResponse{
+		Tasks: []serverapi.TaskInfo{},
+	}
+
+	for _, t := range s.tasks {
+		resp.Tasks = append(resp.Tasks, t.Info())
+	}
+
+	sort.Slice(resp.Tasks, func(i, j int) bool {
+		return resp.Tasks[i].StartTime.Before(resp.Tasks[j].StartTime)
+	})
+
+	return resp, nil
+}
+
+func (s *Server) handleTaskInfo(ctx context.Context, r *http.Request) (interface{}, *apiError) {
+	t := s.tasks[r.URL.Query().Get(":id")]
+	if t == nil {
+		return nil, notFoundError("task not found")
+	}
+
+	return t.Info(), nil
+}
+
+func (s *Server) handleTaskCancel(ctx context.Context, r *http.Request) (interface{}, *apiError) {
+	t := s.tasks[r.URL.Query().Get(":id")]
+	if t == nil {
+		return nil, notFoundError("task not found")
+	}
+
+	t.cancel()
+
+	return t.Info(), nil
+}
@@ -0,0 +1,116 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/snapshotfs"
+)
+
+// maximum number of events buffered for each subscriber, slow subscribers will miss events.
+const uploadEventSubscriberBufferSize = 100
+
+// uploadEventHub distributes upload events of all sources to subscribers.
+type uploadEventHub struct {
+	mu          sync.Mutex
+	subscribers map[chan *snapshotfs.UploadEvent]url.Values
+	inProgress  map[snapshot.SourceInfo]*snapshotfs.UploadEvent // last event of each upload in progress
+}
+
+func newUploadEventHub() *uploadEventHub {
+	return &uploadEventHub{
+		subscribers: map[chan *snapshotfs.UploadEvent]url.Values{},
+		inProgress:  map[snapshot.SourceInfo]*snapshotfs.UploadEvent{},
+	}
+}
+
+// subscribe returns a channel receiving events for sources matching the provided filter,
+// starting with the most recent events of uploads currently in progress.
+func (h *uploadEventHub) subscribe(filter url.Values) chan *snapshotfs.UploadEvent {
+	ch := make(chan *snapshotfs.UploadEvent, uploadEventSubscriberBufferSize)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for src, ev := range h.inProgress {
+		if sourceMatchesURLFilter(src, filter) {
+			ch <- ev
+		}
+	}
+
+	h.subscribers[ch] = filter
+
+	return ch
+}
+
+func (h *uploadEventHub) unsubscribe(ch chan *snapshotfs.UploadEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	delete(h.subscribers, ch)
+}
+
+func (h *uploadEventHub) publish(ev *snapshotfs.UploadEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	switch ev.Type {
+	case snapshotfs.UploadEventFinished, snapshotfs.UploadEventError:
+		delete(h.inProgress, ev.Source)
+	default:
+		h.inProgress[ev.Source] = ev
+	}
+
+	for ch, filter := range h.subscribers {
+		if !sourceMatchesURLFilter(ev.Source, filter) {
+			continue
+		}
+
+		select {
+		case ch <- ev:
+		default:
+			log.Warningf("dropping upload event for slow subscriber")
+		}
+	}
+}
+
+// handleSourceEvents streams upload events of sources matching the URL filter as server-sent events.
+func (s *Server) handleSourceEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	ch := s.uploadEvents.subscribe(r.URL.Query())
+	defer s.uploadEvents.unsubscribe(ch)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+
+		case ev := <-ch:
+			b, err := json.Marshal(ev)
+			if err != nil {
+				log.Warningf("error encoding upload event: %v", err)
+				continue
+			}
+
+			if _, err := fmt.Fprintf(w, "event: %v\ndata: %s\n\n", ev.Type, b); err != nil {
+				return
+			}
+
+			flusher.Flush()
+		}
+	}
+}
@@ -2,51 +2,101 @@ package serverapi
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
 	"encoding/json"
+	"io"
 	"net/http"
+	"strings"
 
 	"github.com/pkg/errors"
 )
 
 // Client provides helper methods for communicating with Kopia API serevr.
 type Client struct {
-	baseURL string
-	client  *http.Client
+	baseURL  string
+	username string
+	password string
+	client   *http.Client
 }
 
-// Get sends HTTP GET request and decodes the JSON response into the provided payload structure.
-func (c *Client) Get(path string, respPayload interface{}) error {
-	resp, err := c.client.Get(c.baseURL + path)
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close() //nolint:errcheck
+// ClientOptions encapsulates all connection options for Client.
+type ClientOptions struct {
+	// BaseURL is the address of the server (host:port or http(s)://host:port).
+	BaseURL string
 
-	if resp.StatusCode != 200 {
-		return errors.Errorf("invalid server response: %v", resp.Status)
-	}
-	if err := json.NewDecoder(resp.Body).Decode(respPayload); err != nil {
-		return errors.Wrap(err, "malformed server response")
-	}
+	// Username and Password are used for HTTP basic authentication, if Password is not empty.
+	// When Username is empty, Password is sent as a bearer token instead.
+	Username string
+	Password string
 
-	return nil
+	// TrustedServerCertificateFingerprint is the hex-encoded SHA256 fingerprint of the server certificate.
+	// When specified, TLS is used and the server certificate is trusted if and only if its fingerprint matches.
+	TrustedServerCertificateFingerprint string
+
+	// HTTPClient is the base HTTP client to use, http.DefaultClient if not set.
+	HTTPClient *http.Client
+}
+
+// Get sends HTTP GET request and decodes the JSON response into the provided payload structure.
+func (c *Client) Get(path string, respPayload interface{}) error {
+	return c.do(http.MethodGet, path, nil, respPayload)
 }
 
 // Post sends HTTP post request with given JSON payload structure and decodes the JSON response into another payload structure.
 func (c *Client) Post(path string, reqPayload, respPayload interface{}) error {
-	var buf bytes.Buffer
+	return c.do(http.MethodPost, path, reqPayload, respPayload)
+}
+
+// Put sends HTTP PUT request with given JSON payload structure and decodes the JSON response into another payload structure.
+func (c *Client) Put(path string, reqPayload, respPayload interface{}) error {
+	return c.do(http.MethodPut, path, reqPayload, respPayload)
+}
+
+// Delete sends HTTP DELETE request and decodes the JSON response into the provided payload structure.
+func (c *Client) Delete(path string, respPayload interface{}) error {
+	return c.do(http.MethodDelete, path, nil, respPayload)
+}
+
+func (c *Client) do(method, path string, reqPayload, respPayload interface{}) error {
+	var body io.Reader
 
-	if err := json.NewEncoder(&buf).Encode(reqPayload); err != nil {
-		return errors.Wrap(err, "unable to encode request")
+	if reqPayload != nil {
+		var buf bytes.Buffer
+
+		if err := json.NewEncoder(&buf).Encode(reqPayload); err != nil {
+			return errors.Wrap(err, "unable to encode request")
+		}
+
+		body = &buf
+	}
+
+	req, err := http.NewRequest(method, c.baseURL+path, body)
+	if err != nil {
+		return errors.Wrap(err, "unable to create request")
+	}
+
+	if reqPayload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	switch {
+	case c.password == "":
+	case c.username == "":
+		req.Header.Set("Authorization", "Bearer "+c.password)
+	default:
+		req.SetBasicAuth(c.username, c.password)
 	}
 
-	resp, err := c.client.Post(c.baseURL+path, "application/json", &buf)
+	resp, err := c.client.Do(req)
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close() //nolint:errcheck
 
-	if resp.StatusCode != 200 {
+	if resp.StatusCode != http.StatusOK {
 		return errors.Errorf("invalid server response: %v", resp.Status)
 	}
 	if err := json.NewDecoder(resp.Body).Decode(respPayload); err != nil {
@@ -57,9 +107,65 @@ func (c *Client) Post(path string, reqPayload, respPayload interface{}) error {
 }
 
 // NewClient creates a client for connecting to Kopia HTTP API.
-func NewClient(serverAddress string, cli *http.Client) *Client {
+func NewClient(options ClientOptions) (*Client, error) {
+	cli := options.HTTPClient
 	if cli == nil {
 		cli = http.DefaultClient
 	}
-	return &Client{"http://" + serverAddress + "/api/v1/", cli}
+
+	serverAddress := options.BaseURL
+	if !strings.HasPrefix(serverAddress, "http://") && !strings.HasPrefix(serverAddress, "https://") {
+		if options.TrustedServerCertificateFingerprint != "" {
+			serverAddress = "https://" + serverAddress
+		} else {
+			serverAddress = "http://" + serverAddress
+		}
+	}
+
+	if fp := options.TrustedServerCertificateFingerprint; fp != "" {
+		if strings.HasPrefix(serverAddress, "http://") {
+			return nil, errors.New("server certificate fingerprint requires HTTPS")
+		}
+
+		cli = &http.Client{
+			Transport: &http.Transport{
+				TLSClientConfig: &tls.Config{
+					InsecureSkipVerify:    true, //nolint:gosec
+					VerifyPeerCertificate: verifyPeerCertificateFingerprint(fp),
+				},
+			},
+			Timeout: cli.Timeout,
+		}
+	}
+
+	return &Client{
+		baseURL:  strings.TrimSuffix(serverAddress, "/") + "/api/v1/",
+		username: options.Username,
+		password: options.Password,
+		client:   cli,
+	}, nil
+}
+
+// CertificateFingerprint returns the hex-encoded SHA256 fingerprint of a given certificate.
+func CertificateFingerprint(cert *x509.Certificate) string {
+	h := sha256.Sum256(cert.Raw)
+	return hex.EncodeToString(h[:])
+}
+
+func verifyPeerCertificateFingerprint(expected string) func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+	expected = strings.ToLower(strings.Replace(expected, ":", "", -1))
+
+	return func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		if len(rawCerts) == 0 {
+			return errors.New("server did not present a certificate")
+		}
+
+		// only the leaf certificate is pinned.
+		h := sha256.Sum256(rawCerts[0])
+		if hex.EncodeToString(h[:]) != expected {
+			return errors.New("server certificate fingerprint does not match")
+		}
+
+		return nil
+	}
 }
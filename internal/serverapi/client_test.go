@@ -0,0 +1,123 @@
+package serverapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newTLSTestServer() *httptest.Server {
+	return httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"uniqueID":"abcd"}`)) //nolint:errcheck
+	}))
+}
+
+func TestClientCertificateFingerprint(t *testing.T) {
+	ts := newTLSTestServer()
+	defer ts.Close()
+
+	fp := CertificateFingerprint(ts.Certificate())
+
+	cases := []struct {
+		desc        string
+		fingerprint string
+		wantErr     bool
+	}{
+		{"correct fingerprint", fp, false},
+		{"correct fingerprint with colons", strings.ToUpper(withColons(fp)), false},
+		{"wrong fingerprint", strings.Repeat("0", len(fp)), true},
+		{"truncated fingerprint", fp[0:32], true},
+	}
+
+	for _, tc := range cases {
+		cli, err := NewClient(ClientOptions{
+			BaseURL:                             ts.URL,
+			TrustedServerCertificateFingerprint: tc.fingerprint,
+		})
+		if err != nil {
+			t.Fatalf("%v: unable to create client: %v", tc.desc, err)
+		}
+
+		var resp StatusResponse
+
+		err = cli.Get("status", &resp)
+		if gotErr := err != nil; gotErr != tc.wantErr {
+			t.Errorf("%v: unexpected error %v, want error: %v", tc.desc, err, tc.wantErr)
+		}
+
+		if !tc.wantErr && resp.UniqueID != "abcd" {
+			t.Errorf("%v: unexpected response: %+v", tc.desc, resp)
+		}
+	}
+}
+
+func TestClientWithoutFingerprintRejectsUntrustedCertificate(t *testing.T) {
+	ts := newTLSTestServer()
+	defer ts.Close()
+
+	cli, err := NewClient(ClientOptions{BaseURL: ts.URL})
+	if err != nil {
+		t.Fatalf("unable to create client: %v", err)
+	}
+
+	var resp StatusResponse
+	if err := cli.Get("status", &resp); err == nil {
+		t.Errorf("expected error when connecting to server with self-signed certificate")
+	}
+}
+
+func TestClientFingerprintRequiresHTTPS(t *testing.T) {
+	if _, err := NewClient(ClientOptions{
+		BaseURL:                             "http://localhost:51515",
+		TrustedServerCertificateFingerprint: "abcd",
+	}); err == nil {
+		t.Errorf("expected error")
+	}
+}
+
+func TestClientCredentials(t *testing.T) {
+	var gotAuth string
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+		w.Write([]byte(`{}`)) //nolint:errcheck
+	}))
+	defer ts.Close()
+
+	cases := []struct {
+		username, password string
+		want               string
+	}{
+		{"", "", ""},
+		{"", "token", "Bearer token"},
+		{"user", "pass", "Basic dXNlcjpwYXNz"},
+	}
+
+	for _, tc := range cases {
+		cli, err := NewClient(ClientOptions{BaseURL: ts.URL, Username: tc.username, Password: tc.password})
+		if err != nil {
+			t.Fatalf("unable to create client: %v", err)
+		}
+
+		var resp StatusResponse
+		if err := cli.Get("status", &resp); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if gotAuth != tc.want {
+			t.Errorf("unexpected authorization header for %q/%q: %q, want %q", tc.username, tc.password, gotAuth, tc.want)
+		}
+	}
+}
+
+func withColons(s string) string {
+	var parts []string
+
+	for i := 0; i < len(s); i += 2 {
+		parts = append(parts, s[i:i+2])
+	}
+
+	return strings.Join(parts, ":")
+}
@@ -0,0 +1,55 @@
+package serverapi
+
+import (
+	"encoding/hex"
+	"time"
+
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/snapshot"
+)
+
+// NewStatusResponse returns the status of the provided repository with secrets removed.
+func NewStatusResponse(rep *repo.Repository) *StatusResponse {
+	bf := rep.Content.Format
+	bf.HMACSecret = nil
+	bf.MasterKey = nil
+
+	resp := &StatusResponse{
+		ConfigFile:      rep.ConfigFile,
+		CacheDir:        rep.Content.CachingOptions.CacheDirectory,
+		UniqueID:        hex.EncodeToString(rep.UniqueID),
+		BlockFormatting: bf,
+		Splitter:        rep.Objects.Format.Splitter,
+		Storage:         rep.Blobs.ConnectionInfo().Type,
+		Parity:          rep.Parity,
+		Throttling:      rep.Throttling,
+	}
+
+	if t := rep.Throttling; t != nil {
+		// scheduled limits are applied by the storage wrapper as time passes.
+		l := t.LimitsAt(time.Now())
+		resp.CurrentLimits = &l
+	}
+
+	return resp
+}
+
+// NewSnapshot converts the provided snapshot manifest to Snapshot.
+func NewSnapshot(m *snapshot.Manifest) *Snapshot {
+	e := &Snapshot{
+		ID:               m.ID,
+		Source:           m.Source,
+		Description:      m.Description,
+		StartTime:        m.StartTime,
+		EndTime:          m.EndTime,
+		IncompleteReason: m.IncompleteReason,
+		RootEntry:        m.RootObjectID().String(),
+		RetentionReasons: m.RetentionReasons,
+	}
+
+	if re := m.RootEntry; re != nil {
+		e.Summary = re.DirSummary
+	}
+
+	return e
+}
@@ -3,17 +3,60 @@ package serverapi
 import (
 	"time"
 
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/repo/blob/parity"
+	"github.com/kopia/kopia/repo/blob/throttling"
 	"github.com/kopia/kopia/repo/content"
+	"github.com/kopia/kopia/repo/manifest"
 	"github.com/kopia/kopia/snapshot"
 	"github.com/kopia/kopia/snapshot/policy"
+	"github.com/kopia/kopia/snapshot/restore"
 )
 
-// StatusResponse is the response of 'status' HTTP API command.
+// StatusResponse is the response of 'status' HTTP API command and the output of 'kopia repository status --json'.
 type StatusResponse struct {
 	ConfigFile      string                    `json:"configFile"`
 	CacheDir        string                    `json:"cacheDir"`
+	UniqueID        string                    `json:"uniqueID"`
 	BlockFormatting content.FormattingOptions `json:"blockFormatting"`
+	Splitter        string                    `json:"splitter"`
 	Storage         string                    `json:"storage"`
+	Parity          *parity.Options           `json:"parity,omitempty"`
+	Throttling      *throttling.Options       `json:"throttling,omitempty"`
+	CurrentLimits   *throttling.Limits        `json:"currentLimits,omitempty"`
+}
+
+// CacheInfoResponse is the output of 'kopia cache info --json'.
+type CacheInfoResponse struct {
+	Path        string                `json:"path"`
+	Directories []*CacheDirectoryInfo `json:"directories"`
+}
+
+// CacheDirectoryInfo describes a single cache directory.
+type CacheDirectoryInfo struct {
+	Path      string `json:"path"`
+	Files     int    `json:"files"`
+	TotalSize int64  `json:"totalSize"`
+	MaxSize   int64  `json:"maxSize,omitempty"`
+}
+
+// Snapshot describes a single snapshot. It's an element of 'snapshots' HTTP API command response
+// and a single line of 'kopia snapshot list --json' output.
+type Snapshot struct {
+	ID               manifest.ID          `json:"id"`
+	Source           snapshot.SourceInfo  `json:"source"`
+	Description      string               `json:"description"`
+	StartTime        time.Time            `json:"startTime"`
+	EndTime          time.Time            `json:"endTime"`
+	IncompleteReason string               `json:"incomplete,omitempty"`
+	Summary          *fs.DirectorySummary `json:"summary"`
+	RootEntry        string               `json:"rootID"`
+	RetentionReasons []string             `json:"retention"`
+}
+
+// SnapshotsResponse is the response of 'snapshots' HTTP API command.
+type SnapshotsResponse struct {
+	Snapshots []*Snapshot `json:"snapshots"`
 }
 
 // SourcesResponse is the response of 'sources' HTTP API command.
@@ -37,6 +80,13 @@ type SourceStatus struct {
 	} `json:"upload"`
 }
 
+// AddSourceRequest is the request of 'sources' HTTP API command adding a new source.
+type AddSourceRequest struct {
+	Path           string         `json:"path"`
+	CreateSnapshot bool           `json:"createSnapshot,omitempty"`
+	Policy         *policy.Policy `json:"policy,omitempty"`
+}
+
 // PolicyListEntry describes single policy.
 type PolicyListEntry struct {
 	ID     string              `json:"id"`
@@ -49,6 +99,14 @@ type PoliciesResponse struct {
 	Policies []*PolicyListEntry `json:"policies"`
 }
 
+// EffectivePolicyResponse is the response of 'policy/effective' HTTP API command
+// and a single line of 'kopia policy show --json' output.
+type EffectivePolicyResponse struct {
+	Target     snapshot.SourceInfo              `json:"target"`
+	Effective  *policy.Policy                   `json:"effective"`
+	Definition map[string][]snapshot.SourceInfo `json:"definition"`
+}
+
 // Empty represents empty request/response.
 type Empty struct {
 }
@@ -62,3 +120,46 @@ type SourceActionResponse struct {
 type MultipleSourceActionResponse struct {
 	Sources map[string]SourceActionResponse `json:"sources"`
 }
+
+// DirectoryEntriesResponse is the response of 'objects/:oid/entries' HTTP API command.
+type DirectoryEntriesResponse struct {
+	Entries []*snapshot.DirEntry `json:"entries"`
+}
+
+// RestoreRequest is the request of 'restore' HTTP API command.
+// The root of the restore is either the root of the snapshot with a given ID or a directory object,
+// optionally narrowed down to a path relative to that root.
+type RestoreRequest struct {
+	SnapshotID           manifest.ID `json:"snapshotID,omitempty"`
+	ObjectID             string      `json:"objectID,omitempty"`
+	Path                 string      `json:"path,omitempty"`
+	TargetPath           string      `json:"targetPath"`
+	OverwriteFiles       bool        `json:"overwriteFiles,omitempty"`
+	OverwriteDirectories bool        `json:"overwriteDirectories,omitempty"`
+}
+
+// Task status values.
+const (
+	TaskStatusRunning  = "RUNNING"
+	TaskStatusSuccess  = "SUCCESS"
+	TaskStatusFailed   = "FAILED"
+	TaskStatusCanceled = "CANCELED"
+)
+
+// TaskInfo describes the state of a long-running task.
+type TaskInfo struct {
+	ID          string               `json:"id"`
+	Kind        string               `json:"kind"`
+	Description string               `json:"description"`
+	Status      string               `json:"status"`
+	StartTime   time.Time            `json:"startTime"`
+	EndTime     *time.Time           `json:"endTime,omitempty"`
+	Error       string               `json:"error,omitempty"`
+	Total       *fs.DirectorySummary `json:"total,omitempty"`
+	Restore     *restore.Stats       `json:"restore,omitempty"`
+}
+
+// TasksResponse is the response of 'tasks' HTTP API command.
+type TasksResponse struct {
+	Tasks []TaskInfo `json:"tasks"`
+}
@@ -0,0 +1,154 @@
+package serverapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/fs/ignorefs"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/blob/parity"
+	"github.com/kopia/kopia/repo/content"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/changedetection"
+	"github.com/kopia/kopia/snapshot/policy"
+	"github.com/kopia/kopia/snapshot/snapshotfs"
+)
+
+var updateGolden = flag.Bool("update-golden", false, "Update golden files")
+
+// TestJSONSchema verifies that JSON representation of structures returned by the API and emitted
+// by 'kopia --json' matches golden files, which document the schema.
+func TestJSONSchema(t *testing.T) {
+	ts := time.Date(2019, 10, 1, 12, 30, 0, 0, time.UTC)
+	src := snapshot.SourceInfo{Host: "host", UserName: "user", Path: "/some/path"}
+	keep := 3
+	maxFileErrors := 10
+
+	pol := &policy.Policy{
+		RetentionPolicy: policy.RetentionPolicy{KeepLatest: &keep, KeepDaily: &keep},
+		FilesPolicy:     ignorefs.FilesPolicy{IgnoreRules: []string{"*.tmp"}, MaxFileSize: 1000000},
+		ErrorHandlingPolicy: policy.ErrorHandlingPolicy{
+			MaxFileErrors: &maxFileErrors,
+		},
+		HashingPolicy: policy.HashingPolicy{
+			ChangeDetection: changedetection.TrustChangeTime,
+		},
+		SchedulingPolicy: policy.SchedulingPolicy{
+			IntervalSeconds: 3600,
+			TimesOfDay:      []policy.TimeOfDay{{Hour: 10, Minute: 30}},
+		},
+	}
+
+	cases := map[string]interface{}{
+		"snapshot": &Snapshot{
+			ID:          "f0f0f0f0f0f0f0f0f0f0f0f0f0f0f0f0",
+			Source:      src,
+			Description: "some description",
+			StartTime:   ts,
+			EndTime:     ts.Add(time.Minute),
+			Summary: &fs.DirectorySummary{
+				TotalFileSize:  1000,
+				TotalFileCount: 10,
+				TotalDirCount:  2,
+				MaxModTime:     ts.Add(-time.Hour),
+				NumFailed:      1,
+				FailedEntries: []*fs.EntryWithError{
+					{EntryPath: "./some/file", Error: "unable to open file: permission denied"},
+				},
+			},
+			RootEntry:        "k0123456789abcdef0123456789abcdef",
+			RetentionReasons: []string{"latest-1", "daily-1"},
+		},
+		"effective-policy": &EffectivePolicyResponse{
+			Target:    src,
+			Effective: pol,
+			Definition: map[string][]snapshot.SourceInfo{
+				"retention.keepLatest": {policy.GlobalPolicySourceInfo},
+				"scheduling.timeOfDay": {src, policy.GlobalPolicySourceInfo},
+			},
+		},
+		"content-info": &content.Info{
+			ID:               "0123456789abcdef0123456789abcdef",
+			Length:           4096,
+			TimestampSeconds: ts.Unix(),
+			PackBlobID:       "p0123456789abcdef0123456789abcdef",
+			PackOffset:       8192,
+			FormatVersion:    1,
+		},
+		"blob-metadata": &blob.Metadata{
+			BlobID:    "p0123456789abcdef0123456789abcdef",
+			Length:    20000000,
+			Timestamp: ts,
+		},
+		"status": &StatusResponse{
+			ConfigFile: "/home/user/.config/kopia/repository.config",
+			CacheDir:   "/home/user/.cache/kopia/0123456789abcdef",
+			UniqueID:   "0123456789abcdef",
+			BlockFormatting: content.FormattingOptions{
+				Version:     1,
+				Hash:        "BLAKE2B-256-128",
+				Encryption:  "AES-256-CTR-HMAC-SHA256",
+				MaxPackSize: 20000000,
+			},
+			Splitter: "DYNAMIC-4M-BUZHASH",
+			Storage:  "filesystem",
+			Parity:   &parity.Options{DataShards: 10, ParityShards: 2, ShardSize: 1048576},
+		},
+		"cache-info": &CacheInfoResponse{
+			Path: "/home/user/.cache/kopia/0123456789abcdef",
+			Directories: []*CacheDirectoryInfo{
+				{Path: "/home/user/.cache/kopia/0123456789abcdef/contents", Files: 10, TotalSize: 1000, MaxSize: 5000000000},
+				{Path: "/home/user/.cache/kopia/0123456789abcdef/metadata", Files: 5, TotalSize: 500},
+			},
+		},
+		"upload-event": &snapshotfs.UploadEvent{
+			Type:           snapshotfs.UploadEventProgress,
+			Time:           ts,
+			Source:         src,
+			Path:           "./some/dir",
+			PathCompleted:  3000,
+			PathTotal:      5000,
+			HashedFiles:    10,
+			HashedBytes:    100000,
+			CachedFiles:    20,
+			ProcessedBytes: 300000,
+			UploadedBytes:  50000,
+			EstimatedBytes: 900000,
+			ETASeconds:     12.5,
+		},
+	}
+
+	for name, v := range cases {
+		b, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			t.Fatalf("unable to marshal %v: %v", name, err)
+		}
+
+		b = append(b, '\n')
+
+		goldenFile := filepath.Join("testdata", name+".golden.json")
+
+		if *updateGolden {
+			if err := ioutil.WriteFile(goldenFile, b, 0600); err != nil {
+				t.Fatalf("unable to update golden file: %v", err)
+			}
+
+			continue
+		}
+
+		want, err := ioutil.ReadFile(goldenFile)
+		if err != nil {
+			t.Fatalf("unable to read golden file: %v", err)
+		}
+
+		if !bytes.Equal(b, want) {
+			t.Errorf("JSON representation of %v does not match %v, got:\n%s", name, goldenFile, b)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package blob
+
+import (
+	"context"
+	"net"
+)
+
+// ErrorKind classifies storage errors, which determines whether failed operations can be retried.
+type ErrorKind int
+
+// Supported error kinds.
+const (
+	ErrorKindPermanent ErrorKind = iota // the operation will not succeed when retried
+	ErrorKindNotFound                   // the blob does not exist
+	ErrorKindThrottled                  // the storage provider asked to slow down
+	ErrorKindTransient                  // temporary failure, such as network or server error
+)
+
+func (k ErrorKind) String() string {
+	switch k {
+	case ErrorKindNotFound:
+		return "not found"
+	case ErrorKindThrottled:
+		return "throttled"
+	case ErrorKindTransient:
+		return "transient"
+	default:
+		return "permanent"
+	}
+}
+
+// IsRetriable returns true if operations failing with errors of this kind may succeed when retried.
+func (k ErrorKind) IsRetriable() bool {
+	return k == ErrorKindThrottled || k == ErrorKindTransient
+}
+
+type classifiedError struct {
+	kind ErrorKind
+	err  error
+}
+
+func (e *classifiedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *classifiedError) Cause() error {
+	return e.err
+}
+
+// NewError returns an error of a given kind wrapping the provided error. Storage providers use it to map
+// provider-specific errors into common error kinds.
+func NewError(kind ErrorKind, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if kind == ErrorKindNotFound {
+		return ErrBlobNotFound
+	}
+
+	return &classifiedError{kind, err}
+}
+
+// ErrorKindOf returns the kind of the provided error. Errors not classified using NewError() are permanent, except for
+// ErrBlobNotFound and network errors, which are considered transient.
+func ErrorKindOf(err error) ErrorKind {
+	for err != nil {
+		switch e := err.(type) {
+		case *classifiedError:
+			return e.kind
+
+		case net.Error:
+			return ErrorKindTransient
+		}
+
+		if err == ErrBlobNotFound {
+			return ErrorKindNotFound
+		}
+
+		if err == context.Canceled || err == context.DeadlineExceeded {
+			return ErrorKindPermanent
+		}
+
+		// unwrap the same way as errors.Cause()
+		c, ok := err.(interface{ Cause() error })
+		if !ok {
+			break
+		}
+
+		err = c.Cause()
+	}
+
+	return ErrorKindPermanent
+}
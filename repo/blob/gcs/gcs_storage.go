@@ -4,7 +4,6 @@ package gcs
 import (
 	"bytes"
 	"context"
-	"fmt"
 	"io"
 	"io/ioutil"
 
@@ -16,7 +15,6 @@ import (
 	"google.golang.org/api/iterator"
 	"google.golang.org/api/option"
 
-	"github.com/kopia/kopia/internal/retry"
 	"github.com/kopia/kopia/internal/throttle"
 	"github.com/kopia/kopia/repo/blob"
 
@@ -43,22 +41,17 @@ func (gcs *gcsStorage) GetBlob(ctx context.Context, b blob.ID, offset, length in
 		return nil, errors.Errorf("invalid offset")
 	}
 
-	attempt := func() (interface{}, error) {
-		reader, err := gcs.bucket.Object(gcs.getObjectNameString(b)).NewRangeReader(gcs.ctx, offset, length)
-		if err != nil {
-			return nil, err
-		}
-		defer reader.Close() //nolint:errcheck
-
-		return ioutil.ReadAll(reader)
+	reader, err := gcs.bucket.Object(gcs.getObjectNameString(b)).NewRangeReader(gcs.ctx, offset, length)
+	if err != nil {
+		return nil, translateError(err)
 	}
+	defer reader.Close() //nolint:errcheck
 
-	v, err := exponentialBackoff(fmt.Sprintf("GetBlob(%q,%v,%v)", b, offset, length), attempt)
+	fetched, err := ioutil.ReadAll(reader)
 	if err != nil {
 		return nil, translateError(err)
 	}
 
-	fetched := v.([]byte)
 	if len(fetched) != int(length) && length >= 0 {
 		return nil, errors.Errorf("invalid offset/length")
 	}
@@ -66,28 +59,19 @@ func (gcs *gcsStorage) GetBlob(ctx context.Context, b blob.ID, offset, length in
 	return fetched, nil
 }
 
-func exponentialBackoff(desc string, att retry.AttemptFunc) (interface{}, error) {
-	return retry.WithExponentialBackoff(desc, att, isRetriableError)
-}
-
-func isRetriableError(err error) bool {
+// translateError maps GCS errors into blob.ErrorKind.
+func translateError(err error) error {
 	if apiError, ok := err.(*googleapi.Error); ok {
-		return apiError.Code >= 500
-	}
-
-	switch err {
-	case nil:
-		return false
-	case gcsclient.ErrObjectNotExist:
-		return false
-	case gcsclient.ErrBucketNotExist:
-		return false
-	default:
-		return true
+		switch {
+		case apiError.Code == 429:
+			return blob.NewError(blob.ErrorKindThrottled, err)
+		case apiError.Code >= 500:
+			return blob.NewError(blob.ErrorKindTransient, err)
+		default:
+			return errors.Wrap(err, "GCS error")
+		}
 	}
-}
 
-func translateError(err error) error {
 	switch err {
 	case nil:
 		return nil
@@ -96,7 +80,7 @@ func translateError(err error) error {
 	case gcsclient.ErrBucketNotExist:
 		return blob.ErrBlobNotFound
 	default:
-		return errors.Wrap(err, "unexpected GCS error")
+		return blob.NewError(blob.ErrorKindTransient, errors.Wrap(err, "unexpected GCS error"))
 	}
 }
 func (gcs *gcsStorage) PutBlob(ctx context.Context, b blob.ID, data []byte) error {
@@ -134,12 +118,7 @@ func (gcs *gcsStorage) PutBlob(ctx context.Context, b blob.ID, data []byte) erro
 }
 
 func (gcs *gcsStorage) DeleteBlob(ctx context.Context, b blob.ID) error {
-	attempt := func() (interface{}, error) {
-		return nil, gcs.bucket.Object(gcs.getObjectNameString(b)).Delete(gcs.ctx)
-	}
-
-	_, err := exponentialBackoff(fmt.Sprintf("DeleteBlob(%q)", b), attempt)
-	err = translateError(err)
+	err := translateError(gcs.bucket.Object(gcs.getObjectNameString(b)).Delete(gcs.ctx))
 	if err == blob.ErrBlobNotFound {
 		return nil
 	}
@@ -0,0 +1,312 @@
+// Package obfuscate implements a wrapper around Storage that hides blob names and approximate sizes from the storage provider.
+//
+// Each blob name is encrypted deterministically (AES-256-CTR with synthetic IV computed using keyed HMAC of the name),
+// so that it can be listed and decrypted, and is prefixed with two single-byte keyed tags computed from the first
+// one and two characters of the name. This allows prefix listing to request only the matching subset of blobs from
+// the underlying storage, at the cost of revealing how many blobs share the same one- or two-character prefix.
+//
+// When size padding is enabled, blob contents are padded to size buckets with encrypted original length in the trailer.
+// Reads until the end of a blob return the original contents, while other partial reads and listing operate on
+// stored (padded) contents, so readers that locate data relative to the end of a blob must read it until the end.
+package obfuscate
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"math/bits"
+	"strings"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/hkdf"
+	"golang.org/x/crypto/scrypt"
+
+	"github.com/kopia/kopia/repo/blob"
+)
+
+// ParametersBlobID is the ID of an unobfuscated blob that holds Parameters.
+const ParametersBlobID blob.ID = "obfuscation"
+
+const (
+	defaultKeyDerivationAlgorithm = "scrypt-65536-8-1"
+	saltSize                      = 32
+	masterKeySize                 = 32
+	ivSize                        = 16
+	tagSize                       = 1
+	trailerSize                   = 8
+	minPaddingQuantum             = 512
+)
+
+// Parameters describe how blob names are obfuscated. They are stored unencrypted in the storage so that
+// the key can be derived from the password on connect.
+type Parameters struct {
+	KeyDerivationAlgorithm string `json:"keyAlgo"`
+	Salt                   []byte `json:"salt"`
+	PadSizes               bool   `json:"padSizes,omitempty"`
+}
+
+// NewParameters returns new Parameters with random salt.
+func NewParameters(padSizes bool) (*Parameters, error) {
+	salt := make([]byte, saltSize)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, errors.Wrap(err, "unable to generate salt")
+	}
+
+	return &Parameters{
+		KeyDerivationAlgorithm: defaultKeyDerivationAlgorithm,
+		Salt:                   salt,
+		PadSizes:               padSizes,
+	}, nil
+}
+
+// DeriveKey derives obfuscation key from the password.
+func (p *Parameters) DeriveKey(password string) ([]byte, error) {
+	switch p.KeyDerivationAlgorithm {
+	case "scrypt-65536-8-1":
+		return scrypt.Key([]byte(password), p.Salt, 65536, 8, 1, masterKeySize)
+
+	default:
+		return nil, errors.Errorf("unsupported key algorithm: %v", p.KeyDerivationAlgorithm)
+	}
+}
+
+// ReadParameters reads obfuscation parameters from the provided storage.
+// Returns blob.ErrBlobNotFound if the storage is not obfuscated.
+func ReadParameters(ctx context.Context, st blob.Storage) (*Parameters, error) {
+	b, err := st.GetBlob(ctx, ParametersBlobID, 0, -1)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Parameters{}
+	if err := json.Unmarshal(b, p); err != nil {
+		return nil, errors.Wrap(err, "invalid obfuscation parameters")
+	}
+
+	return p, nil
+}
+
+// WriteParameters writes obfuscation parameters to the provided storage.
+func WriteParameters(ctx context.Context, st blob.Storage, p *Parameters) error {
+	b, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+
+	return st.PutBlob(ctx, ParametersBlobID, b)
+}
+
+type obfuscatedStorage struct {
+	base     blob.Storage
+	padSizes bool
+
+	tagKey     []byte
+	nameMACKey []byte
+	nameCipher cipher.Block
+	sizeCipher cipher.Block
+}
+
+func (s *obfuscatedStorage) tag(level int, id blob.ID) string {
+	h := hmac.New(sha256.New, s.tagKey)
+	h.Write([]byte{byte(level)}) //nolint:errcheck
+	h.Write([]byte(id))          //nolint:errcheck
+
+	return hex.EncodeToString(h.Sum(nil)[0:tagSize])
+}
+
+// storagePrefix returns the prefix of obfuscated names of all blobs starting with the given prefix.
+func (s *obfuscatedStorage) storagePrefix(prefix blob.ID) blob.ID {
+	switch {
+	case prefix == "":
+		return ""
+
+	case len(prefix) == 1:
+		return blob.ID(s.tag(1, prefix))
+
+	default:
+		return blob.ID(s.tag(1, prefix[0:1]) + s.tag(2, prefix[0:2]))
+	}
+}
+
+func (s *obfuscatedStorage) iv(id blob.ID) []byte {
+	h := hmac.New(sha256.New, s.nameMACKey)
+	h.Write([]byte(id)) //nolint:errcheck
+
+	return h.Sum(nil)[0:ivSize]
+}
+
+func (s *obfuscatedStorage) obfuscateName(id blob.ID) blob.ID {
+	iv := s.iv(id)
+
+	encrypted := make([]byte, ivSize+len(id))
+	copy(encrypted, iv)
+	cipher.NewCTR(s.nameCipher, iv).XORKeyStream(encrypted[ivSize:], []byte(id))
+
+	second := id
+	if len(second) > 2 {
+		second = second[0:2]
+	}
+
+	return blob.ID(s.tag(1, id[0:1]) + s.tag(2, second) + hex.EncodeToString(encrypted))
+}
+
+func (s *obfuscatedStorage) deobfuscateName(name blob.ID) (blob.ID, bool) {
+	const encodedTagsLength = 4 * tagSize
+
+	if len(name) <= encodedTagsLength {
+		return "", false
+	}
+
+	encrypted, err := hex.DecodeString(string(name[encodedTagsLength:]))
+	if err != nil || len(encrypted) <= ivSize {
+		return "", false
+	}
+
+	iv := encrypted[0:ivSize]
+	plain := make([]byte, len(encrypted)-ivSize)
+	cipher.NewCTR(s.nameCipher, iv).XORKeyStream(plain, encrypted[ivSize:])
+
+	id := blob.ID(plain)
+	if !hmac.Equal(iv, s.iv(id)) || s.obfuscateName(id) != name {
+		return "", false
+	}
+
+	return id, true
+}
+
+// paddedLength returns the size bucket for the given length, wasting at most 1/16 of the space.
+func paddedLength(n int) int {
+	quantum := 1 << uint(bits.Len(uint(n))-1) / 16
+	if quantum < minPaddingQuantum {
+		quantum = minPaddingQuantum
+	}
+
+	return (n + quantum - 1) / quantum * quantum
+}
+
+func (s *obfuscatedStorage) lengthTrailer(id blob.ID, trailer []byte) {
+	cipher.NewCTR(s.sizeCipher, s.iv(id)).XORKeyStream(trailer, trailer)
+}
+
+func (s *obfuscatedStorage) pad(id blob.ID, data []byte) []byte {
+	padded := make([]byte, paddedLength(len(data)+trailerSize))
+	copy(padded, data)
+
+	trailer := padded[len(padded)-trailerSize:]
+	binary.BigEndian.PutUint64(trailer, uint64(len(data)))
+	s.lengthTrailer(id, trailer)
+
+	return padded
+}
+
+func (s *obfuscatedStorage) unpad(id blob.ID, padded []byte) ([]byte, error) {
+	if len(padded) < trailerSize {
+		return nil, errors.Errorf("blob %v is too short", id)
+	}
+
+	trailer := append([]byte(nil), padded[len(padded)-trailerSize:]...)
+	s.lengthTrailer(id, trailer)
+
+	length := binary.BigEndian.Uint64(trailer)
+	if length > uint64(len(padded)-trailerSize) {
+		return nil, errors.Errorf("invalid padding of blob %v", id)
+	}
+
+	return padded[0:length], nil
+}
+
+func (s *obfuscatedStorage) GetBlob(ctx context.Context, id blob.ID, offset, length int64) ([]byte, error) {
+	if !s.padSizes || length >= 0 {
+		return s.base.GetBlob(ctx, s.obfuscateName(id), offset, length)
+	}
+
+	// the original length is only known after reading the trailer, so reads until the end of the blob
+	// read it entirely and apply the offset to the original contents.
+	padded, err := s.base.GetBlob(ctx, s.obfuscateName(id), 0, -1)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := s.unpad(id, padded)
+	if err != nil {
+		return nil, err
+	}
+
+	if offset < 0 || offset > int64(len(data)) {
+		return nil, errors.Errorf("invalid offset %v of blob %v", offset, id)
+	}
+
+	return data[offset:], nil
+}
+
+func (s *obfuscatedStorage) PutBlob(ctx context.Context, id blob.ID, data []byte) error {
+	if s.padSizes {
+		data = s.pad(id, data)
+	}
+
+	return s.base.PutBlob(ctx, s.obfuscateName(id), data)
+}
+
+func (s *obfuscatedStorage) DeleteBlob(ctx context.Context, id blob.ID) error {
+	return s.base.DeleteBlob(ctx, s.obfuscateName(id))
+}
+
+func (s *obfuscatedStorage) ListBlobs(ctx context.Context, prefix blob.ID, callback func(blob.Metadata) error) error {
+	return s.base.ListBlobs(ctx, s.storagePrefix(prefix), func(bm blob.Metadata) error {
+		id, ok := s.deobfuscateName(bm.BlobID)
+		if !ok || !strings.HasPrefix(string(id), string(prefix)) {
+			// not an obfuscated blob or does not match the prefix.
+			return nil
+		}
+
+		bm.BlobID = id
+
+		return callback(bm)
+	})
+}
+
+func (s *obfuscatedStorage) ConnectionInfo() blob.ConnectionInfo {
+	return s.base.ConnectionInfo()
+}
+
+func (s *obfuscatedStorage) Close(ctx context.Context) error {
+	return s.base.Close(ctx)
+}
+
+func deriveKey(masterKey []byte, purpose string) []byte {
+	key := make([]byte, 32)
+	k := hkdf.New(sha256.New, masterKey, nil, []byte(purpose))
+	io.ReadFull(k, key) //nolint:errcheck
+
+	return key
+}
+
+// NewWrapper returns a Storage wrapper that obfuscates blob names and optionally sizes using the provided key
+// derived from the password using Parameters.DeriveKey().
+func NewWrapper(st blob.Storage, p *Parameters, key []byte) (blob.Storage, error) {
+	nameCipher, err := aes.NewCipher(deriveKey(key, "blob-name-encryption"))
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to initialize name cipher")
+	}
+
+	sizeCipher, err := aes.NewCipher(deriveKey(key, "blob-size-encryption"))
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to initialize size cipher")
+	}
+
+	return &obfuscatedStorage{
+		base:       st,
+		padSizes:   p.PadSizes,
+		tagKey:     deriveKey(key, "blob-name-tag"),
+		nameMACKey: deriveKey(key, "blob-name-iv"),
+		nameCipher: nameCipher,
+		sizeCipher: sizeCipher,
+	}, nil
+}
@@ -0,0 +1,129 @@
+package obfuscate
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/kopia/kopia/internal/blobtesting"
+	"github.com/kopia/kopia/repo/blob"
+)
+
+func newTestStorage(t *testing.T, data blobtesting.DataMap, padSizes bool, password string) blob.Storage {
+	t.Helper()
+
+	p := &Parameters{
+		KeyDerivationAlgorithm: defaultKeyDerivationAlgorithm,
+		Salt:                   []byte("salt"),
+		PadSizes:               padSizes,
+	}
+
+	key, err := p.DeriveKey(password)
+	if err != nil {
+		t.Fatalf("unable to derive key: %v", err)
+	}
+
+	st, err := NewWrapper(blobtesting.NewMapStorage(data, nil, nil), p, key)
+	if err != nil {
+		t.Fatalf("unable to create wrapper: %v", err)
+	}
+
+	return st
+}
+
+func TestObfuscatedStorage(t *testing.T) {
+	ctx := context.Background()
+	data := blobtesting.DataMap{}
+	st := newTestStorage(t, data, false, "password")
+
+	blobtesting.VerifyStorage(ctx, t, st)
+
+	for id := range data {
+		if strings.Contains(string(id), "kopia") || strings.Contains(string(id), "abff4585") {
+			t.Errorf("underlying blob name not obfuscated: %v", id)
+		}
+	}
+
+	// add blobs that are not obfuscated, which must be ignored.
+	data["pabcd"] = []byte{1, 2, 3}
+	data[ParametersBlobID] = []byte{1, 2, 3}
+
+	blobtesting.AssertListResults(ctx, t, st, "", "zxce0e35630770c54668a8cfb4e414c6bf8f", "abff4585856ebf0748fd989e1dd623a8963d", "abgc3dca496d510f492c858a2df1eb824e62", "kopia.repository")
+	blobtesting.AssertListResults(ctx, t, st, "a", "abff4585856ebf0748fd989e1dd623a8963d", "abgc3dca496d510f492c858a2df1eb824e62")
+	blobtesting.AssertListResults(ctx, t, st, "abf", "abff4585856ebf0748fd989e1dd623a8963d")
+	blobtesting.AssertListResults(ctx, t, st, "p")
+
+	// different password can't see any blobs.
+	blobtesting.AssertListResults(ctx, t, newTestStorage(t, data, false, "other-password"), "")
+}
+
+func TestPaddedStorage(t *testing.T) {
+	ctx := context.Background()
+	data := blobtesting.DataMap{}
+	st := newTestStorage(t, data, true, "password")
+
+	for _, length := range []int{0, 1, 100, 1000, 5000, 100000} {
+		id := blob.ID("p" + strings.Repeat("x", length%7+1))
+		contents := bytes.Repeat([]byte{1, 2, 3}, length)[0:length]
+
+		if err := st.PutBlob(ctx, id, contents); err != nil {
+			t.Fatalf("unable to put blob: %v", err)
+		}
+
+		// partial reads past the end of padded blobs don't fail, so only verify valid reads.
+		b, err := st.GetBlob(ctx, id, 0, -1)
+		if err != nil || !bytes.Equal(b, contents) {
+			t.Errorf("unexpected contents of %v bytes: %x %v", length, b, err)
+		}
+
+		if half := int64(length / 2); half > 0 {
+			b, err := st.GetBlob(ctx, id, half, int64(length)-half)
+			if err != nil || !bytes.Equal(b, contents[half:]) {
+				t.Errorf("unexpected partial contents of %v bytes: %x %v", length, b, err)
+			}
+		}
+
+		// reads until the end of the blob return original contents after the offset.
+		for _, offset := range []int64{0, int64(length / 2), int64(length)} {
+			b, err := st.GetBlob(ctx, id, offset, -1)
+			if err != nil || !bytes.Equal(b, contents[offset:]) {
+				t.Errorf("unexpected contents of %v bytes from offset %v: %x %v", length, offset, b, err)
+			}
+		}
+
+		if _, err := st.GetBlob(ctx, id, int64(length)+1, -1); err == nil {
+			t.Errorf("expected error reading %v bytes past the end", length)
+		}
+
+		md, err := blob.ListAllBlobs(ctx, st, id)
+		if err != nil || len(md) != 1 {
+			t.Fatalf("unable to list blob %v: %v %v", id, md, err)
+		}
+
+		if got, want := md[0].Length, int64(paddedLength(length+trailerSize)); got != want {
+			t.Errorf("unexpected padded length of %v bytes: %v, want %v", length, got, want)
+		}
+
+		if err := st.DeleteBlob(ctx, id); err != nil {
+			t.Fatalf("unable to delete blob: %v", err)
+		}
+	}
+}
+
+func TestPaddedLength(t *testing.T) {
+	cases := map[int]int{
+		1:         512,
+		512:       512,
+		513:       1024,
+		20000:     20480,
+		1 << 20:   1 << 20,
+		1<<20 + 1: 1<<20 + 1<<16,
+	}
+
+	for n, want := range cases {
+		if got := paddedLength(n); got != want {
+			t.Errorf("paddedLength(%v) = %v, want %v", n, got, want)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+// Package parity implements a wrapper around Storage that writes Reed-Solomon parity data for selected
+// blobs to sidecar blobs, which allows damaged blobs to be reconstructed using Repair().
+package parity
+
+import (
+	"context"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/repologging"
+	"github.com/kopia/kopia/repo/blob"
+)
+
+var log = repologging.Logger("repo/blob/parity")
+
+// BlobIDPrefix is the prefix of sidecar blobs containing parity data.
+const BlobIDPrefix blob.ID = "e"
+
+const (
+	defaultDataShards = 20
+	defaultShardSize  = 16 << 10
+
+	// Reed-Solomon over GF(2^8) supports up to 256 shards.
+	maxTotalShards = 256
+)
+
+// Options specifies how parity data is computed.
+// Blobs are divided into stripes of DataShards shards of ShardSize bytes each and ParityShards
+// parity shards are computed for each stripe, so up to ParityShards damaged shards in each stripe can be reconstructed.
+type Options struct {
+	DataShards   int `json:"dataShards"`
+	ParityShards int `json:"parityShards"`
+	ShardSize    int `json:"shardSize"`
+}
+
+// OptionsForOverhead returns options for the given parity overhead expressed as percentage of data size
+// or nil if the overhead is zero.
+func OptionsForOverhead(percent int) (*Options, error) {
+	if percent == 0 {
+		return nil, nil
+	}
+
+	if percent < 0 || percent > 100 {
+		return nil, errors.Errorf("invalid parity overhead %v%%, must be between 0 and 100", percent)
+	}
+
+	return &Options{
+		DataShards:   defaultDataShards,
+		ParityShards: (defaultDataShards*percent + 99) / 100,
+		ShardSize:    defaultShardSize,
+	}, nil
+}
+
+// Validate returns an error if the options are invalid.
+func (o *Options) Validate() error {
+	if o.DataShards <= 0 || o.ParityShards <= 0 || o.DataShards+o.ParityShards > maxTotalShards {
+		return errors.Errorf("invalid number of shards: %v data and %v parity", o.DataShards, o.ParityShards)
+	}
+
+	if o.ShardSize <= 0 {
+		return errors.Errorf("invalid shard size: %v", o.ShardSize)
+	}
+
+	return nil
+}
+
+// SidecarBlobID returns the ID of the blob holding parity data for a given blob.
+func SidecarBlobID(id blob.ID) blob.ID {
+	return BlobIDPrefix + id
+}
+
+type parityStorage struct {
+	blob.Storage
+
+	opt      Options
+	prefixes []blob.ID
+}
+
+func (s *parityStorage) isProtected(id blob.ID) bool {
+	for _, p := range s.prefixes {
+		if strings.HasPrefix(string(id), string(p)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (s *parityStorage) PutBlob(ctx context.Context, id blob.ID, data []byte) error {
+	if err := s.Storage.PutBlob(ctx, id, data); err != nil {
+		return err
+	}
+
+	if !s.isProtected(id) {
+		return nil
+	}
+
+	sidecar, err := encodeSidecar(data, s.opt)
+	if err != nil {
+		return errors.Wrapf(err, "unable to compute parity for %v", id)
+	}
+
+	return s.Storage.PutBlob(ctx, SidecarBlobID(id), sidecar)
+}
+
+func (s *parityStorage) DeleteBlob(ctx context.Context, id blob.ID) error {
+	if err := s.Storage.DeleteBlob(ctx, id); err != nil {
+		return err
+	}
+
+	if !s.isProtected(id) {
+		return nil
+	}
+
+	if err := s.Storage.DeleteBlob(ctx, SidecarBlobID(id)); err != nil && err != blob.ErrBlobNotFound {
+		return errors.Wrapf(err, "unable to delete parity for %v", id)
+	}
+
+	return nil
+}
+
+// NewWrapper returns a Storage wrapper that writes parity data for blobs with any of the provided prefixes.
+func NewWrapper(st blob.Storage, opt Options, protectedPrefixes []blob.ID) (blob.Storage, error) {
+	if err := opt.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &parityStorage{
+		Storage:  st,
+		opt:      opt,
+		prefixes: protectedPrefixes,
+	}, nil
+}
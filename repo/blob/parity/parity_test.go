@@ -0,0 +1,167 @@
+package parity
+
+import (
+	"bytes"
+	"context"
+	"math/rand"
+	"testing"
+
+	"github.com/kopia/kopia/internal/blobtesting"
+	"github.com/kopia/kopia/repo/blob"
+)
+
+// overwritingStorage replaces existing blobs on PutBlob(), which map storage does not do.
+type overwritingStorage struct {
+	blob.Storage
+}
+
+func (s overwritingStorage) PutBlob(ctx context.Context, id blob.ID, data []byte) error {
+	if err := s.Storage.DeleteBlob(ctx, id); err != nil {
+		return err
+	}
+
+	return s.Storage.PutBlob(ctx, id, data)
+}
+
+func TestParityStorage(t *testing.T) {
+	ctx := context.Background()
+	data := blobtesting.DataMap{}
+	underlying := blobtesting.NewMapStorage(data, nil, nil)
+
+	st, err := NewWrapper(underlying, Options{DataShards: 4, ParityShards: 2, ShardSize: 100}, []blob.ID{"p"})
+	if err != nil {
+		t.Fatalf("unable to create wrapper: %v", err)
+	}
+
+	blobtesting.VerifyStorage(ctx, t, st)
+
+	if err := st.PutBlob(ctx, "xabc", []byte{1, 2, 3}); err != nil {
+		t.Fatalf("put error: %v", err)
+	}
+
+	if _, ok := data[SidecarBlobID("xabc")]; ok {
+		t.Errorf("unexpected parity for unprotected blob")
+	}
+
+	if err := st.PutBlob(ctx, "pabc", []byte{1, 2, 3}); err != nil {
+		t.Fatalf("put error: %v", err)
+	}
+
+	if _, ok := data[SidecarBlobID("pabc")]; !ok {
+		t.Errorf("missing parity for protected blob")
+	}
+
+	if err := st.DeleteBlob(ctx, "pabc"); err != nil {
+		t.Fatalf("delete error: %v", err)
+	}
+
+	if _, ok := data[SidecarBlobID("pabc")]; ok {
+		t.Errorf("parity not deleted")
+	}
+}
+
+func TestRepair(t *testing.T) {
+	ctx := context.Background()
+
+	cases := []struct {
+		desc    string
+		length  int
+		damage  func(d []byte) []byte
+		wantErr bool
+	}{
+		{"intact", 1000, func(d []byte) []byte { return d }, false},
+		{"empty", 0, func(d []byte) []byte { return d }, false},
+		{"single bit", 1000, func(d []byte) []byte { d[17] ^= 1; return d }, false},
+		{"two shards", 1000, func(d []byte) []byte { d[17] ^= 1; d[900] ^= 0x80; return d }, false},
+		{"one shard in each stripe", 3000, func(d []byte) []byte { d[5] ^= 1; d[1005] ^= 1; d[2995] ^= 1; return d }, false},
+		{"truncated", 1000, func(d []byte) []byte { return d[0:950] }, false},
+		{"too many shards", 1000, func(d []byte) []byte { d[17] ^= 1; d[300] ^= 1; d[900] ^= 1; return d }, true},
+	}
+
+	for _, tc := range cases {
+		data := blobtesting.DataMap{}
+		underlying := overwritingStorage{blobtesting.NewMapStorage(data, nil, nil)}
+
+		st, err := NewWrapper(underlying, Options{DataShards: 4, ParityShards: 2, ShardSize: 250}, []blob.ID{"p"})
+		if err != nil {
+			t.Fatalf("unable to create wrapper: %v", err)
+		}
+
+		original := make([]byte, tc.length)
+		rand.Read(original) //nolint:errcheck
+
+		if err := st.PutBlob(ctx, "pblob", original); err != nil {
+			t.Fatalf("put error: %v", err)
+		}
+
+		data["pblob"] = tc.damage(append([]byte(nil), original...))
+		damaged := append([]byte(nil), data["pblob"]...)
+
+		// dry run does not modify the blob
+		if _, err := Repair(ctx, underlying, "pblob", true); (err != nil) != tc.wantErr {
+			t.Errorf("%v: unexpected dry run error: %v", tc.desc, err)
+		}
+
+		if !bytes.Equal(data["pblob"], damaged) {
+			t.Errorf("%v: dry run modified the blob", tc.desc)
+		}
+
+		res, err := Repair(ctx, underlying, "pblob", false)
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("%v: expected error", tc.desc)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%v: repair error: %v", tc.desc, err)
+			continue
+		}
+
+		if got, want := res.Repaired, !bytes.Equal(damaged, original); got != want {
+			t.Errorf("%v: unexpected repaired %v, want %v", tc.desc, got, want)
+		}
+
+		if !bytes.Equal(data["pblob"], original) {
+			t.Errorf("%v: blob was not repaired", tc.desc)
+		}
+	}
+}
+
+func TestRepairDamagedParity(t *testing.T) {
+	ctx := context.Background()
+	data := blobtesting.DataMap{}
+	underlying := overwritingStorage{blobtesting.NewMapStorage(data, nil, nil)}
+
+	st, err := NewWrapper(underlying, Options{DataShards: 4, ParityShards: 2, ShardSize: 250}, []blob.ID{"p"})
+	if err != nil {
+		t.Fatalf("unable to create wrapper: %v", err)
+	}
+
+	if err := st.PutBlob(ctx, "pblob", bytes.Repeat([]byte{1, 2, 3}, 300)); err != nil {
+		t.Fatalf("put error: %v", err)
+	}
+
+	sidecar := data[SidecarBlobID("pblob")]
+	original := append([]byte(nil), sidecar...)
+	sidecar[len(sidecar)-1] ^= 1
+
+	res, err := Repair(ctx, underlying, "pblob", false)
+	if err != nil {
+		t.Fatalf("repair error: %v", err)
+	}
+
+	if res.CorruptShards != 1 || !res.Repaired {
+		t.Errorf("unexpected result: %+v", res)
+	}
+
+	if !bytes.Equal(data[SidecarBlobID("pblob")], original) {
+		t.Errorf("parity was not repaired")
+	}
+
+	if _, err := Repair(ctx, underlying, "pother", false); err != ErrNoParity {
+		t.Errorf("unexpected error for blob without parity: %v", err)
+	}
+}
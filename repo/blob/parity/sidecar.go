@@ -0,0 +1,307 @@
+package parity
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"hash/crc32"
+
+	"github.com/klauspost/reedsolomon"
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo/blob"
+)
+
+// Sidecar blob format (all integers are big-endian):
+//
+//	header (32 bytes):
+//	  magic "KPAR", version (1 byte), data shards (1 byte), parity shards (1 byte), reserved (1 byte),
+//	  shard size (4 bytes), data length (8 bytes), stripe count (4 bytes), reserved (4 bytes), header CRC (4 bytes)
+//	CRC of each data and parity shard of each stripe (4 bytes each)
+//	parity shards of each stripe
+const (
+	sidecarMagic      = "KPAR"
+	sidecarVersion    = 1
+	sidecarHeaderSize = 32
+)
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// ErrNoParity is returned by Repair when a blob does not have parity data.
+var ErrNoParity = errors.New("parity data not found")
+
+type sidecarHeader struct {
+	dataShards   int
+	parityShards int
+	shardSize    int
+	dataLength   int64
+	stripeCount  int
+}
+
+func (h *sidecarHeader) totalShards() int {
+	return h.dataShards + h.parityShards
+}
+
+func (h *sidecarHeader) stripeSize() int {
+	return h.dataShards * h.shardSize
+}
+
+func (h *sidecarHeader) checksumOffset(stripe, shard int) int {
+	return sidecarHeaderSize + 4*(stripe*h.totalShards()+shard)
+}
+
+func (h *sidecarHeader) parityOffset(stripe, parityShard int) int {
+	return sidecarHeaderSize + 4*h.stripeCount*h.totalShards() + (stripe*h.parityShards+parityShard)*h.shardSize
+}
+
+func (h *sidecarHeader) sidecarLength() int {
+	return h.parityOffset(h.stripeCount, 0)
+}
+
+func newSidecarHeader(dataLength int, opt Options) *sidecarHeader {
+	h := &sidecarHeader{
+		dataShards:   opt.DataShards,
+		parityShards: opt.ParityShards,
+		shardSize:    opt.ShardSize,
+		dataLength:   int64(dataLength),
+	}
+
+	// use smaller shards for small blobs, so that parity of small blobs stays small.
+	if perShard := (dataLength + h.dataShards - 1) / h.dataShards; perShard < h.shardSize {
+		h.shardSize = perShard
+	}
+
+	if h.shardSize > 0 {
+		h.stripeCount = (dataLength + h.stripeSize() - 1) / h.stripeSize()
+	}
+
+	return h
+}
+
+func (h *sidecarHeader) marshal() []byte {
+	b := make([]byte, sidecarHeaderSize)
+	copy(b, sidecarMagic)
+	b[4] = sidecarVersion
+	b[5] = byte(h.dataShards)
+	b[6] = byte(h.parityShards)
+	binary.BigEndian.PutUint32(b[8:], uint32(h.shardSize))
+	binary.BigEndian.PutUint64(b[12:], uint64(h.dataLength))
+	binary.BigEndian.PutUint32(b[20:], uint32(h.stripeCount))
+	binary.BigEndian.PutUint32(b[28:], crc32.Checksum(b[0:28], crcTable))
+
+	return b
+}
+
+func parseSidecarHeader(b []byte) (*sidecarHeader, error) {
+	if len(b) < sidecarHeaderSize || string(b[0:4]) != sidecarMagic {
+		return nil, errors.New("invalid parity header")
+	}
+
+	if crc32.Checksum(b[0:28], crcTable) != binary.BigEndian.Uint32(b[28:]) {
+		return nil, errors.New("parity header is corrupt")
+	}
+
+	if b[4] != sidecarVersion {
+		return nil, errors.Errorf("unsupported parity version %v", b[4])
+	}
+
+	h := &sidecarHeader{
+		dataShards:   int(b[5]),
+		parityShards: int(b[6]),
+		shardSize:    int(binary.BigEndian.Uint32(b[8:])),
+		dataLength:   int64(binary.BigEndian.Uint64(b[12:])),
+		stripeCount:  int(binary.BigEndian.Uint32(b[20:])),
+	}
+
+	if h.dataShards == 0 || h.parityShards == 0 {
+		return nil, errors.New("invalid number of shards in parity header")
+	}
+
+	if len(b) != h.sidecarLength() {
+		return nil, errors.Errorf("invalid parity length %v, expected %v", len(b), h.sidecarLength())
+	}
+
+	return h, nil
+}
+
+// stripeShards returns data shards of a given stripe, padded with zeros to the full shard size.
+func (h *sidecarHeader) stripeShards(data []byte, stripe int) [][]byte {
+	shards := make([][]byte, h.totalShards())
+
+	for i := 0; i < h.dataShards; i++ {
+		shards[i] = make([]byte, h.shardSize)
+
+		start := stripe*h.stripeSize() + i*h.shardSize
+		if start < len(data) {
+			end := start + h.shardSize
+			if end > len(data) {
+				end = len(data)
+			}
+
+			copy(shards[i], data[start:end])
+		}
+	}
+
+	return shards
+}
+
+func encodeSidecar(data []byte, opt Options) ([]byte, error) {
+	h := newSidecarHeader(len(data), opt)
+
+	result := make([]byte, h.sidecarLength())
+	copy(result, h.marshal())
+
+	if h.stripeCount == 0 {
+		return result, nil
+	}
+
+	enc, err := reedsolomon.New(h.dataShards, h.parityShards)
+	if err != nil {
+		return nil, err
+	}
+
+	for stripe := 0; stripe < h.stripeCount; stripe++ {
+		shards := h.stripeShards(data, stripe)
+		for i := h.dataShards; i < h.totalShards(); i++ {
+			shards[i] = make([]byte, h.shardSize)
+		}
+
+		if err := enc.Encode(shards); err != nil {
+			return nil, errors.Wrap(err, "unable to encode parity")
+		}
+
+		for i, s := range shards {
+			binary.BigEndian.PutUint32(result[h.checksumOffset(stripe, i):], crc32.Checksum(s, crcTable))
+		}
+
+		for i := 0; i < h.parityShards; i++ {
+			copy(result[h.parityOffset(stripe, i):], shards[h.dataShards+i])
+		}
+	}
+
+	return result, nil
+}
+
+// RepairResult describes the outcome of a repair.
+type RepairResult struct {
+	CorruptShards int  `json:"corruptShards"`
+	Repaired      bool `json:"repaired"`
+}
+
+// Repair verifies the blob with a given ID against its parity data and, unless dryRun is set,
+// reconstructs and rewrites the blob if it was damaged. The storage must not be wrapped by NewWrapper.
+func Repair(ctx context.Context, st blob.Storage, id blob.ID, dryRun bool) (*RepairResult, error) {
+	sidecar, err := st.GetBlob(ctx, SidecarBlobID(id), 0, -1)
+	if err == blob.ErrBlobNotFound {
+		return nil, ErrNoParity
+	}
+
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read parity")
+	}
+
+	h, err := parseSidecarHeader(sidecar)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := st.GetBlob(ctx, id, 0, -1)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read blob")
+	}
+
+	// truncated or extended blobs are repaired by reconstructing the affected shards.
+	repaired := make([]byte, h.dataLength)
+	copy(repaired, data)
+
+	enc, err := reedsolomon.New(h.dataShards, h.parityShards)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &RepairResult{}
+
+	for stripe := 0; stripe < h.stripeCount; stripe++ {
+		shards := h.stripeShards(repaired, stripe)
+		for i := 0; i < h.parityShards; i++ {
+			off := h.parityOffset(stripe, i)
+			shards[h.dataShards+i] = sidecar[off : off+h.shardSize]
+		}
+
+		corrupt := 0
+
+		for i, s := range shards {
+			if crc32.Checksum(s, crcTable) != binary.BigEndian.Uint32(sidecar[h.checksumOffset(stripe, i):]) {
+				shards[i] = nil
+				corrupt++
+			}
+		}
+
+		if corrupt == 0 {
+			continue
+		}
+
+		result.CorruptShards += corrupt
+
+		if corrupt > h.parityShards {
+			return result, errors.Errorf("stripe %v has %v damaged shards, only %v can be reconstructed", stripe, corrupt, h.parityShards)
+		}
+
+		if err := enc.Reconstruct(shards); err != nil {
+			return result, errors.Wrapf(err, "unable to reconstruct stripe %v", stripe)
+		}
+
+		for i := 0; i < h.dataShards; i++ {
+			start := stripe*h.stripeSize() + i*h.shardSize
+			if start >= len(repaired) {
+				break
+			}
+
+			copy(repaired[start:], shards[i])
+		}
+	}
+
+	if bytes.Equal(data, repaired) {
+		if result.CorruptShards > 0 && !dryRun {
+			// only parity was damaged, recompute it.
+			if err := rewriteSidecar(ctx, st, id, repaired, h); err != nil {
+				return result, err
+			}
+
+			result.Repaired = true
+		}
+
+		return result, nil
+	}
+
+	if dryRun {
+		return result, nil
+	}
+
+	log.Infof("rewriting repaired blob %v", id)
+
+	if err := st.PutBlob(ctx, id, repaired); err != nil {
+		return result, errors.Wrap(err, "unable to write repaired blob")
+	}
+
+	if err := rewriteSidecar(ctx, st, id, repaired, h); err != nil {
+		return result, err
+	}
+
+	result.Repaired = true
+
+	return result, nil
+}
+
+func rewriteSidecar(ctx context.Context, st blob.Storage, id blob.ID, data []byte, h *sidecarHeader) error {
+	sidecar, err := encodeSidecar(data, Options{
+		DataShards:   h.dataShards,
+		ParityShards: h.parityShards,
+		ShardSize:    h.shardSize,
+	})
+	if err != nil {
+		return err
+	}
+
+	return st.PutBlob(ctx, SidecarBlobID(id), sidecar)
+}
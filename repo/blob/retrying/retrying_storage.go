@@ -0,0 +1,83 @@
+// Package retrying implements a wrapper around Storage that retries failed operations according to a retry policy,
+// based on the kind of errors returned by the underlying storage (see blob.ErrorKindOf).
+package retrying
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/kopia/kopia/internal/retry"
+	"github.com/kopia/kopia/repo/blob"
+)
+
+type retryingStorage struct {
+	base   blob.Storage
+	policy retry.Policy
+}
+
+func isRetriable(err error) bool {
+	return blob.ErrorKindOf(err).IsRetriable()
+}
+
+func (s *retryingStorage) GetBlob(ctx context.Context, id blob.ID, offset, length int64) ([]byte, error) {
+	v, err := s.policy.Run(ctx, fmt.Sprintf("GetBlob(%q,%v,%v)", id, offset, length), func() (interface{}, error) {
+		return s.base.GetBlob(ctx, id, offset, length)
+	}, isRetriable)
+	if err != nil {
+		return nil, err
+	}
+
+	return v.([]byte), nil
+}
+
+func (s *retryingStorage) PutBlob(ctx context.Context, id blob.ID, data []byte) error {
+	_, err := s.policy.Run(ctx, fmt.Sprintf("PutBlob(%q)", id), func() (interface{}, error) {
+		return nil, s.base.PutBlob(ctx, id, data)
+	}, isRetriable)
+
+	return err
+}
+
+func (s *retryingStorage) DeleteBlob(ctx context.Context, id blob.ID) error {
+	_, err := s.policy.Run(ctx, fmt.Sprintf("DeleteBlob(%q)", id), func() (interface{}, error) {
+		return nil, s.base.DeleteBlob(ctx, id)
+	}, isRetriable)
+
+	return err
+}
+
+func (s *retryingStorage) ListBlobs(ctx context.Context, prefix blob.ID, callback func(blob.Metadata) error) error {
+	// retrying in the middle of iteration would report some blobs twice, so collect them first.
+	v, err := s.policy.Run(ctx, fmt.Sprintf("ListBlobs(%q)", prefix), func() (interface{}, error) {
+		return blob.ListAllBlobs(ctx, s.base, prefix)
+	}, isRetriable)
+	if err != nil {
+		return err
+	}
+
+	for _, bm := range v.([]blob.Metadata) {
+		if err := callback(bm); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *retryingStorage) ConnectionInfo() blob.ConnectionInfo {
+	return s.base.ConnectionInfo()
+}
+
+func (s *retryingStorage) Close(ctx context.Context) error {
+	return s.base.Close(ctx)
+}
+
+// NewWrapper returns a Storage wrapper that retries operations failing with transient or throttling errors
+// according to the provided policy.
+func NewWrapper(st blob.Storage, policy retry.Policy) (blob.Storage, error) {
+	if err := policy.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &retryingStorage{st, policy}, nil
+}
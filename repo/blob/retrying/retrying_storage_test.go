@@ -0,0 +1,147 @@
+package retrying
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/blobtesting"
+	"github.com/kopia/kopia/internal/retry"
+	"github.com/kopia/kopia/repo/blob"
+)
+
+var testPolicy = retry.Policy{
+	MaxAttempts:    3,
+	InitialBackoff: time.Millisecond,
+	MaxBackoff:     2 * time.Millisecond,
+}
+
+func TestRetryingStorage(t *testing.T) {
+	ctx := context.Background()
+
+	st, err := NewWrapper(blobtesting.NewMapStorage(blobtesting.DataMap{}, nil, nil), testPolicy)
+	if err != nil {
+		t.Fatalf("unable to create wrapper: %v", err)
+	}
+
+	blobtesting.VerifyStorage(ctx, t, st)
+}
+
+func TestRetries(t *testing.T) {
+	ctx := context.Background()
+
+	errTransient := blob.NewError(blob.ErrorKindTransient, errors.New("transient"))
+	errThrottled := blob.NewError(blob.ErrorKindThrottled, errors.New("throttled"))
+	errPermanent := errors.New("permanent")
+
+	cases := []struct {
+		desc    string
+		faults  func() []*blobtesting.Fault
+		wantErr error
+	}{
+		{"no faults", func() []*blobtesting.Fault { return nil }, nil},
+		{"transient", func() []*blobtesting.Fault {
+			return []*blobtesting.Fault{{Err: errTransient}, {Err: errors.Wrap(errThrottled, "wrapped")}}
+		}, nil},
+		{"too many transient", func() []*blobtesting.Fault { return []*blobtesting.Fault{{Err: errTransient, Repeat: 2}} }, errTransient},
+		{"permanent", func() []*blobtesting.Fault { return []*blobtesting.Fault{{Err: errPermanent}} }, errPermanent},
+		{"transient then permanent", func() []*blobtesting.Fault { return []*blobtesting.Fault{{Err: errTransient}, {Err: errPermanent}} }, errPermanent},
+	}
+
+	for _, tc := range cases {
+		for _, method := range []string{"GetBlob", "PutBlob", "DeleteBlob", "ListBlobs"} {
+			base := blobtesting.NewMapStorage(blobtesting.DataMap{}, nil, nil)
+			if err := base.PutBlob(ctx, "foo", []byte{1, 2, 3}); err != nil {
+				t.Fatalf("unable to put blob: %v", err)
+			}
+
+			fs := &blobtesting.FaultyStorage{
+				Base:   base,
+				Faults: map[string][]*blobtesting.Fault{method: tc.faults()},
+			}
+
+			st, err := NewWrapper(fs, testPolicy)
+			if err != nil {
+				t.Fatalf("unable to create wrapper: %v", err)
+			}
+
+			switch method {
+			case "GetBlob":
+				_, err = st.GetBlob(ctx, "foo", 0, -1)
+			case "PutBlob":
+				err = st.PutBlob(ctx, "bar", []byte{1})
+			case "DeleteBlob":
+				err = st.DeleteBlob(ctx, "foo")
+			case "ListBlobs":
+				var cnt int
+				err = st.ListBlobs(ctx, "", func(bm blob.Metadata) error {
+					cnt++
+					return nil
+				})
+
+				if err == nil && cnt != 1 {
+					t.Errorf("%v %v: unexpected number of listed blobs: %v", tc.desc, method, cnt)
+				}
+			}
+
+			if tc.wantErr == nil {
+				if err != nil {
+					t.Errorf("%v %v: unexpected error: %v", tc.desc, method, err)
+				}
+
+				continue
+			}
+
+			if tc.wantErr == errPermanent && err != errPermanent {
+				t.Errorf("%v %v: unexpected error: %v, want %v", tc.desc, method, err, tc.wantErr)
+			}
+
+			if tc.wantErr == errTransient && blob.ErrorKindOf(err) != blob.ErrorKindTransient {
+				t.Errorf("%v %v: unexpected error: %v, want transient error", tc.desc, method, err)
+			}
+		}
+	}
+}
+
+func TestNotFoundIsNotRetried(t *testing.T) {
+	ctx := context.Background()
+	fs := &blobtesting.FaultyStorage{
+		Base: blobtesting.NewMapStorage(blobtesting.DataMap{}, nil, nil),
+		Faults: map[string][]*blobtesting.Fault{
+			"GetBlob": {{Err: blob.ErrBlobNotFound}, {Err: errors.New("must not be reached")}},
+		},
+	}
+
+	st, err := NewWrapper(fs, testPolicy)
+	if err != nil {
+		t.Fatalf("unable to create wrapper: %v", err)
+	}
+
+	if _, err := st.GetBlob(ctx, "foo", 0, -1); err != blob.ErrBlobNotFound {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestCancellation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	fs := &blobtesting.FaultyStorage{
+		Base: blobtesting.NewMapStorage(blobtesting.DataMap{}, nil, nil),
+		Faults: map[string][]*blobtesting.Fault{
+			"PutBlob": {{ErrCallback: func() error {
+				cancel()
+				return blob.NewError(blob.ErrorKindTransient, errors.New("transient"))
+			}}},
+		},
+	}
+
+	st, err := NewWrapper(fs, retry.Policy{MaxAttempts: 10, InitialBackoff: time.Hour})
+	if err != nil {
+		t.Fatalf("unable to create wrapper: %v", err)
+	}
+
+	if err := st.PutBlob(ctx, "foo", []byte{1}); err != context.Canceled {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
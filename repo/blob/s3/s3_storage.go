@@ -12,7 +12,6 @@ import (
 	"github.com/minio/minio-go"
 	"github.com/pkg/errors"
 
-	"github.com/kopia/kopia/internal/retry"
 	"github.com/kopia/kopia/repo/blob"
 )
 
@@ -32,69 +31,52 @@ type s3Storage struct {
 }
 
 func (s *s3Storage) GetBlob(ctx context.Context, b blob.ID, offset, length int64) ([]byte, error) {
-	attempt := func() (interface{}, error) {
-		var opt minio.GetObjectOptions
-		if length > 0 {
-			if err := opt.SetRange(offset, offset+length-1); err != nil {
-				return nil, errors.Wrap(err, "unable to set range")
-			}
-		}
-
-		o, err := s.cli.GetObject(s.BucketName, s.getObjectNameString(b), opt)
-		if err != nil {
-			return 0, err
-		}
-
-		defer o.Close() //nolint:errcheck
-		throttled, err := s.downloadThrottler.AddReader(o)
-		if err != nil {
-			return nil, err
-		}
-
-		b, err := ioutil.ReadAll(throttled)
-		if err != nil {
-			return nil, err
-		}
-
-		if len(b) != int(length) && length > 0 {
-			return nil, errors.Errorf("invalid length, got %v bytes, but expected %v", len(b), length)
+	var opt minio.GetObjectOptions
+	if length > 0 {
+		if err := opt.SetRange(offset, offset+length-1); err != nil {
+			return nil, errors.Wrap(err, "unable to set range")
 		}
+	}
 
-		if length == 0 {
-			return []byte{}, nil
-		}
+	o, err := s.cli.GetObject(s.BucketName, s.getObjectNameString(b), opt)
+	if err != nil {
+		return nil, translateError(err)
+	}
 
-		return b, nil
+	defer o.Close() //nolint:errcheck
+	throttled, err := s.downloadThrottler.AddReader(o)
+	if err != nil {
+		return nil, err
 	}
 
-	v, err := exponentialBackoff(fmt.Sprintf("GetBlob(%q,%v,%v)", b, offset, length), attempt)
+	data, err := ioutil.ReadAll(throttled)
 	if err != nil {
 		return nil, translateError(err)
 	}
 
-	return v.([]byte), nil
-}
-
-func exponentialBackoff(desc string, att retry.AttemptFunc) (interface{}, error) {
-	return retry.WithExponentialBackoff(desc, att, isRetriableError)
-}
+	if len(data) != int(length) && length > 0 {
+		return nil, errors.Errorf("invalid length, got %v bytes, but expected %v", len(data), length)
+	}
 
-func isRetriableError(err error) bool {
-	if me, ok := err.(minio.ErrorResponse); ok {
-		// retry on server errors, not on client errors
-		return me.StatusCode >= 500
+	if length == 0 {
+		return []byte{}, nil
 	}
 
-	return false
+	return data, nil
 }
 
+// translateError maps S3 errors into blob.ErrorKind.
 func translateError(err error) error {
 	if me, ok := err.(minio.ErrorResponse); ok {
-		if me.StatusCode == 200 {
+		switch {
+		case me.StatusCode == 200:
 			return nil
-		}
-		if me.StatusCode == 404 {
+		case me.StatusCode == 404:
 			return blob.ErrBlobNotFound
+		case me.StatusCode == 429 || me.StatusCode == 503 || me.Code == "SlowDown":
+			return blob.NewError(blob.ErrorKindThrottled, err)
+		case me.StatusCode >= 500:
+			return blob.NewError(blob.ErrorKindTransient, err)
 		}
 	}
 
@@ -127,12 +109,7 @@ func (s *s3Storage) PutBlob(ctx context.Context, b blob.ID, data []byte) error {
 }
 
 func (s *s3Storage) DeleteBlob(ctx context.Context, b blob.ID) error {
-	attempt := func() (interface{}, error) {
-		return nil, s.cli.RemoveObject(s.BucketName, s.getObjectNameString(b))
-	}
-
-	_, err := exponentialBackoff(fmt.Sprintf("DeleteBlob(%q)", b), attempt)
-	return translateError(err)
+	return translateError(s.cli.RemoveObject(s.BucketName, s.getObjectNameString(b)))
 }
 
 func (s *s3Storage) getObjectNameString(b blob.ID) string {
@@ -2,9 +2,12 @@ package blob_test
 
 import (
 	"context"
+	"net"
 	"testing"
 	"time"
 
+	"github.com/pkg/errors"
+
 	"github.com/kopia/kopia/internal/blobtesting"
 	"github.com/kopia/kopia/repo/blob"
 )
@@ -55,3 +58,25 @@ func TestListAllBlobsConsistentEmpty(t *testing.T) {
 		t.Errorf("unexpected list result count: %v, want %v", got, want)
 	}
 }
+
+func TestErrorKindOf(t *testing.T) {
+	cases := []struct {
+		err  error
+		want blob.ErrorKind
+	}{
+		{errors.New("unknown"), blob.ErrorKindPermanent},
+		{blob.ErrBlobNotFound, blob.ErrorKindNotFound},
+		{errors.Wrap(blob.ErrBlobNotFound, "wrapped"), blob.ErrorKindNotFound},
+		{blob.NewError(blob.ErrorKindNotFound, errors.New("missing")), blob.ErrorKindNotFound},
+		{blob.NewError(blob.ErrorKindThrottled, errors.New("slow down")), blob.ErrorKindThrottled},
+		{errors.Wrap(blob.NewError(blob.ErrorKindTransient, errors.New("503")), "wrapped"), blob.ErrorKindTransient},
+		{&net.OpError{Op: "dial", Err: errors.New("connection refused")}, blob.ErrorKindTransient},
+		{context.Canceled, blob.ErrorKindPermanent},
+	}
+
+	for _, tc := range cases {
+		if got := blob.ErrorKindOf(tc.err); got != tc.want {
+			t.Errorf("unexpected kind of %v: %v, want %v", tc.err, got, tc.want)
+		}
+	}
+}
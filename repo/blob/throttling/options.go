@@ -0,0 +1,231 @@
+package throttling
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Limits specifies limits of storage operations, zero values mean no limit.
+type Limits struct {
+	UploadBytesPerSecond   int64 `json:"uploadBytesPerSecond,omitempty"`
+	DownloadBytesPerSecond int64 `json:"downloadBytesPerSecond,omitempty"`
+	MaxConcurrentOps       int   `json:"maxConcurrentOps,omitempty"`
+}
+
+// String returns human-readable representation of limits.
+func (l Limits) String() string {
+	return fmt.Sprintf("upload=%v download=%v ops=%v",
+		limitString(l.UploadBytesPerSecond), limitString(l.DownloadBytesPerSecond), limitString(int64(l.MaxConcurrentOps)))
+}
+
+func limitString(v int64) string {
+	if v == 0 {
+		return "unlimited"
+	}
+
+	return strconv.FormatInt(v, 10)
+}
+
+// ScheduledLimits specifies limits that apply during the given time of day on given days of the week.
+type ScheduledLimits struct {
+	Days  []time.Weekday `json:"days,omitempty"` // all days if empty
+	Start TimeOfDay      `json:"start"`
+	End   TimeOfDay      `json:"end"` // may be before Start, in which case the window spans midnight
+	Limits
+}
+
+// TimeOfDay represents the time of day in minutes since midnight.
+type TimeOfDay int
+
+// String returns HH:MM representation of the time of day.
+func (t TimeOfDay) String() string {
+	return fmt.Sprintf("%02v:%02v", int(t)/60, int(t)%60)
+}
+
+// ParseTimeOfDay parses time of day in HH:MM format.
+func ParseTimeOfDay(s string) (TimeOfDay, error) {
+	var h, m int
+
+	if _, err := fmt.Sscanf(s, "%d:%d", &h, &m); err != nil || h < 0 || h > 24 || m < 0 || m > 59 || h*60+m > 24*60 {
+		return 0, errors.Errorf("invalid time of day %q, expected HH:MM", s)
+	}
+
+	return TimeOfDay(h*60 + m), nil
+}
+
+var weekdayNames = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+func parseWeekday(s string) (time.Weekday, error) {
+	for i, n := range weekdayNames {
+		if strings.EqualFold(s, n) {
+			return time.Weekday(i), nil
+		}
+	}
+
+	return 0, errors.Errorf("invalid day of week %q", s)
+}
+
+// parseDays parses comma-separated list of days or day ranges such as 'mon-fri,sun'.
+func parseDays(s string) ([]time.Weekday, error) {
+	var result []time.Weekday
+
+	for _, part := range strings.Split(s, ",") {
+		r := strings.SplitN(part, "-", 2)
+
+		first, err := parseWeekday(r[0])
+		if err != nil {
+			return nil, err
+		}
+
+		last := first
+		if len(r) == 2 {
+			if last, err = parseWeekday(r[1]); err != nil {
+				return nil, err
+			}
+		}
+
+		for d := first; ; d = (d + 1) % 7 {
+			result = append(result, d)
+
+			if d == last {
+				break
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// ParseScheduledLimits parses scheduled limits in the format '[DAYS] HH:MM-HH:MM [upload=N] [download=N] [ops=N]',
+// for example 'mon-fri 09:00-17:00 upload=1000000 ops=2'.
+func ParseScheduledLimits(s string) (ScheduledLimits, error) {
+	var sl ScheduledLimits
+
+	fields := strings.Fields(s)
+	if len(fields) > 0 && !strings.Contains(fields[0], ":") {
+		days, err := parseDays(fields[0])
+		if err != nil {
+			return sl, err
+		}
+
+		sl.Days = days
+		fields = fields[1:]
+	}
+
+	if len(fields) == 0 {
+		return sl, errors.Errorf("missing time range in %q", s)
+	}
+
+	r := strings.SplitN(fields[0], "-", 2)
+	if len(r) != 2 {
+		return sl, errors.Errorf("invalid time range %q, expected HH:MM-HH:MM", fields[0])
+	}
+
+	var err error
+
+	if sl.Start, err = ParseTimeOfDay(r[0]); err != nil {
+		return sl, err
+	}
+
+	if sl.End, err = ParseTimeOfDay(r[1]); err != nil {
+		return sl, err
+	}
+
+	for _, f := range fields[1:] {
+		kv := strings.SplitN(f, "=", 2)
+		if len(kv) != 2 {
+			return sl, errors.Errorf("invalid limit %q, expected KEY=VALUE", f)
+		}
+
+		v, err := strconv.ParseInt(kv[1], 10, 64)
+		if err != nil || v < 0 {
+			return sl, errors.Errorf("invalid value of %v: %q", kv[0], kv[1])
+		}
+
+		switch kv[0] {
+		case "upload":
+			sl.UploadBytesPerSecond = v
+		case "download":
+			sl.DownloadBytesPerSecond = v
+		case "ops":
+			sl.MaxConcurrentOps = int(v)
+		default:
+			return sl, errors.Errorf("unknown limit %q, must be one of upload, download or ops", kv[0])
+		}
+	}
+
+	return sl, nil
+}
+
+// String returns the representation of scheduled limits that can be parsed using ParseScheduledLimits().
+func (sl ScheduledLimits) String() string {
+	var days []string
+	for _, d := range sl.Days {
+		days = append(days, weekdayNames[d])
+	}
+
+	prefix := ""
+	if len(days) > 0 {
+		prefix = strings.Join(days, ",") + " "
+	}
+
+	return fmt.Sprintf("%v%v-%v upload=%v download=%v ops=%v", prefix, sl.Start, sl.End,
+		sl.UploadBytesPerSecond, sl.DownloadBytesPerSecond, sl.MaxConcurrentOps)
+}
+
+// matches determines whether the scheduled limits apply at a given local time.
+func (sl ScheduledLimits) matches(t time.Time) bool {
+	tod := TimeOfDay(t.Hour()*60 + t.Minute())
+	day := t.Weekday()
+
+	if sl.Start > sl.End && tod < sl.End {
+		// early morning part of the window that started the previous day.
+		day = (day + 6) % 7
+	}
+
+	if len(sl.Days) > 0 && !containsWeekday(sl.Days, day) {
+		return false
+	}
+
+	if sl.Start <= sl.End {
+		return tod >= sl.Start && tod < sl.End
+	}
+
+	return tod >= sl.Start || tod < sl.End
+}
+
+func containsWeekday(days []time.Weekday, d time.Weekday) bool {
+	for _, v := range days {
+		if v == d {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Options specifies throttling of storage operations.
+type Options struct {
+	Limits
+	Schedule []ScheduledLimits `json:"schedule,omitempty"` // first matching entry replaces default limits
+}
+
+// LimitsAt returns the limits in effect at the given time.
+func (o *Options) LimitsAt(t time.Time) Limits {
+	for _, sl := range o.Schedule {
+		if sl.matches(t) {
+			return sl.Limits
+		}
+	}
+
+	return o.Limits
+}
+
+// IsEmpty returns true if the options don't impose any limits.
+func (o *Options) IsEmpty() bool {
+	return o.Limits == Limits{} && len(o.Schedule) == 0
+}
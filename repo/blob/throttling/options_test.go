@@ -0,0 +1,96 @@
+package throttling
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseScheduledLimits(t *testing.T) {
+	cases := []struct {
+		input   string
+		want    ScheduledLimits
+		wantErr bool
+	}{
+		{
+			input: "09:00-17:30 upload=1000",
+			want:  ScheduledLimits{Start: 9 * 60, End: 17*60 + 30, Limits: Limits{UploadBytesPerSecond: 1000}},
+		},
+		{
+			input: "mon-fri 09:00-17:00 upload=1000 download=2000 ops=3",
+			want: ScheduledLimits{
+				Days:   []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday},
+				Start:  9 * 60,
+				End:    17 * 60,
+				Limits: Limits{UploadBytesPerSecond: 1000, DownloadBytesPerSecond: 2000, MaxConcurrentOps: 3},
+			},
+		},
+		{
+			input: "sat-sun,wed 22:00-06:00",
+			want: ScheduledLimits{
+				Days:  []time.Weekday{time.Saturday, time.Sunday, time.Wednesday},
+				Start: 22 * 60,
+				End:   6 * 60,
+			},
+		},
+		{input: "", wantErr: true},
+		{input: "mon", wantErr: true},
+		{input: "09:00", wantErr: true},
+		{input: "25:00-26:00", wantErr: true},
+		{input: "xyz 09:00-10:00", wantErr: true},
+		{input: "09:00-10:00 speed=3", wantErr: true},
+		{input: "09:00-10:00 upload=-3", wantErr: true},
+	}
+
+	for _, tc := range cases {
+		got, err := ParseScheduledLimits(tc.input)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("unexpected error for %q: %v", tc.input, err)
+			continue
+		}
+
+		if tc.wantErr {
+			continue
+		}
+
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("unexpected result for %q: %+v, want %+v", tc.input, got, tc.want)
+		}
+
+		roundTrip, err := ParseScheduledLimits(got.String())
+		if err != nil || !reflect.DeepEqual(roundTrip, got) {
+			t.Errorf("%q does not round-trip: %+v %v", got.String(), roundTrip, err)
+		}
+	}
+}
+
+func TestLimitsAt(t *testing.T) {
+	opt := Options{
+		Limits: Limits{UploadBytesPerSecond: 1},
+		Schedule: []ScheduledLimits{
+			{Days: []time.Weekday{time.Monday, time.Tuesday}, Start: 9 * 60, End: 17 * 60, Limits: Limits{UploadBytesPerSecond: 2}},
+			{Days: []time.Weekday{time.Friday}, Start: 22 * 60, End: 6 * 60, Limits: Limits{UploadBytesPerSecond: 3}},
+		},
+	}
+
+	cases := []struct {
+		t    time.Time
+		want int64
+	}{
+		{time.Date(2019, 7, 1, 8, 59, 0, 0, time.UTC), 1},  // Monday
+		{time.Date(2019, 7, 1, 9, 0, 0, 0, time.UTC), 2},   // Monday
+		{time.Date(2019, 7, 2, 16, 59, 0, 0, time.UTC), 2}, // Tuesday
+		{time.Date(2019, 7, 2, 17, 0, 0, 0, time.UTC), 1},  // Tuesday
+		{time.Date(2019, 7, 3, 12, 0, 0, 0, time.UTC), 1},  // Wednesday
+		{time.Date(2019, 7, 5, 5, 0, 0, 0, time.UTC), 1},   // Friday morning, window started on Thursday
+		{time.Date(2019, 7, 5, 23, 0, 0, 0, time.UTC), 3},  // Friday night
+		{time.Date(2019, 7, 6, 5, 59, 0, 0, time.UTC), 3},  // Saturday morning, window started on Friday
+		{time.Date(2019, 7, 6, 6, 0, 0, 0, time.UTC), 1},   // Saturday
+	}
+
+	for _, tc := range cases {
+		if got := opt.LimitsAt(tc.t).UploadBytesPerSecond; got != tc.want {
+			t.Errorf("unexpected limit at %v: %v, want %v", tc.t, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,223 @@
+// Package throttling implements a wrapper around Storage that limits upload and download throughput
+// and the number of concurrent operations, with limits that can vary by time of day.
+package throttling
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/kopia/kopia/internal/repologging"
+	"github.com/kopia/kopia/repo/blob"
+)
+
+var log = repologging.Logger("repo/blob/throttling")
+
+// rateLimiter is a token bucket with adjustable rate, which allows up to one second worth of burst.
+// Requests larger than the bucket put the bucket in debt, which delays subsequent requests.
+type rateLimiter struct {
+	mu        sync.Mutex
+	available float64
+	last      time.Time
+}
+
+// wait blocks until n bytes can be transferred at the given rate.
+func (l *rateLimiter) wait(ctx context.Context, now time.Time, rate int64, n int64) error {
+	if rate <= 0 || n <= 0 {
+		return nil
+	}
+
+	l.mu.Lock()
+	if l.last.IsZero() {
+		l.available = float64(rate)
+	} else {
+		l.available += now.Sub(l.last).Seconds() * float64(rate)
+	}
+
+	if l.available > float64(rate) {
+		l.available = float64(rate)
+	}
+
+	l.last = now
+	l.available -= float64(n)
+	delay := time.Duration(-l.available / float64(rate) * float64(time.Second))
+	l.mu.Unlock()
+
+	if delay <= 0 {
+		return nil
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(delay):
+		return nil
+	}
+}
+
+// concurrencyLimiter limits the number of concurrent operations to a limit that can change over time.
+type concurrencyLimiter struct {
+	mu       sync.Mutex
+	inUse    int
+	released chan struct{} // closed and replaced when an operation completes
+}
+
+func (l *concurrencyLimiter) acquire(ctx context.Context, max func() int) error {
+	for {
+		l.mu.Lock()
+		if m := max(); m <= 0 || l.inUse < m {
+			l.inUse++
+			l.mu.Unlock()
+
+			return nil
+		}
+
+		if l.released == nil {
+			l.released = make(chan struct{})
+		}
+
+		ch := l.released
+		l.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ch:
+		case <-time.After(time.Minute):
+			// limits may have changed according to schedule.
+		}
+	}
+}
+
+func (l *concurrencyLimiter) release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.inUse--
+
+	if l.released != nil {
+		close(l.released)
+		l.released = nil
+	}
+}
+
+type throttlingStorage struct {
+	base    blob.Storage
+	timeNow func() time.Time
+
+	mu      sync.Mutex
+	opt     Options
+	current Limits
+
+	upload   rateLimiter
+	download rateLimiter
+	ops      concurrencyLimiter
+}
+
+// limits returns the limits currently in effect.
+func (s *throttlingStorage) limits() Limits {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	l := s.opt.LimitsAt(s.timeNow())
+	if l != s.current {
+		log.Infof("storage throttling limits changed to %v", l)
+		s.current = l
+	}
+
+	return l
+}
+
+func (s *throttlingStorage) beginOperation(ctx context.Context) error {
+	return s.ops.acquire(ctx, func() int { return s.limits().MaxConcurrentOps })
+}
+
+func (s *throttlingStorage) GetBlob(ctx context.Context, id blob.ID, offset, length int64) ([]byte, error) {
+	if err := s.beginOperation(ctx); err != nil {
+		return nil, err
+	}
+
+	data, err := s.base.GetBlob(ctx, id, offset, length)
+	s.ops.release()
+
+	if err != nil {
+		return nil, err
+	}
+
+	// the size of the download is not known in advance, so wait afterwards to maintain the average rate.
+	if err := s.download.wait(ctx, s.timeNow(), s.limits().DownloadBytesPerSecond, int64(len(data))); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+func (s *throttlingStorage) PutBlob(ctx context.Context, id blob.ID, data []byte) error {
+	if err := s.upload.wait(ctx, s.timeNow(), s.limits().UploadBytesPerSecond, int64(len(data))); err != nil {
+		return err
+	}
+
+	if err := s.beginOperation(ctx); err != nil {
+		return err
+	}
+	defer s.ops.release()
+
+	return s.base.PutBlob(ctx, id, data)
+}
+
+func (s *throttlingStorage) DeleteBlob(ctx context.Context, id blob.ID) error {
+	if err := s.beginOperation(ctx); err != nil {
+		return err
+	}
+	defer s.ops.release()
+
+	return s.base.DeleteBlob(ctx, id)
+}
+
+func (s *throttlingStorage) ListBlobs(ctx context.Context, prefix blob.ID, callback func(blob.Metadata) error) error {
+	if err := s.beginOperation(ctx); err != nil {
+		return err
+	}
+
+	// collect results before invoking callbacks, which may perform other operations.
+	all, err := blob.ListAllBlobs(ctx, s.base, prefix)
+	s.ops.release()
+
+	if err != nil {
+		return err
+	}
+
+	for _, bm := range all {
+		if err := callback(bm); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *throttlingStorage) ConnectionInfo() blob.ConnectionInfo {
+	return s.base.ConnectionInfo()
+}
+
+func (s *throttlingStorage) Close(ctx context.Context) error {
+	return s.base.Close(ctx)
+}
+
+// NewWrapper returns a Storage wrapper that throttles operations according to the provided options.
+// Scheduled limits are evaluated using local time for each operation, so they take effect in long-running processes.
+func NewWrapper(st blob.Storage, opt Options) blob.Storage {
+	return newWrapperWithClock(st, opt, time.Now)
+}
+
+func newWrapperWithClock(st blob.Storage, opt Options, timeNow func() time.Time) *throttlingStorage {
+	s := &throttlingStorage{
+		base:    st,
+		timeNow: timeNow,
+		opt:     opt,
+	}
+
+	s.current = opt.LimitsAt(timeNow())
+
+	return s
+}
@@ -0,0 +1,118 @@
+package throttling
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kopia/kopia/internal/blobtesting"
+	"github.com/kopia/kopia/repo/blob"
+)
+
+func TestThrottlingStorage(t *testing.T) {
+	ctx := context.Background()
+	st := NewWrapper(blobtesting.NewMapStorage(blobtesting.DataMap{}, nil, nil), Options{
+		Limits: Limits{MaxConcurrentOps: 1},
+	})
+
+	blobtesting.VerifyStorage(ctx, t, st)
+}
+
+func TestUploadRateLimit(t *testing.T) {
+	ctx := context.Background()
+	st := NewWrapper(blobtesting.NewMapStorage(blobtesting.DataMap{}, nil, nil), Options{
+		Limits: Limits{UploadBytesPerSecond: 10000},
+	})
+
+	t0 := time.Now()
+
+	// first two uploads consume the initial burst, the third one must wait.
+	for _, id := range []blob.ID{"a", "b", "c"} {
+		if err := st.PutBlob(ctx, id, make([]byte, 5000)); err != nil {
+			t.Fatalf("unable to put blob: %v", err)
+		}
+	}
+
+	if dt := time.Since(t0); dt < 400*time.Millisecond {
+		t.Errorf("uploads were not throttled, took %v", dt)
+	}
+
+	// context cancellation interrupts waiting.
+	ctx2, cancel := context.WithCancel(ctx)
+	cancel()
+
+	if err := st.PutBlob(ctx2, "d", make([]byte, 50000)); err != context.Canceled {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+type slowStorage struct {
+	blob.Storage
+
+	active    int32
+	maxActive int32
+}
+
+func (s *slowStorage) PutBlob(ctx context.Context, id blob.ID, data []byte) error {
+	n := atomic.AddInt32(&s.active, 1)
+	defer atomic.AddInt32(&s.active, -1)
+
+	for {
+		m := atomic.LoadInt32(&s.maxActive)
+		if n <= m || atomic.CompareAndSwapInt32(&s.maxActive, m, n) {
+			break
+		}
+	}
+
+	time.Sleep(10 * time.Millisecond)
+
+	return s.Storage.PutBlob(ctx, id, data)
+}
+
+func TestConcurrencyLimitSchedule(t *testing.T) {
+	ctx := context.Background()
+	base := &slowStorage{Storage: blobtesting.NewMapStorage(blobtesting.DataMap{}, nil, nil)}
+
+	var now atomic.Value
+	now.Store(time.Date(2019, 7, 1, 10, 0, 0, 0, time.Local)) // Monday
+
+	st := newWrapperWithClock(base, Options{
+		Limits: Limits{MaxConcurrentOps: 4},
+		Schedule: []ScheduledLimits{
+			{Days: []time.Weekday{time.Monday}, Start: 9 * 60, End: 17 * 60, Limits: Limits{MaxConcurrentOps: 1}},
+		},
+	}, func() time.Time { return now.Load().(time.Time) })
+
+	putInParallel := func() {
+		var wg sync.WaitGroup
+
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+
+			go func(i int) {
+				defer wg.Done()
+
+				if err := st.PutBlob(ctx, blob.ID(rune('a'+i)), []byte{1}); err != nil {
+					t.Errorf("unable to put blob: %v", err)
+				}
+			}(i)
+		}
+
+		wg.Wait()
+	}
+
+	putInParallel()
+
+	if got := atomic.LoadInt32(&base.maxActive); got != 1 {
+		t.Errorf("unexpected concurrency during business hours: %v", got)
+	}
+
+	now.Store(time.Date(2019, 7, 1, 18, 0, 0, 0, time.Local))
+	putInParallel()
+
+	if got := atomic.LoadInt32(&base.maxActive); got <= 1 || got > 4 {
+		t.Errorf("unexpected concurrency outside of business hours: %v", got)
+	}
+}
@@ -13,7 +13,6 @@ import (
 	"github.com/pkg/errors"
 	"github.com/studio-b12/gowebdav"
 
-	"github.com/kopia/kopia/internal/retry"
 	"github.com/kopia/kopia/repo/blob"
 	"github.com/kopia/kopia/repo/blob/sharded"
 )
@@ -45,13 +44,11 @@ type davStorageImpl struct {
 }
 
 func (d *davStorageImpl) GetBlobFromPath(ctx context.Context, dirPath, path string, offset, length int64) ([]byte, error) {
-	v, err := retry.WithExponentialBackoff("GetBlobFromPath", func() (interface{}, error) {
-		return d.cli.Read(path)
-	}, isRetriable)
+	data, err := d.cli.Read(path)
 	if err != nil {
 		return nil, d.translateError(err)
 	}
-	data := v.([]byte)
+
 	if length < 0 {
 		return data, nil
 	}
@@ -80,46 +77,53 @@ func httpErrorCode(err error) int {
 }
 
 func (d *davStorageImpl) translateError(err error) error {
-	switch err := err.(type) {
+	if err, ok := err.(*os.PathError); ok && httpErrorCode(err) == http.StatusNotFound {
+		return blob.ErrBlobNotFound
+	}
+
+	return classifyError(err)
+}
+
+// classifyError maps WebDAV errors into blob.ErrorKind, leaving permanent errors unchanged.
+func classifyError(err error) error {
+	switch e := err.(type) {
+	case nil:
+		return nil
+
 	case *os.PathError:
-		switch httpErrorCode(err) {
-		case http.StatusNotFound:
-			return blob.ErrBlobNotFound
+		switch httpCode := httpErrorCode(e); {
+		case httpCode == http.StatusTooManyRequests:
+			return blob.NewError(blob.ErrorKindThrottled, err)
+		case httpCode >= 500:
+			return blob.NewError(blob.ErrorKindTransient, err)
 		default:
 			return err
 		}
+
 	default:
-		return err
+		return blob.NewError(blob.ErrorKindTransient, err)
 	}
 }
 
 func (d *davStorageImpl) ReadDir(ctx context.Context, dir string) ([]os.FileInfo, error) {
-	v, err := retry.WithExponentialBackoff("ReadDir("+dir+")", func() (interface{}, error) {
-		return d.cli.ReadDir(gowebdav.FixSlash(dir))
-	}, isRetriable)
-	if err == nil {
-		return v.([]os.FileInfo), nil
+	entries, err := d.cli.ReadDir(gowebdav.FixSlash(dir))
+	if err != nil {
+		return nil, classifyError(err)
 	}
 
-	return nil, err
+	return entries, nil
 }
 
 func (d *davStorageImpl) PutBlobInPath(ctx context.Context, dirPath, filePath string, data []byte) error {
 	tmpPath := fmt.Sprintf("%v-%v", filePath, rand.Int63())
-	if err := d.translateError(retry.WithExponentialBackoffNoValue("Write", func() error {
-		return d.cli.Write(tmpPath, data, defaultFilePerm)
-	}, isRetriable)); err != nil {
+	if err := d.translateError(d.cli.Write(tmpPath, data, defaultFilePerm)); err != nil {
 		if err != blob.ErrBlobNotFound {
 			return err
 		}
 
-		_ = retry.WithExponentialBackoffNoValue("MkdirAll", func() error {
-			return d.cli.MkdirAll(dirPath, defaultDirPerm)
-		}, isRetriable)
+		_ = d.cli.MkdirAll(dirPath, defaultDirPerm)
 
-		if err := d.translateError(retry.WithExponentialBackoffNoValue("Write", func() error {
-			return d.cli.Write(tmpPath, data, defaultFilePerm)
-		}, isRetriable)); err != nil {
+		if err := d.translateError(d.cli.Write(tmpPath, data, defaultFilePerm)); err != nil {
 			return err
 		}
 	}
@@ -128,9 +132,7 @@ func (d *davStorageImpl) PutBlobInPath(ctx context.Context, dirPath, filePath st
 }
 
 func (d *davStorageImpl) DeleteBlobInPath(ctx context.Context, dirPath, filePath string) error {
-	return d.translateError(retry.WithExponentialBackoffNoValue("DeleteBlobInPath", func() error {
-		return d.cli.Remove(filePath)
-	}, isRetriable))
+	return d.translateError(d.cli.Remove(filePath))
 }
 
 func (d *davStorage) ConnectionInfo() blob.ConnectionInfo {
@@ -144,20 +146,6 @@ func (d *davStorage) Close(ctx context.Context) error {
 	return nil
 }
 
-func isRetriable(err error) bool {
-	switch err := err.(type) {
-	case nil:
-		return false
-
-	case *os.PathError:
-		httpCode := httpErrorCode(err)
-		return httpCode == 429 || httpCode >= 500
-
-	default:
-		return true
-	}
-}
-
 // New creates new WebDAV-backed storage in a specified URL.
 func New(ctx context.Context, opts *Options) (blob.Storage, error) {
 	return &davStorage{
@@ -11,18 +11,31 @@ import (
 
 	"github.com/pkg/errors"
 
+	"github.com/kopia/kopia/internal/retry"
 	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/blob/obfuscate"
+	"github.com/kopia/kopia/repo/blob/throttling"
 	"github.com/kopia/kopia/repo/content"
 )
 
 // ConnectOptions specifies options when persisting configuration to connect to a repository.
 type ConnectOptions struct {
 	content.CachingOptions
+	Throttling throttling.Options
+	Retry      *retry.Policy // nil for default
 }
 
 // Connect connects to the repository in the specified storage and persists the configuration and credentials in the file provided.
 func Connect(ctx context.Context, configFile string, st blob.Storage, password string, opt ConnectOptions) error {
+	var lc LocalConfig
+	lc.Storage = st.ConnectionInfo()
+
 	formatBytes, err := st.GetBlob(ctx, FormatBlobID, 0, -1)
+	if err == blob.ErrBlobNotFound {
+		// format blob may be hidden by blob name obfuscation.
+		formatBytes, lc.Obfuscation, err = readObfuscatedFormatBlob(ctx, st, password)
+	}
+
 	if err != nil {
 		return errors.Wrap(err, "unable to read format blob")
 	}
@@ -32,8 +45,17 @@ func Connect(ctx context.Context, configFile string, st blob.Storage, password s
 		return err
 	}
 
-	var lc LocalConfig
-	lc.Storage = st.ConnectionInfo()
+	if opt.Retry != nil {
+		if err = opt.Retry.Validate(); err != nil {
+			return errors.Wrap(err, "invalid retry policy")
+		}
+
+		lc.Retry = opt.Retry
+	}
+
+	if !opt.Throttling.IsEmpty() {
+		lc.Throttling = &opt.Throttling
+	}
 
 	if err = setupCaching(configFile, &lc, opt.CachingOptions, f.UniqueID); err != nil {
 		return errors.Wrap(err, "unable to set up caching")
@@ -61,6 +83,29 @@ func Connect(ctx context.Context, configFile string, st blob.Storage, password s
 	return r.Close(ctx)
 }
 
+func readObfuscatedFormatBlob(ctx context.Context, st blob.Storage, password string) ([]byte, *obfuscate.Parameters, error) {
+	p, err := obfuscate.ReadParameters(ctx, st)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ost, err := obfuscatedStorage(st, p, password)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	b, err := ost.GetBlob(ctx, FormatBlobID, 0, -1)
+	if err == blob.ErrBlobNotFound {
+		return nil, nil, errors.New("format blob not found in obfuscated storage, the password may be incorrect")
+	}
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return b, p, nil
+}
+
 func setupCaching(configPath string, lc *LocalConfig, opt content.CachingOptions, uniqueID []byte) error {
 	if opt.MaxCacheSizeBytes == 0 {
 		lc.Caching = content.CachingOptions{}
@@ -216,8 +216,9 @@ func (bm *Manager) finishPackAndMaybeFlushIndexesLocked(ctx context.Context, pre
 }
 
 // Stats returns statistics about content manager operations.
+// It is safe to call while contents are being written concurrently.
 func (bm *Manager) Stats() Stats {
-	return bm.stats
+	return bm.stats.snapshot()
 }
 
 // ResetStats resets statistics to zero values.
@@ -794,6 +795,15 @@ func (bm *Manager) WriteContent(ctx context.Context, data []byte, prefix ID) (ID
 	return contentID, err
 }
 
+// ComputeContentID returns the ID under which the provided data would be stored with a given prefix, without storing it.
+func (bm *Manager) ComputeContentID(data []byte, prefix ID) (ID, error) {
+	if err := validatePrefix(prefix); err != nil {
+		return "", err
+	}
+
+	return prefix + ID(hex.EncodeToString(bm.hasher(data))), nil
+}
+
 func validatePrefix(prefix ID) error {
 	switch len(prefix) {
 	case 0:
@@ -920,6 +920,70 @@ func verifyContentManagerDataSet(ctx context.Context, t *testing.T, mgr *Manager
 	}
 }
 
+func TestVerifyPack(t *testing.T) {
+	ctx := context.Background()
+	data := blobtesting.DataMap{}
+	keyTime := map[blob.ID]time.Time{}
+	bm := newTestContentManager(data, keyTime, nil)
+
+	var ids []ID
+	for i := 0; i < 3; i++ {
+		ids = append(ids, writeContentAndVerify(ctx, t, bm, seededRandomData(i, 100)))
+	}
+
+	if err := bm.Flush(ctx); err != nil {
+		t.Fatalf("flush error: %v", err)
+	}
+
+	var packs []PackInfo
+	if err := bm.IteratePacks(IteratePackOptions{IncludeContentInfos: true}, func(pi PackInfo) error {
+		packs = append(packs, pi)
+		return nil
+	}); err != nil {
+		t.Fatalf("iterate packs error: %v", err)
+	}
+
+	if len(packs) != 1 {
+		t.Fatalf("unexpected number of packs: %v", len(packs))
+	}
+
+	pi := packs[0]
+
+	verifyPackErrors(ctx, t, bm, pi, nil)
+
+	// corrupt a single content.
+	ci, err := bm.ContentInfo(ctx, ids[1])
+	if err != nil {
+		t.Fatalf("content info error: %v", err)
+	}
+
+	data[pi.PackID][ci.PackOffset+5] ^= 1
+	verifyPackErrors(ctx, t, bm, pi, []ID{ids[1]})
+
+	// missing pack makes all contents corrupt.
+	delete(data, pi.PackID)
+	verifyPackErrors(ctx, t, bm, pi, ids)
+}
+
+func verifyPackErrors(ctx context.Context, t *testing.T, bm *Manager, pi PackInfo, wantCorrupt []ID) {
+	t.Helper()
+
+	corrupt, err := bm.VerifyPack(ctx, pi.PackID, pi.ContentInfos)
+	if err != nil {
+		t.Fatalf("verify error: %v", err)
+	}
+
+	if len(corrupt) != len(wantCorrupt) {
+		t.Errorf("unexpected corrupt contents: %v, want %v", corrupt, wantCorrupt)
+	}
+
+	for _, id := range wantCorrupt {
+		if corrupt[id] == nil {
+			t.Errorf("content %v not reported as corrupt", id)
+		}
+	}
+}
+
 func newTestContentManager(data blobtesting.DataMap, keyTime map[blob.ID]time.Time, timeFunc func() time.Time) *Manager {
 	if timeFunc == nil {
 		timeFunc = fakeTimeNowWithAutoAdvance(fakeTime, 1*time.Second)
@@ -0,0 +1,60 @@
+package content
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo/blob"
+)
+
+// VerifyPack downloads the entire pack blob and verifies the provided contents stored in it against
+// their index entries by decrypting them and checking their hashes. Returns errors for corrupt contents
+// keyed by content ID. The returned error indicates failure to perform verification.
+func (bm *Manager) VerifyPack(ctx context.Context, packID blob.ID, contents []Info) (map[ID]error, error) {
+	data, err := bm.st.GetBlob(ctx, packID, 0, -1)
+	if err == blob.ErrBlobNotFound {
+		result := map[ID]error{}
+		for _, ci := range contents {
+			result[ci.ID] = errors.Errorf("pack blob %v not found", packID)
+		}
+
+		return result, nil
+	}
+
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to read pack %v", packID)
+	}
+
+	result := map[ID]error{}
+
+	for _, ci := range contents {
+		if err := bm.verifyContentInPack(ci, packID, data); err != nil {
+			result[ci.ID] = err
+		}
+	}
+
+	return result, nil
+}
+
+func (bm *Manager) verifyContentInPack(ci Info, packID blob.ID, packData []byte) error {
+	if ci.PackBlobID != packID {
+		return errors.Errorf("content is indexed in pack %v", ci.PackBlobID)
+	}
+
+	end := int64(ci.PackOffset) + int64(ci.Length)
+	if end > int64(len(packData)) {
+		return errors.Errorf("content at offset %v length %v extends beyond the end of pack (%v bytes)", ci.PackOffset, ci.Length, len(packData))
+	}
+
+	iv, err := getPackedContentIV(ci.ID)
+	if err != nil {
+		return errors.Wrap(err, "invalid content ID")
+	}
+
+	if _, err := bm.decryptAndVerify(cloneBytes(packData[ci.PackOffset:end]), iv); err != nil {
+		return errors.Wrapf(err, "invalid checksum at offset %v length %v", ci.PackOffset, ci.Length)
+	}
+
+	return nil
+}
@@ -1,5 +1,7 @@
 package content
 
+import "sync/atomic"
+
 // Stats exposes statistics about content operation.
 type Stats struct {
 	// Keep int64 fields first to ensure they get aligned to at least 64-bit boundaries
@@ -23,3 +25,22 @@ type Stats struct {
 func (s *Stats) Reset() {
 	*s = Stats{}
 }
+
+// snapshot returns a copy of statistics using atomic loads, since counters are updated atomically.
+func (s *Stats) snapshot() Stats {
+	return Stats{
+		ReadBytes:      atomic.LoadInt64(&s.ReadBytes),
+		WrittenBytes:   atomic.LoadInt64(&s.WrittenBytes),
+		DecryptedBytes: atomic.LoadInt64(&s.DecryptedBytes),
+		EncryptedBytes: atomic.LoadInt64(&s.EncryptedBytes),
+		HashedBytes:    atomic.LoadInt64(&s.HashedBytes),
+
+		ReadContents:    atomic.LoadInt32(&s.ReadContents),
+		WrittenContents: atomic.LoadInt32(&s.WrittenContents),
+		CheckedContents: atomic.LoadInt32(&s.CheckedContents),
+		HashedContents:  atomic.LoadInt32(&s.HashedContents),
+		InvalidContents: atomic.LoadInt32(&s.InvalidContents),
+		PresentContents: atomic.LoadInt32(&s.PresentContents),
+		ValidContents:   atomic.LoadInt32(&s.ValidContents),
+	}
+}
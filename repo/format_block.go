@@ -67,6 +67,16 @@ func parseFormatBlob(b []byte) (*formatBlob, error) {
 	return f, nil
 }
 
+// FormatBlobUniqueID returns the unique ID of the repository described by the provided format blob contents.
+func FormatBlobUniqueID(b []byte) ([]byte, error) {
+	f, err := parseFormatBlob(b)
+	if err != nil {
+		return nil, err
+	}
+
+	return f.UniqueID, nil
+}
+
 // RecoverFormatBlob attempts to recover format blob replica from the specified file.
 // The format blob can be either the prefix or a suffix of the given file.
 // optionally the length can be provided (if known) to speed up recovery.
@@ -100,33 +110,70 @@ func recoverFormatBlobWithLength(ctx context.Context, st blob.Storage, blobID bl
 		chunkLength = length
 	}
 
-	if chunkLength > 4 {
-		// try prefix
-		prefixChunk, err := st.GetBlob(ctx, blobID, 0, chunkLength)
-		if err != nil {
-			return nil, err
-		}
-		if l := int(prefixChunk[0]) + int(prefixChunk[1])<<8; l <= maxChecksummedFormatBytesLength && l+2 < len(prefixChunk) {
-			if b, ok := verifyFormatBlobChecksum(prefixChunk[2 : 2+l]); ok {
-				return b, nil
-			}
-		}
+	if chunkLength <= 4 {
+		return nil, errFormatBlobNotFound
+	}
 
-		// try the suffix
-		suffixChunk, err := st.GetBlob(ctx, blobID, length-chunkLength, chunkLength)
-		if err != nil {
-			return nil, err
-		}
-		if l := int(suffixChunk[len(suffixChunk)-2]) + int(suffixChunk[len(suffixChunk)-1])<<8; l <= maxChecksummedFormatBytesLength && l+2 < len(suffixChunk) {
-			if b, ok := verifyFormatBlobChecksum(suffixChunk[len(suffixChunk)-2-l : len(suffixChunk)-2]); ok {
-				return b, nil
-			}
+	// try prefix
+	prefixChunk, err := st.GetBlob(ctx, blobID, 0, chunkLength)
+	if err != nil {
+		return nil, err
+	}
+
+	if b, ok := formatBlobFromPrefix(prefixChunk); ok {
+		return b, nil
+	}
+
+	// try the suffix
+	suffixChunk, err := st.GetBlob(ctx, blobID, length-chunkLength, chunkLength)
+	if err != nil {
+		return nil, err
+	}
+
+	if b, ok := formatBlobFromSuffix(suffixChunk); ok {
+		return b, nil
+	}
+
+	// storage that pads blob sizes lists and reads padded contents, only full reads
+	// return the original contents, which end with the format blob.
+	fullContents, err := st.GetBlob(ctx, blobID, 0, -1)
+	if err != nil {
+		return nil, err
+	}
+
+	if int64(len(fullContents)) < length {
+		if b, ok := formatBlobFromSuffix(fullContents); ok {
+			return b, nil
 		}
 	}
 
 	return nil, errFormatBlobNotFound
 }
 
+func formatBlobFromPrefix(chunk []byte) ([]byte, bool) {
+	if len(chunk) < 2 {
+		return nil, false
+	}
+
+	if l := int(chunk[0]) + int(chunk[1])<<8; l <= maxChecksummedFormatBytesLength && l+2 < len(chunk) {
+		return verifyFormatBlobChecksum(chunk[2 : 2+l])
+	}
+
+	return nil, false
+}
+
+func formatBlobFromSuffix(chunk []byte) ([]byte, bool) {
+	if len(chunk) < 2 {
+		return nil, false
+	}
+
+	if l := int(chunk[len(chunk)-2]) + int(chunk[len(chunk)-1])<<8; l <= maxChecksummedFormatBytesLength && l+2 < len(chunk) {
+		return verifyFormatBlobChecksum(chunk[len(chunk)-2-l : len(chunk)-2])
+	}
+
+	return nil, false
+}
+
 func verifyFormatBlobChecksum(b []byte) ([]byte, bool) {
 	if len(b) < formatBlobChecksumSize {
 		return nil, false
@@ -144,20 +191,29 @@ func verifyFormatBlobChecksum(b []byte) ([]byte, bool) {
 }
 
 func writeFormatBlob(ctx context.Context, st blob.Storage, f *formatBlob) error {
-	var buf bytes.Buffer
-	e := json.NewEncoder(&buf)
-	e.SetIndent("", "  ")
-	if err := e.Encode(f); err != nil {
-		return errors.Wrap(err, "unable to marshal format blob")
+	b, err := serializeFormatBlob(f)
+	if err != nil {
+		return err
 	}
 
-	if err := st.PutBlob(ctx, FormatBlobID, buf.Bytes()); err != nil {
+	if err := st.PutBlob(ctx, FormatBlobID, b); err != nil {
 		return errors.Wrap(err, "unable to write format blob")
 	}
 
 	return nil
 }
 
+func serializeFormatBlob(f *formatBlob) ([]byte, error) {
+	var buf bytes.Buffer
+	e := json.NewEncoder(&buf)
+	e.SetIndent("", "  ")
+	if err := e.Encode(f); err != nil {
+		return nil, errors.Wrap(err, "unable to marshal format blob")
+	}
+
+	return buf.Bytes(), nil
+}
+
 func (f *formatBlob) decryptFormatBytes(masterKey []byte) (*repositoryObjectFormat, error) {
 	switch f.EncryptionAlgorithm {
 	case "NONE": // do nothing
@@ -8,11 +8,28 @@ import (
 
 	"github.com/kopia/kopia/internal/blobtesting"
 	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/blob/obfuscate"
 )
 
 func TestFormatBlobRecovery(t *testing.T) {
-	data := blobtesting.DataMap{}
-	st := blobtesting.NewMapStorage(data, nil, nil)
+	verifyFormatBlobRecovery(t, blobtesting.NewMapStorage(blobtesting.DataMap{}, nil, nil))
+}
+
+func TestFormatBlobRecoveryWithPaddedBlobSizes(t *testing.T) {
+	p, err := obfuscate.NewParameters(true)
+	if err != nil {
+		t.Fatalf("unable to create parameters: %v", err)
+	}
+
+	st, err := obfuscate.NewWrapper(blobtesting.NewMapStorage(blobtesting.DataMap{}, nil, nil), p, make([]byte, 32))
+	if err != nil {
+		t.Fatalf("unable to create wrapper: %v", err)
+	}
+
+	verifyFormatBlobRecovery(t, st)
+}
+
+func verifyFormatBlobRecovery(t *testing.T, st blob.Storage) {
 	ctx := context.Background()
 
 	someDataBlock := []byte("aadsdasdas")
@@ -8,6 +8,8 @@ import (
 	"github.com/pkg/errors"
 
 	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/blob/obfuscate"
+	"github.com/kopia/kopia/repo/blob/parity"
 	"github.com/kopia/kopia/repo/content"
 	"github.com/kopia/kopia/repo/object"
 )
@@ -24,7 +26,11 @@ type NewRepositoryOptions struct {
 	UniqueID     []byte // force the use of particular unique ID
 	BlockFormat  content.FormattingOptions
 	DisableHMAC  bool
-	ObjectFormat object.Format // object format
+	ObjectFormat object.Format   // object format
+	Parity       *parity.Options // parity data written for pack blobs, nil disables
+
+	ObfuscateBlobNames bool // hide blob names from the storage provider
+	PadBlobSizes       bool // hide approximate blob sizes from the storage provider, requires ObfuscateBlobNames
 }
 
 // Initialize creates initial repository data structures in the specified storage with given credentials.
@@ -42,6 +48,21 @@ func Initialize(ctx context.Context, st blob.Storage, opt *NewRepositoryOptions,
 		return err
 	}
 
+	if opt.ObfuscateBlobNames {
+		st, err = initializeObfuscation(ctx, st, opt.PadBlobSizes, password)
+		if err != nil {
+			return errors.Wrap(err, "unable to initialize blob name obfuscation")
+		}
+	} else if opt.PadBlobSizes {
+		return errors.New("padding blob sizes requires obfuscation of blob names")
+	}
+
+	if opt.Parity != nil {
+		if err := opt.Parity.Validate(); err != nil {
+			return errors.Wrap(err, "invalid parity options")
+		}
+	}
+
 	format := formatBlobFromOptions(opt)
 	masterKey, err := format.deriveMasterKeyFromPassword(password)
 	if err != nil {
@@ -59,6 +80,27 @@ func Initialize(ctx context.Context, st blob.Storage, opt *NewRepositoryOptions,
 	return nil
 }
 
+func initializeObfuscation(ctx context.Context, st blob.Storage, padSizes bool, password string) (blob.Storage, error) {
+	if _, err := obfuscate.ReadParameters(ctx, st); err != blob.ErrBlobNotFound {
+		if err == nil {
+			return nil, errors.Errorf("repository already initialized")
+		}
+
+		return nil, err
+	}
+
+	p, err := obfuscate.NewParameters(padSizes)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := obfuscate.WriteParameters(ctx, st, p); err != nil {
+		return nil, errors.Wrap(err, "unable to write obfuscation parameters")
+	}
+
+	return obfuscatedStorage(st, p, password)
+}
+
 func formatBlobFromOptions(opt *NewRepositoryOptions) *formatBlob {
 	f := &formatBlob{
 		Tool:                   "https://github.com/kopia/kopia",
@@ -89,6 +131,7 @@ func repositoryObjectFormatFromOptions(opt *NewRepositoryOptions) *repositoryObj
 		Format: object.Format{
 			Splitter: applyDefaultString(opt.ObjectFormat.Splitter, object.DefaultSplitter),
 		},
+		Parity: opt.Parity,
 	}
 
 	if opt.DisableHMAC {
@@ -5,7 +5,11 @@ import (
 	"io"
 	"os"
 
+	"github.com/kopia/kopia/internal/retry"
 	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/blob/obfuscate"
+	"github.com/kopia/kopia/repo/blob/parity"
+	"github.com/kopia/kopia/repo/blob/throttling"
 	"github.com/kopia/kopia/repo/content"
 	"github.com/kopia/kopia/repo/object"
 )
@@ -14,12 +18,18 @@ import (
 type LocalConfig struct {
 	Storage blob.ConnectionInfo    `json:"storage"`
 	Caching content.CachingOptions `json:"caching"`
+
+	Obfuscation *obfuscate.Parameters `json:"obfuscation,omitempty"` // blob name obfuscation, nil if disabled
+	Throttling  *throttling.Options   `json:"throttling,omitempty"`  // storage throttling, nil if disabled
+	Retry       *retry.Policy         `json:"retry,omitempty"`       // storage retry policy, nil for default
 }
 
 // repositoryObjectFormat describes the format of objects in a repository.
 type repositoryObjectFormat struct {
 	content.FormattingOptions
 	object.Format
+
+	Parity *parity.Options `json:"parity,omitempty"` // parity data for pack blobs, nil when disabled
 }
 
 // Load reads local configuration from the specified reader.
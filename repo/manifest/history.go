@@ -0,0 +1,171 @@
+package manifest
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// History returns all retained versions of the provided manifest entry, including deleted ones, oldest first.
+// If no version is retained, returns ErrNotFound.
+func (m *Manager) History(ctx context.Context, id ID) ([]*EntryMetadata, error) {
+	if err := m.ensureInitialized(ctx); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	versions := append([]*manifestEntry(nil), m.committedHistory[id]...)
+
+	if e := m.committedEntries[id]; e != nil {
+		versions = append(versions, e)
+	}
+
+	if e := m.pendingEntries[id]; e != nil {
+		versions = append(versions, e)
+	}
+
+	if len(versions) == 0 {
+		return nil, ErrNotFound
+	}
+
+	result := make([]*EntryMetadata, len(versions))
+	for i, e := range versions {
+		result[i] = cloneEntryMetadata(e)
+	}
+
+	return result, nil
+}
+
+// GetDeleted retrieves the contents of the provided deleted manifest item that is still retained
+// by deserializing it as JSON to provided object. If the manifest is not deleted or no longer retained, returns ErrNotFound.
+func (m *Manager) GetDeleted(ctx context.Context, id ID, data interface{}) error {
+	if err := m.ensureInitialized(ctx); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	b := m.deletedContentLocked(id)
+	m.mu.Unlock()
+
+	if b == nil {
+		return ErrNotFound
+	}
+
+	if err := json.Unmarshal(b, data); err != nil {
+		return errors.Wrapf(err, "unable to unmashal %q", id)
+	}
+
+	return nil
+}
+
+// Undelete restores the provided deleted manifest entry, which must still be retained.
+func (m *Manager) Undelete(ctx context.Context, id ID) error {
+	if err := m.ensureInitialized(ctx); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e := m.currentEntryLocked(id)
+	if e == nil {
+		return ErrNotFound
+	}
+
+	if !e.Deleted {
+		return errors.Errorf("manifest %v is not deleted", id)
+	}
+
+	b := m.deletedContentLocked(id)
+	if b == nil {
+		return errors.Errorf("contents of deleted manifest %v are no longer retained", id)
+	}
+
+	labels := e.Labels
+	if prev := m.lastLiveVersionLocked(id); len(labels) == 0 && prev != nil {
+		labels = prev.Labels
+	}
+
+	m.pendingEntries[id] = &manifestEntry{
+		ID:      id,
+		ModTime: time.Now().UTC(),
+		Labels:  copyLabels(labels),
+		Content: b,
+	}
+
+	return nil
+}
+
+// deletedContentLocked returns the contents of the deleted entry, which are stored in the tombstone
+// or, for tombstones written by older versions, in the last retained version before deletion.
+func (m *Manager) deletedContentLocked(id ID) []byte {
+	e := m.currentEntryLocked(id)
+	if e == nil || !e.Deleted {
+		return nil
+	}
+
+	if e.Content != nil {
+		return e.Content
+	}
+
+	if prev := m.lastLiveVersionLocked(id); prev != nil {
+		return prev.Content
+	}
+
+	return nil
+}
+
+func (m *Manager) lastLiveVersionLocked(id ID) *manifestEntry {
+	h := m.committedHistory[id]
+	for i := len(h) - 1; i >= 0; i-- {
+		if !h[i].Deleted {
+			return h[i]
+		}
+	}
+
+	return nil
+}
+
+// pruneHistoryLocked removes versions superseded before the cutoff time and entries deleted before the cutoff time.
+func (m *Manager) pruneHistoryLocked(cutoff time.Time) {
+	for id, h := range m.committedHistory {
+		// each version is retained until the time its successor was written plus the retention period.
+		successor := func(i int) *manifestEntry {
+			if i+1 < len(h) {
+				return h[i+1]
+			}
+
+			return m.committedEntries[id]
+		}
+
+		first := 0
+		for first < len(h) && successor(first).ModTime.Before(cutoff) {
+			first++
+		}
+
+		if first == len(h) {
+			delete(m.committedHistory, id)
+		} else {
+			m.committedHistory[id] = h[first:]
+		}
+	}
+
+	for id, e := range m.committedEntries {
+		if e.Deleted && e.ModTime.Before(cutoff) {
+			m.committedIndex.remove(e)
+			delete(m.committedEntries, id)
+			delete(m.committedHistory, id)
+		}
+	}
+}
+
+func sortEntriesByTime(entries []*manifestEntry) {
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].ModTime.Before(entries[j].ModTime)
+	})
+}
@@ -9,4 +9,5 @@ type EntryMetadata struct {
 	Length  int
 	Labels  map[string]string
 	ModTime time.Time
+	Deleted bool
 }
@@ -8,7 +8,6 @@ import (
 	"crypto/rand"
 	"encoding/hex"
 	"encoding/json"
-	"sort"
 	"sync"
 	"time"
 
@@ -48,7 +47,12 @@ type Manager struct {
 	pendingEntries map[ID]*manifestEntry
 
 	committedEntries    map[ID]*manifestEntry
+	committedIndex      labelIndex
 	committedContentIDs map[content.ID]bool
+
+	// committedHistory holds superseded versions of committed entries, oldest first.
+	committedHistory map[ID][]*manifestEntry
+	historyRetention time.Duration
 }
 
 // Put serializes the provided payload to JSON and persists it. Returns unique identifier that represents the manifest.
@@ -152,34 +156,7 @@ func (m *Manager) GetRaw(ctx context.Context, id ID) ([]byte, error) {
 
 // Find returns the list of EntryMetadata for manifest entries matching all provided labels.
 func (m *Manager) Find(ctx context.Context, labels map[string]string) ([]*EntryMetadata, error) {
-	if err := m.ensureInitialized(ctx); err != nil {
-		return nil, err
-	}
-
-	m.mu.Lock()
-	defer m.mu.Unlock()
-
-	var matches []*EntryMetadata
-	for _, e := range m.pendingEntries {
-		if matchesLabels(e.Labels, labels) {
-			matches = append(matches, cloneEntryMetadata(e))
-		}
-	}
-	for _, e := range m.committedEntries {
-		if m.pendingEntries[e.ID] != nil {
-			// ignore committed that are also in pending
-			continue
-		}
-
-		if matchesLabels(e.Labels, labels) {
-			matches = append(matches, cloneEntryMetadata(e))
-		}
-	}
-
-	sort.Slice(matches, func(i, j int) bool {
-		return matches[i].ModTime.Before(matches[j].ModTime)
-	})
-	return matches, nil
+	return m.Query(ctx, LabelsQuery(labels))
 }
 
 func cloneEntryMetadata(e *manifestEntry) *EntryMetadata {
@@ -188,20 +165,10 @@ func cloneEntryMetadata(e *manifestEntry) *EntryMetadata {
 		Labels:  copyLabels(e.Labels),
 		Length:  len(e.Content),
 		ModTime: e.ModTime,
+		Deleted: e.Deleted,
 	}
 }
 
-// matchesLabels returns true when all entries in 'b' are found in the 'a'.
-func matchesLabels(a, b map[string]string) bool {
-	for k, v := range b {
-		if a[k] != v {
-			return false
-		}
-	}
-
-	return true
-}
-
 // Flush persists changes to manifest manager.
 func (m *Manager) Flush(ctx context.Context) error {
 	m.mu.Lock()
@@ -222,6 +189,11 @@ func (m *Manager) flushPendingEntriesLocked(ctx context.Context) (content.ID, er
 		man.Entries = append(man.Entries, e)
 	}
 
+	return m.writeManifestLocked(ctx, man)
+}
+
+// writeManifestLocked writes the provided manifest and commits all pending entries.
+func (m *Manager) writeManifestLocked(ctx context.Context, man manifest) (content.ID, error) {
 	var buf bytes.Buffer
 	gz := gzip.NewWriter(&buf)
 	mustSucceed(json.NewEncoder(gz).Encode(man))
@@ -234,7 +206,7 @@ func (m *Manager) flushPendingEntriesLocked(ctx context.Context) (content.ID, er
 	}
 
 	for _, e := range m.pendingEntries {
-		m.committedEntries[e.ID] = e
+		m.setCommittedEntryLocked(e)
 		delete(m.pendingEntries, e.ID)
 	}
 
@@ -249,24 +221,40 @@ func mustSucceed(e error) {
 	}
 }
 
-// Delete marks the specified manifest ID for deletion.
+// Delete marks the specified manifest ID for deletion. The labels and contents of the deleted
+// manifest are retained for the history retention period, during which it can be undeleted.
 func (m *Manager) Delete(ctx context.Context, id ID) error {
 	if err := m.ensureInitialized(ctx); err != nil {
 		return err
 	}
 
-	if m.pendingEntries[id] == nil && m.committedEntries[id] == nil {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e := m.currentEntryLocked(id)
+	if e == nil || e.Deleted {
 		return nil
 	}
 
 	m.pendingEntries[id] = &manifestEntry{
 		ID:      id,
 		ModTime: time.Now().UTC(),
+		Labels:  e.Labels,
 		Deleted: true,
+		Content: e.Content,
 	}
 	return nil
 }
 
+// currentEntryLocked returns the most recent version of the provided entry, which may be deleted, or nil.
+func (m *Manager) currentEntryLocked(id ID) *manifestEntry {
+	if e := m.pendingEntries[id]; e != nil {
+		return e
+	}
+
+	return m.committedEntries[id]
+}
+
 // Refresh updates the committed contents from the underlying storage.
 func (m *Manager) Refresh(ctx context.Context) error {
 	m.mu.Lock()
@@ -321,7 +309,9 @@ func (m *Manager) loadCommittedContentsLocked(ctx context.Context) error {
 
 func (m *Manager) loadManifestContentsLocked(manifests map[content.ID]manifest) {
 	m.committedEntries = map[ID]*manifestEntry{}
+	m.committedIndex = labelIndex{}
 	m.committedContentIDs = map[content.ID]bool{}
+	m.committedHistory = map[ID][]*manifestEntry{}
 
 	for contentID := range manifests {
 		m.committedContentIDs[contentID] = true
@@ -333,12 +323,15 @@ func (m *Manager) loadManifestContentsLocked(manifests map[content.ID]manifest)
 		}
 	}
 
-	// after merging, remove contents marked as deleted.
-	for k, e := range m.committedEntries {
-		if e.Deleted {
-			delete(m.committedEntries, k)
-		}
+	for _, h := range m.committedHistory {
+		sortEntriesByTime(h)
 	}
+
+	for _, e := range m.committedEntries {
+		m.committedIndex.add(e)
+	}
+
+	m.pruneHistoryLocked(time.Now().Add(-m.historyRetention))
 }
 
 func (m *Manager) loadManifestContent(ctx context.Context, contentID content.ID) (manifest, error) {
@@ -399,11 +392,26 @@ func (m *Manager) compactLocked(ctx context.Context) error {
 	m.b.DisableIndexFlush()
 	defer m.b.EnableIndexFlush()
 
+	m.pruneHistoryLocked(time.Now().Add(-m.historyRetention))
+
+	// the compacted content includes retained history, committed entries and pending entries.
+	man := manifest{}
+
+	for _, h := range m.committedHistory {
+		man.Entries = append(man.Entries, h...)
+	}
+
 	for _, e := range m.committedEntries {
-		m.pendingEntries[e.ID] = e
+		man.Entries = append(man.Entries, e)
+	}
+
+	for _, e := range m.pendingEntries {
+		if m.committedEntries[e.ID] != e {
+			man.Entries = append(man.Entries, e)
+		}
 	}
 
-	contentID, err := m.flushPendingEntriesLocked(ctx)
+	contentID, err := m.writeManifestLocked(ctx, man)
 	if err != nil {
 		return err
 	}
@@ -425,6 +433,8 @@ func (m *Manager) compactLocked(ctx context.Context) error {
 	return nil
 }
 
+// mergeEntry makes the provided entry committed if it's newer than the committed one, moving the older
+// version to history. Versions with identical modification times are considered duplicates.
 func (m *Manager) mergeEntry(e *manifestEntry) {
 	prev := m.committedEntries[e.ID]
 	if prev == nil {
@@ -432,9 +442,38 @@ func (m *Manager) mergeEntry(e *manifestEntry) {
 		return
 	}
 
+	if e.ModTime.Equal(prev.ModTime) {
+		return
+	}
+
 	if e.ModTime.After(prev.ModTime) {
 		m.committedEntries[e.ID] = e
+		e = prev
+	}
+
+	for _, h := range m.committedHistory[e.ID] {
+		if h.ModTime.Equal(e.ModTime) {
+			return
+		}
+	}
+
+	m.committedHistory[e.ID] = append(m.committedHistory[e.ID], e)
+}
+
+// setCommittedEntryLocked adds or replaces the committed entry, keeping the label index up-to-date
+// and moving the replaced entry to history.
+func (m *Manager) setCommittedEntryLocked(e *manifestEntry) {
+	if prev := m.committedEntries[e.ID]; prev != nil {
+		if prev == e {
+			return
+		}
+
+		m.committedIndex.remove(prev)
+		m.committedHistory[e.ID] = append(m.committedHistory[e.ID], prev)
 	}
+
+	m.committedEntries[e.ID] = e
+	m.committedIndex.add(e)
 }
 
 func (m *Manager) ensureInitialized(ctx context.Context) error {
@@ -461,13 +500,33 @@ func copyLabels(m map[string]string) map[string]string {
 	return r
 }
 
+// DefaultHistoryRetention is the default period during which deleted and superseded manifest entries are retained.
+const DefaultHistoryRetention = 7 * 24 * time.Hour
+
+// ManagerOptions specifies manifest manager options.
+type ManagerOptions struct {
+	// HistoryRetention is the period during which deleted and superseded entries are retained,
+	// DefaultHistoryRetention is used when zero and negative value disables history.
+	HistoryRetention time.Duration
+}
+
 // NewManager returns new manifest manager for the provided content manager.
-func NewManager(ctx context.Context, b contentManager) (*Manager, error) {
+func NewManager(ctx context.Context, b contentManager, opts ManagerOptions) (*Manager, error) {
 	m := &Manager{
 		b:                   b,
 		pendingEntries:      map[ID]*manifestEntry{},
 		committedEntries:    map[ID]*manifestEntry{},
+		committedIndex:      labelIndex{},
 		committedContentIDs: map[content.ID]bool{},
+		committedHistory:    map[ID][]*manifestEntry{},
+		historyRetention:    opts.HistoryRetention,
+	}
+
+	switch {
+	case m.historyRetention == 0:
+		m.historyRetention = DefaultHistoryRetention
+	case m.historyRetention < 0:
+		m.historyRetention = 0
 	}
 
 	return m, nil
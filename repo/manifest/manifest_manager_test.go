@@ -136,7 +136,7 @@ func TestManifestInitCorruptedBlock(t *testing.T) {
 		t.Fatalf("err: %v", err)
 	}
 
-	mgr, err := NewManager(ctx, bm)
+	mgr, err := NewManager(ctx, bm, ManagerOptions{})
 	if err != nil {
 		t.Fatalf("err: %v", err)
 	}
@@ -162,7 +162,7 @@ func TestManifestInitCorruptedBlock(t *testing.T) {
 		t.Fatalf("err: %v", err)
 	}
 
-	mgr, err = NewManager(ctx, bm)
+	mgr, err = NewManager(ctx, bm, ManagerOptions{})
 	if err != nil {
 		t.Fatalf("err: %v", err)
 	}
@@ -269,6 +269,10 @@ func sortIDs(s []ID) {
 }
 
 func newManagerForTesting(ctx context.Context, t *testing.T, data blobtesting.DataMap) *Manager {
+	return newManagerWithOptionsForTesting(ctx, t, data, ManagerOptions{})
+}
+
+func newManagerWithOptionsForTesting(ctx context.Context, t *testing.T, data blobtesting.DataMap, opts ManagerOptions) *Manager {
 	st := blobtesting.NewMapStorage(data, nil, nil)
 
 	bm, err := content.NewManager(ctx, st, &content.FormattingOptions{
@@ -281,7 +285,7 @@ func newManagerForTesting(ctx context.Context, t *testing.T, data blobtesting.Da
 		t.Fatalf("can't create content manager: %v", err)
 	}
 
-	mm, err := NewManager(ctx, bm)
+	mm, err := NewManager(ctx, bm, opts)
 	if err != nil {
 		t.Fatalf("can't create manifest manager: %v", err)
 	}
@@ -324,3 +328,215 @@ func TestManifestAutoCompaction(t *testing.T) {
 		mgr.Flush(ctx)
 	}
 }
+
+func TestQuery(t *testing.T) {
+	ctx := context.Background()
+	data := blobtesting.DataMap{}
+	mgr := newManagerForTesting(ctx, t, data)
+
+	var ids []ID
+
+	for i, labels := range []map[string]string{
+		{"type": "snapshot", "path": "/home/a", "host": "h1"},
+		{"type": "snapshot", "path": "/home/b", "host": "h1"},
+		{"type": "snapshot", "path": "/var", "host": "h2"},
+		{"type": "policy", "path": "/home"},
+		{"type": "policy"},
+	} {
+		id, err := mgr.Put(ctx, labels, i)
+		if err != nil {
+			t.Fatalf("put error: %v", err)
+		}
+
+		ids = append(ids, id)
+
+		time.Sleep(time.Millisecond)
+	}
+
+	md, err := mgr.GetMetadata(ctx, ids[2])
+	if err != nil {
+		t.Fatalf("unable to get metadata: %v", err)
+	}
+
+	cases := []struct {
+		query    Query
+		expected []ID
+	}{
+		{Query{Labels: []LabelPredicate{LabelEquals("type", "snapshot")}}, ids[0:3]},
+		{Query{Labels: []LabelPredicate{LabelExists("path")}}, ids[0:4]},
+		{Query{Labels: []LabelPredicate{LabelHasPrefix("path", "/home")}}, []ID{ids[0], ids[1], ids[3]}},
+		{Query{Labels: []LabelPredicate{LabelIn("host", "h2", "h3")}}, []ID{ids[2]}},
+		{Query{Labels: []LabelPredicate{LabelEquals("type", "policy"), LabelExists("path")}}, []ID{ids[3]}},
+		{Query{Labels: []LabelPredicate{LabelEquals("type", "other")}}, nil},
+		{Query{Labels: []LabelPredicate{LabelEquals("type", "snapshot")}, Reverse: true, Limit: 2}, []ID{ids[2], ids[1]}},
+		{Query{Labels: []LabelPredicate{LabelEquals("type", "snapshot")}, Offset: 1, Limit: 1}, []ID{ids[1]}},
+		{Query{Offset: 10}, nil},
+		{Query{ModifiedAfter: md.ModTime}, ids[2:]},
+		{Query{ModifiedBefore: md.ModTime}, ids[0:2]},
+	}
+
+	verify := func(desc string, m *Manager) {
+		t.Helper()
+
+		for _, tc := range cases {
+			res, err := m.Query(ctx, tc.query)
+			if err != nil {
+				t.Fatalf("query error: %v", err)
+			}
+
+			var got []ID
+			for _, r := range res {
+				got = append(got, r.ID)
+			}
+
+			if !reflect.DeepEqual(got, tc.expected) {
+				t.Errorf("%v: unexpected result of %+v: %v, want %v", desc, tc.query, got, tc.expected)
+			}
+		}
+	}
+
+	verify("pending", mgr)
+
+	if err := mgr.Flush(ctx); err != nil {
+		t.Fatalf("flush error: %v", err)
+	}
+
+	verify("committed", mgr)
+
+	mgr.b.Flush(ctx)
+	verify("reloaded", newManagerForTesting(ctx, t, data))
+
+	// deleted entries must be removed from the index.
+	if err := mgr.Delete(ctx, ids[1]); err != nil {
+		t.Fatalf("delete error: %v", err)
+	}
+
+	if err := mgr.Flush(ctx); err != nil {
+		t.Fatalf("flush error: %v", err)
+	}
+
+	res, err := mgr.Query(ctx, Query{Labels: []LabelPredicate{LabelEquals("path", "/home/b")}})
+	if err != nil || len(res) != 0 {
+		t.Errorf("unexpected result after delete: %v %v", res, err)
+	}
+}
+
+func TestHistory(t *testing.T) {
+	ctx := context.Background()
+	data := blobtesting.DataMap{}
+	mgr := newManagerForTesting(ctx, t, data)
+
+	labels1 := map[string]string{"type": "item", "color": "red"}
+	item1 := map[string]int{"foo": 1}
+
+	id1 := addAndVerify(ctx, t, mgr, labels1, item1)
+	id2 := addAndVerify(ctx, t, mgr, map[string]string{"type": "item", "color": "blue"}, map[string]int{"foo": 2})
+
+	mustFlush(ctx, t, mgr)
+
+	if err := mgr.Undelete(ctx, id1); err == nil {
+		t.Errorf("expected error when undeleting live entry")
+	}
+
+	if err := mgr.Delete(ctx, id1); err != nil {
+		t.Fatalf("delete error: %v", err)
+	}
+
+	mustFlush(ctx, t, mgr)
+
+	if err := mgr.Compact(ctx); err != nil {
+		t.Fatalf("can't compact: %v", err)
+	}
+
+	mgr.b.Flush(ctx)
+
+	// deleted entry survives compaction and reload.
+	mgr2 := newManagerForTesting(ctx, t, data)
+	verifyItemNotFound(ctx, t, mgr2, id1)
+	verifyHistory(ctx, t, mgr2, id1, []bool{false, true})
+
+	deleted, err := mgr2.Query(ctx, Query{Labels: []LabelPredicate{LabelEquals("type", "item")}, OnlyDeleted: true})
+	if err != nil {
+		t.Fatalf("query error: %v", err)
+	}
+
+	if len(deleted) != 1 || deleted[0].ID != id1 || !deleted[0].Deleted || !reflect.DeepEqual(deleted[0].Labels, labels1) {
+		t.Errorf("unexpected deleted entries: %v", deleted)
+	}
+
+	all, err := mgr2.Query(ctx, Query{Labels: []LabelPredicate{LabelEquals("type", "item")}, IncludeDeleted: true})
+	if err != nil {
+		t.Fatalf("query error: %v", err)
+	}
+
+	if len(all) != 2 {
+		t.Errorf("unexpected number of entries including deleted: %v", len(all))
+	}
+
+	var d map[string]int
+	if err := mgr2.GetDeleted(ctx, id1, &d); err != nil || !reflect.DeepEqual(d, item1) {
+		t.Errorf("unexpected deleted contents: %v %v", d, err)
+	}
+
+	if err := mgr2.GetDeleted(ctx, id2, &d); err != ErrNotFound {
+		t.Errorf("unexpected error getting live entry as deleted: %v", err)
+	}
+
+	if err := mgr2.Undelete(ctx, id1); err != nil {
+		t.Fatalf("undelete error: %v", err)
+	}
+
+	verifyItem(ctx, t, mgr2, id1, labels1, item1)
+	verifyMatches(ctx, t, mgr2, map[string]string{"color": "red"}, []ID{id1})
+	mustFlush(ctx, t, mgr2)
+	verifyHistory(ctx, t, mgr2, id1, []bool{false, true, false})
+
+	// entries expire after retention period.
+	mgr2.mu.Lock()
+	mgr2.pruneHistoryLocked(time.Now().Add(time.Hour))
+	mgr2.mu.Unlock()
+	verifyHistory(ctx, t, mgr2, id1, []bool{false})
+
+	// with history disabled, deleted entries are gone after reload.
+	if err := mgr2.Delete(ctx, id2); err != nil {
+		t.Fatalf("delete error: %v", err)
+	}
+
+	mustFlush(ctx, t, mgr2)
+	mgr2.b.Flush(ctx)
+
+	mgr3 := newManagerWithOptionsForTesting(ctx, t, data, ManagerOptions{HistoryRetention: -1})
+	if _, err := mgr3.History(ctx, id2); err != ErrNotFound {
+		t.Errorf("unexpected history of deleted entry: %v", err)
+	}
+
+	if err := mgr3.Undelete(ctx, id2); err != ErrNotFound {
+		t.Errorf("unexpected undelete error: %v", err)
+	}
+}
+
+func verifyHistory(ctx context.Context, t *testing.T, mgr *Manager, id ID, wantDeleted []bool) {
+	t.Helper()
+
+	h, err := mgr.History(ctx, id)
+	if err != nil {
+		t.Fatalf("unable to get history of %v: %v", id, err)
+	}
+
+	var gotDeleted []bool
+	for _, e := range h {
+		gotDeleted = append(gotDeleted, e.Deleted)
+	}
+
+	if !reflect.DeepEqual(gotDeleted, wantDeleted) {
+		t.Errorf("unexpected history of %v: %v, want %v", id, gotDeleted, wantDeleted)
+	}
+}
+
+func mustFlush(ctx context.Context, t *testing.T, mgr *Manager) {
+	t.Helper()
+
+	if err := mgr.Flush(ctx); err != nil {
+		t.Fatalf("flush error: %v", err)
+	}
+}
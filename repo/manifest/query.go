@@ -0,0 +1,288 @@
+package manifest
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+)
+
+type predicateOp int
+
+const (
+	opEquals predicateOp = iota
+	opExists
+	opHasPrefix
+	opIn
+)
+
+// LabelPredicate is a condition on the value of a single label.
+type LabelPredicate struct {
+	Key    string
+	op     predicateOp
+	values []string
+}
+
+// LabelEquals returns a predicate matching entries where the label has the provided value.
+func LabelEquals(key, value string) LabelPredicate {
+	return LabelPredicate{Key: key, op: opEquals, values: []string{value}}
+}
+
+// LabelExists returns a predicate matching entries that have the label, regardless of its value.
+func LabelExists(key string) LabelPredicate {
+	return LabelPredicate{Key: key, op: opExists}
+}
+
+// LabelHasPrefix returns a predicate matching entries where the label value starts with the provided prefix.
+func LabelHasPrefix(key, prefix string) LabelPredicate {
+	return LabelPredicate{Key: key, op: opHasPrefix, values: []string{prefix}}
+}
+
+// LabelIn returns a predicate matching entries where the label has any of the provided values.
+func LabelIn(key string, values ...string) LabelPredicate {
+	return LabelPredicate{Key: key, op: opIn, values: values}
+}
+
+func (p LabelPredicate) matches(labels map[string]string) bool {
+	v, ok := labels[p.Key]
+	if !ok {
+		return false
+	}
+
+	switch p.op {
+	case opExists:
+		return true
+
+	case opHasPrefix:
+		return strings.HasPrefix(v, p.values[0])
+
+	default:
+		for _, want := range p.values {
+			if v == want {
+				return true
+			}
+		}
+
+		return false
+	}
+}
+
+// matchingValues returns the values of the label in the provided index matching the predicate.
+func (p LabelPredicate) matchingValues(values map[string]map[ID]*manifestEntry) []string {
+	var result []string
+
+	switch p.op {
+	case opEquals, opIn:
+		for _, v := range p.values {
+			if values[v] != nil {
+				result = append(result, v)
+			}
+		}
+
+	default:
+		for v := range values {
+			if p.op == opExists || strings.HasPrefix(v, p.values[0]) {
+				result = append(result, v)
+			}
+		}
+	}
+
+	return result
+}
+
+// Query describes a set of manifest entries to find.
+type Query struct {
+	Labels []LabelPredicate // all predicates must match
+
+	ModifiedAfter  time.Time // if set, only entries modified at or after the given time are returned
+	ModifiedBefore time.Time // if set, only entries modified before the given time are returned
+
+	IncludeDeleted bool // also return deleted entries that are still retained, their modification time is the time of deletion
+	OnlyDeleted    bool // return only deleted entries that are still retained
+
+	Reverse bool // return newest entries first
+	Offset  int  // number of matching entries to skip
+	Limit   int  // maximum number of entries to return, 0 means no limit
+}
+
+func (q *Query) matches(e *manifestEntry) bool {
+	switch {
+	case q.OnlyDeleted && !e.Deleted:
+		return false
+	case e.Deleted && !q.IncludeDeleted && !q.OnlyDeleted:
+		return false
+	}
+
+	if !q.ModifiedAfter.IsZero() && e.ModTime.Before(q.ModifiedAfter) {
+		return false
+	}
+
+	if !q.ModifiedBefore.IsZero() && !e.ModTime.Before(q.ModifiedBefore) {
+		return false
+	}
+
+	for _, p := range q.Labels {
+		if !p.matches(e.Labels) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// labelIndex is an index of committed manifest entries by label key and value.
+type labelIndex map[string]map[string]map[ID]*manifestEntry
+
+func (li labelIndex) add(e *manifestEntry) {
+	for k, v := range e.Labels {
+		values := li[k]
+		if values == nil {
+			values = map[string]map[ID]*manifestEntry{}
+			li[k] = values
+		}
+
+		entries := values[v]
+		if entries == nil {
+			entries = map[ID]*manifestEntry{}
+			values[v] = entries
+		}
+
+		entries[e.ID] = e
+	}
+}
+
+func (li labelIndex) remove(e *manifestEntry) {
+	for k, v := range e.Labels {
+		values := li[k]
+
+		delete(values[v], e.ID)
+
+		if len(values[v]) == 0 {
+			delete(values, v)
+		}
+
+		if len(values) == 0 {
+			delete(li, k)
+		}
+	}
+}
+
+// candidates returns the smallest set of entries that may match all provided predicates
+// or false if the index can't narrow down the search.
+func (li labelIndex) candidates(predicates []LabelPredicate) ([]*manifestEntry, bool) {
+	var (
+		best      []map[ID]*manifestEntry
+		bestCount = -1
+	)
+
+	for _, p := range predicates {
+		values := li[p.Key]
+
+		var (
+			sets  []map[ID]*manifestEntry
+			count int
+		)
+
+		for _, v := range p.matchingValues(values) {
+			sets = append(sets, values[v])
+			count += len(values[v])
+		}
+
+		if bestCount < 0 || count < bestCount {
+			best, bestCount = sets, count
+		}
+	}
+
+	if bestCount < 0 {
+		return nil, false
+	}
+
+	result := make([]*manifestEntry, 0, bestCount)
+	for _, s := range best {
+		for _, e := range s {
+			result = append(result, e)
+		}
+	}
+
+	return result, true
+}
+
+// Query returns the list of EntryMetadata for manifest entries matching the provided query, ordered by modification time.
+func (m *Manager) Query(ctx context.Context, q Query) ([]*EntryMetadata, error) {
+	if err := m.ensureInitialized(ctx); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var matches []*manifestEntry
+
+	for _, e := range m.pendingEntries {
+		if q.matches(e) {
+			matches = append(matches, e)
+		}
+	}
+
+	committed, ok := m.committedIndex.candidates(q.Labels)
+	if !ok {
+		committed = make([]*manifestEntry, 0, len(m.committedEntries))
+		for _, e := range m.committedEntries {
+			committed = append(committed, e)
+		}
+	}
+
+	for _, e := range committed {
+		if m.pendingEntries[e.ID] != nil {
+			// ignore committed that are also in pending
+			continue
+		}
+
+		if q.matches(e) {
+			matches = append(matches, e)
+		}
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		a, b := matches[i], matches[j]
+		if q.Reverse {
+			a, b = b, a
+		}
+
+		if !a.ModTime.Equal(b.ModTime) {
+			return a.ModTime.Before(b.ModTime)
+		}
+
+		return a.ID < b.ID
+	})
+
+	if q.Offset > 0 {
+		if q.Offset >= len(matches) {
+			return nil, nil
+		}
+
+		matches = matches[q.Offset:]
+	}
+
+	if q.Limit > 0 && len(matches) > q.Limit {
+		matches = matches[0:q.Limit]
+	}
+
+	result := make([]*EntryMetadata, len(matches))
+	for i, e := range matches {
+		result[i] = cloneEntryMetadata(e)
+	}
+
+	return result, nil
+}
+
+// LabelsQuery returns a query matching entries having all the provided labels.
+func LabelsQuery(labels map[string]string) Query {
+	var q Query
+
+	for k, v := range labels {
+		q.Labels = append(q.Labels, LabelEquals(k, v))
+	}
+
+	return q
+}
@@ -0,0 +1,130 @@
+package object
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo/content"
+)
+
+// CopyStats contains statistics about contents copied by CopyObject.
+type CopyStats struct {
+	CopiedContents  int64
+	CopiedBytes     int64
+	SkippedContents int64
+}
+
+// copyProbeData is hashed by both repositories to determine whether they use identical content IDs.
+var copyProbeData = []byte("kopia-copy-object-probe")
+
+// CopyObject copies the object with a given ID from the source object manager preserving the way
+// it is split into contents and returns the ID of the object in this repository.
+// When both repositories compute identical content IDs (such as replicas sharing the hashing scheme and secret),
+// contents that are already present are not read from the source or written again. Otherwise all contents
+// are read, so callers should remember copied objects to avoid copying them again.
+func (om *Manager) CopyObject(ctx context.Context, src *Manager, oid ID, stats *CopyStats) (ID, error) {
+	sameContentIDs, err := om.sharesContentIDsWith(src)
+	if err != nil {
+		return "", err
+	}
+
+	return om.copyObject(ctx, src, oid, sameContentIDs, stats)
+}
+
+// sharesContentIDsWith returns true if the content managers of both object managers assign identical IDs to identical contents.
+func (om *Manager) sharesContentIDsWith(src *Manager) (bool, error) {
+	id1, err := om.contentMgr.ComputeContentID(copyProbeData, "")
+	if err != nil {
+		return false, errors.Wrap(err, "unable to compute content ID")
+	}
+
+	id2, err := src.contentMgr.ComputeContentID(copyProbeData, "")
+	if err != nil {
+		return false, errors.Wrap(err, "unable to compute source content ID")
+	}
+
+	return id1 == id2, nil
+}
+
+func (om *Manager) copyObject(ctx context.Context, src *Manager, oid ID, sameContentIDs bool, stats *CopyStats) (ID, error) {
+	if indexObjectID, ok := oid.IndexObjectID(); ok {
+		return om.copyIndirectObject(ctx, src, indexObjectID, sameContentIDs, stats)
+	}
+
+	contentID, ok := oid.ContentID()
+	if !ok {
+		return "", errors.Errorf("unrecognized object type: %v", oid)
+	}
+
+	if sameContentIDs {
+		if bi, err := om.contentMgr.ContentInfo(ctx, contentID); err == nil && !bi.Deleted {
+			stats.SkippedContents++
+			return oid, nil
+		}
+	}
+
+	data, err := src.contentMgr.GetContent(ctx, contentID)
+	if err != nil {
+		return "", errors.Wrapf(err, "unable to read content %v", contentID)
+	}
+
+	newContentID, err := om.contentMgr.WriteContent(ctx, data, contentID.Prefix())
+	if err != nil {
+		return "", errors.Wrapf(err, "unable to write content %v", contentID)
+	}
+
+	stats.CopiedContents++
+	stats.CopiedBytes += int64(len(data))
+
+	return DirectObjectID(newContentID), nil
+}
+
+func (om *Manager) copyIndirectObject(ctx context.Context, src *Manager, indexObjectID ID, sameContentIDs bool, stats *CopyStats) (ID, error) {
+	rd, err := src.Open(ctx, indexObjectID)
+	if err != nil {
+		return "", errors.Wrap(err, "unable to open index object")
+	}
+	defer rd.Close() //nolint:errcheck
+
+	entries, err := src.flattenListChunk(rd)
+	if err != nil {
+		return "", err
+	}
+
+	var prefix content.ID
+
+	for i, e := range entries {
+		newOID, err := om.copyObject(ctx, src, e.Object, sameContentIDs, stats)
+		if err != nil {
+			return "", err
+		}
+
+		entries[i].Object = newOID
+
+		if cid, ok := newOID.ContentID(); ok {
+			prefix = cid.Prefix()
+		}
+	}
+
+	iw := om.NewWriter(ctx, WriterOptions{
+		Description: "LIST(" + string(indexObjectID) + ")",
+		Prefix:      prefix,
+	})
+	defer iw.Close() //nolint:errcheck
+
+	if err := json.NewEncoder(iw).Encode(indirectObject{
+		StreamID: "kopia:indirect",
+		Entries:  entries,
+	}); err != nil {
+		return "", errors.Wrap(err, "unable to write indirect object index")
+	}
+
+	newIndexObjectID, err := iw.Result()
+	if err != nil {
+		return "", err
+	}
+
+	return IndirectObjectID(newIndexObjectID), nil
+}
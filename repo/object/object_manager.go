@@ -27,6 +27,7 @@ type contentManager interface {
 	ContentInfo(ctx context.Context, contentID content.ID) (content.Info, error)
 	GetContent(ctx context.Context, contentID content.ID) ([]byte, error)
 	WriteContent(ctx context.Context, data []byte, prefix content.ID) (content.ID, error)
+	ComputeContentID(data []byte, prefix content.ID) (content.ID, error)
 }
 
 // Format describes the format of objects in a repository.
@@ -55,6 +56,11 @@ func (om *Manager) NewWriter(ctx context.Context, opt WriterOptions) Writer {
 	}
 }
 
+// NewSplitter returns a new instance of the splitter used for writing objects to the repository.
+func (om *Manager) NewSplitter() Splitter {
+	return om.newSplitter()
+}
+
 // Open creates new ObjectReader for reading given object from a repository.
 func (om *Manager) Open(ctx context.Context, objectID ID) (Reader, error) {
 	if indexObjectID, ok := objectID.IndexObjectID(); ok {
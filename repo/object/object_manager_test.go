@@ -21,6 +21,7 @@ import (
 type fakeContentManager struct {
 	mu   sync.Mutex
 	data map[content.ID][]byte
+	salt []byte
 }
 
 func (f *fakeContentManager) GetContent(ctx context.Context, contentID content.ID) ([]byte, error) {
@@ -35,9 +36,7 @@ func (f *fakeContentManager) GetContent(ctx context.Context, contentID content.I
 }
 
 func (f *fakeContentManager) WriteContent(ctx context.Context, data []byte, prefix content.ID) (content.ID, error) {
-	h := sha256.New()
-	h.Write(data) //nolint:errcheck
-	contentID := prefix + content.ID(hex.EncodeToString(h.Sum(nil)))
+	contentID, _ := f.ComputeContentID(data, prefix)
 
 	f.mu.Lock()
 	defer f.mu.Unlock()
@@ -46,6 +45,14 @@ func (f *fakeContentManager) WriteContent(ctx context.Context, data []byte, pref
 	return contentID, nil
 }
 
+func (f *fakeContentManager) ComputeContentID(data []byte, prefix content.ID) (content.ID, error) {
+	h := sha256.New()
+	h.Write(f.salt) //nolint:errcheck
+	h.Write(data)   //nolint:errcheck
+
+	return prefix + content.ID(hex.EncodeToString(h.Sum(nil))), nil
+}
+
 func (f *fakeContentManager) ContentInfo(ctx context.Context, contentID content.ID) (content.Info, error) {
 	f.mu.Lock()
 	defer f.mu.Unlock()
@@ -217,6 +224,85 @@ func TestIndirection(t *testing.T) {
 	}
 }
 
+func TestCopyObject(t *testing.T) {
+	ctx := context.Background()
+
+	srcData, src := setupTest(t)
+
+	contentBytes := make([]byte, 5500)
+	cryptorand.Read(contentBytes) //nolint:errcheck
+
+	writer := src.NewWriter(ctx, WriterOptions{Prefix: "x"})
+	writer.(*objectWriter).splitter = newFixedSplitterFactory(1000)()
+	writer.Write(contentBytes) //nolint:errcheck
+
+	oid, err := writer.Result()
+	if err != nil {
+		t.Fatalf("error writing object: %v", err)
+	}
+
+	if indirectionLevel(oid) != 1 {
+		t.Fatalf("expected indirect object, got %v", oid)
+	}
+
+	// destination using different hashing produces different content IDs.
+	dstData := map[content.ID][]byte{}
+	dst, err := NewObjectManager(ctx, &fakeContentManager{data: dstData, salt: []byte("salt")}, Format{Splitter: "FIXED-1M"}, ManagerOptions{})
+	if err != nil {
+		t.Fatalf("can't create object manager: %v", err)
+	}
+
+	var stats CopyStats
+
+	newOID, err := dst.CopyObject(ctx, src, oid, &stats)
+	if err != nil {
+		t.Fatalf("copy error: %v", err)
+	}
+
+	if newOID == oid {
+		t.Errorf("expected different object ID after copy")
+	}
+
+	if got, want := stats.CopiedContents, int64(6); got != want {
+		t.Errorf("unexpected number of copied contents: %v, want %v", got, want)
+	}
+
+	verify(ctx, t, dst, newOID, contentBytes, "copied")
+
+	for cid := range dstData {
+		if cid[0] != 'x' {
+			t.Errorf("content prefix was not preserved: %v", cid)
+		}
+	}
+
+	// content IDs of the source are meaningless in destination using different hashing, contents are copied
+	// even if they happen to exist.
+	for cid := range srcData {
+		dstData[cid] = []byte("unrelated")
+	}
+
+	stats = CopyStats{}
+
+	if _, err := dst.CopyObject(ctx, src, oid, &stats); err != nil {
+		t.Fatalf("copy error: %v", err)
+	}
+
+	if stats.CopiedContents != 6 || stats.SkippedContents != 0 {
+		t.Errorf("unexpected stats when copying to a repository using different hashing: %+v", stats)
+	}
+
+	// copying again to a destination sharing content IDs skips all contents.
+	stats = CopyStats{}
+
+	if _, err := src.CopyObject(ctx, src, oid, &stats); err != nil {
+		t.Fatalf("copy error: %v", err)
+	}
+
+	if stats.CopiedContents != 0 || stats.SkippedContents != 6 {
+		t.Errorf("unexpected stats when copying to the same repository: %+v", stats)
+	}
+}
+
 func indirectionLevel(oid ID) int {
 	indexObjectID, ok := oid.IndexObjectID()
 	if !ok {
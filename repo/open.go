@@ -9,8 +9,13 @@ import (
 	"github.com/pkg/errors"
 
 	"github.com/kopia/kopia/internal/repologging"
+	"github.com/kopia/kopia/internal/retry"
 	"github.com/kopia/kopia/repo/blob"
 	"github.com/kopia/kopia/repo/blob/logging"
+	"github.com/kopia/kopia/repo/blob/obfuscate"
+	"github.com/kopia/kopia/repo/blob/parity"
+	"github.com/kopia/kopia/repo/blob/retrying"
+	"github.com/kopia/kopia/repo/blob/throttling"
 	"github.com/kopia/kopia/repo/content"
 	"github.com/kopia/kopia/repo/manifest"
 	"github.com/kopia/kopia/repo/object"
@@ -24,6 +29,7 @@ var (
 type Options struct {
 	TraceStorage         func(f string, args ...interface{}) // Logs all storage access using provided Printf-style function
 	ObjectManagerOptions object.ManagerOptions
+	ManifestOptions      manifest.ManagerOptions
 }
 
 // Open opens a Repository specified in the configuration file.
@@ -68,8 +74,47 @@ func Open(ctx context.Context, configFile, password string, options *Options) (r
 	return r, nil
 }
 
+// WithRetries wraps the storage so that failed operations are retried according to the provided policy
+// or the default one if nil.
+func WithRetries(st blob.Storage, p *retry.Policy) (blob.Storage, error) {
+	policy := retry.DefaultPolicy()
+	if p != nil {
+		policy = *p
+	}
+
+	rst, err := retrying.NewWrapper(st, policy)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid retry policy")
+	}
+
+	return rst, nil
+}
+
 // OpenWithConfig opens the repository with a given configuration, avoiding the need for a config file.
 func OpenWithConfig(ctx context.Context, st blob.Storage, lc *LocalConfig, password string, options *Options, caching content.CachingOptions) (*Repository, error) {
+	st, err := WithRetries(st, lc.Retry)
+	if err != nil {
+		return nil, err
+	}
+
+	throttle := lc.Throttling
+	if throttle != nil && throttle.IsEmpty() {
+		throttle = nil
+	}
+
+	if throttle != nil {
+		st = throttling.NewWrapper(st, *throttle)
+	}
+
+	if lc.Obfuscation != nil {
+		ost, err := obfuscatedStorage(st, lc.Obfuscation, password)
+		if err != nil {
+			return nil, err
+		}
+
+		st = ost
+	}
+
 	// Read format blob, potentially from cache.
 	fb, err := readAndCacheFormatBlobBytes(ctx, st, caching.CacheDirectory)
 	if err != nil {
@@ -81,6 +126,8 @@ func OpenWithConfig(ctx context.Context, st blob.Storage, lc *LocalConfig, passw
 		return nil, errors.Wrap(err, "can't parse format blob")
 	}
 
+	formatBlobBytes := fb
+
 	fb, err = addFormatBlobChecksumAndLength(fb)
 	if err != nil {
 		return nil, errors.Errorf("unable to add checksum")
@@ -103,6 +150,13 @@ func OpenWithConfig(ctx context.Context, st blob.Storage, lc *LocalConfig, passw
 		fo.MaxPackSize = 20 << 20 // 20 MB
 	}
 
+	if repoConfig.Parity != nil {
+		st, err = parity.NewWrapper(st, *repoConfig.Parity, content.PackBlobIDPrefixes)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to set up parity")
+		}
+	}
+
 	cm, err := content.NewManager(ctx, st, fo, caching, fb)
 	if err != nil {
 		return nil, errors.Wrap(err, "unable to open content manager")
@@ -113,23 +167,47 @@ func OpenWithConfig(ctx context.Context, st blob.Storage, lc *LocalConfig, passw
 		return nil, errors.Wrap(err, "unable to open object manager")
 	}
 
-	manifests, err := manifest.NewManager(ctx, cm)
+	manifests, err := manifest.NewManager(ctx, cm, options.ManifestOptions)
 	if err != nil {
 		return nil, errors.Wrap(err, "unable to open manifests")
 	}
 
 	return &Repository{
-		Content:   cm,
-		Objects:   om,
-		Blobs:     st,
-		Manifests: manifests,
-		UniqueID:  f.UniqueID,
-
-		formatBlob: f,
-		masterKey:  masterKey,
+		Content:    cm,
+		Objects:    om,
+		Blobs:      st,
+		Manifests:  manifests,
+		UniqueID:   f.UniqueID,
+		Parity:     repoConfig.Parity,
+		Throttling: throttle,
+
+		formatBlob:      f,
+		formatBlobBytes: formatBlobBytes,
+		masterKey:       masterKey,
 	}, nil
 }
 
+// OpenRawStorage opens the storage of the repository without the wrappers applied when opening it
+// (retrying, throttling, blob name obfuscation or parity), so that blobs are accessed exactly as stored.
+// The caller must close the returned storage.
+func (r *Repository) OpenRawStorage(ctx context.Context) (blob.Storage, error) {
+	if r.ConfigFile == "" {
+		return nil, errors.New("repository was not opened from a config file")
+	}
+
+	lc, err := loadConfigFromFile(r.ConfigFile)
+	if err != nil {
+		return nil, err
+	}
+
+	st, err := blob.NewStorage(ctx, lc.Storage)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot open storage")
+	}
+
+	return st, nil
+}
+
 // SetCachingConfig changes caching configuration for a given repository.
 func (r *Repository) SetCachingConfig(opt content.CachingOptions) error {
 	lc, err := loadConfigFromFile(r.ConfigFile)
@@ -153,6 +231,36 @@ func (r *Repository) SetCachingConfig(opt content.CachingOptions) error {
 	return nil
 }
 
+// SetThrottlingConfig changes storage throttling configuration for a given repository.
+func (r *Repository) SetThrottlingConfig(opt *throttling.Options) error {
+	lc, err := loadConfigFromFile(r.ConfigFile)
+	if err != nil {
+		return err
+	}
+
+	if opt != nil && opt.IsEmpty() {
+		opt = nil
+	}
+
+	lc.Throttling = opt
+
+	d, err := json.MarshalIndent(&lc, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(r.ConfigFile, d, 0600)
+}
+
+func obfuscatedStorage(st blob.Storage, p *obfuscate.Parameters, password string) (blob.Storage, error) {
+	key, err := p.DeriveKey(password)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to derive obfuscation key")
+	}
+
+	return obfuscate.NewWrapper(st, p, key)
+}
+
 func readAndCacheFormatBlobBytes(ctx context.Context, st blob.Storage, cacheDirectory string) ([]byte, error) {
 	cachedFile := filepath.Join(cacheDirectory, "kopia.repository")
 	if cacheDirectory != "" {
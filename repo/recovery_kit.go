@@ -0,0 +1,396 @@
+package repo
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/scrypt"
+
+	"github.com/kopia/kopia/internal/scrubber"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/content"
+)
+
+const (
+	recoveryKitVersion     = "1"
+	recoveryKitBeginMarker = "-----BEGIN KOPIA RECOVERY KIT-----"
+	recoveryKitEndMarker   = "-----END KOPIA RECOVERY KIT-----"
+	recoveryKitLineLength  = 64
+	recoveryKeySaltLength  = 32
+)
+
+// RecoveryKit contains a copy of the format blob of a repository along with (optionally) the key material
+// necessary to decrypt it, which allows the format blob to be restored if it's lost or damaged.
+type RecoveryKit struct {
+	Version    string          `json:"version"`
+	UniqueID   []byte          `json:"uniqueID"`
+	Created    time.Time       `json:"created"`
+	Storage    json.RawMessage `json:"storage"` // connection info with sensitive fields redacted
+	FormatBlob []byte          `json:"formatBlob"`
+
+	// KeyMaterial holds the master key, encrypted using a key derived from the recovery passphrase
+	// if KeyDerivationAlgorithm is set, plain text otherwise.
+	KeyMaterial            []byte `json:"keyMaterial,omitempty"`
+	KeyDerivationAlgorithm string `json:"keyAlgo,omitempty"`
+	KeySalt                []byte `json:"keySalt,omitempty"`
+}
+
+// HasKeyMaterial returns true if the recovery kit includes the master key.
+func (k *RecoveryKit) HasKeyMaterial() bool {
+	return len(k.KeyMaterial) > 0
+}
+
+// IsKeyMaterialProtected returns true if the master key is protected with a recovery passphrase.
+func (k *RecoveryKit) IsKeyMaterialProtected() bool {
+	return k.KeyDerivationAlgorithm != ""
+}
+
+// RecoveryKit returns the recovery kit for the repository. When includeKeys is set, the kit includes the master key,
+// protected by the recovery passphrase unless it's empty.
+func (r *Repository) RecoveryKit(includeKeys bool, recoveryPassphrase string) (*RecoveryKit, error) {
+	if len(r.formatBlobBytes) == 0 {
+		return nil, errors.New("format blob is not available")
+	}
+
+	storage, err := json.Marshal(blob.ConnectionInfo{
+		Type:   r.Blobs.ConnectionInfo().Type,
+		Config: redactedConfig(r.Blobs.ConnectionInfo().Config),
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to marshal connection info")
+	}
+
+	k := &RecoveryKit{
+		Version:    recoveryKitVersion,
+		UniqueID:   r.UniqueID,
+		Created:    time.Now().UTC(),
+		Storage:    storage,
+		FormatBlob: r.formatBlobBytes,
+	}
+
+	if !includeKeys {
+		return k, nil
+	}
+
+	if recoveryPassphrase == "" {
+		k.KeyMaterial = r.masterKey
+		return k, nil
+	}
+
+	k.KeyDerivationAlgorithm = defaultKeyDerivationAlgorithm
+	k.KeySalt = make([]byte, recoveryKeySaltLength)
+
+	if _, err := io.ReadFull(rand.Reader, k.KeySalt); err != nil {
+		return nil, errors.Wrap(err, "unable to generate salt")
+	}
+
+	aead, err := k.recoveryCipher(recoveryPassphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, errors.Wrap(err, "unable to generate nonce")
+	}
+
+	k.KeyMaterial = aead.Seal(nonce, nonce, r.masterKey, k.UniqueID)
+
+	return k, nil
+}
+
+// redactedConfig returns a copy of storage configuration with sensitive fields scrubbed, if possible.
+func redactedConfig(cfg interface{}) interface{} {
+	v := reflect.ValueOf(cfg)
+	if v.Kind() == reflect.Ptr && v.Elem().Kind() == reflect.Struct {
+		return scrubber.ScrubSensitiveData(v).Interface()
+	}
+
+	return cfg
+}
+
+func (k *RecoveryKit) recoveryCipher(recoveryPassphrase string) (cipher.AEAD, error) {
+	if k.KeyDerivationAlgorithm != defaultKeyDerivationAlgorithm {
+		return nil, errors.Errorf("unsupported key algorithm: %v", k.KeyDerivationAlgorithm)
+	}
+
+	key, err := scrypt.Key([]byte(recoveryPassphrase), k.KeySalt, 65536, 8, 1, 32)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to derive recovery key")
+	}
+
+	blk, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot create cipher")
+	}
+
+	return cipher.NewGCM(blk)
+}
+
+// MasterKey returns the master key stored in the recovery kit after verifying that it decrypts the format blob.
+// The recovery passphrase is ignored if the key material is not protected.
+func (k *RecoveryKit) MasterKey(recoveryPassphrase string) ([]byte, error) {
+	if !k.HasKeyMaterial() {
+		return nil, errors.New("recovery kit does not include key material")
+	}
+
+	masterKey := k.KeyMaterial
+
+	if k.IsKeyMaterialProtected() {
+		aead, err := k.recoveryCipher(recoveryPassphrase)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(k.KeyMaterial) < aead.NonceSize() {
+			return nil, errors.New("invalid key material")
+		}
+
+		masterKey, err = aead.Open(nil, k.KeyMaterial[0:aead.NonceSize()], k.KeyMaterial[aead.NonceSize():], k.UniqueID)
+		if err != nil {
+			return nil, errors.New("unable to decrypt key material, invalid recovery passphrase?")
+		}
+	}
+
+	f, err := parseFormatBlob(k.FormatBlob)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := f.decryptFormatBytes(masterKey); err != nil {
+		return nil, errors.Wrap(err, "key material does not match the format blob")
+	}
+
+	return masterKey, nil
+}
+
+// formatBlobWithNewPassword returns the format blob from the recovery kit, decrypted using its key material and
+// encrypted again using the master key derived from the new password, which restores access to the repository
+// whose password was lost. Blob name obfuscation is keyed by the old password and is not affected.
+func (k *RecoveryKit) formatBlobWithNewPassword(recoveryPassphrase, newPassword string) ([]byte, error) {
+	masterKey, err := k.MasterKey(recoveryPassphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := parseFormatBlob(k.FormatBlob)
+	if err != nil {
+		return nil, err
+	}
+
+	format, err := f.decryptFormatBytes(masterKey)
+	if err != nil {
+		return nil, err
+	}
+
+	newMasterKey, err := f.deriveMasterKeyFromPassword(newPassword)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to derive master key")
+	}
+
+	if err := encryptFormatBytes(f, format, newMasterKey, f.UniqueID); err != nil {
+		return nil, errors.Wrap(err, "unable to encrypt format blob")
+	}
+
+	return serializeFormatBlob(f)
+}
+
+// Encode returns the printable representation of the recovery kit.
+func (k *RecoveryKit) Encode() ([]byte, error) {
+	var payload bytes.Buffer
+
+	zw := gzip.NewWriter(&payload)
+	if err := json.NewEncoder(zw).Encode(k); err != nil {
+		return nil, errors.Wrap(err, "unable to encode recovery kit")
+	}
+
+	if err := zw.Close(); err != nil {
+		return nil, errors.Wrap(err, "unable to compress recovery kit")
+	}
+
+	var buf bytes.Buffer
+
+	fmt.Fprintf(&buf, "Kopia repository recovery kit\n\n")
+	fmt.Fprintf(&buf, "Repository ID: %x\n", k.UniqueID)
+	fmt.Fprintf(&buf, "Created:       %v\n", k.Created.Format(time.RFC3339))
+	fmt.Fprintf(&buf, "Storage:       %v\n", k.storageType())
+
+	switch {
+	case !k.HasKeyMaterial():
+		fmt.Fprintf(&buf, "Key material:  not included, repository password is required\n")
+	case k.IsKeyMaterialProtected():
+		fmt.Fprintf(&buf, "Key material:  included, protected by recovery passphrase\n")
+	default:
+		fmt.Fprintf(&buf, "Key material:  included, NOT PROTECTED - keep this document safe\n")
+	}
+
+	fmt.Fprintf(&buf, "\n%v\n", recoveryKitBeginMarker)
+
+	encoded := base64.StdEncoding.EncodeToString(payload.Bytes())
+	for len(encoded) > recoveryKitLineLength {
+		fmt.Fprintf(&buf, "%v\n", encoded[0:recoveryKitLineLength])
+		encoded = encoded[recoveryKitLineLength:]
+	}
+
+	fmt.Fprintf(&buf, "%v\n%v\n", encoded, recoveryKitEndMarker)
+
+	return buf.Bytes(), nil
+}
+
+func (k *RecoveryKit) storageType() string {
+	var ci struct {
+		Type string `json:"type"`
+	}
+
+	if err := json.Unmarshal(k.Storage, &ci); err != nil || ci.Type == "" {
+		return "unknown"
+	}
+
+	return ci.Type
+}
+
+// DecodeRecoveryKit parses the printable representation of the recovery kit, ignoring any text
+// outside of the encoded block.
+func DecodeRecoveryKit(b []byte) (*RecoveryKit, error) {
+	s := string(b)
+
+	begin := strings.Index(s, recoveryKitBeginMarker)
+	end := strings.Index(s, recoveryKitEndMarker)
+
+	if begin < 0 || end < begin {
+		return nil, errors.New("recovery kit not found")
+	}
+
+	encoded := strings.Join(strings.Fields(s[begin+len(recoveryKitBeginMarker):end]), "")
+
+	payload, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid recovery kit encoding")
+	}
+
+	zr, err := gzip.NewReader(bytes.NewReader(payload))
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid recovery kit")
+	}
+
+	// reading the entire stream verifies gzip checksum.
+	data, err := ioutil.ReadAll(zr)
+	if err != nil {
+		return nil, errors.Wrap(err, "recovery kit is damaged")
+	}
+
+	k := &RecoveryKit{}
+	if err := json.Unmarshal(data, k); err != nil {
+		return nil, errors.Wrap(err, "invalid recovery kit")
+	}
+
+	if k.Version != recoveryKitVersion {
+		return nil, errors.Errorf("unsupported recovery kit version %v", k.Version)
+	}
+
+	uniqueID, err := FormatBlobUniqueID(k.FormatBlob)
+	if err != nil {
+		return nil, err
+	}
+
+	if !bytes.Equal(uniqueID, k.UniqueID) {
+		return nil, errors.New("format blob in the recovery kit does not match repository ID")
+	}
+
+	return k, nil
+}
+
+// RecoveryKitVerification describes the result of verifying a recovery kit against the packs in a repository.
+type RecoveryKitVerification struct {
+	CheckedPacks  int `json:"checkedPacks"`
+	MatchingPacks int `json:"matchingPacks"` // packs with format blob identical to the one in recovery kit
+	OutdatedPacks int `json:"outdatedPacks"` // packs with an older version of format blob of the same repository
+}
+
+// VerifyRecoveryKit compares the format blob in the recovery kit against replicas stored in up to maxPacks pack blobs
+// and returns an error if any of them belongs to a different repository.
+func VerifyRecoveryKit(ctx context.Context, st blob.Storage, k *RecoveryKit, maxPacks int) (*RecoveryKitVerification, error) {
+	errEnough := errors.New("enough packs")
+	result := &RecoveryKitVerification{}
+
+	for _, prefix := range content.PackBlobIDPrefixes {
+		err := st.ListBlobs(ctx, prefix, func(bm blob.Metadata) error {
+			if result.CheckedPacks >= maxPacks {
+				return errEnough
+			}
+
+			b, err := RecoverFormatBlob(ctx, st, bm.BlobID, bm.Length)
+			if err == errFormatBlobNotFound {
+				log.Warningf("pack %v does not have a replica of format blob", bm.BlobID)
+				return nil
+			}
+
+			if err != nil {
+				return errors.Wrapf(err, "unable to read pack %v", bm.BlobID)
+			}
+
+			result.CheckedPacks++
+
+			if bytes.Equal(b, k.FormatBlob) {
+				result.MatchingPacks++
+				return nil
+			}
+
+			uniqueID, err := FormatBlobUniqueID(b)
+			if err != nil || !bytes.Equal(uniqueID, k.UniqueID) {
+				return errors.Errorf("pack %v belongs to a different repository", bm.BlobID)
+			}
+
+			result.OutdatedPacks++
+
+			return nil
+		})
+
+		switch err {
+		case errEnough:
+			return result, nil
+		case nil:
+			// do nothing
+		default:
+			return result, err
+		}
+	}
+
+	return result, nil
+}
+
+// RestoreFormatBlob writes the format blob from the recovery kit to the storage.
+func RestoreFormatBlob(ctx context.Context, st blob.Storage, k *RecoveryKit) error {
+	return writeFormatBlobBytes(ctx, st, k.FormatBlob)
+}
+
+// ResetPassword writes the format blob from the recovery kit encrypted using the new password to the storage.
+func ResetPassword(ctx context.Context, st blob.Storage, k *RecoveryKit, recoveryPassphrase, newPassword string) error {
+	b, err := k.formatBlobWithNewPassword(recoveryPassphrase, newPassword)
+	if err != nil {
+		return err
+	}
+
+	return writeFormatBlobBytes(ctx, st, b)
+}
+
+func writeFormatBlobBytes(ctx context.Context, st blob.Storage, b []byte) error {
+	if err := st.PutBlob(ctx, FormatBlobID, b); err != nil {
+		return errors.Wrap(err, "unable to write format blob")
+	}
+
+	return nil
+}
@@ -7,6 +7,8 @@ import (
 	"github.com/pkg/errors"
 
 	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/blob/parity"
+	"github.com/kopia/kopia/repo/blob/throttling"
 	"github.com/kopia/kopia/repo/content"
 	"github.com/kopia/kopia/repo/manifest"
 	"github.com/kopia/kopia/repo/object"
@@ -14,16 +16,19 @@ import (
 
 // Repository represents storage where both content-addressable and user-addressable data is kept.
 type Repository struct {
-	Blobs     blob.Storage
-	Content   *content.Manager
-	Objects   *object.Manager
-	Manifests *manifest.Manager
-	UniqueID  []byte
+	Blobs      blob.Storage
+	Content    *content.Manager
+	Objects    *object.Manager
+	Manifests  *manifest.Manager
+	UniqueID   []byte
+	Parity     *parity.Options     // parity written for pack blobs, nil if disabled
+	Throttling *throttling.Options // storage throttling in effect, nil if disabled
 
 	ConfigFile string
 
-	formatBlob *formatBlob
-	masterKey  []byte
+	formatBlob      *formatBlob
+	formatBlobBytes []byte
+	masterKey       []byte
 }
 
 // Close closes the repository and releases all resources.
@@ -10,6 +10,7 @@ import (
 
 	"github.com/kopia/kopia/internal/repotesting"
 	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/blob"
 	"github.com/kopia/kopia/repo/content"
 	"github.com/kopia/kopia/repo/object"
 )
@@ -298,3 +299,229 @@ func TestFormats(t *testing.T) {
 		}
 	}
 }
+
+func TestObfuscatedBlobNames(t *testing.T) {
+	ctx := context.Background()
+
+	var env repotesting.Environment
+	defer env.Setup(t, func(n *repo.NewRepositoryOptions) {
+		n.ObfuscateBlobNames = true
+		n.PadBlobSizes = true
+	}).Close(t)
+
+	data := []byte("The quick brown fox jumps over the lazy dog")
+
+	w := env.Repository.Objects.NewWriter(ctx, object.WriterOptions{})
+	w.Write(data) //nolint:errcheck
+
+	oid, err := w.Result()
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	if err := env.Repository.Flush(ctx); err != nil {
+		t.Fatalf("flush error: %v", err)
+	}
+
+	env.MustReopen(t)
+
+	if _, err := env.Repository.Blobs.GetBlob(ctx, repo.FormatBlobID, 0, -1); err != nil {
+		t.Errorf("unable to read format blob: %v", err)
+	}
+
+	rc, err := env.Repository.Objects.Open(ctx, oid)
+	if err != nil {
+		t.Fatalf("open failed: %v", err)
+	}
+
+	bytesRead, err := ioutil.ReadAll(rc)
+	if err != nil || !bytes.Equal(bytesRead, data) {
+		t.Errorf("data mismatch, read:%x %v vs written:%x", bytesRead, err, data)
+	}
+}
+
+func TestRecoveryKit(t *testing.T) {
+	ctx := context.Background()
+
+	var env repotesting.Environment
+	defer env.Setup(t).Close(t)
+
+	writeObject(ctx, t, env.Repository, []byte("The quick brown fox jumps over the lazy dog"), "recovery-kit")
+
+	if err := env.Repository.Flush(ctx); err != nil {
+		t.Fatalf("flush error: %v", err)
+	}
+
+	kit, err := env.Repository.RecoveryKit(true, "recovery-passphrase")
+	if err != nil {
+		t.Fatalf("unable to create recovery kit: %v", err)
+	}
+
+	encoded, err := kit.Encode()
+	if err != nil {
+		t.Fatalf("unable to encode recovery kit: %v", err)
+	}
+
+	kit, err = repo.DecodeRecoveryKit(append([]byte("some text before\n"), encoded...))
+	if err != nil {
+		t.Fatalf("unable to decode recovery kit: %v", err)
+	}
+
+	if !kit.IsKeyMaterialProtected() {
+		t.Errorf("key material is not protected")
+	}
+
+	if _, err := kit.MasterKey("wrong-passphrase"); err == nil {
+		t.Errorf("unexpected success with wrong recovery passphrase")
+	}
+
+	if _, err := kit.MasterKey("recovery-passphrase"); err != nil {
+		t.Errorf("unable to get master key: %v", err)
+	}
+
+	if err := env.Repository.Blobs.DeleteBlob(ctx, repo.FormatBlobID); err != nil {
+		t.Fatalf("unable to delete format blob: %v", err)
+	}
+
+	v, err := repo.VerifyRecoveryKit(ctx, env.Repository.Blobs, kit, 10)
+	if err != nil {
+		t.Fatalf("verification failed: %v", err)
+	}
+
+	if v.CheckedPacks == 0 || v.MatchingPacks != v.CheckedPacks {
+		t.Errorf("unexpected verification result: %+v", v)
+	}
+
+	if err := repo.RestoreFormatBlob(ctx, env.Repository.Blobs, kit); err != nil {
+		t.Fatalf("unable to restore format blob: %v", err)
+	}
+
+	b, err := env.Repository.Blobs.GetBlob(ctx, repo.FormatBlobID, 0, -1)
+	if err != nil || !bytes.Equal(b, kit.FormatBlob) {
+		t.Errorf("format blob was not restored: %v", err)
+	}
+
+	// recovery kit of another repository must not match.
+	var otherEnv repotesting.Environment
+	defer otherEnv.Setup(t).Close(t)
+
+	otherKit, err := otherEnv.Repository.RecoveryKit(false, "")
+	if err != nil {
+		t.Fatalf("unable to create recovery kit: %v", err)
+	}
+
+	if otherKit.HasKeyMaterial() {
+		t.Errorf("unexpected key material")
+	}
+
+	if _, err := repo.VerifyRecoveryKit(ctx, env.Repository.Blobs, otherKit, 10); err == nil {
+		t.Errorf("unexpected success verifying recovery kit of another repository")
+	}
+}
+
+func TestRecoveryKitResetPassword(t *testing.T) {
+	ctx := context.Background()
+
+	var env repotesting.Environment
+	defer env.Setup(t, func(o *repo.NewRepositoryOptions) {
+		// format blob is only encrypted in encrypted repositories.
+		o.BlockFormat.Hash = content.DefaultHash
+		o.BlockFormat.Encryption = content.DefaultEncryption
+	}).Close(t)
+
+	oid := writeObject(ctx, t, env.Repository, []byte("The quick brown fox jumps over the lazy dog"), "reset-password")
+
+	if err := env.Repository.Flush(ctx); err != nil {
+		t.Fatalf("flush error: %v", err)
+	}
+
+	kit, err := env.Repository.RecoveryKit(true, "")
+	if err != nil {
+		t.Fatalf("unable to create recovery kit: %v", err)
+	}
+
+	if err := repo.ResetPassword(ctx, env.Repository.Blobs, kit, "", "new-password"); err != nil {
+		t.Fatalf("unable to reset password: %v", err)
+	}
+
+	if _, err = repo.Open(ctx, env.Repository.ConfigFile, "foobarbazfoobarbaz", &repo.Options{}); err == nil {
+		t.Errorf("unexpected success opening repository with the old password")
+	}
+
+	rep, err := repo.Open(ctx, env.Repository.ConfigFile, "new-password", &repo.Options{})
+	if err != nil {
+		t.Fatalf("unable to open repository with the new password: %v", err)
+	}
+	defer rep.Close(ctx) //nolint:errcheck
+
+	verify(ctx, t, rep, oid, []byte("The quick brown fox jumps over the lazy dog"), "reset-password")
+
+	// the password can't be reset without key material.
+	kit, err = env.Repository.RecoveryKit(false, "")
+	if err != nil {
+		t.Fatalf("unable to create recovery kit: %v", err)
+	}
+
+	if err := repo.ResetPassword(ctx, env.Repository.Blobs, kit, "", "another-password"); err == nil {
+		t.Errorf("unexpected success resetting password without key material")
+	}
+}
+
+func TestRecoverFormatBlobWithPaddedBlobSizes(t *testing.T) {
+	ctx := context.Background()
+
+	var env repotesting.Environment
+	defer env.Setup(t, func(n *repo.NewRepositoryOptions) {
+		n.ObfuscateBlobNames = true
+		n.PadBlobSizes = true
+	}).Close(t)
+
+	writeObject(ctx, t, env.Repository, []byte("The quick brown fox jumps over the lazy dog"), "padded")
+
+	if err := env.Repository.Flush(ctx); err != nil {
+		t.Fatalf("flush error: %v", err)
+	}
+
+	formatBlob, err := env.Repository.Blobs.GetBlob(ctx, repo.FormatBlobID, 0, -1)
+	if err != nil {
+		t.Fatalf("unable to read format blob: %v", err)
+	}
+
+	var packs []blob.Metadata
+
+	for _, prefix := range content.PackBlobIDPrefixes {
+		bms, lerr := blob.ListAllBlobs(ctx, env.Repository.Blobs, prefix)
+		if lerr != nil {
+			t.Fatalf("unable to list packs: %v", lerr)
+		}
+
+		packs = append(packs, bms...)
+	}
+
+	if len(packs) == 0 {
+		t.Fatalf("no packs found")
+	}
+
+	for _, bm := range packs {
+		for _, length := range []int64{bm.Length, -1} {
+			b, rerr := repo.RecoverFormatBlob(ctx, env.Repository.Blobs, bm.BlobID, length)
+			if rerr != nil || !bytes.Equal(b, formatBlob) {
+				t.Errorf("unable to recover format blob from %v with length %v: %v", bm.BlobID, length, rerr)
+			}
+		}
+	}
+
+	kit, err := env.Repository.RecoveryKit(false, "")
+	if err != nil {
+		t.Fatalf("unable to create recovery kit: %v", err)
+	}
+
+	v, err := repo.VerifyRecoveryKit(ctx, env.Repository.Blobs, kit, 10)
+	if err != nil {
+		t.Fatalf("verification failed: %v", err)
+	}
+
+	if v.CheckedPacks != len(packs) || v.MatchingPacks != v.CheckedPacks {
+		t.Errorf("unexpected verification result: %+v", v)
+	}
+}
@@ -0,0 +1,60 @@
+// Package changedetection defines how files which have not changed since the previous snapshot are detected.
+package changedetection
+
+import "strings"
+
+// Mode determines how the uploader detects that a file has changed since the previous snapshot.
+type Mode string
+
+// Supported change detection modes.
+const (
+	// TrustModTime reuses previous contents of files whose size, modification time, mode and owner are unchanged.
+	TrustModTime Mode = "trust-mtime"
+
+	// TrustChangeTime additionally requires the local hash cache to confirm that the file
+	// has not changed since it was hashed, which detects changes that preserve modification time.
+	TrustChangeTime Mode = "trust-ctime"
+
+	// AlwaysHash always hashes contents of all files.
+	AlwaysHash Mode = "always-hash"
+)
+
+// Modes lists all supported change detection modes.
+var Modes = []Mode{
+	TrustModTime,
+	TrustChangeTime,
+	AlwaysHash,
+}
+
+// Map maps paths relative to the root of the snapshot to change detection modes used for files
+// in the subtrees they designate. Relative paths start with "." and path elements are separated with "/".
+type Map map[string]Mode
+
+// ModeForPath returns the change detection mode of the directory with a given relative path,
+// which is defined for the closest ancestor directory or TrustModTime.
+func (m Map) ModeForPath(relativePath string) Mode {
+	for {
+		if mode := m[relativePath]; mode != "" {
+			return mode
+		}
+
+		p := strings.LastIndex(relativePath, "/")
+		if p < 0 {
+			return TrustModTime
+		}
+
+		relativePath = relativePath[0:p]
+	}
+}
+
+// UsesHashCache returns true if any subtree uses a change detection mode which requires the local hash cache.
+// Other modes don't read it, so the cost of loading and saving it can be avoided.
+func (m Map) UsesHashCache() bool {
+	for _, mode := range m {
+		if mode == TrustChangeTime {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,40 @@
+package changedetection
+
+import "testing"
+
+func TestModeForPath(t *testing.T) {
+	m := Map{
+		".":       TrustChangeTime,
+		"./a/b":   AlwaysHash,
+		"./a/b/c": TrustModTime,
+	}
+
+	cases := map[string]Mode{
+		".":         TrustChangeTime,
+		"./a":       TrustChangeTime,
+		"./a/b":     AlwaysHash,
+		"./a/bb":    TrustChangeTime,
+		"./a/b/d/e": AlwaysHash,
+		"./a/b/c/d": TrustModTime,
+	}
+
+	for p, want := range cases {
+		if got := m.ModeForPath(p); got != want {
+			t.Errorf("invalid mode for %v: %v, want %v", p, got, want)
+		}
+	}
+
+	if got, want := Map(nil).ModeForPath("./a"), TrustModTime; got != want {
+		t.Errorf("invalid default mode: %v, want %v", got, want)
+	}
+
+	if !m.UsesHashCache() {
+		t.Errorf("hash cache is not used by %v", m)
+	}
+
+	for _, m := range []Map{nil, {".": TrustModTime, "./a": AlwaysHash}} {
+		if m.UsesHashCache() {
+			t.Errorf("hash cache is unexpectedly used by %v", m)
+		}
+	}
+}
@@ -57,8 +57,8 @@ func ListSnapshots(ctx context.Context, rep *repo.Repository, si SourceInfo) ([]
 	return LoadSnapshots(ctx, rep, entryIDs(entries))
 }
 
-// loadSnapshot loads and parses a snapshot with a given ID.
-func loadSnapshot(ctx context.Context, rep *repo.Repository, manifestID manifest.ID) (*Manifest, error) {
+// LoadSnapshot loads and parses a snapshot with a given ID.
+func LoadSnapshot(ctx context.Context, rep *repo.Repository, manifestID manifest.ID) (*Manifest, error) {
 	sm := &Manifest{}
 	if err := rep.Manifests.Get(ctx, manifestID, sm); err != nil {
 		return nil, errors.Wrap(err, "unable to find manifest entries")
@@ -68,6 +68,17 @@ func loadSnapshot(ctx context.Context, rep *repo.Repository, manifestID manifest
 	return sm, nil
 }
 
+// LoadDeletedSnapshot loads and parses a deleted snapshot manifest that is still retained.
+func LoadDeletedSnapshot(ctx context.Context, rep *repo.Repository, manifestID manifest.ID) (*Manifest, error) {
+	sm := &Manifest{}
+	if err := rep.Manifests.GetDeleted(ctx, manifestID, sm); err != nil {
+		return nil, errors.Wrap(err, "unable to find deleted manifest entries")
+	}
+
+	sm.ID = manifestID
+	return sm, nil
+}
+
 // SaveSnapshot persists given snapshot manifest and returns manifest ID.
 func SaveSnapshot(ctx context.Context, rep *repo.Repository, man *Manifest) (manifest.ID, error) {
 	if man.Source.Host == "" {
@@ -88,6 +99,27 @@ func SaveSnapshot(ctx context.Context, rep *repo.Repository, man *Manifest) (man
 	return id, nil
 }
 
+// DeleteCheckpoints deletes checkpoint manifests of the source of a given snapshot, which were started
+// no later than the snapshot itself and are no longer needed to resume uploads.
+func DeleteCheckpoints(ctx context.Context, rep *repo.Repository, man *Manifest) error {
+	snapshots, err := ListSnapshots(ctx, rep, man.Source)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range snapshots {
+		if m.IncompleteReason != IncompleteReasonCheckpoint || m.StartTime.After(man.StartTime) {
+			continue
+		}
+
+		if err := rep.Manifests.Delete(ctx, m.ID); err != nil {
+			return errors.Wrapf(err, "unable to delete checkpoint %v", m.ID)
+		}
+	}
+
+	return nil
+}
+
 // LoadSnapshots efficiently loads and parses a given list of snapshot IDs.
 func LoadSnapshots(ctx context.Context, rep *repo.Repository, manifestIDs []manifest.ID) ([]*Manifest, error) {
 	result := make([]*Manifest, len(manifestIDs))
@@ -98,7 +130,7 @@ func LoadSnapshots(ctx context.Context, rep *repo.Repository, manifestIDs []mani
 		go func(i int, n manifest.ID) {
 			defer func() { <-sem }()
 
-			m, err := loadSnapshot(ctx, rep, n)
+			m, err := LoadSnapshot(ctx, rep, n)
 			if err != nil {
 				log.Warningf("unable to parse snapshot manifest %v: %v", n, err)
 				return
@@ -124,21 +156,42 @@ func LoadSnapshots(ctx context.Context, rep *repo.Repository, manifestIDs []mani
 
 // ListSnapshotManifests returns the list of snapshot manifests for a given source or all sources if nil.
 func ListSnapshotManifests(ctx context.Context, rep *repo.Repository, src *SourceInfo) ([]manifest.ID, error) {
-	labels := map[string]string{
-		"type": "snapshot",
+	q := manifest.Query{
+		Labels: []manifest.LabelPredicate{manifest.LabelEquals("type", "snapshot")},
 	}
 
 	if src != nil {
-		labels = sourceInfoToLabels(*src)
+		q = manifest.LabelsQuery(sourceInfoToLabels(*src))
 	}
 
-	entries, err := rep.Manifests.Find(ctx, labels)
+	entries, err := rep.Manifests.Query(ctx, q)
 	if err != nil {
 		return nil, errors.Wrap(err, "unable to find manifest entries")
 	}
 	return entryIDs(entries), nil
 }
 
+// ListDeletedSnapshotManifests returns metadata of deleted snapshot manifests that are still retained, optionally
+// filtered by source, ordered by deletion time.
+func ListDeletedSnapshotManifests(ctx context.Context, rep *repo.Repository, src *SourceInfo) ([]*manifest.EntryMetadata, error) {
+	q := manifest.Query{
+		Labels: []manifest.LabelPredicate{manifest.LabelEquals("type", "snapshot")},
+	}
+
+	if src != nil {
+		q = manifest.LabelsQuery(sourceInfoToLabels(*src))
+	}
+
+	q.OnlyDeleted = true
+
+	entries, err := rep.Manifests.Query(ctx, q)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to find deleted manifest entries")
+	}
+
+	return entries, nil
+}
+
 func entryIDs(entries []*manifest.EntryMetadata) []manifest.ID {
 	var ids []manifest.ID
 	for _, e := range entries {
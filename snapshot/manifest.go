@@ -25,9 +25,15 @@ type Manifest struct {
 
 	RootEntry *DirEntry `json:"rootEntry"`
 
+	// fingerprint of policies the snapshot was taken with (see policy.UploadPolicyFingerprint), empty if unknown
+	PolicyFingerprint string `json:"policyFingerprint,omitempty"`
+
 	RetentionReasons []string `json:"-"`
 }
 
+// IncompleteReasonCheckpoint is the IncompleteReason of manifests written periodically while the upload is in progress.
+const IncompleteReasonCheckpoint = "checkpoint"
+
 // EntryType is a type of a filesystem entry.
 type EntryType string
 
@@ -128,3 +134,8 @@ func SortByTime(manifests []*Manifest, reverse bool) []*Manifest {
 
 	return result
 }
+
+// HasDirEntry is implemented by objects that have a DirEntry associated with them.
+type HasDirEntry interface {
+	DirEntry() *DirEntry
+}
@@ -0,0 +1,234 @@
+package policy
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// maxCronSearchYears limits how far into the future the next matching time is searched for.
+const maxCronSearchYears = 5
+
+var cronMacros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+var monthNames = map[string]int{
+	"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+	"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+}
+
+var dayOfWeekNames = map[string]int{
+	"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+}
+
+// cronSchedule is a parsed cron expression in the standard 5-field format:
+// minute, hour, day of month, month and day of week.
+type cronSchedule struct {
+	minute, hour, dayOfMonth, month, dayOfWeek uint64
+
+	// when either day field is unrestricted, both must match, otherwise any of them.
+	dayOfMonthStar, dayOfWeekStar bool
+}
+
+// parseCronExpression parses the standard 5-field cron expression, supporting lists (1,2),
+// ranges (1-5), steps (*/15, 0-30/10), names of months and days of week and macros such as @daily.
+func parseCronExpression(s string) (*cronSchedule, error) {
+	s = strings.TrimSpace(s)
+	if m, ok := cronMacros[strings.ToLower(s)]; ok {
+		s = m
+	}
+
+	fields := strings.Fields(s)
+	if len(fields) != 5 {
+		return nil, errors.Errorf("invalid cron expression %q, expected 5 fields", s)
+	}
+
+	var (
+		c   cronSchedule
+		err error
+	)
+
+	if c.minute, err = parseCronField(fields[0], 0, 59, nil); err != nil {
+		return nil, errors.Wrap(err, "invalid minute")
+	}
+
+	if c.hour, err = parseCronField(fields[1], 0, 23, nil); err != nil {
+		return nil, errors.Wrap(err, "invalid hour")
+	}
+
+	if c.dayOfMonth, err = parseCronField(fields[2], 1, 31, nil); err != nil {
+		return nil, errors.Wrap(err, "invalid day of month")
+	}
+
+	if c.month, err = parseCronField(fields[3], 1, 12, monthNames); err != nil {
+		return nil, errors.Wrap(err, "invalid month")
+	}
+
+	if c.dayOfWeek, err = parseCronField(fields[4], 0, 7, dayOfWeekNames); err != nil {
+		return nil, errors.Wrap(err, "invalid day of week")
+	}
+
+	// both 0 and 7 mean Sunday
+	if c.dayOfWeek&(1<<7) != 0 {
+		c.dayOfWeek |= 1
+	}
+
+	c.dayOfMonthStar = strings.HasPrefix(fields[2], "*")
+	c.dayOfWeekStar = strings.HasPrefix(fields[4], "*")
+
+	return &c, nil
+}
+
+// parseCronField parses a single comma-separated cron field into a bit set of allowed values.
+func parseCronField(s string, min, max int, names map[string]int) (uint64, error) {
+	var result uint64
+
+	for _, part := range strings.Split(s, ",") {
+		step := 1
+
+		if p := strings.Index(part, "/"); p >= 0 {
+			v, err := strconv.Atoi(part[p+1:])
+			if err != nil || v <= 0 {
+				return 0, errors.Errorf("invalid step in %q", part)
+			}
+
+			step = v
+			part = part[0:p]
+		}
+
+		lo, hi := min, max
+
+		if part != "*" {
+			var err error
+
+			rng := strings.SplitN(part, "-", 2)
+			if lo, err = parseCronValue(rng[0], names); err != nil {
+				return 0, err
+			}
+
+			hi = lo
+			if len(rng) == 2 {
+				if hi, err = parseCronValue(rng[1], names); err != nil {
+					return 0, err
+				}
+			} else if step > 1 {
+				// 'N/step' means from N to the maximum value
+				hi = max
+			}
+		}
+
+		if lo < min || hi > max || lo > hi {
+			return 0, errors.Errorf("value out of range in %q, must be between %v and %v", part, min, max)
+		}
+
+		for v := lo; v <= hi; v += step {
+			result |= 1 << uint(v)
+		}
+	}
+
+	return result, nil
+}
+
+func parseCronValue(s string, names map[string]int) (int, error) {
+	if v, ok := names[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, errors.Errorf("invalid value %q", s)
+	}
+
+	return v, nil
+}
+
+func (c *cronSchedule) matchesDay(t time.Time) bool {
+	domMatch := c.dayOfMonth&(1<<uint(t.Day())) != 0
+	dowMatch := c.dayOfWeek&(1<<uint(t.Weekday())) != 0
+
+	if c.dayOfMonthStar || c.dayOfWeekStar {
+		return domMatch && dowMatch
+	}
+
+	return domMatch || dowMatch
+}
+
+// next returns the earliest time strictly after t matching the schedule, in t's location.
+// Returns zero time if there's no such time within the next few years (e.g. February 30th).
+func (c *cronSchedule) next(t time.Time) time.Time {
+	loc := t.Location()
+	t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute()+1, 0, 0, loc)
+	limit := t.Year() + maxCronSearchYears
+
+	for t.Year() <= limit {
+		if c.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+			continue
+		}
+
+		if !c.matchesDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+			continue
+		}
+
+		if c.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+			continue
+		}
+
+		if c.minute&(1<<uint(t.Minute())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute()+1, 0, 0, loc)
+			continue
+		}
+
+		return t
+	}
+
+	return time.Time{}
+}
+
+// ParseDaysOfWeek parses comma-separated list of days of week (names such as 'mon' or numbers 0-7, where both 0 and 7 mean Sunday),
+// including ranges such as 'mon-fri'.
+func ParseDaysOfWeek(s string) ([]int, error) {
+	bits, err := parseCronField(s, 0, 7, dayOfWeekNames)
+	if err != nil {
+		return nil, err
+	}
+
+	if bits&(1<<7) != 0 {
+		bits |= 1
+	}
+
+	return bitsToList(bits, 0, 6), nil
+}
+
+// ParseDaysOfMonth parses comma-separated list of days of month (1-31), including ranges such as '1-7'.
+func ParseDaysOfMonth(s string) ([]int, error) {
+	bits, err := parseCronField(s, 1, 31, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return bitsToList(bits, 1, 31), nil
+}
+
+func bitsToList(bits uint64, min, max int) []int {
+	var result []int
+
+	for v := min; v <= max; v++ {
+		if bits&(1<<uint(v)) != 0 {
+			result = append(result, v)
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,25 @@
+package policy
+
+// ErrorHandlingPolicy describes how to handle errors encountered while snapshotting individual files.
+type ErrorHandlingPolicy struct {
+	// MaxFileErrors causes the snapshot to fail when more than the given number of files could not be read.
+	MaxFileErrors *int `json:"maxFileErrors,omitempty"`
+}
+
+// Merge applies default values from the provided policy.
+func (p *ErrorHandlingPolicy) Merge(src ErrorHandlingPolicy) {
+	if p.MaxFileErrors == nil {
+		p.MaxFileErrors = src.MaxFileErrors
+	}
+}
+
+// FileErrorLimit returns the maximum number of file errors tolerated by the snapshot or -1 if there's no limit.
+func (p *ErrorHandlingPolicy) FileErrorLimit() int {
+	if p.MaxFileErrors == nil {
+		return -1
+	}
+
+	return *p.MaxFileErrors
+}
+
+var defaultErrorHandlingPolicy = ErrorHandlingPolicy{}
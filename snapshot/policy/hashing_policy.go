@@ -0,0 +1,20 @@
+package policy
+
+import "github.com/kopia/kopia/snapshot/changedetection"
+
+// HashingPolicy describes how files are hashed while taking snapshots.
+type HashingPolicy struct {
+	// ChangeDetection determines how files which have not changed since the previous snapshot are detected.
+	ChangeDetection changedetection.Mode `json:"changeDetection,omitempty"`
+}
+
+// Merge applies default values from the provided policy.
+func (p *HashingPolicy) Merge(src HashingPolicy) {
+	if p.ChangeDetection == "" {
+		p.ChangeDetection = src.ChangeDetection
+	}
+}
+
+var defaultHashingPolicy = HashingPolicy{
+	ChangeDetection: changedetection.TrustModTime,
+}
@@ -14,11 +14,13 @@ var ErrPolicyNotFound = errors.New("policy not found")
 
 // Policy describes snapshot policy for a single source.
 type Policy struct {
-	Labels           map[string]string    `json:"-"`
-	RetentionPolicy  RetentionPolicy      `json:"retention,omitempty"`
-	FilesPolicy      ignorefs.FilesPolicy `json:"files,omitempty"`
-	SchedulingPolicy SchedulingPolicy     `json:"scheduling,omitempty"`
-	NoParent         bool                 `json:"noParent,omitempty"`
+	Labels              map[string]string    `json:"-"`
+	RetentionPolicy     RetentionPolicy      `json:"retention,omitempty"`
+	FilesPolicy         ignorefs.FilesPolicy `json:"files,omitempty"`
+	ErrorHandlingPolicy ErrorHandlingPolicy  `json:"errorHandling,omitempty"`
+	HashingPolicy       HashingPolicy        `json:"hashing,omitempty"`
+	SchedulingPolicy    SchedulingPolicy     `json:"scheduling,omitempty"`
+	NoParent            bool                 `json:"noParent,omitempty"`
 }
 
 func (p *Policy) String() string {
@@ -57,12 +59,16 @@ func MergePolicies(policies []*Policy) *Policy {
 
 		merged.RetentionPolicy.Merge(p.RetentionPolicy)
 		merged.FilesPolicy.Merge(p.FilesPolicy)
+		merged.ErrorHandlingPolicy.Merge(p.ErrorHandlingPolicy)
+		merged.HashingPolicy.Merge(p.HashingPolicy)
 		merged.SchedulingPolicy.Merge(p.SchedulingPolicy)
 	}
 
 	// Merge default expiration policy.
 	merged.RetentionPolicy.Merge(defaultRetentionPolicy)
 	merged.FilesPolicy.Merge(ignorefs.DefaultFilesPolicy)
+	merged.ErrorHandlingPolicy.Merge(defaultErrorHandlingPolicy)
+	merged.HashingPolicy.Merge(defaultHashingPolicy)
 	merged.SchedulingPolicy.Merge(defaultSchedulingPolicy)
 
 	return &merged
@@ -0,0 +1,77 @@
+package policy
+
+import (
+	"encoding/json"
+	"sort"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/snapshot"
+)
+
+// mergedFields are JSON paths of list fields whose effective values are merged from all policies
+// instead of being taken from the most specific policy defining them (see SchedulingPolicy.Merge).
+var mergedFields = map[string]bool{
+	"scheduling.timeOfDay": true,
+	"scheduling.cron":      true,
+}
+
+// DefinitionPoints returns the targets of policies which defined each field of the effective policy,
+// given the list of contributing policies returned by GetEffectivePolicy (most specific first).
+// The keys are dot-separated JSON field paths (e.g. "retention.keepDaily"). Fields which are not
+// defined by any of the policies have default values and are not included.
+// Most fields are defined by a single policy, fields merged from multiple policies (such as
+// "scheduling.timeOfDay") list all policies which contributed to them, most specific first.
+func DefinitionPoints(policies []*Policy) (map[string][]snapshot.SourceInfo, error) {
+	result := map[string][]snapshot.SourceInfo{}
+
+	for _, p := range policies {
+		fields, err := definedFields(p)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to determine fields of %v", p.Target())
+		}
+
+		for _, f := range fields {
+			if _, ok := result[f]; !ok || mergedFields[f] {
+				result[f] = append(result[f], p.Target())
+			}
+		}
+
+		if p.NoParent {
+			break
+		}
+	}
+
+	return result, nil
+}
+
+// definedFields returns sorted JSON paths of all leaf fields defined in a given policy.
+func definedFields(p *Policy) ([]string, error) {
+	b, err := json.Marshal(p)
+	if err != nil {
+		return nil, err
+	}
+
+	var m map[string]interface{}
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, err
+	}
+
+	var result []string
+
+	flattenFields("", m, &result)
+	sort.Strings(result)
+
+	return result, nil
+}
+
+func flattenFields(prefix string, m map[string]interface{}, result *[]string) {
+	for k, v := range m {
+		if nested, ok := v.(map[string]interface{}); ok {
+			flattenFields(prefix+k+".", nested, result)
+			continue
+		}
+
+		*result = append(*result, prefix+k)
+	}
+}
@@ -0,0 +1,103 @@
+package policy
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/kopia/kopia/fs/ignorefs"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/changedetection"
+)
+
+func policyWithTarget(p *Policy, si snapshot.SourceInfo) *Policy {
+	p.Labels = labelsForSource(si)
+	return p
+}
+
+func TestDefinitionPoints(t *testing.T) {
+	global := GlobalPolicySourceInfo
+	host := snapshot.SourceInfo{Host: "host"}
+	dir := snapshot.SourceInfo{Host: "host", UserName: "user", Path: "/some/path"}
+
+	policies := []*Policy{
+		policyWithTarget(&Policy{
+			RetentionPolicy:  RetentionPolicy{KeepDaily: intPtr(3)},
+			FilesPolicy:      ignorefs.FilesPolicy{IgnoreRules: []string{"*.tmp"}},
+			SchedulingPolicy: SchedulingPolicy{TimesOfDay: []TimeOfDay{{Hour: 10}}},
+		}, dir),
+		policyWithTarget(&Policy{
+			RetentionPolicy: RetentionPolicy{KeepDaily: intPtr(5), KeepHourly: intPtr(7)},
+			FilesPolicy:     ignorefs.FilesPolicy{MaxFileSize: 1000, IgnoreRules: []string{"*.bak"}},
+		}, host),
+		policyWithTarget(&Policy{
+			RetentionPolicy:  RetentionPolicy{KeepAnnual: intPtr(1)},
+			SchedulingPolicy: SchedulingPolicy{IntervalSeconds: 3600, TimesOfDay: []TimeOfDay{{Hour: 20}}},
+		}, global),
+	}
+
+	def, err := DefinitionPoints(policies)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	want := map[string][]snapshot.SourceInfo{
+		"retention.keepDaily":        {dir},
+		"retention.keepHourly":       {host},
+		"files.maxFileSize":          {host},
+		"retention.keepAnnual":       {global},
+		"scheduling.intervalSeconds": {global},
+
+		// ignore rules are not merged, the most specific policy defines them.
+		"files.ignore": {dir},
+
+		// times of day are merged from all policies.
+		"scheduling.timeOfDay": {dir, global},
+	}
+
+	if !reflect.DeepEqual(def, want) {
+		t.Errorf("unexpected definitions: %v, want %v", def, want)
+	}
+
+	// definitions stop at the first policy that doesn't inherit from parents.
+	policies[1].NoParent = true
+
+	def, err = DefinitionPoints(policies)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if _, ok := def["retention.keepAnnual"]; ok {
+		t.Errorf("unexpected definition inherited from global policy")
+	}
+
+	if got, want := def["scheduling.timeOfDay"], []snapshot.SourceInfo{dir}; !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected definition of merged field: %v, want %v", got, want)
+	}
+}
+
+func TestValidatePolicy(t *testing.T) {
+	cases := []struct {
+		pol     *Policy
+		wantErr bool
+	}{
+		{&Policy{}, false},
+		{&Policy{RetentionPolicy: RetentionPolicy{KeepLatest: intPtr(0)}}, false},
+		{&Policy{RetentionPolicy: RetentionPolicy{KeepLatest: intPtr(-1)}}, true},
+		{&Policy{SchedulingPolicy: SchedulingPolicy{IntervalSeconds: -1}}, true},
+		{&Policy{SchedulingPolicy: SchedulingPolicy{TimesOfDay: []TimeOfDay{{Hour: 23, Minute: 59}}}}, false},
+		{&Policy{SchedulingPolicy: SchedulingPolicy{TimesOfDay: []TimeOfDay{{Hour: 24, Minute: 0}}}}, true},
+		{&Policy{SchedulingPolicy: SchedulingPolicy{TimesOfDay: []TimeOfDay{{Hour: 1, Minute: 60}}}}, true},
+		{&Policy{FilesPolicy: ignorefs.FilesPolicy{MaxFileSize: -1}}, true},
+		{&Policy{ErrorHandlingPolicy: ErrorHandlingPolicy{MaxFileErrors: intPtr(0)}}, false},
+		{&Policy{ErrorHandlingPolicy: ErrorHandlingPolicy{MaxFileErrors: intPtr(-1)}}, true},
+		{&Policy{HashingPolicy: HashingPolicy{ChangeDetection: changedetection.TrustChangeTime}}, false},
+		{&Policy{HashingPolicy: HashingPolicy{ChangeDetection: "trust-nothing"}}, true},
+	}
+
+	for i, tc := range cases {
+		err := ValidatePolicy(tc.pol)
+		if gotErr := err != nil; gotErr != tc.wantErr {
+			t.Errorf("case %v: unexpected error %v, wantErr %v", i, err, tc.wantErr)
+		}
+	}
+}
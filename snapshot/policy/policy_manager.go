@@ -3,7 +3,11 @@ package policy
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/pkg/errors"
@@ -13,6 +17,7 @@ import (
 	"github.com/kopia/kopia/repo"
 	"github.com/kopia/kopia/repo/manifest"
 	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/changedetection"
 )
 
 // GlobalPolicySourceInfo is a source where global policy is attached.
@@ -24,22 +29,10 @@ var log = kopialogging.Logger("kopia/snapshot/policy")
 // with parent policies. The source must contain a path.
 // Returns the effective policies and all source policies that contributed to that (most specific first).
 func GetEffectivePolicy(ctx context.Context, rep *repo.Repository, si snapshot.SourceInfo) (effective *Policy, sources []*Policy, e error) {
-	var md []*manifest.EntryMetadata
-
 	// Find policies applying to paths all the way up to the root.
-	for tmp := si; len(si.Path) > 0; {
-		manifests, err := rep.Manifests.Find(ctx, labelsForSource(tmp))
-		if err != nil {
-			return nil, nil, err
-		}
-		md = append(md, manifests...)
-
-		parentPath := filepath.Dir(tmp.Path)
-		if parentPath == tmp.Path {
-			break
-		}
-
-		tmp.Path = parentPath
+	md, err := findPathPolicies(ctx, rep, si)
+	if err != nil {
+		return nil, nil, err
 	}
 
 	// Try user@host policy
@@ -80,6 +73,45 @@ func GetEffectivePolicy(ctx context.Context, rep *repo.Repository, si snapshot.S
 	return merged, policies, nil
 }
 
+// findPathPolicies returns path policies applying to the source, most specific first.
+func findPathPolicies(ctx context.Context, rep *repo.Repository, si snapshot.SourceInfo) ([]*manifest.EntryMetadata, error) {
+	var paths []string
+
+	for p := si.Path; len(p) > 0; {
+		paths = append(paths, p)
+
+		parentPath := filepath.Dir(p)
+		if parentPath == p {
+			break
+		}
+
+		p = parentPath
+	}
+
+	if len(paths) == 0 {
+		return nil, nil
+	}
+
+	md, err := rep.Manifests.Query(ctx, manifest.Query{
+		Labels: []manifest.LabelPredicate{
+			manifest.LabelEquals("type", "policy"),
+			manifest.LabelEquals("policyType", "path"),
+			manifest.LabelEquals("username", si.UserName),
+			manifest.LabelEquals("hostname", si.Host),
+			manifest.LabelIn("path", paths...),
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(md, func(i, j int) bool {
+		return len(md[i].Labels["path"]) > len(md[j].Labels["path"])
+	})
+
+	return md, nil
+}
+
 // GetDefinedPolicy returns the policy defined on the provided snapshot.SourceInfo or ErrPolicyNotFound if not present.
 func GetDefinedPolicy(ctx context.Context, rep *repo.Repository, si snapshot.SourceInfo) (*Policy, error) {
 	md, err := rep.Manifests.Find(ctx, labelsForSource(si))
@@ -116,7 +148,12 @@ func GetDefinedPolicy(ctx context.Context, rep *repo.Repository, si snapshot.Sou
 }
 
 // SetPolicy sets the policy on a given source.
+// The policy is validated using ValidatePolicy before being saved.
 func SetPolicy(ctx context.Context, rep *repo.Repository, si snapshot.SourceInfo, pol *Policy) error {
+	if err := ValidatePolicy(pol); err != nil {
+		return err
+	}
+
 	md, err := rep.Manifests.Find(ctx, labelsForSource(si))
 	if err != nil {
 		return errors.Wrapf(err, "unable to load manifests for %v", si)
@@ -205,6 +242,86 @@ func FilesPolicyGetter(ctx context.Context, rep *repo.Repository, si snapshot.So
 
 	result["."] = &pol.FilesPolicy
 
+	subdirPolicies, err := subdirectoryPolicies(ctx, rep, si)
+	if err != nil {
+		return nil, err
+	}
+
+	for rel, pol := range subdirPolicies {
+		result[rel] = &pol.FilesPolicy
+	}
+
+	return result, nil
+}
+
+// ChangeDetectionMap returns changedetection.Map for a given source.
+func ChangeDetectionMap(ctx context.Context, rep *repo.Repository, si snapshot.SourceInfo) (changedetection.Map, error) {
+	result := changedetection.Map{}
+
+	pol, _, err := GetEffectivePolicy(ctx, rep, si)
+	if err != nil {
+		return nil, err
+	}
+
+	result["."] = pol.HashingPolicy.ChangeDetection
+
+	subdirPolicies, err := subdirectoryPolicies(ctx, rep, si)
+	if err != nil {
+		return nil, err
+	}
+
+	for rel, pol := range subdirPolicies {
+		if m := pol.HashingPolicy.ChangeDetection; m != "" {
+			result[rel] = m
+		}
+	}
+
+	return result, nil
+}
+
+// uploadPolicy is a subset of policy which affects contents of snapshots.
+type uploadPolicy struct {
+	FilesPolicy         ignorefs.FilesPolicy `json:"files"`
+	ErrorHandlingPolicy ErrorHandlingPolicy  `json:"errorHandling"`
+	HashingPolicy       HashingPolicy        `json:"hashing"`
+}
+
+// UploadPolicyFingerprint returns a fingerprint of policies which affect contents of snapshots of a given source,
+// which are the effective policy of the source and policies defined for its subdirectories.
+func UploadPolicyFingerprint(ctx context.Context, rep *repo.Repository, si snapshot.SourceInfo) (string, error) {
+	pol, _, err := GetEffectivePolicy(ctx, rep, si)
+	if err != nil {
+		return "", err
+	}
+
+	subdirPolicies, err := subdirectoryPolicies(ctx, rep, si)
+	if err != nil {
+		return "", err
+	}
+
+	policies := map[string]uploadPolicy{
+		".": {pol.FilesPolicy, pol.ErrorHandlingPolicy, pol.HashingPolicy},
+	}
+
+	for rel, pol := range subdirPolicies {
+		policies[rel] = uploadPolicy{pol.FilesPolicy, pol.ErrorHandlingPolicy, pol.HashingPolicy}
+	}
+
+	// map keys are sorted when encoding, which makes the fingerprint deterministic.
+	b, err := json.Marshal(policies)
+	if err != nil {
+		return "", errors.Wrap(err, "unable to encode policies")
+	}
+
+	h := sha256.Sum256(b)
+
+	return hex.EncodeToString(h[:]), nil
+}
+
+// subdirectoryPolicies returns policies defined for subdirectories of a given source keyed by their relative paths.
+func subdirectoryPolicies(ctx context.Context, rep *repo.Repository, si snapshot.SourceInfo) (map[string]*Policy, error) {
+	result := map[string]*Policy{}
+
 	// Find all policies for this host and user
 	policies, err := rep.Manifests.Find(ctx, map[string]string{
 		"type":       "policy",
@@ -240,7 +357,7 @@ func FilesPolicyGetter(ctx context.Context, rep *repo.Repository, si snapshot.So
 		if err := rep.Manifests.Get(ctx, id.ID, pol); err != nil {
 			return nil, errors.Wrapf(err, "unable to load policy %v", id.ID)
 		}
-		result[rel] = &pol.FilesPolicy
+		result[rel] = pol
 	}
 
 	return result, nil
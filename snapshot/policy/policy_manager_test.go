@@ -0,0 +1,82 @@
+package policy
+
+import (
+	"context"
+	"testing"
+
+	"github.com/kopia/kopia/fs/ignorefs"
+	"github.com/kopia/kopia/internal/repotesting"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/changedetection"
+)
+
+func TestUploadPolicyFingerprint(t *testing.T) {
+	ctx := context.Background()
+
+	var env repotesting.Environment
+	defer env.Setup(t).Close(t)
+
+	src := snapshot.SourceInfo{Host: "host", UserName: "user", Path: "/some/path"}
+
+	fingerprint := func() string {
+		t.Helper()
+
+		fp, err := UploadPolicyFingerprint(ctx, env.Repository, src)
+		if err != nil {
+			t.Fatalf("unable to compute fingerprint: %v", err)
+		}
+
+		return fp
+	}
+
+	setPolicy := func(si snapshot.SourceInfo, pol *Policy) {
+		t.Helper()
+
+		if err := SetPolicy(ctx, env.Repository, si, pol); err != nil {
+			t.Fatalf("unable to set policy: %v", err)
+		}
+	}
+
+	fp0 := fingerprint()
+	if fp0 == "" || fingerprint() != fp0 {
+		t.Fatalf("fingerprint is not stable: %q", fp0)
+	}
+
+	// policies which don't affect contents of snapshots don't change the fingerprint.
+	keepLatest := 3
+	setPolicy(GlobalPolicySourceInfo, &Policy{RetentionPolicy: RetentionPolicy{KeepLatest: &keepLatest}})
+	setPolicy(snapshot.SourceInfo{Host: "host", UserName: "user", Path: "/other/path/sub"}, &Policy{
+		FilesPolicy: ignorefs.FilesPolicy{IgnoreRules: []string{"*.tmp"}},
+	})
+
+	if got := fingerprint(); got != fp0 {
+		t.Errorf("fingerprint changed by unrelated policies")
+	}
+
+	fp := fp0
+
+	for _, tc := range []struct {
+		desc string
+		si   snapshot.SourceInfo
+		pol  *Policy
+	}{
+		{"ignore rules of a subdirectory", snapshot.SourceInfo{Host: "host", UserName: "user", Path: "/some/path/sub"}, &Policy{
+			FilesPolicy: ignorefs.FilesPolicy{IgnoreRules: []string{"*.tmp"}},
+		}},
+		{"change detection", src, &Policy{
+			HashingPolicy: HashingPolicy{ChangeDetection: changedetection.TrustChangeTime},
+		}},
+		{"inherited file error limit", snapshot.SourceInfo{Host: "host"}, &Policy{
+			ErrorHandlingPolicy: ErrorHandlingPolicy{MaxFileErrors: &keepLatest},
+		}},
+	} {
+		setPolicy(tc.si, tc.pol)
+
+		got := fingerprint()
+		if got == fp {
+			t.Errorf("fingerprint not changed by %v", tc.desc)
+		}
+
+		fp = got
+	}
+}
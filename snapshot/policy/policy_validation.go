@@ -0,0 +1,117 @@
+package policy
+
+import (
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/snapshot/changedetection"
+)
+
+// ValidatePolicy returns error if the given policy is invalid.
+// Policies are validated before being persisted, so the same rules apply regardless of whether
+// the policy is being set from the command line or via the API.
+func ValidatePolicy(pol *Policy) error {
+	if err := ValidateRetentionPolicy(pol.RetentionPolicy); err != nil {
+		return errors.Wrap(err, "invalid retention policy")
+	}
+
+	if err := ValidateSchedulingPolicy(pol.SchedulingPolicy); err != nil {
+		return errors.Wrap(err, "invalid scheduling policy")
+	}
+
+	if pol.FilesPolicy.MaxFileSize < 0 {
+		return errors.New("invalid files policy: maximum file size must be non-negative")
+	}
+
+	if v := pol.ErrorHandlingPolicy.MaxFileErrors; v != nil && *v < 0 {
+		return errors.New("invalid error handling policy: maximum number of file errors must be non-negative")
+	}
+
+	if err := ValidateHashingPolicy(pol.HashingPolicy); err != nil {
+		return errors.Wrap(err, "invalid hashing policy")
+	}
+
+	return nil
+}
+
+// ValidateRetentionPolicy returns an error if the retention policy is invalid.
+func ValidateRetentionPolicy(p RetentionPolicy) error {
+	cases := []struct {
+		desc string
+		val  *int
+	}{
+		{"number of annual backups to keep", p.KeepAnnual},
+		{"number of monthly backups to keep", p.KeepMonthly},
+		{"number of weekly backups to keep", p.KeepWeekly},
+		{"number of daily backups to keep", p.KeepDaily},
+		{"number of hourly backups to keep", p.KeepHourly},
+		{"number of latest backups to keep", p.KeepLatest},
+	}
+
+	for _, c := range cases {
+		if c.val != nil && *c.val < 0 {
+			return errors.Errorf("%v must be non-negative", c.desc)
+		}
+	}
+
+	return nil
+}
+
+// ValidateHashingPolicy returns an error if the hashing policy is invalid.
+func ValidateHashingPolicy(p HashingPolicy) error {
+	if p.ChangeDetection == "" {
+		return nil
+	}
+
+	for _, m := range changedetection.Modes {
+		if p.ChangeDetection == m {
+			return nil
+		}
+	}
+
+	return errors.Errorf("unsupported change detection mode %q", p.ChangeDetection)
+}
+
+// ValidateSchedulingPolicy returns an error if the scheduling policy is invalid.
+func ValidateSchedulingPolicy(p SchedulingPolicy) error {
+	if p.IntervalSeconds < 0 {
+		return errors.New("snapshot interval must be non-negative")
+	}
+
+	for _, tod := range p.TimesOfDay {
+		if tod.Hour < 0 || tod.Hour > 23 {
+			return errors.Errorf("invalid hour in %v, must be between 0 and 23", tod)
+		}
+
+		if tod.Minute < 0 || tod.Minute > 59 {
+			return errors.Errorf("invalid minute in %v, must be between 0 and 59", tod)
+		}
+	}
+
+	for _, d := range p.DaysOfWeek {
+		if d < 0 || d > 6 {
+			return errors.Errorf("invalid day of week %v, must be between 0 and 6", d)
+		}
+	}
+
+	for _, d := range p.DaysOfMonth {
+		if d < 1 || d > 31 {
+			return errors.Errorf("invalid day of month %v, must be between 1 and 31", d)
+		}
+	}
+
+	for _, expr := range p.Cron {
+		if _, err := parseCronExpression(expr); err != nil {
+			return errors.Wrapf(err, "invalid cron expression %q", expr)
+		}
+	}
+
+	if _, err := p.Location(); err != nil {
+		return errors.Wrapf(err, "invalid time zone %q", p.TimeZone)
+	}
+
+	if p.CatchUpWindowSeconds < 0 {
+		return errors.New("catch-up window must be non-negative")
+	}
+
+	return nil
+}
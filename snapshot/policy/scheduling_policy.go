@@ -50,6 +50,20 @@ func SortAndDedupeTimesOfDay(tod []TimeOfDay) []TimeOfDay {
 type SchedulingPolicy struct {
 	IntervalSeconds int64       `json:"intervalSeconds,omitempty"`
 	TimesOfDay      []TimeOfDay `json:"timeOfDay,omitempty"`
+
+	// DaysOfWeek (0=Sunday) and DaysOfMonth (1-31) restrict the days on which TimesOfDay apply.
+	DaysOfWeek  []int `json:"daysOfWeek,omitempty"`
+	DaysOfMonth []int `json:"daysOfMonth,omitempty"`
+
+	// Cron contains standard 5-field cron expressions describing additional snapshot times.
+	Cron []string `json:"cron,omitempty"`
+
+	// TimeZone is the IANA name of the time zone in which TimesOfDay and Cron are interpreted (local time zone if empty).
+	TimeZone string `json:"timeZone,omitempty"`
+
+	// CatchUpWindowSeconds allows snapshots whose scheduled time was missed (for example while the
+	// machine was asleep) to be taken as soon as possible, provided they were missed by no more than this duration.
+	CatchUpWindowSeconds int64 `json:"catchUpWindowSeconds,omitempty"`
 }
 
 // Interval returns the snapshot interval or zero if not specified.
@@ -62,6 +76,25 @@ func (p *SchedulingPolicy) SetInterval(d time.Duration) {
 	p.IntervalSeconds = int64(d.Seconds())
 }
 
+// CatchUpWindow returns the catch-up window for missed snapshots or zero if not specified.
+func (p *SchedulingPolicy) CatchUpWindow() time.Duration {
+	return time.Duration(p.CatchUpWindowSeconds) * time.Second
+}
+
+// SetCatchUpWindow sets the catch-up window for missed snapshots (zero disables).
+func (p *SchedulingPolicy) SetCatchUpWindow(d time.Duration) {
+	p.CatchUpWindowSeconds = int64(d.Seconds())
+}
+
+// Location returns the time zone in which calendar-based schedules are interpreted.
+func (p *SchedulingPolicy) Location() (*time.Location, error) {
+	if p.TimeZone == "" {
+		return time.Local, nil
+	}
+
+	return time.LoadLocation(p.TimeZone)
+}
+
 // Merge applies default values from the provided policy.
 func (p *SchedulingPolicy) Merge(src SchedulingPolicy) {
 	if p.IntervalSeconds == 0 {
@@ -69,6 +102,162 @@ func (p *SchedulingPolicy) Merge(src SchedulingPolicy) {
 	}
 	p.TimesOfDay = SortAndDedupeTimesOfDay(
 		append(append([]TimeOfDay(nil), src.TimesOfDay...), p.TimesOfDay...))
+
+	if len(p.DaysOfWeek) == 0 {
+		p.DaysOfWeek = src.DaysOfWeek
+	}
+
+	if len(p.DaysOfMonth) == 0 {
+		p.DaysOfMonth = src.DaysOfMonth
+	}
+
+	p.Cron = SortAndDedupeStrings(append(append([]string(nil), src.Cron...), p.Cron...))
+
+	if p.TimeZone == "" {
+		p.TimeZone = src.TimeZone
+	}
+
+	if p.CatchUpWindowSeconds == 0 {
+		p.CatchUpWindowSeconds = src.CatchUpWindowSeconds
+	}
+}
+
+// SortAndDedupeStrings sorts the slice of strings and removes duplicates.
+func SortAndDedupeStrings(s []string) []string {
+	if len(s) == 0 {
+		return nil
+	}
+
+	sort.Strings(s)
+
+	result := s[:1]
+	for _, v := range s[1:] {
+		if v != result[len(result)-1] {
+			result = append(result, v)
+		}
+	}
+
+	return result
+}
+
+// hasCalendarSchedule returns true if the policy defines snapshot times based on the calendar.
+func (p *SchedulingPolicy) hasCalendarSchedule() bool {
+	return len(p.TimesOfDay) > 0 || len(p.Cron) > 0
+}
+
+// NextSnapshotTime computes the time of the next snapshot given the start time of the previous
+// snapshot (zero if none) and current time. When a calendar-based snapshot was missed since the
+// previous snapshot by no more than the catch-up window, the snapshot is due immediately.
+// Returns false if the policy does not define any schedule applicable without previous snapshots.
+func (p *SchedulingPolicy) NextSnapshotTime(previousSnapshotTime, now time.Time) (time.Time, bool) {
+	var (
+		result time.Time
+		ok     bool
+	)
+
+	consider := func(t time.Time) {
+		if !ok || t.Before(result) {
+			result = t
+			ok = true
+		}
+	}
+
+	// intervals are measured from the previous snapshot, the first one is only taken on request.
+	if interval := p.Interval(); interval > 0 && !previousSnapshotTime.IsZero() {
+		consider(previousSnapshotTime.Add(interval).Truncate(interval))
+	}
+
+	if p.hasCalendarSchedule() {
+		start := now
+
+		if window := p.CatchUpWindow(); window > 0 && !previousSnapshotTime.IsZero() {
+			start = now.Add(-window)
+			if previousSnapshotTime.After(start) {
+				start = previousSnapshotTime
+			}
+		}
+
+		if t, found := p.nextCalendarTime(start); found {
+			if t.After(now) {
+				consider(t)
+			} else {
+				// missed snapshot within the catch-up window.
+				consider(now)
+			}
+		}
+	}
+
+	return result, ok
+}
+
+// nextCalendarTime returns the earliest time strictly after t matching TimesOfDay or Cron schedules.
+func (p *SchedulingPolicy) nextCalendarTime(t time.Time) (time.Time, bool) {
+	loc, err := p.Location()
+	if err != nil {
+		log.Warningf("invalid time zone %q, using local time: %v", p.TimeZone, err)
+		loc = time.Local
+	}
+
+	t = t.In(loc)
+
+	var (
+		result time.Time
+		ok     bool
+	)
+
+	if len(p.TimesOfDay) > 0 {
+		result, ok = p.nextTimeOfDay(t)
+	}
+
+	for _, expr := range p.Cron {
+		c, err := parseCronExpression(expr)
+		if err != nil {
+			log.Warningf("invalid cron expression %q: %v", expr, err)
+			continue
+		}
+
+		if n := c.next(t); !n.IsZero() && (!ok || n.Before(result)) {
+			result = n
+			ok = true
+		}
+	}
+
+	return result, ok
+}
+
+// nextTimeOfDay returns the earliest time strictly after t matching one of TimesOfDay on a day
+// allowed by DaysOfWeek and DaysOfMonth.
+func (p *SchedulingPolicy) nextTimeOfDay(t time.Time) (time.Time, bool) {
+	for d := 0; d <= 366; d++ {
+		day := time.Date(t.Year(), t.Month(), t.Day()+d, 0, 0, 0, 0, t.Location())
+		if !p.isAllowedDay(day) {
+			continue
+		}
+
+		for _, tod := range SortAndDedupeTimesOfDay(append([]TimeOfDay(nil), p.TimesOfDay...)) {
+			st := time.Date(day.Year(), day.Month(), day.Day(), tod.Hour, tod.Minute, 0, 0, t.Location())
+			if st.After(t) {
+				return st, true
+			}
+		}
+	}
+
+	return time.Time{}, false
+}
+
+func (p *SchedulingPolicy) isAllowedDay(day time.Time) bool {
+	return (len(p.DaysOfWeek) == 0 || containsInt(p.DaysOfWeek, int(day.Weekday()))) &&
+		(len(p.DaysOfMonth) == 0 || containsInt(p.DaysOfMonth, day.Day()))
+}
+
+func containsInt(s []int, v int) bool {
+	for _, item := range s {
+		if item == v {
+			return true
+		}
+	}
+
+	return false
 }
 
 var defaultSchedulingPolicy = SchedulingPolicy{}
@@ -0,0 +1,213 @@
+package policy
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCronExpression(t *testing.T) {
+	cases := []struct {
+		expr  string
+		valid bool
+	}{
+		{"* * * * *", true},
+		{"0 2 * * 1-5", true},
+		{"*/15 0-6 1,15 jan-jun mon-fri", true},
+		{"@daily", true},
+		{"0 0 * * 7", true},
+		{"60 * * * *", false},
+		{"* 24 * * *", false},
+		{"* * 0 * *", false},
+		{"* * * 13 *", false},
+		{"* * * * 8", false},
+		{"*/0 * * * *", false},
+		{"5-1 * * * *", false},
+		{"* * * *", false},
+		{"foo * * * *", false},
+	}
+
+	for _, tc := range cases {
+		_, err := parseCronExpression(tc.expr)
+		if got := err == nil; got != tc.valid {
+			t.Errorf("invalid result for %q: %v, wanted valid=%v", tc.expr, err, tc.valid)
+		}
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	// 2019-06-05 is a Wednesday
+	base := time.Date(2019, 6, 5, 10, 30, 15, 0, time.UTC)
+
+	cases := []struct {
+		expr string
+		want time.Time
+	}{
+		{"* * * * *", time.Date(2019, 6, 5, 10, 31, 0, 0, time.UTC)},
+		{"30 10 * * *", time.Date(2019, 6, 6, 10, 30, 0, 0, time.UTC)},
+		{"*/20 * * * *", time.Date(2019, 6, 5, 10, 40, 0, 0, time.UTC)},
+		{"0 2 * * sat", time.Date(2019, 6, 8, 2, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2019, 6, 9, 0, 0, 0, 0, time.UTC)},
+		{"@monthly", time.Date(2019, 7, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 feb *", time.Date(2020, 2, 29, 0, 0, 0, 0, time.UTC)},
+		// day of month OR day of week when both are restricted.
+		{"0 0 20 * thu", time.Date(2019, 6, 6, 0, 0, 0, 0, time.UTC)},
+		{"0 0 30 feb *", time.Time{}},
+	}
+
+	for _, tc := range cases {
+		c, err := parseCronExpression(tc.expr)
+		if err != nil {
+			t.Fatalf("unable to parse %q: %v", tc.expr, err)
+		}
+
+		if got := c.next(base); !got.Equal(tc.want) {
+			t.Errorf("invalid next time for %q: %v, wanted %v", tc.expr, got, tc.want)
+		}
+	}
+}
+
+func TestParseDays(t *testing.T) {
+	dow, err := ParseDaysOfWeek("mon-fri,7")
+	if err != nil {
+		t.Fatalf("unable to parse days of week: %v", err)
+	}
+
+	if want := []int{0, 1, 2, 3, 4, 5}; !intsEqual(dow, want) {
+		t.Errorf("invalid days of week: %v, wanted %v", dow, want)
+	}
+
+	dom, err := ParseDaysOfMonth("1,15-17")
+	if err != nil {
+		t.Fatalf("unable to parse days of month: %v", err)
+	}
+
+	if want := []int{1, 15, 16, 17}; !intsEqual(dom, want) {
+		t.Errorf("invalid days of month: %v, wanted %v", dom, want)
+	}
+
+	if _, err := ParseDaysOfMonth("0"); err == nil {
+		t.Errorf("expected error parsing day of month 0")
+	}
+}
+
+func TestNextSnapshotTime(t *testing.T) {
+	// 2019-06-05 is a Wednesday
+	now := time.Date(2019, 6, 5, 10, 30, 0, 0, time.UTC)
+
+	cases := []struct {
+		desc     string
+		pol      SchedulingPolicy
+		previous time.Time
+		want     time.Time
+		wantOK   bool
+	}{
+		{
+			desc:   "no schedule",
+			pol:    SchedulingPolicy{},
+			wantOK: false,
+		},
+		{
+			desc:   "interval without previous snapshot",
+			pol:    SchedulingPolicy{IntervalSeconds: 3600},
+			wantOK: false,
+		},
+		{
+			desc:   "interval and time of day without previous snapshot",
+			pol:    SchedulingPolicy{IntervalSeconds: 600, TimesOfDay: []TimeOfDay{{12, 0}}, TimeZone: "UTC"},
+			want:   time.Date(2019, 6, 5, 12, 0, 0, 0, time.UTC),
+			wantOK: true,
+		},
+		{
+			desc:     "interval",
+			pol:      SchedulingPolicy{IntervalSeconds: 3600},
+			previous: time.Date(2019, 6, 5, 10, 15, 0, 0, time.UTC),
+			want:     time.Date(2019, 6, 5, 11, 0, 0, 0, time.UTC),
+			wantOK:   true,
+		},
+		{
+			desc:   "time of day",
+			pol:    SchedulingPolicy{TimesOfDay: []TimeOfDay{{9, 0}, {12, 0}}, TimeZone: "UTC"},
+			want:   time.Date(2019, 6, 5, 12, 0, 0, 0, time.UTC),
+			wantOK: true,
+		},
+		{
+			desc:   "time of day on weekends",
+			pol:    SchedulingPolicy{TimesOfDay: []TimeOfDay{{9, 0}}, DaysOfWeek: []int{0, 6}, TimeZone: "UTC"},
+			want:   time.Date(2019, 6, 8, 9, 0, 0, 0, time.UTC),
+			wantOK: true,
+		},
+		{
+			desc:   "time of day on days of month",
+			pol:    SchedulingPolicy{TimesOfDay: []TimeOfDay{{9, 0}}, DaysOfMonth: []int{1}, TimeZone: "UTC"},
+			want:   time.Date(2019, 7, 1, 9, 0, 0, 0, time.UTC),
+			wantOK: true,
+		},
+		{
+			desc:   "cron in time zone",
+			pol:    SchedulingPolicy{Cron: []string{"0 14 * * *"}, TimeZone: "America/New_York"},
+			want:   time.Date(2019, 6, 5, 18, 0, 0, 0, time.UTC),
+			wantOK: true,
+		},
+		{
+			desc:     "earliest of interval and cron",
+			pol:      SchedulingPolicy{IntervalSeconds: 86400, Cron: []string{"45 10 * * *"}, TimeZone: "UTC"},
+			previous: time.Date(2019, 6, 5, 1, 0, 0, 0, time.UTC),
+			want:     time.Date(2019, 6, 5, 10, 45, 0, 0, time.UTC),
+			wantOK:   true,
+		},
+		{
+			desc:     "missed snapshot without catch-up window",
+			pol:      SchedulingPolicy{TimesOfDay: []TimeOfDay{{9, 0}}, TimeZone: "UTC"},
+			previous: time.Date(2019, 6, 4, 9, 0, 0, 0, time.UTC),
+			want:     time.Date(2019, 6, 6, 9, 0, 0, 0, time.UTC),
+			wantOK:   true,
+		},
+		{
+			desc:     "missed snapshot within catch-up window",
+			pol:      SchedulingPolicy{TimesOfDay: []TimeOfDay{{9, 0}}, TimeZone: "UTC", CatchUpWindowSeconds: 4 * 3600},
+			previous: time.Date(2019, 6, 4, 9, 0, 0, 0, time.UTC),
+			want:     now,
+			wantOK:   true,
+		},
+		{
+			desc:     "missed snapshot outside of catch-up window",
+			pol:      SchedulingPolicy{TimesOfDay: []TimeOfDay{{9, 0}}, TimeZone: "UTC", CatchUpWindowSeconds: 3600},
+			previous: time.Date(2019, 6, 4, 9, 0, 0, 0, time.UTC),
+			want:     time.Date(2019, 6, 6, 9, 0, 0, 0, time.UTC),
+			wantOK:   true,
+		},
+		{
+			desc:     "no missed snapshot within catch-up window",
+			pol:      SchedulingPolicy{TimesOfDay: []TimeOfDay{{9, 0}}, TimeZone: "UTC", CatchUpWindowSeconds: 4 * 3600},
+			previous: time.Date(2019, 6, 5, 9, 0, 5, 0, time.UTC),
+			want:     time.Date(2019, 6, 6, 9, 0, 0, 0, time.UTC),
+			wantOK:   true,
+		},
+	}
+
+	for _, tc := range cases {
+		got, ok := tc.pol.NextSnapshotTime(tc.previous, now)
+		if ok != tc.wantOK {
+			t.Errorf("%v: unexpected ok: %v, wanted %v", tc.desc, ok, tc.wantOK)
+			continue
+		}
+
+		if ok && !got.Equal(tc.want) {
+			t.Errorf("%v: invalid next snapshot time: %v, wanted %v", tc.desc, got, tc.want)
+		}
+	}
+}
+
+func intsEqual(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,197 @@
+package restore
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"context"
+	"io"
+	"os"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/fs"
+)
+
+// ZipOutput writes the restored tree as a ZIP archive to the provided writer.
+type ZipOutput struct {
+	zw *zip.Writer
+}
+
+// BeginDirectory implements restore.Output interface.
+func (o *ZipOutput) BeginDirectory(ctx context.Context, relativePath string, e fs.Directory) error {
+	if relativePath == "" {
+		return nil
+	}
+
+	h, err := zip.FileInfoHeader(e)
+	if err != nil {
+		return errors.Wrap(err, "unable to create zip header")
+	}
+
+	h.Name = relativePath + "/"
+
+	_, err = o.zw.CreateHeader(h)
+
+	return err
+}
+
+// FinishDirectory implements restore.Output interface.
+func (o *ZipOutput) FinishDirectory(ctx context.Context, relativePath string, e fs.Directory) error {
+	return nil
+}
+
+// WriteFile implements restore.Output interface.
+func (o *ZipOutput) WriteFile(ctx context.Context, relativePath string, f fs.File) error {
+	h, err := zip.FileInfoHeader(f)
+	if err != nil {
+		return errors.Wrap(err, "unable to create zip header")
+	}
+
+	h.Name = archiveEntryName(relativePath, f)
+	h.Method = zip.Deflate
+
+	w, err := o.zw.CreateHeader(h)
+	if err != nil {
+		return errors.Wrap(err, "unable to create zip entry")
+	}
+
+	return copyFileToArchive(ctx, w, f)
+}
+
+// CreateSymlink implements restore.Output interface.
+func (o *ZipOutput) CreateSymlink(ctx context.Context, relativePath string, e fs.Symlink) error {
+	target, err := e.Readlink(ctx)
+	if err != nil {
+		return errors.Wrap(err, "error reading link target")
+	}
+
+	h, err := zip.FileInfoHeader(e)
+	if err != nil {
+		return errors.Wrap(err, "unable to create zip header")
+	}
+
+	h.Name = relativePath
+
+	w, err := o.zw.CreateHeader(h)
+	if err != nil {
+		return errors.Wrap(err, "unable to create zip entry")
+	}
+
+	_, err = io.WriteString(w, target)
+
+	return err
+}
+
+// Close implements restore.Output interface.
+func (o *ZipOutput) Close(ctx context.Context) error {
+	return o.zw.Close()
+}
+
+// NewZipOutput creates new ZIP writer output.
+func NewZipOutput(w io.Writer) *ZipOutput {
+	return &ZipOutput{zip.NewWriter(w)}
+}
+
+// TarOutput writes the restored tree as a TAR archive to the provided writer.
+type TarOutput struct {
+	tw *tar.Writer
+}
+
+// BeginDirectory implements restore.Output interface.
+func (o *TarOutput) BeginDirectory(ctx context.Context, relativePath string, e fs.Directory) error {
+	if relativePath == "" {
+		return nil
+	}
+
+	h, err := tar.FileInfoHeader(e, "")
+	if err != nil {
+		return errors.Wrap(err, "unable to create tar header")
+	}
+
+	h.Name = relativePath + "/"
+	setTarOwner(h, e)
+
+	return o.tw.WriteHeader(h)
+}
+
+// FinishDirectory implements restore.Output interface.
+func (o *TarOutput) FinishDirectory(ctx context.Context, relativePath string, e fs.Directory) error {
+	return nil
+}
+
+// WriteFile implements restore.Output interface.
+func (o *TarOutput) WriteFile(ctx context.Context, relativePath string, f fs.File) error {
+	h, err := tar.FileInfoHeader(f, "")
+	if err != nil {
+		return errors.Wrap(err, "unable to create tar header")
+	}
+
+	h.Name = archiveEntryName(relativePath, f)
+	setTarOwner(h, f)
+
+	if err := o.tw.WriteHeader(h); err != nil {
+		return errors.Wrap(err, "unable to write tar header")
+	}
+
+	return copyFileToArchive(ctx, o.tw, f)
+}
+
+// CreateSymlink implements restore.Output interface.
+func (o *TarOutput) CreateSymlink(ctx context.Context, relativePath string, e fs.Symlink) error {
+	target, err := e.Readlink(ctx)
+	if err != nil {
+		return errors.Wrap(err, "error reading link target")
+	}
+
+	h, err := tar.FileInfoHeader(e, target)
+	if err != nil {
+		return errors.Wrap(err, "unable to create tar header")
+	}
+
+	h.Name = relativePath
+	setTarOwner(h, e)
+
+	return o.tw.WriteHeader(h)
+}
+
+// Close implements restore.Output interface.
+func (o *TarOutput) Close(ctx context.Context) error {
+	return o.tw.Close()
+}
+
+// NewTarOutput creates new TAR writer output.
+func NewTarOutput(w io.Writer) *TarOutput {
+	return &TarOutput{tar.NewWriter(w)}
+}
+
+func setTarOwner(h *tar.Header, e fs.Entry) {
+	h.Uid = int(e.Owner().UserID)
+	h.Gid = int(e.Owner().GroupID)
+}
+
+// archiveEntryName returns the name of an archive entry, using the entry name when restoring
+// a single file as the root of the archive.
+func archiveEntryName(relativePath string, e os.FileInfo) string {
+	if relativePath == "" {
+		return e.Name()
+	}
+
+	return relativePath
+}
+
+func copyFileToArchive(ctx context.Context, w io.Writer, f fs.File) error {
+	r, err := f.Open(ctx)
+	if err != nil {
+		return errors.Wrap(err, "unable to open snapshot file")
+	}
+	defer r.Close() //nolint:errcheck
+
+	if _, err := copyWithContext(ctx, w, r); err != nil {
+		return errors.Wrap(err, "unable to copy file contents")
+	}
+
+	return nil
+}
+
+var _ Output = &ZipOutput{}
+var _ Output = &TarOutput{}
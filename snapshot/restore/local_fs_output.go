@@ -0,0 +1,142 @@
+package restore
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/fs"
+)
+
+// FilesystemOutput contains the options for outputting a file system tree.
+type FilesystemOutput struct {
+	// TargetPath for restore.
+	TargetPath string
+
+	// When set to true, do not return an error if a directory already exists.
+	OverwriteDirectories bool
+
+	// When set to true, overwrite existing files, otherwise restoring such files fails.
+	OverwriteFiles bool
+}
+
+// BeginDirectory implements restore.Output interface.
+func (o *FilesystemOutput) BeginDirectory(ctx context.Context, relativePath string, e fs.Directory) error {
+	path := filepath.Join(o.TargetPath, filepath.FromSlash(relativePath))
+
+	if err := os.MkdirAll(path, 0700); err != nil {
+		return errors.Wrap(err, "error creating directory")
+	}
+
+	if !o.OverwriteDirectories {
+		entries, err := readDirNames(path)
+		if err != nil {
+			return err
+		}
+
+		if len(entries) > 0 {
+			return errors.Errorf("non-empty directory already exists: %v", path)
+		}
+	}
+
+	return nil
+}
+
+// FinishDirectory implements restore.Output interface.
+func (o *FilesystemOutput) FinishDirectory(ctx context.Context, relativePath string, e fs.Directory) error {
+	path := filepath.Join(o.TargetPath, filepath.FromSlash(relativePath))
+	return o.setAttributes(path, e)
+}
+
+// WriteFile implements restore.Output interface.
+func (o *FilesystemOutput) WriteFile(ctx context.Context, relativePath string, f fs.File) error {
+	path := filepath.Join(o.TargetPath, filepath.FromSlash(relativePath))
+
+	if err := o.copyFileContent(ctx, path, f); err != nil {
+		return err
+	}
+
+	return o.setAttributes(path, f)
+}
+
+// CreateSymlink implements restore.Output interface.
+func (o *FilesystemOutput) CreateSymlink(ctx context.Context, relativePath string, e fs.Symlink) error {
+	path := filepath.Join(o.TargetPath, filepath.FromSlash(relativePath))
+
+	target, err := e.Readlink(ctx)
+	if err != nil {
+		return errors.Wrap(err, "error reading link target")
+	}
+
+	if o.OverwriteFiles {
+		if st, err := os.Lstat(path); err == nil && st.Mode()&os.ModeSymlink != 0 {
+			if err := os.Remove(path); err != nil {
+				return errors.Wrap(err, "unable to remove existing symlink")
+			}
+		}
+	}
+
+	return os.Symlink(target, path)
+}
+
+// Close implements restore.Output interface.
+func (o *FilesystemOutput) Close(ctx context.Context) error {
+	return nil
+}
+
+func (o *FilesystemOutput) setAttributes(path string, e fs.Entry) error {
+	if err := os.Chmod(path, e.Mode()&os.ModePerm); err != nil {
+		return errors.Wrap(err, "could not change permissions")
+	}
+
+	if err := os.Chtimes(path, e.ModTime(), e.ModTime()); err != nil {
+		return errors.Wrap(err, "could not change mod time")
+	}
+
+	return nil
+}
+
+func (o *FilesystemOutput) copyFileContent(ctx context.Context, targetPath string, f fs.File) error {
+	flags := os.O_WRONLY | os.O_CREATE | os.O_EXCL
+	if o.OverwriteFiles {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	}
+
+	r, err := f.Open(ctx)
+	if err != nil {
+		return errors.Wrap(err, "unable to open snapshot file")
+	}
+	defer r.Close() //nolint:errcheck
+
+	out, err := os.OpenFile(targetPath, flags, 0600)
+	if err != nil {
+		return errors.Wrap(err, "unable to create output file")
+	}
+
+	if _, err := copyWithContext(ctx, out, r); err != nil {
+		out.Close() //nolint:errcheck
+		return errors.Wrap(err, "unable to copy file contents")
+	}
+
+	return out.Close()
+}
+
+func readDirNames(path string) ([]string, error) {
+	d, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to open directory")
+	}
+	defer d.Close() //nolint:errcheck
+
+	names, err := d.Readdirnames(1)
+	if err == io.EOF {
+		return nil, nil
+	}
+
+	return names, err
+}
+
+var _ Output = &FilesystemOutput{}
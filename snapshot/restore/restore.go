@@ -0,0 +1,185 @@
+// Package restore implements restoring filesystem trees from snapshots.
+package restore
+
+import (
+	"context"
+	"io"
+	"path"
+	"strings"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/internal/kopialogging"
+)
+
+var log = kopialogging.Logger("kopia/restore")
+
+// Output encapsulates output for restore operation.
+type Output interface {
+	BeginDirectory(ctx context.Context, relativePath string, e fs.Directory) error
+	FinishDirectory(ctx context.Context, relativePath string, e fs.Directory) error
+	WriteFile(ctx context.Context, relativePath string, e fs.File) error
+	CreateSymlink(ctx context.Context, relativePath string, e fs.Symlink) error
+	Close(ctx context.Context) error
+}
+
+// Stats represents restore statistics.
+type Stats struct {
+	RestoredTotalFileSize int64 `json:"restoredTotalFileSize"`
+	RestoredFileCount     int32 `json:"restoredFileCount"`
+	RestoredDirCount      int32 `json:"restoredDirCount"`
+	RestoredSymlinkCount  int32 `json:"restoredSymlinkCount"`
+}
+
+// Options provides optional restore parameters.
+type Options struct {
+	// ProgressCallback, if set, is invoked after each restored entry with the current statistics.
+	ProgressCallback func(stats Stats)
+}
+
+// Entry walks a snapshot root with given root entry and restores it to the provided output.
+func Entry(ctx context.Context, output Output, rootEntry fs.Entry, options Options) (Stats, error) {
+	c := copier{output: output, options: options}
+
+	if err := c.copyEntry(ctx, rootEntry, ""); err != nil {
+		return c.stats(), errors.Wrap(err, "error copying")
+	}
+
+	if err := output.Close(ctx); err != nil {
+		return c.stats(), errors.Wrap(err, "error closing output")
+	}
+
+	return c.stats(), nil
+}
+
+type copier struct {
+	output  Output
+	options Options
+
+	restoredTotalFileSize int64
+	restoredFileCount     int32
+	restoredDirCount      int32
+	restoredSymlinkCount  int32
+}
+
+func (c *copier) stats() Stats {
+	return Stats{
+		RestoredTotalFileSize: atomic.LoadInt64(&c.restoredTotalFileSize),
+		RestoredFileCount:     atomic.LoadInt32(&c.restoredFileCount),
+		RestoredDirCount:      atomic.LoadInt32(&c.restoredDirCount),
+		RestoredSymlinkCount:  atomic.LoadInt32(&c.restoredSymlinkCount),
+	}
+}
+
+func (c *copier) reportProgress() {
+	if c.options.ProgressCallback != nil {
+		c.options.ProgressCallback(c.stats())
+	}
+}
+
+func (c *copier) copyEntry(ctx context.Context, e fs.Entry, targetPath string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	switch e := e.(type) {
+	case fs.Directory:
+		log.Debugf("dir: '%v'", targetPath)
+		return c.copyDirectory(ctx, e, targetPath)
+
+	case fs.File:
+		log.Debugf("file: '%v'", targetPath)
+		if err := c.output.WriteFile(ctx, targetPath, e); err != nil {
+			return errors.Wrapf(err, "unable to restore file %v", targetPath)
+		}
+
+		atomic.AddInt32(&c.restoredFileCount, 1)
+		atomic.AddInt64(&c.restoredTotalFileSize, e.Size())
+		c.reportProgress()
+
+		return nil
+
+	case fs.Symlink:
+		log.Debugf("symlink: '%v'", targetPath)
+		if err := c.output.CreateSymlink(ctx, targetPath, e); err != nil {
+			return errors.Wrapf(err, "unable to restore symlink %v", targetPath)
+		}
+
+		atomic.AddInt32(&c.restoredSymlinkCount, 1)
+		c.reportProgress()
+
+		return nil
+
+	default:
+		return errors.Errorf("unsupported entry type: %T", e)
+	}
+}
+
+func (c *copier) copyDirectory(ctx context.Context, d fs.Directory, targetPath string) error {
+	if err := c.output.BeginDirectory(ctx, targetPath, d); err != nil {
+		return errors.Wrapf(err, "unable to create directory %v", targetPath)
+	}
+
+	entries, err := d.Readdir(ctx)
+	if err != nil {
+		return errors.Wrapf(err, "unable to read directory %v", targetPath)
+	}
+
+	for _, e := range entries {
+		if !isSafeEntryName(e.Name()) {
+			return errors.Errorf("invalid entry name %q in %v", e.Name(), targetPath)
+		}
+
+		if err := c.copyEntry(ctx, e, path.Join(targetPath, e.Name())); err != nil {
+			return err
+		}
+	}
+
+	if err := c.output.FinishDirectory(ctx, targetPath, d); err != nil {
+		return errors.Wrapf(err, "unable to finish directory %v", targetPath)
+	}
+
+	atomic.AddInt32(&c.restoredDirCount, 1)
+	c.reportProgress()
+
+	return nil
+}
+
+// isSafeEntryName returns true if a given entry name can be safely joined with the target path
+// without escaping it.
+func isSafeEntryName(n string) bool {
+	return n != "" && n != "." && n != ".." && !strings.ContainsAny(n, "/\\")
+}
+
+// copyWithContext copies data from r to w, aborting when the context is canceled.
+func copyWithContext(ctx context.Context, w io.Writer, r io.Reader) (int64, error) {
+	buf := make([]byte, 65536)
+
+	var total int64
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return total, err
+		}
+
+		n, err := r.Read(buf)
+		if n > 0 {
+			written, werr := w.Write(buf[0:n])
+			total += int64(written)
+
+			if werr != nil {
+				return total, werr
+			}
+		}
+
+		if err == io.EOF {
+			return total, nil
+		}
+
+		if err != nil {
+			return total, err
+		}
+	}
+}
@@ -0,0 +1,273 @@
+package restore
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/fs/localfs"
+	"github.com/kopia/kopia/internal/mockfs"
+	"github.com/kopia/kopia/internal/repotesting"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/snapshotfs"
+)
+
+func uploadTestTree(t *testing.T, env *repotesting.Environment) fs.Entry {
+	ctx := context.Background()
+
+	sourceDir := mockfs.NewDirectory()
+	sourceDir.AddFile("f1", []byte{1, 2, 3}, 0644)
+	sourceDir.AddDir("d1", 0755)
+	sourceDir.AddFile("d1/f2", []byte{1, 2, 3, 4}, 0600)
+	sourceDir.AddDir("d1/d2", 0700)
+	sourceDir.AddFile("d1/d2/f3", []byte("hello world"), 0644)
+
+	man, err := snapshotfs.NewUploader(env.Repository).Upload(ctx, sourceDir, snapshot.SourceInfo{})
+	if err != nil {
+		t.Fatalf("upload error: %v", err)
+	}
+
+	root, err := snapshotfs.SnapshotRoot(env.Repository, man)
+	if err != nil {
+		t.Fatalf("unable to get snapshot root: %v", err)
+	}
+
+	return root
+}
+
+func TestRestoreToFilesystem(t *testing.T) {
+	ctx := context.Background()
+
+	var env repotesting.Environment
+	defer env.Setup(t).Close(t)
+
+	root := uploadTestTree(t, &env)
+
+	targetDir, err := ioutil.TempDir("", "kopia-restore")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(targetDir) //nolint:errcheck
+
+	var progressCalls int
+
+	stats, err := Entry(ctx, &FilesystemOutput{TargetPath: targetDir}, root, Options{
+		ProgressCallback: func(Stats) { progressCalls++ },
+	})
+	if err != nil {
+		t.Fatalf("restore error: %v", err)
+	}
+
+	if got, want := stats, (Stats{RestoredTotalFileSize: 18, RestoredFileCount: 3, RestoredDirCount: 3}); got != want {
+		t.Errorf("unexpected stats: %+v, want %+v", got, want)
+	}
+
+	if progressCalls != 6 {
+		t.Errorf("unexpected number of progress callbacks: %v", progressCalls)
+	}
+
+	b, err := ioutil.ReadFile(filepath.Join(targetDir, "d1", "d2", "f3"))
+	if err != nil {
+		t.Fatalf("unable to read restored file: %v", err)
+	}
+
+	if string(b) != "hello world" {
+		t.Errorf("unexpected restored content: %q", b)
+	}
+
+	st, err := os.Stat(filepath.Join(targetDir, "d1", "f2"))
+	if err != nil {
+		t.Fatalf("unable to stat restored file: %v", err)
+	}
+
+	if st.Mode().Perm() != 0600 {
+		t.Errorf("unexpected permissions: %v", st.Mode())
+	}
+
+	// restoring again into the non-empty directory must fail.
+	if _, err := Entry(ctx, &FilesystemOutput{TargetPath: targetDir}, root, Options{}); err == nil {
+		t.Errorf("expected error when restoring into non-empty directory")
+	}
+
+	if _, err := Entry(ctx, &FilesystemOutput{TargetPath: targetDir, OverwriteDirectories: true, OverwriteFiles: true}, root, Options{}); err != nil {
+		t.Errorf("unexpected error when overwriting: %v", err)
+	}
+}
+
+func TestRestoreToArchive(t *testing.T) {
+	ctx := context.Background()
+
+	var env repotesting.Environment
+	defer env.Setup(t).Close(t)
+
+	root := uploadTestTree(t, &env)
+	want := []string{"d1/", "d1/d2/", "d1/d2/f3", "d1/f2", "f1"}
+
+	var zipBuf bytes.Buffer
+	if _, err := Entry(ctx, NewZipOutput(&zipBuf), root, Options{}); err != nil {
+		t.Fatalf("zip restore error: %v", err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(zipBuf.Bytes()), int64(zipBuf.Len()))
+	if err != nil {
+		t.Fatalf("invalid zip: %v", err)
+	}
+
+	var zipNames []string
+	for _, f := range zr.File {
+		zipNames = append(zipNames, f.Name)
+	}
+
+	verifyNames(t, "zip", zipNames, want)
+
+	var tarBuf bytes.Buffer
+	if _, err := Entry(ctx, NewTarOutput(&tarBuf), root, Options{}); err != nil {
+		t.Fatalf("tar restore error: %v", err)
+	}
+
+	var tarNames []string
+
+	tr := tar.NewReader(&tarBuf)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			t.Fatalf("invalid tar: %v", err)
+		}
+
+		tarNames = append(tarNames, h.Name)
+	}
+
+	verifyNames(t, "tar", tarNames, want)
+}
+
+func verifyNames(t *testing.T, desc string, got, want []string) {
+	t.Helper()
+
+	sort.Strings(got)
+
+	if len(got) != len(want) {
+		t.Fatalf("unexpected %v entries: %v, want %v", desc, got, want)
+	}
+
+	for i := range got {
+		if got[i] != want[i] {
+			t.Errorf("unexpected %v entries: %v, want %v", desc, got, want)
+			return
+		}
+	}
+}
+
+func TestVerify(t *testing.T) {
+	ctx := context.Background()
+
+	var env repotesting.Environment
+	defer env.Setup(t).Close(t)
+
+	sourceDir, err := ioutil.TempDir("", "kopia-verify-source")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(sourceDir) //nolint:errcheck
+
+	scratchDir, err := ioutil.TempDir("", "kopia-verify-scratch")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(scratchDir) //nolint:errcheck
+
+	for _, f := range []string{"f1", "f2", "d1/f3", "d1/f4"} {
+		p := filepath.Join(sourceDir, filepath.FromSlash(f))
+		if err := os.MkdirAll(filepath.Dir(p), 0700); err != nil {
+			t.Fatalf("unable to create directory: %v", err)
+		}
+
+		if err := ioutil.WriteFile(p, []byte("contents of "+f), 0600); err != nil {
+			t.Fatalf("unable to write file: %v", err)
+		}
+	}
+
+	source, err := localfs.Directory(sourceDir)
+	if err != nil {
+		t.Fatalf("unable to get source directory: %v", err)
+	}
+
+	man, err := snapshotfs.NewUploader(env.Repository).Upload(ctx, source, snapshot.SourceInfo{})
+	if err != nil {
+		t.Fatalf("upload error: %v", err)
+	}
+
+	root, err := snapshotfs.SnapshotRoot(env.Repository, man)
+	if err != nil {
+		t.Fatalf("unable to get snapshot root: %v", err)
+	}
+
+	report, err := Verify(ctx, root, VerifyOptions{SamplePercent: 100, ScratchDirectory: scratchDir, Source: source})
+	if err != nil {
+		t.Fatalf("verify error: %v", err)
+	}
+
+	if report.Failed() || report.VerifiedFiles != 4 || report.ComparedWithSource != 4 {
+		t.Errorf("unexpected report: %+v", report)
+	}
+
+	if names, _ := readDirNames(filepath.Join(scratchDir, "d1")); len(names) != 0 {
+		t.Errorf("restored files were not removed from scratch directory: %v", names)
+	}
+
+	// modify f1 preserving size and modification time, touch f2 and change permissions of d1/f3.
+	fi, err := os.Stat(filepath.Join(sourceDir, "f1"))
+	if err != nil {
+		t.Fatalf("stat error: %v", err)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(sourceDir, "f1"), []byte("CONTENTS of f1"), 0600); err != nil {
+		t.Fatalf("unable to write file: %v", err)
+	}
+
+	if err := os.Chtimes(filepath.Join(sourceDir, "f1"), fi.ModTime(), fi.ModTime()); err != nil {
+		t.Fatalf("chtimes error: %v", err)
+	}
+
+	if err := os.Chtimes(filepath.Join(sourceDir, "f2"), fi.ModTime().Add(time.Hour), fi.ModTime().Add(time.Hour)); err != nil {
+		t.Fatalf("chtimes error: %v", err)
+	}
+
+	if err := os.Chmod(filepath.Join(sourceDir, "d1", "f3"), 0644); err != nil {
+		t.Fatalf("chmod error: %v", err)
+	}
+
+	report, err = Verify(ctx, root, VerifyOptions{SamplePercent: 100, Source: source})
+	if err != nil {
+		t.Fatalf("verify error: %v", err)
+	}
+
+	want := map[string]FileStatus{
+		"f1":    FileContentMismatch,
+		"f2":    FileSourceChanged,
+		"d1/f3": FileMetadataMismatch,
+		"d1/f4": FileOK,
+	}
+
+	for _, f := range report.Files {
+		if got := f.Status; got != want[f.Path] {
+			t.Errorf("unexpected status of %v: %v, want %v (%v)", f.Path, got, want[f.Path], f.Mismatches)
+		}
+	}
+
+	if !report.Failed() || report.Mode != "in-memory" {
+		t.Errorf("unexpected report: %+v", report)
+	}
+}
@@ -0,0 +1,326 @@
+package restore
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math/rand"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/fs/localfs"
+)
+
+// FileStatus is the outcome of verifying a single file.
+type FileStatus string
+
+// Possible outcomes of verifying a file.
+const (
+	FileOK               FileStatus = "ok"
+	FileContentMismatch  FileStatus = "content-mismatch"
+	FileMetadataMismatch FileStatus = "metadata-mismatch"
+	FileSourceChanged    FileStatus = "source-changed" // source was modified after the snapshot, contents not compared
+	FileError            FileStatus = "error"
+)
+
+// VerifyOptions provides options for Verify.
+type VerifyOptions struct {
+	// SamplePercent is the percentage of files to verify, all files are verified when 100 or more.
+	SamplePercent float64
+
+	// ScratchDirectory, if set, is the directory where sampled files are restored and read back,
+	// otherwise files are verified in memory.
+	ScratchDirectory string
+
+	// Source, if set, is the original source of the snapshot, which is re-hashed and compared with restored files.
+	Source fs.Entry
+}
+
+// VerifiedFile describes the result of verifying a single file.
+type VerifiedFile struct {
+	Path         string     `json:"path"`
+	Size         int64      `json:"size"`
+	Status       FileStatus `json:"status"`
+	RestoredHash string     `json:"restoredHash,omitempty"`
+	SourceHash   string     `json:"sourceHash,omitempty"`
+	Mismatches   []string   `json:"mismatches,omitempty"`
+	Error        string     `json:"error,omitempty"`
+}
+
+// VerifyReport describes the result of restore verification.
+type VerifyReport struct {
+	StartTime     time.Time `json:"startTime"`
+	EndTime       time.Time `json:"endTime"`
+	Mode          string    `json:"mode"` // "scratch-directory" or "in-memory"
+	SamplePercent float64   `json:"samplePercent"`
+
+	TotalFiles         int   `json:"totalFiles"`
+	VerifiedFiles      int   `json:"verifiedFiles"`
+	VerifiedBytes      int64 `json:"verifiedBytes"`
+	ComparedWithSource int   `json:"comparedWithSource"`
+
+	ContentMismatches  int `json:"contentMismatches"`
+	MetadataMismatches int `json:"metadataMismatches"`
+	SourceChanged      int `json:"sourceChanged"`
+	Errors             int `json:"errors"`
+
+	Files []VerifiedFile `json:"files"`
+}
+
+// Failed returns true if any of the verified files did not match or could not be restored.
+func (r *VerifyReport) Failed() bool {
+	return r.ContentMismatches+r.MetadataMismatches+r.Errors > 0
+}
+
+func (r *VerifyReport) add(f VerifiedFile) {
+	r.Files = append(r.Files, f)
+	r.VerifiedFiles++
+	r.VerifiedBytes += f.Size
+
+	if f.SourceHash != "" {
+		r.ComparedWithSource++
+	}
+
+	switch f.Status {
+	case FileContentMismatch:
+		r.ContentMismatches++
+	case FileMetadataMismatch:
+		r.MetadataMismatches++
+	case FileSourceChanged:
+		r.SourceChanged++
+	case FileError:
+		r.Errors++
+	}
+}
+
+// Verify restores a random sample of files under the provided snapshot entry and verifies that their contents
+// and metadata match the snapshot and, if provided, the original source.
+func Verify(ctx context.Context, rootEntry fs.Entry, options VerifyOptions) (*VerifyReport, error) {
+	v := &verifier{
+		options: options,
+		report: &VerifyReport{
+			StartTime:     time.Now(),
+			Mode:          "in-memory",
+			SamplePercent: options.SamplePercent,
+		},
+	}
+
+	if options.ScratchDirectory != "" {
+		v.report.Mode = "scratch-directory"
+	}
+
+	if err := v.verifyEntry(ctx, rootEntry, options.Source, ""); err != nil {
+		return v.report, err
+	}
+
+	v.report.EndTime = time.Now()
+
+	return v.report, nil
+}
+
+type verifier struct {
+	options VerifyOptions
+	report  *VerifyReport
+}
+
+func (v *verifier) verifyEntry(ctx context.Context, e, source fs.Entry, relativePath string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	switch e := e.(type) {
+	case fs.Directory:
+		return v.verifyDirectory(ctx, e, source, relativePath)
+
+	case fs.File:
+		v.report.TotalFiles++
+
+		if v.options.SamplePercent < 100 && rand.Float64()*100 >= v.options.SamplePercent { //nolint:gosec
+			return nil
+		}
+
+		sourceFile, _ := source.(fs.File)
+		v.report.add(v.verifyFile(ctx, e, sourceFile, relativePath))
+
+		return nil
+
+	default:
+		return nil
+	}
+}
+
+func (v *verifier) verifyDirectory(ctx context.Context, d fs.Directory, source fs.Entry, relativePath string) error {
+	entries, err := d.Readdir(ctx)
+	if err != nil {
+		return errors.Wrapf(err, "unable to read directory %v", relativePath)
+	}
+
+	var sourceEntries fs.Entries
+
+	if sd, ok := source.(fs.Directory); ok {
+		sourceEntries, err = sd.Readdir(ctx)
+		if err != nil {
+			log.Warningf("unable to read source directory %v: %v", relativePath, err)
+		}
+	}
+
+	for _, e := range entries {
+		if !isSafeEntryName(e.Name()) {
+			return errors.Errorf("invalid entry name %q in %v", e.Name(), relativePath)
+		}
+
+		var sourceEntry fs.Entry
+		if sourceEntries != nil {
+			sourceEntry = sourceEntries.FindByName(e.Name())
+		}
+
+		if err := v.verifyEntry(ctx, e, sourceEntry, path.Join(relativePath, e.Name())); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (v *verifier) verifyFile(ctx context.Context, f, source fs.File, relativePath string) VerifiedFile {
+	result := VerifiedFile{
+		Path:   relativePath,
+		Size:   f.Size(),
+		Status: FileOK,
+	}
+
+	restoredHash, restoredSize, restoredEntry, err := v.restoreFile(ctx, f, relativePath)
+	if err != nil {
+		result.Status = FileError
+		result.Error = err.Error()
+
+		return result
+	}
+
+	result.RestoredHash = restoredHash
+
+	var contentMismatch, metadataMismatch bool
+
+	mismatch := func(isContent bool, desc string, args ...interface{}) {
+		result.Mismatches = append(result.Mismatches, fmt.Sprintf(desc, args...))
+		contentMismatch = contentMismatch || isContent
+		metadataMismatch = metadataMismatch || !isContent
+	}
+
+	if restoredSize != f.Size() {
+		mismatch(true, "restored size %v, snapshot size %v", restoredSize, f.Size())
+	}
+
+	if restoredEntry != nil {
+		if got, want := restoredEntry.Mode()&os.ModePerm, f.Mode()&os.ModePerm; got != want {
+			mismatch(false, "restored mode %v, snapshot mode %v", got, want)
+		}
+
+		if got, want := restoredEntry.ModTime(), f.ModTime(); !got.Equal(want) {
+			mismatch(false, "restored modification time %v, snapshot modification time %v", got, want)
+		}
+	}
+
+	if source != nil {
+		switch {
+		case source.Size() != f.Size() || !source.ModTime().Equal(f.ModTime()):
+			// source was modified after the snapshot, its contents are expected to differ.
+			if !contentMismatch && !metadataMismatch {
+				result.Status = FileSourceChanged
+			}
+
+		default:
+			v.compareWithSource(ctx, &result, f, source, mismatch)
+		}
+	}
+
+	switch {
+	case contentMismatch:
+		result.Status = FileContentMismatch
+	case metadataMismatch:
+		result.Status = FileMetadataMismatch
+	}
+
+	return result
+}
+
+func (v *verifier) compareWithSource(ctx context.Context, result *VerifiedFile, f, source fs.File, mismatch func(bool, string, ...interface{})) {
+	if got, want := f.Mode()&os.ModePerm, source.Mode()&os.ModePerm; got != want {
+		mismatch(false, "snapshot mode %v, source mode %v", got, want)
+	}
+
+	if got, want := f.Owner(), source.Owner(); got != want {
+		mismatch(false, "snapshot owner %v:%v, source owner %v:%v", got.UserID, got.GroupID, want.UserID, want.GroupID)
+	}
+
+	sourceHash, _, err := hashFile(ctx, source)
+	if err != nil {
+		mismatch(false, "unable to read source: %v", err)
+		return
+	}
+
+	result.SourceHash = sourceHash
+
+	if sourceHash != result.RestoredHash {
+		mismatch(true, "restored contents differ from source")
+	}
+}
+
+// restoreFile restores the file and returns the hash and size of the restored contents along with
+// the restored filesystem entry when restoring to a scratch directory.
+func (v *verifier) restoreFile(ctx context.Context, f fs.File, relativePath string) (string, int64, fs.Entry, error) {
+	if v.options.ScratchDirectory == "" {
+		h, n, err := hashFile(ctx, f)
+		return h, n, nil, err
+	}
+
+	targetPath := filepath.Join(v.options.ScratchDirectory, filepath.FromSlash(relativePath))
+	if err := os.MkdirAll(filepath.Dir(targetPath), 0700); err != nil {
+		return "", 0, nil, errors.Wrap(err, "unable to create scratch directory")
+	}
+
+	// restored files are removed as soon as they are verified, so that the scratch directory stays small.
+	defer os.Remove(targetPath) //nolint:errcheck
+
+	out := &FilesystemOutput{TargetPath: v.options.ScratchDirectory, OverwriteFiles: true}
+	if err := out.WriteFile(ctx, relativePath, f); err != nil {
+		return "", 0, nil, err
+	}
+
+	restored, err := localfs.NewEntry(targetPath)
+	if err != nil {
+		return "", 0, nil, errors.Wrap(err, "unable to read restored file")
+	}
+
+	rf, ok := restored.(fs.File)
+	if !ok {
+		return "", 0, nil, errors.Errorf("restored entry is not a file: %v", targetPath)
+	}
+
+	h, n, err := hashFile(ctx, rf)
+
+	return h, n, restored, err
+}
+
+func hashFile(ctx context.Context, f fs.File) (string, int64, error) {
+	r, err := f.Open(ctx)
+	if err != nil {
+		return "", 0, err
+	}
+	defer r.Close() //nolint:errcheck
+
+	h := sha256.New()
+
+	n, err := copyWithContext(ctx, h, r)
+	if err != nil {
+		return "", n, err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), n, nil
+}
@@ -0,0 +1,151 @@
+package scrub
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/content"
+	"github.com/kopia/kopia/repo/manifest"
+	"github.com/kopia/kopia/repo/object"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/snapshotfs"
+)
+
+// Reference describes a file or directory in a snapshot that references a content.
+type Reference struct {
+	ContentID  content.ID          `json:"contentID"`
+	Source     snapshot.SourceInfo `json:"source"`
+	SnapshotID manifest.ID         `json:"snapshotID"`
+	StartTime  time.Time           `json:"startTime"`
+	Path       string              `json:"path"`
+}
+
+type referenceFinder struct {
+	rep     *repo.Repository
+	corrupt map[content.ID]bool
+
+	// directories known not to reference any of the contents.
+	clean map[object.ID]bool
+
+	manifest *snapshot.Manifest
+	result   []Reference
+}
+
+// FindReferences returns files and directories in all snapshots that reference any of the provided contents.
+func FindReferences(ctx context.Context, rep *repo.Repository, contentIDs []content.ID) ([]Reference, error) {
+	f := &referenceFinder{
+		rep:     rep,
+		corrupt: map[content.ID]bool{},
+		clean:   map[object.ID]bool{},
+	}
+
+	for _, cid := range contentIDs {
+		f.corrupt[cid] = true
+	}
+
+	ids, err := snapshot.ListSnapshotManifests(ctx, rep, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to list snapshots")
+	}
+
+	manifests, err := snapshot.LoadSnapshots(ctx, rep, ids)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to load snapshots")
+	}
+
+	for _, m := range snapshot.SortByTime(manifests, false) {
+		if m.RootEntry == nil {
+			continue
+		}
+
+		f.manifest = m
+		f.walkDirectory(ctx, m.RootObjectID(), ".")
+	}
+
+	return f.result, nil
+}
+
+func (f *referenceFinder) addReference(cid content.ID, path string) {
+	f.result = append(f.result, Reference{
+		ContentID:  cid,
+		Source:     f.manifest.Source,
+		SnapshotID: f.manifest.ID,
+		StartTime:  f.manifest.StartTime,
+		Path:       path,
+	})
+}
+
+// walkDirectory reports references in the directory and returns true if any were found.
+func (f *referenceFinder) walkDirectory(ctx context.Context, oid object.ID, path string) bool {
+	if f.clean[oid] {
+		return false
+	}
+
+	if f.checkObject(ctx, oid, path) {
+		// directory object itself is corrupt, can't read entries.
+		return true
+	}
+
+	entries, err := snapshotfs.DirectoryEntry(f.rep, oid, nil).Readdir(ctx)
+	if err != nil {
+		log.Warningf("unable to read directory %v: %v", path, err)
+		return false
+	}
+
+	found := false
+
+	for _, e := range entries {
+		childOID := e.(object.HasObjectID).ObjectID()
+		childPath := path + "/" + e.Name()
+
+		if e.IsDir() {
+			found = f.walkDirectory(ctx, childOID, childPath) || found
+		} else {
+			found = f.checkObject(ctx, childOID, childPath) || found
+		}
+	}
+
+	if !found {
+		f.clean[oid] = true
+	}
+
+	return found
+}
+
+// checkObject reports references to corrupt contents by the provided object and returns true if any were found.
+func (f *referenceFinder) checkObject(ctx context.Context, oid object.ID, path string) bool {
+	_, cids, err := f.rep.Objects.VerifyObject(ctx, oid)
+	if err != nil {
+		// object index could not be read, check the innermost index object itself.
+		for {
+			indexObjectID, ok := oid.IndexObjectID()
+			if !ok {
+				break
+			}
+
+			oid = indexObjectID
+		}
+
+		cid, ok := oid.ContentID()
+		if !ok || !f.corrupt[cid] {
+			log.Warningf("unable to verify %v: %v", path, err)
+			return false
+		}
+
+		cids = []content.ID{cid}
+	}
+
+	found := false
+
+	for _, cid := range cids {
+		if f.corrupt[cid] {
+			f.addReference(cid, path)
+			found = true
+		}
+	}
+
+	return found
+}
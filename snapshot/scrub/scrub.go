@@ -0,0 +1,239 @@
+// Package scrub implements periodic verification of pack blobs, which over a number of runs
+// downloads and verifies all contents stored in the repository.
+package scrub
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/kopialogging"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/content"
+)
+
+var log = kopialogging.Logger("kopia/scrub")
+
+// State records the time when each pack was last verified.
+type State struct {
+	Packs map[blob.ID]time.Time `json:"packs"`
+}
+
+// LoadState loads the scrubbing state from the provided file, returning empty state if the file does not exist.
+func LoadState(fname string) (*State, error) {
+	s := &State{Packs: map[blob.ID]time.Time{}}
+
+	b, err := ioutil.ReadFile(fname)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read scrub state")
+	}
+
+	if err := json.Unmarshal(b, s); err != nil {
+		return nil, errors.Wrap(err, "unable to parse scrub state")
+	}
+
+	if s.Packs == nil {
+		s.Packs = map[blob.ID]time.Time{}
+	}
+
+	return s, nil
+}
+
+// Save atomically writes the scrubbing state to the provided file.
+func (s *State) Save(fname string) error {
+	b, err := json.Marshal(s)
+	if err != nil {
+		return errors.Wrap(err, "unable to marshal scrub state")
+	}
+
+	tmpFile := fname + ".tmp"
+	if err := ioutil.WriteFile(tmpFile, b, 0600); err != nil {
+		return errors.Wrap(err, "unable to write scrub state")
+	}
+
+	return os.Rename(tmpFile, fname)
+}
+
+// Options controls the scrubbing run.
+type Options struct {
+	// Percent of all packs to verify in this run, packs that were never verified or were verified least recently go first.
+	Percent float64
+
+	// TimeBudget, if non-zero, stops verification of new packs after the given duration.
+	TimeBudget time.Duration
+
+	// Parallel is the number of packs verified in parallel.
+	Parallel int
+}
+
+// CorruptContent describes a content that failed verification.
+type CorruptContent struct {
+	ContentID content.ID `json:"contentID"`
+	PackID    blob.ID    `json:"packID"`
+	Error     string     `json:"error"`
+}
+
+// Result contains the results of scrubbing run.
+type Result struct {
+	TotalPacks       int              `json:"totalPacks"`
+	VerifiedPacks    int              `json:"verifiedPacks"`
+	VerifiedContents int              `json:"verifiedContents"`
+	VerifiedBytes    int64            `json:"verifiedBytes"`
+	NeverVerified    int              `json:"neverVerified"`
+	Corrupt          []CorruptContent `json:"corrupt,omitempty"`
+}
+
+// Run verifies a subset of packs in the repository, updating the provided state.
+func Run(ctx context.Context, rep *repo.Repository, state *State, opt Options) (*Result, error) {
+	var packs []content.PackInfo
+
+	if err := rep.Content.IteratePacks(content.IteratePackOptions{IncludeContentInfos: true}, func(pi content.PackInfo) error {
+		packs = append(packs, pi)
+		return nil
+	}); err != nil {
+		return nil, errors.Wrap(err, "unable to list packs")
+	}
+
+	pruneState(state, packs)
+
+	selected := selectPacks(packs, state, opt.Percent)
+	result := &Result{TotalPacks: len(packs)}
+
+	log.Debugf("verifying %v out of %v packs", len(selected), len(packs))
+
+	deadline := time.Time{}
+	if opt.TimeBudget > 0 {
+		deadline = time.Now().Add(opt.TimeBudget)
+	}
+
+	ch := make(chan content.PackInfo)
+
+	go func() {
+		defer close(ch)
+
+		for _, pi := range selected {
+			if !deadline.IsZero() && time.Now().After(deadline) {
+				log.Infof("time budget exceeded, stopping")
+				return
+			}
+
+			select {
+			case ch <- pi:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+	)
+
+	parallel := opt.Parallel
+	if parallel < 1 {
+		parallel = 1
+	}
+
+	for i := 0; i < parallel; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for pi := range ch {
+				corrupt, err := rep.Content.VerifyPack(ctx, pi.PackID, pi.ContentInfos)
+
+				mu.Lock()
+				if err != nil {
+					if firstErr == nil {
+						firstErr = err
+					}
+					mu.Unlock()
+
+					continue
+				}
+
+				state.Packs[pi.PackID] = time.Now()
+				result.VerifiedPacks++
+				result.VerifiedContents += len(pi.ContentInfos)
+				result.VerifiedBytes += pi.TotalSize
+
+				for _, ci := range pi.ContentInfos {
+					if err := corrupt[ci.ID]; err != nil {
+						log.Warningf("content %v in pack %v is corrupt: %v", ci.ID, pi.PackID, err)
+						result.Corrupt = append(result.Corrupt, CorruptContent{
+							ContentID: ci.ID,
+							PackID:    pi.PackID,
+							Error:     err.Error(),
+						})
+					}
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	for _, pi := range packs {
+		if _, ok := state.Packs[pi.PackID]; !ok {
+			result.NeverVerified++
+		}
+	}
+
+	sort.Slice(result.Corrupt, func(i, j int) bool {
+		return result.Corrupt[i].ContentID < result.Corrupt[j].ContentID
+	})
+
+	return result, firstErr
+}
+
+// pruneState removes packs that no longer exist from the state.
+func pruneState(state *State, packs []content.PackInfo) {
+	existing := map[blob.ID]bool{}
+	for _, pi := range packs {
+		existing[pi.PackID] = true
+	}
+
+	for packID := range state.Packs {
+		if !existing[packID] {
+			delete(state.Packs, packID)
+		}
+	}
+}
+
+// selectPacks returns the given percentage of packs which were never verified (in random order)
+// followed by packs verified least recently.
+func selectPacks(packs []content.PackInfo, state *State, percent float64) []content.PackInfo {
+	result := append([]content.PackInfo(nil), packs...)
+
+	rand.Shuffle(len(result), func(i, j int) {
+		result[i], result[j] = result[j], result[i]
+	})
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return state.Packs[result[i].PackID].Before(state.Packs[result[j].PackID])
+	})
+
+	cnt := int(math.Ceil(float64(len(result)) * percent / 100))
+	if cnt > len(result) {
+		cnt = len(result)
+	}
+
+	return result[0:cnt]
+}
@@ -0,0 +1,134 @@
+package scrub
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kopia/kopia/internal/mockfs"
+	"github.com/kopia/kopia/internal/repotesting"
+	"github.com/kopia/kopia/repo/content"
+	"github.com/kopia/kopia/repo/object"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/snapshotfs"
+)
+
+func TestScrub(t *testing.T) {
+	ctx := context.Background()
+
+	var env repotesting.Environment
+	defer env.Setup(t).Close(t)
+
+	sourceDir := mockfs.NewDirectory()
+	sourceDir.AddFile("f1", []byte{1, 2, 3}, 0644)
+	sourceDir.AddDir("d1", 0755)
+	sourceDir.AddFile("d1/f2", []byte("hello world"), 0644)
+
+	man, err := snapshotfs.NewUploader(env.Repository).Upload(ctx, sourceDir, snapshot.SourceInfo{Host: "host", UserName: "user", Path: "/path"})
+	if err != nil {
+		t.Fatalf("upload error: %v", err)
+	}
+
+	if _, err := snapshot.SaveSnapshot(ctx, env.Repository, man); err != nil {
+		t.Fatalf("unable to save snapshot: %v", err)
+	}
+
+	if err := env.Repository.Flush(ctx); err != nil {
+		t.Fatalf("flush error: %v", err)
+	}
+
+	stateDir, err := ioutil.TempDir("", "kopia-scrub")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(stateDir) //nolint:errcheck
+
+	stateFile := filepath.Join(stateDir, "state.json")
+
+	// two runs of 50% each verify all packs.
+	var total int
+
+	for i := 0; i < 2; i++ {
+		state, err := LoadState(stateFile)
+		if err != nil {
+			t.Fatalf("unable to load state: %v", err)
+		}
+
+		res, err := Run(ctx, env.Repository, state, Options{Percent: 50, Parallel: 2})
+		if err != nil {
+			t.Fatalf("scrub error: %v", err)
+		}
+
+		if len(res.Corrupt) != 0 {
+			t.Errorf("unexpected corrupt contents: %v", res.Corrupt)
+		}
+
+		total += res.VerifiedPacks
+
+		if err := state.Save(stateFile); err != nil {
+			t.Fatalf("unable to save state: %v", err)
+		}
+
+		if i == 1 && res.NeverVerified != 0 {
+			t.Errorf("not all packs verified after two runs: %+v", res)
+		}
+	}
+
+	state, err := LoadState(stateFile)
+	if err != nil {
+		t.Fatalf("unable to load state: %v", err)
+	}
+
+	if total != len(state.Packs) || total == 0 {
+		t.Errorf("unexpected number of verified packs: %v, state has %v", total, len(state.Packs))
+	}
+
+	// corrupt the content of d1/f2
+	root, err := snapshotfs.SnapshotRoot(env.Repository, man)
+	if err != nil {
+		t.Fatalf("unable to get snapshot root: %v", err)
+	}
+
+	f2, err := snapshotfs.GetNestedEntry(ctx, root, []string{"d1", "f2"})
+	if err != nil {
+		t.Fatalf("unable to find file: %v", err)
+	}
+
+	cid, _ := f2.(object.HasObjectID).ObjectID().ContentID()
+
+	ci, err := env.Repository.Content.ContentInfo(ctx, cid)
+	if err != nil {
+		t.Fatalf("unable to get content info: %v", err)
+	}
+
+	packData, err := env.Repository.Blobs.GetBlob(ctx, ci.PackBlobID, 0, -1)
+	if err != nil {
+		t.Fatalf("unable to read pack: %v", err)
+	}
+
+	packData[ci.PackOffset+2] ^= 1
+
+	if err := env.Repository.Blobs.PutBlob(ctx, ci.PackBlobID, packData); err != nil {
+		t.Fatalf("unable to write pack: %v", err)
+	}
+
+	res, err := Run(ctx, env.Repository, state, Options{Percent: 100})
+	if err != nil {
+		t.Fatalf("scrub error: %v", err)
+	}
+
+	if len(res.Corrupt) != 1 || res.Corrupt[0].ContentID != cid {
+		t.Fatalf("unexpected corrupt contents: %v, want %v", res.Corrupt, cid)
+	}
+
+	refs, err := FindReferences(ctx, env.Repository, []content.ID{cid})
+	if err != nil {
+		t.Fatalf("unable to find references: %v", err)
+	}
+
+	if len(refs) != 1 || refs[0].Path != "./d1/f2" || refs[0].SnapshotID != man.ID {
+		t.Errorf("unexpected references: %+v", refs)
+	}
+}
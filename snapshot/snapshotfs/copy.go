@@ -0,0 +1,284 @@
+package snapshotfs
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/manifest"
+	"github.com/kopia/kopia/repo/object"
+	"github.com/kopia/kopia/snapshot"
+)
+
+// CopyStats contains statistics about snapshot copy.
+type CopyStats struct {
+	Directories int64 `json:"directories"`
+	Files       int64 `json:"files"`
+
+	CopiedContents  int64 `json:"copiedContents"`
+	CopiedBytes     int64 `json:"copiedBytes"`
+	SkippedContents int64 `json:"skippedContents"`
+}
+
+// Copier copies snapshots between repositories by walking their object graphs and copying
+// contents missing in the destination, without reading and splitting the original files again.
+type Copier struct {
+	Source      *repo.Repository
+	Destination *repo.Repository
+
+	// Parallelism is the maximum number of objects copied in parallel.
+	Parallelism int
+
+	// ProgressCallback, if provided, is invoked periodically with cumulative statistics.
+	ProgressCallback func(stats CopyStats)
+
+	// CheckpointInterval is the interval between checkpoints, which flush the destination and record objects
+	// copied so far, so that an interrupted copy of a snapshot resumes without reading them again. 0 disables
+	// time-based checkpoints.
+	CheckpointInterval time.Duration
+
+	// CheckpointBytes is the number of bytes copied between checkpoints, 0 disables size-based checkpoints.
+	CheckpointBytes int64
+
+	mu        sync.Mutex
+	stats     CopyStats
+	copied    map[object.ID]object.ID // source object ID => destination object ID
+	resumable map[object.ID]object.ID // subset of copied recorded in checkpoints
+	sem       chan struct{}
+
+	// state of checkpoints of the snapshot being copied, protected by mu.
+	checkpointSource     manifest.ID   // ID of the source snapshot manifest, empty if checkpoints are disabled
+	checkpointIDs        []manifest.ID // checkpoints replaced by the next one
+	nextCheckpointTime   time.Time
+	nextCheckpointBytes  int64
+	checkpointInProgress bool
+}
+
+// NewCopier creates a Copier between the provided repositories.
+func NewCopier(src, dst *repo.Repository) *Copier {
+	return &Copier{
+		Source:      src,
+		Destination: dst,
+		Parallelism: 1,
+	}
+}
+
+// Stats returns cumulative copy statistics.
+func (c *Copier) Stats() CopyStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.stats
+}
+
+// CopySnapshot copies the objects referenced by the provided snapshot manifest and saves
+// the manifest in the destination repository. The destination is only flushed by checkpoints,
+// the caller must flush it to persist the copied snapshot. Snapshots must be copied one at a time.
+func (c *Copier) CopySnapshot(ctx context.Context, m *snapshot.Manifest) (*snapshot.Manifest, error) {
+	if m.RootEntry == nil {
+		return nil, errors.Errorf("snapshot %v has no root entry", m.ID)
+	}
+
+	c.mu.Lock()
+	if c.copied == nil {
+		c.copied = map[object.ID]object.ID{}
+		c.resumable = map[object.ID]object.ID{}
+	}
+
+	if c.sem == nil {
+		p := c.Parallelism
+		if p < 1 {
+			p = 1
+		}
+
+		// the calling goroutine is one of the workers.
+		c.sem = make(chan struct{}, p-1)
+	}
+	c.mu.Unlock()
+
+	if err := c.startCheckpoints(ctx, m); err != nil {
+		return nil, err
+	}
+	defer c.resetCheckpoints()
+
+	newRoot, err := c.copyEntry(ctx, m.RootEntry)
+	if err != nil {
+		return nil, err
+	}
+
+	newm := *m
+	newm.ID = ""
+	newm.RootEntry = newRoot
+
+	if _, err := snapshot.SaveSnapshot(ctx, c.Destination, &newm); err != nil {
+		return nil, errors.Wrap(err, "unable to save snapshot manifest")
+	}
+
+	if err := c.finishCheckpoints(ctx); err != nil {
+		return nil, err
+	}
+
+	return &newm, nil
+}
+
+func (c *Copier) copyEntry(ctx context.Context, e *snapshot.DirEntry) (*snapshot.DirEntry, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	newEntry := *e
+	if e.ObjectID == "" {
+		return &newEntry, nil
+	}
+
+	c.mu.Lock()
+	oid, ok := c.copied[e.ObjectID]
+	c.mu.Unlock()
+
+	if !ok {
+		var err error
+
+		if e.Type == snapshot.EntryTypeDirectory {
+			oid, err = c.copyDirectory(ctx, e.ObjectID)
+		} else {
+			oid, err = c.copyObject(ctx, e.ObjectID)
+		}
+
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to copy %q", e.Name)
+		}
+
+		c.mu.Lock()
+		c.copied[e.ObjectID] = oid
+		if isResumableEntry(e) {
+			c.resumable[e.ObjectID] = oid
+		}
+		c.mu.Unlock()
+
+		c.maybeCheckpoint(ctx)
+	}
+
+	newEntry.ObjectID = oid
+
+	return &newEntry, nil
+}
+
+func (c *Copier) copyObject(ctx context.Context, oid object.ID) (object.ID, error) {
+	var st object.CopyStats
+
+	newOID, err := c.Destination.Objects.CopyObject(ctx, c.Source.Objects, oid, &st)
+	if err != nil {
+		return "", err
+	}
+
+	c.mu.Lock()
+	c.stats.Files++
+	c.stats.CopiedContents += st.CopiedContents
+	c.stats.CopiedBytes += st.CopiedBytes
+	c.stats.SkippedContents += st.SkippedContents
+	stats := c.stats
+	c.mu.Unlock()
+
+	if c.ProgressCallback != nil {
+		c.ProgressCallback(stats)
+	}
+
+	return newOID, nil
+}
+
+// copyDirectory copies all entries of a directory and writes the directory object with rewritten object IDs.
+func (c *Copier) copyDirectory(ctx context.Context, oid object.ID) (object.ID, error) {
+	r, err := c.Source.Objects.Open(ctx, oid)
+	if err != nil {
+		return "", errors.Wrap(err, "unable to open directory")
+	}
+
+	var dir snapshot.DirManifest
+
+	err = json.NewDecoder(r).Decode(&dir)
+	r.Close() //nolint:errcheck
+
+	if err != nil {
+		return "", errors.Wrap(err, "unable to parse directory object")
+	}
+
+	if dir.StreamType != directoryStreamType {
+		return "", errors.Errorf("invalid directory stream type")
+	}
+
+	var (
+		wg       sync.WaitGroup
+		errMu    sync.Mutex
+		firstErr error
+	)
+
+	for i, e := range dir.Entries {
+		i, e := i, e
+
+		copyOne := func() {
+			newEntry, err := c.copyEntry(ctx, e)
+
+			errMu.Lock()
+			defer errMu.Unlock()
+
+			if err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+
+				return
+			}
+
+			dir.Entries[i] = newEntry
+		}
+
+		select {
+		case c.sem <- struct{}{}:
+			wg.Add(1)
+
+			go func() {
+				defer func() {
+					<-c.sem
+					wg.Done()
+				}()
+
+				copyOne()
+			}()
+
+		default:
+			// no parallelism available, copy in the current goroutine.
+			copyOne()
+		}
+	}
+
+	wg.Wait()
+
+	if firstErr != nil {
+		return "", firstErr
+	}
+
+	w := c.Destination.Objects.NewWriter(ctx, object.WriterOptions{
+		Description: "DIR:" + string(oid),
+		Prefix:      "k",
+	})
+	defer w.Close() //nolint:errcheck
+
+	if err := json.NewEncoder(w).Encode(&dir); err != nil {
+		return "", errors.Wrap(err, "unable to encode directory JSON")
+	}
+
+	newOID, err := w.Result()
+	if err != nil {
+		return "", err
+	}
+
+	c.mu.Lock()
+	c.stats.Directories++
+	c.mu.Unlock()
+
+	return newOID, nil
+}
@@ -0,0 +1,194 @@
+package snapshotfs
+
+import (
+	"context"
+	"encoding/hex"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo/manifest"
+	"github.com/kopia/kopia/repo/object"
+	"github.com/kopia/kopia/snapshot"
+)
+
+// DefaultCopyCheckpointBytes is the default number of bytes copied between checkpoints of snapshot copy.
+const DefaultCopyCheckpointBytes = 1 << 30
+
+// copyCheckpoint records objects copied so far, which are persisted in the destination, so that an interrupted
+// copy of a snapshot skips them when resumed. Only directories and objects consisting of multiple contents are
+// recorded, which keeps checkpoints small while allowing finished subtrees and large files to be skipped.
+type copyCheckpoint struct {
+	Objects map[object.ID]object.ID `json:"objects"` // source object ID => destination object ID
+}
+
+// isResumableEntry returns true if the copied entry is recorded in checkpoints.
+func isResumableEntry(e *snapshot.DirEntry) bool {
+	if e.Type == snapshot.EntryTypeDirectory {
+		return true
+	}
+
+	_, ok := e.ObjectID.IndexObjectID()
+
+	return ok
+}
+
+func (c *Copier) checkpointLabels(sourceSnapshotID manifest.ID) map[string]string {
+	return map[string]string{
+		"type":             "copy-checkpoint",
+		"sourceRepository": hex.EncodeToString(c.Source.UniqueID),
+		"sourceSnapshot":   string(sourceSnapshotID),
+	}
+}
+
+// startCheckpoints loads objects recorded by checkpoints of previous interrupted copies of the snapshot,
+// which are replaced by the next checkpoint. Snapshots which were not loaded from the source repository
+// have no ID and are copied without checkpoints.
+func (c *Copier) startCheckpoints(ctx context.Context, m *snapshot.Manifest) error {
+	if m.ID == "" {
+		return nil
+	}
+
+	entries, err := c.Destination.Manifests.Find(ctx, c.checkpointLabels(m.ID))
+	if err != nil {
+		return errors.Wrap(err, "unable to find copy checkpoints")
+	}
+
+	var ids []manifest.ID
+
+	restored := map[object.ID]object.ID{}
+
+	for _, e := range entries {
+		var cp copyCheckpoint
+		if err := c.Destination.Manifests.Get(ctx, e.ID, &cp); err != nil {
+			return errors.Wrapf(err, "unable to load copy checkpoint %v", e.ID)
+		}
+
+		for src, dst := range cp.Objects {
+			restored[src] = dst
+		}
+
+		ids = append(ids, e.ID)
+	}
+
+	if len(restored) > 0 {
+		log.Infof("resuming copy of %v at %v, %v objects were already copied", m.Source, m.StartTime, len(restored))
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for src, dst := range restored {
+		c.copied[src] = dst
+		c.resumable[src] = dst
+	}
+
+	c.checkpointSource = m.ID
+	c.checkpointIDs = ids
+	c.nextCheckpointTime = time.Now().Add(c.CheckpointInterval)
+	c.nextCheckpointBytes = c.stats.CopiedBytes + c.CheckpointBytes
+
+	return nil
+}
+
+// finishCheckpoints deletes checkpoints of the snapshot, which are superseded by its copy.
+// It must be called after all objects of the snapshot are copied.
+func (c *Copier) finishCheckpoints(ctx context.Context) error {
+	c.mu.Lock()
+	ids := c.checkpointIDs
+	c.mu.Unlock()
+
+	for _, id := range ids {
+		if err := c.Destination.Manifests.Delete(ctx, id); err != nil {
+			return errors.Wrapf(err, "unable to delete copy checkpoint %v", id)
+		}
+	}
+
+	c.resetCheckpoints()
+
+	return nil
+}
+
+func (c *Copier) resetCheckpoints() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.checkpointSource = ""
+	c.checkpointIDs = nil
+}
+
+// checkpointDueLocked returns true if enough time passed or enough bytes were copied since the last checkpoint.
+func (c *Copier) checkpointDueLocked() bool {
+	if c.CheckpointInterval > 0 && !time.Now().Before(c.nextCheckpointTime) {
+		return true
+	}
+
+	return c.CheckpointBytes > 0 && c.stats.CopiedBytes >= c.nextCheckpointBytes
+}
+
+// maybeCheckpoint writes the checkpoint if it's due. It may be called from any goroutine copying objects,
+// recorded objects are copied while holding Copier.mu and written after releasing it. Only one checkpoint
+// is written at a time.
+func (c *Copier) maybeCheckpoint(ctx context.Context) {
+	c.mu.Lock()
+
+	if c.checkpointSource == "" || c.checkpointInProgress || !c.checkpointDueLocked() {
+		c.mu.Unlock()
+		return
+	}
+
+	cp := &copyCheckpoint{Objects: map[object.ID]object.ID{}}
+	for src, dst := range c.resumable {
+		cp.Objects[src] = dst
+	}
+
+	source := c.checkpointSource
+	prev := c.checkpointIDs
+	c.checkpointInProgress = true
+
+	c.mu.Unlock()
+
+	id, err := c.writeCheckpoint(ctx, source, cp, prev)
+	if err != nil {
+		log.Warningf("unable to write copy checkpoint: %v", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	switch {
+	case id != "" && err != nil:
+		// previous checkpoints which could not be deleted are deleted along with the next one.
+		c.checkpointIDs = append(append([]manifest.ID(nil), prev...), id)
+	case id != "":
+		c.checkpointIDs = []manifest.ID{id}
+	}
+
+	c.checkpointInProgress = false
+	c.nextCheckpointTime = time.Now().Add(c.CheckpointInterval)
+	c.nextCheckpointBytes = c.stats.CopiedBytes + c.CheckpointBytes
+}
+
+// writeCheckpoint saves the checkpoint replacing the previous ones and flushes the destination, which persists
+// recorded objects along with the checkpoint. It returns the ID of the saved checkpoint, even if previous
+// checkpoints could not be deleted.
+func (c *Copier) writeCheckpoint(ctx context.Context, sourceSnapshotID manifest.ID, cp *copyCheckpoint, prev []manifest.ID) (manifest.ID, error) {
+	id, err := c.Destination.Manifests.Put(ctx, c.checkpointLabels(sourceSnapshotID), cp)
+	if err != nil {
+		return "", errors.Wrap(err, "unable to save copy checkpoint")
+	}
+
+	for _, p := range prev {
+		if err := c.Destination.Manifests.Delete(ctx, p); err != nil {
+			return id, errors.Wrapf(err, "unable to delete previous copy checkpoint %v", p)
+		}
+	}
+
+	if err := c.Destination.Flush(ctx); err != nil {
+		return id, errors.Wrap(err, "unable to flush repository")
+	}
+
+	log.Debugf("wrote copy checkpoint %v with %v objects", id, len(cp.Objects))
+
+	return id, nil
+}
@@ -0,0 +1,208 @@
+package snapshotfs
+
+import (
+	"context"
+	"io/ioutil"
+	"testing"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/internal/mockfs"
+	"github.com/kopia/kopia/internal/repotesting"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/snapshot"
+)
+
+func TestCopier(t *testing.T) {
+	ctx := context.Background()
+
+	var srcEnv, dstEnv repotesting.Environment
+	defer srcEnv.Setup(t).Close(t)
+	defer dstEnv.Setup(t, func(opt *repo.NewRepositoryOptions) {
+		opt.BlockFormat.HMACSecret = []byte("different-secret")
+	}).Close(t)
+
+	sourceDir := mockfs.NewDirectory()
+	sourceDir.AddFile("f1", []byte{1, 2, 3}, defaultPermissions)
+	sourceDir.AddDir("d1", defaultPermissions)
+	sourceDir.AddFile("d1/f2", []byte("hello world"), defaultPermissions)
+	sourceDir.AddDir("d1/d2", defaultPermissions)
+	sourceDir.AddFile("d1/d2/f3", []byte("hello world"), defaultPermissions)
+
+	src := snapshot.SourceInfo{Host: "host", UserName: "user", Path: "/path"}
+
+	man, err := NewUploader(srcEnv.Repository).Upload(ctx, sourceDir, src)
+	if err != nil {
+		t.Fatalf("upload error: %v", err)
+	}
+
+	c := NewCopier(srcEnv.Repository, dstEnv.Repository)
+	c.Parallelism = 4
+
+	newMan, err := c.CopySnapshot(ctx, man)
+	if err != nil {
+		t.Fatalf("copy error: %v", err)
+	}
+
+	if newMan.RootObjectID() == man.RootObjectID() {
+		t.Errorf("expected root object ID to change when hashing differs")
+	}
+
+	stats := c.Stats()
+	if stats.Directories != 3 || stats.CopiedContents == 0 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+
+	if err := dstEnv.Repository.Flush(ctx); err != nil {
+		t.Fatalf("flush error: %v", err)
+	}
+
+	snapshots, err := snapshot.ListSnapshots(ctx, dstEnv.Repository, src)
+	if err != nil || len(snapshots) != 1 {
+		t.Fatalf("unexpected snapshots in destination: %v %v", snapshots, err)
+	}
+
+	if got, want := readTree(ctx, t, DirectoryEntry(dstEnv.Repository, snapshots[0].RootObjectID(), nil)), readTree(ctx, t, sourceDir); !mapsEqual(got, want) {
+		t.Errorf("unexpected tree after copy: %v, want %v", got, want)
+	}
+
+	// copying to a repository with the same hashing does not copy any contents,
+	// identical files are only processed once.
+	c2 := NewCopier(srcEnv.Repository, srcEnv.Repository)
+	if _, err := c2.CopySnapshot(ctx, man); err != nil {
+		t.Fatalf("copy error: %v", err)
+	}
+
+	if got := c2.Stats(); got.CopiedContents != 0 || got.SkippedContents != 2 {
+		t.Errorf("unexpected stats when copying to the same repository: %+v", got)
+	}
+}
+
+func TestCopier_ResumeFromCheckpoint(t *testing.T) {
+	ctx := context.Background()
+
+	var srcEnv, dstEnv repotesting.Environment
+	defer srcEnv.Setup(t).Close(t)
+	defer dstEnv.Setup(t, func(opt *repo.NewRepositoryOptions) {
+		opt.BlockFormat.HMACSecret = []byte("different-secret")
+	}).Close(t)
+
+	sourceDir := mockfs.NewDirectory()
+	for _, d := range []string{"d1", "d2", "d3", "d4"} {
+		sourceDir.AddDir(d, defaultPermissions)
+		sourceDir.AddFile(d+"/f", []byte("contents of "+d), defaultPermissions)
+	}
+
+	src := snapshot.SourceInfo{Host: "host", UserName: "user", Path: "/path"}
+
+	man, err := NewUploader(srcEnv.Repository).Upload(ctx, sourceDir, src)
+	if err != nil {
+		t.Fatalf("upload error: %v", err)
+	}
+
+	// checkpoints are keyed by the ID of the source manifest.
+	if _, err = snapshot.SaveSnapshot(ctx, srcEnv.Repository, man); err != nil {
+		t.Fatalf("unable to save snapshot: %v", err)
+	}
+
+	// interrupt the copy before the last directory, by then the first one is recorded by a checkpoint.
+	cctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	c := NewCopier(srcEnv.Repository, dstEnv.Repository)
+	c.CheckpointBytes = 1
+	c.ProgressCallback = func(s CopyStats) {
+		if s.Files == 3 {
+			cancel()
+		}
+	}
+
+	if _, err = c.CopySnapshot(cctx, man); err == nil {
+		t.Fatalf("unexpected success of interrupted copy")
+	}
+
+	// only flushed state is visible after reopening.
+	dstEnv.MustReopen(t)
+
+	c2 := NewCopier(srcEnv.Repository, dstEnv.Repository)
+	if _, err = c2.CopySnapshot(ctx, man); err != nil {
+		t.Fatalf("copy error: %v", err)
+	}
+
+	// the first directory is not copied again.
+	if got := c2.Stats(); got.Directories > 4 || got.Files > 3 {
+		t.Errorf("unexpected stats of resumed copy: %+v", got)
+	}
+
+	if err = dstEnv.Repository.Flush(ctx); err != nil {
+		t.Fatalf("flush error: %v", err)
+	}
+
+	snapshots, err := snapshot.ListSnapshots(ctx, dstEnv.Repository, src)
+	if err != nil || len(snapshots) != 1 {
+		t.Fatalf("unexpected snapshots in destination: %v %v", snapshots, err)
+	}
+
+	if got, want := readTree(ctx, t, DirectoryEntry(dstEnv.Repository, snapshots[0].RootObjectID(), nil)), readTree(ctx, t, sourceDir); !mapsEqual(got, want) {
+		t.Errorf("unexpected tree after copy: %v, want %v", got, want)
+	}
+
+	// checkpoints are deleted once the snapshot is copied.
+	checkpoints, err := dstEnv.Repository.Manifests.Find(ctx, map[string]string{"type": "copy-checkpoint"})
+	if err != nil || len(checkpoints) != 0 {
+		t.Errorf("unexpected checkpoints after copy: %v %v", checkpoints, err)
+	}
+}
+
+// readTree returns the map of relative file paths to their contents.
+func readTree(ctx context.Context, t *testing.T, dir fs.Directory) map[string]string {
+	result := map[string]string{}
+
+	var walk func(prefix string, d fs.Directory)
+
+	walk = func(prefix string, d fs.Directory) {
+		entries, err := d.Readdir(ctx)
+		if err != nil {
+			t.Fatalf("readdir error: %v", err)
+		}
+
+		for _, e := range entries {
+			switch e := e.(type) {
+			case fs.Directory:
+				walk(prefix+e.Name()+"/", e)
+
+			case fs.File:
+				r, err := e.Open(ctx)
+				if err != nil {
+					t.Fatalf("open error: %v", err)
+				}
+
+				b, err := ioutil.ReadAll(r)
+				r.Close() //nolint:errcheck
+
+				if err != nil {
+					t.Fatalf("read error: %v", err)
+				}
+
+				result[prefix+e.Name()] = string(b)
+			}
+		}
+	}
+
+	walk("", dir)
+
+	return result
+}
+
+func mapsEqual(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for k, v := range a {
+		if b[k] != v {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,131 @@
+package snapshotfs
+
+import (
+	"bytes"
+	"context"
+	"io"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/content"
+)
+
+// DedupEstimate describes the predicted effect of deduplication on uploading a set of files.
+type DedupEstimate struct {
+	Files           int   `json:"files"`
+	Bytes           int64 `json:"bytes"`
+	Chunks          int   `json:"chunks"`
+	ExistingChunks  int   `json:"existingChunks"`  // chunks already present in the repository
+	DuplicateChunks int   `json:"duplicateChunks"` // chunks repeated within the estimated files
+	NewBytes        int64 `json:"newBytes"`        // bytes of chunks that would be uploaded
+}
+
+// NewBytesRatio returns the fraction of bytes that would be uploaded after deduplication.
+func (e DedupEstimate) NewBytesRatio() float64 {
+	if e.Bytes == 0 {
+		return 1
+	}
+
+	return float64(e.NewBytes) / float64(e.Bytes)
+}
+
+// DedupEstimator predicts how much data needs to be uploaded by running file contents through
+// the repository splitter and hash function and looking up the resulting contents in the repository.
+type DedupEstimator struct {
+	DedupEstimate
+
+	rep  *repo.Repository
+	seen map[content.ID]bool
+}
+
+// NewDedupEstimator returns a new DedupEstimator for a given repository.
+func NewDedupEstimator(rep *repo.Repository) *DedupEstimator {
+	return &DedupEstimator{
+		rep:  rep,
+		seen: map[content.ID]bool{},
+	}
+}
+
+// AddFile adds the contents of the provided file to the estimate.
+func (e *DedupEstimator) AddFile(ctx context.Context, f fs.File) error {
+	r, err := f.Open(ctx)
+	if err != nil {
+		return errors.Wrap(err, "unable to open file")
+	}
+	defer r.Close() //nolint:errcheck
+
+	splitter := e.rep.Objects.NewSplitter()
+
+	var (
+		buf        bytes.Buffer
+		chunk      = make([]byte, 65536)
+		fileChunks int
+	)
+
+	for {
+		n, readErr := r.Read(chunk)
+
+		for _, b := range chunk[0:n] {
+			buf.WriteByte(b)
+
+			if splitter.ShouldSplit(b) {
+				if err := e.addChunk(ctx, buf.Bytes()); err != nil {
+					return err
+				}
+
+				buf.Reset()
+				fileChunks++
+			}
+		}
+
+		if readErr == io.EOF {
+			break
+		}
+
+		if readErr != nil {
+			return errors.Wrap(readErr, "unable to read file")
+		}
+	}
+
+	// like object writer, empty files are stored as a single empty chunk.
+	if buf.Len() > 0 || fileChunks == 0 {
+		if err := e.addChunk(ctx, buf.Bytes()); err != nil {
+			return err
+		}
+	}
+
+	e.Files++
+
+	return nil
+}
+
+func (e *DedupEstimator) addChunk(ctx context.Context, data []byte) error {
+	cid, err := e.rep.Content.ComputeContentID(data, "")
+	if err != nil {
+		return err
+	}
+
+	e.Chunks++
+	e.Bytes += int64(len(data))
+
+	if e.seen[cid] {
+		e.DuplicateChunks++
+		return nil
+	}
+
+	e.seen[cid] = true
+
+	ci, err := e.rep.Content.ContentInfo(ctx, cid)
+	switch {
+	case err == nil && !ci.Deleted:
+		e.ExistingChunks++
+	case err == nil || err == content.ErrContentNotFound:
+		e.NewBytes += int64(len(data))
+	default:
+		return errors.Wrapf(err, "unable to look up content %v", cid)
+	}
+
+	return nil
+}
@@ -0,0 +1,58 @@
+package snapshotfs
+
+import (
+	"context"
+	"testing"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/internal/mockfs"
+	"github.com/kopia/kopia/internal/repotesting"
+	"github.com/kopia/kopia/snapshot"
+)
+
+func TestDedupEstimator(t *testing.T) {
+	ctx := context.Background()
+
+	var env repotesting.Environment
+	defer env.Setup(t).Close(t)
+
+	sourceDir := mockfs.NewDirectory()
+	sourceDir.AddFile("f1", []byte("hello world"), defaultPermissions)
+	sourceDir.AddFile("f2", []byte{1, 2, 3}, defaultPermissions)
+
+	if _, err := NewUploader(env.Repository).Upload(ctx, sourceDir, snapshot.SourceInfo{Host: "host", UserName: "user", Path: "/path"}); err != nil {
+		t.Fatalf("upload error: %v", err)
+	}
+
+	newDir := mockfs.NewDirectory()
+	newDir.AddFile("existing", []byte("hello world"), defaultPermissions)
+	newDir.AddFile("new1", []byte("some new data"), defaultPermissions)
+	newDir.AddFile("new2", []byte("some new data"), defaultPermissions)
+	newDir.AddFile("empty", nil, defaultPermissions)
+
+	entries, err := newDir.Readdir(ctx)
+	if err != nil {
+		t.Fatalf("readdir error: %v", err)
+	}
+
+	e := NewDedupEstimator(env.Repository)
+
+	for _, f := range entries {
+		if err := e.AddFile(ctx, f.(fs.File)); err != nil {
+			t.Fatalf("unable to estimate %v: %v", f.Name(), err)
+		}
+	}
+
+	want := DedupEstimate{
+		Files:           4,
+		Bytes:           37,
+		Chunks:          4,
+		ExistingChunks:  1,
+		DuplicateChunks: 1,
+		NewBytes:        13,
+	}
+
+	if got := e.DedupEstimate; got != want {
+		t.Errorf("unexpected estimate: %+v, want %+v", got, want)
+	}
+}
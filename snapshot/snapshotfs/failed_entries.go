@@ -0,0 +1,53 @@
+package snapshotfs
+
+import (
+	"context"
+	"path"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/fs"
+)
+
+// FailedEntries returns the list of entries that could not be snapshotted, as recorded in summaries
+// of the provided directory and its subdirectories. Only subdirectories with failures are visited.
+// The result may be shorter than the number of failures reported in the summary if some directories
+// had too many failures to record all of them.
+func FailedEntries(ctx context.Context, dir fs.Directory) ([]*fs.EntryWithError, error) {
+	var result []*fs.EntryWithError
+
+	if err := collectFailedEntries(ctx, dir, ".", &result); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func collectFailedEntries(ctx context.Context, dir fs.Directory, relativePath string, result *[]*fs.EntryWithError) error {
+	summ := dir.Summary()
+	if summ == nil || summ.NumFailed == 0 {
+		return nil
+	}
+
+	// summary includes failures from subdirectories, only report failures of direct children here.
+	for _, e := range summ.FailedEntries {
+		if path.Dir(e.EntryPath) == path.Clean(relativePath) {
+			*result = append(*result, e)
+		}
+	}
+
+	entries, err := dir.Readdir(ctx)
+	if err != nil {
+		return errors.Wrapf(err, "unable to read directory %v", relativePath)
+	}
+
+	for _, e := range entries {
+		if subdir, ok := e.(fs.Directory); ok {
+			if err := collectFailedEntries(ctx, subdir, relativePath+"/"+e.Name(), result); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -51,6 +51,10 @@ func (e *repositoryEntry) ObjectID() object.ID {
 	return e.metadata.ObjectID
 }
 
+func (e *repositoryEntry) DirEntry() *snapshot.DirEntry {
+	return e.metadata
+}
+
 func (e *repositoryEntry) Sys() interface{} {
 	return nil
 }
@@ -191,6 +195,35 @@ func SnapshotRoot(rep *repo.Repository, man *snapshot.Manifest) (fs.Entry, error
 	return newRepoEntry(rep, man.RootEntry)
 }
 
+// GetNestedEntry returns the entry nested under a given directory by following the provided path elements.
+func GetNestedEntry(ctx context.Context, startingDir fs.Entry, pathElements []string) (fs.Entry, error) {
+	current := startingDir
+	for _, part := range pathElements {
+		if part == "" {
+			continue
+		}
+		dir, ok := current.(fs.Directory)
+		if !ok {
+			return nil, errors.Errorf("entry not found %q: parent is not a directory", part)
+		}
+
+		entries, err := dir.Readdir(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		e := entries.FindByName(part)
+		if e == nil {
+			return nil, errors.Errorf("entry not found: %q", part)
+		}
+
+		current = e
+	}
+
+	return current, nil
+}
+
 var _ fs.Directory = &repositoryDirectory{}
 var _ fs.File = &repositoryFile{}
 var _ fs.Symlink = &repositorySymlink{}
+var _ snapshot.HasDirEntry = &repositoryEntry{}
@@ -16,16 +16,23 @@ import (
 
 	"github.com/kopia/kopia/fs"
 	"github.com/kopia/kopia/fs/ignorefs"
+	"github.com/kopia/kopia/internal/hashcache"
 	"github.com/kopia/kopia/internal/kopialogging"
 	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/manifest"
 	"github.com/kopia/kopia/repo/object"
 	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/changedetection"
 )
 
 var log = kopialogging.Logger("kopia/upload")
 
 var errCancelled = errors.New("canceled")
 
+// maximum number of failed entries recorded in each directory summary, failures of entries
+// of the directory itself take precedence over failures found in its subdirectories.
+const maxFailedEntriesPerDirectorySummary = 100
+
 // Uploader supports efficient uploading files and directories to repository.
 type Uploader struct {
 	Progress UploadProgress
@@ -38,25 +45,62 @@ type Uploader struct {
 	// ignore file read errors
 	IgnoreFileErrors bool
 
+	// fail the upload when the number of ignored file errors exceeds this value, negative means no limit
+	MaxFileErrors int
+
 	// probability with cached entries will be ignored, must be [0..100]
 	// 0=always use cached object entries if possible
 	// 100=never use cached entries
 	ForceHashPercentage int
 
-	// Number of files to hash and upload in parallel.
+	// modes of detecting changed files in subtrees of the snapshot, trust-mtime if not specified
+	ChangeDetection changedetection.Map
+
+	// optional local cache of object IDs of files, which is required to reuse files in trust-ctime mode
+	HashCache *hashcache.Cache
+
+	// Number of files to hash and upload in parallel, which is the global limit for the entire upload shared
+	// by all directories traversed in parallel, 0 uses the number of CPUs.
 	ParallelUploads int
 
+	// Number of directories to traverse in parallel, which is the global limit for the entire upload.
+	// Subdirectories are processed by the goroutine processing their parent when the limit is reached.
+	ParallelDirectories int
+
+	// interval between checkpoint manifests which allow interrupted uploads to be resumed, 0 disables checkpoints
+	CheckpointInterval time.Duration
+
+	// optional journal of directories changed since ChangeJournalBase snapshot was started,
+	// directories which have not changed since then are reused without reading them
+	ChangeJournal     ChangeJournal
+	ChangeJournalBase *snapshot.Manifest
+
+	// fingerprint of policies affecting contents of the snapshot, which is stored in the manifest,
+	// the change journal is only used if the base snapshot was taken with the same non-empty fingerprint
+	PolicyFingerprint string
+
 	repo *repo.Repository
 
-	stats    snapshot.Stats
 	canceled int32
 
+	// limits the number of goroutines traversing directories in addition to the one that started the upload
+	dirWorkers chan struct{}
+
+	// limits the number of files hashed and uploaded at the same time across all directories
+	fileWorkers chan struct{}
+
 	progressMutex          sync.Mutex
 	nextProgressReportTime time.Time
-	currentProgressDir     string // current directory for reporting progress
-	currentDirNumFiles     int    // number of files in current directory
-	currentDirCompleted    int64  // bytes completed in current directory
-	currentDirTotalSize    int64  // total # of bytes in current directory
+
+	// mu protects statistics, manifests and summaries of directories in progress and state of checkpointing,
+	// which are shared by goroutines traversing different directories.
+	mu                   sync.Mutex
+	stats                snapshot.Stats
+	checkpointManifest   *snapshot.Manifest
+	checkpointRoot       *checkpointDir
+	lastCheckpointID     manifest.ID
+	nextCheckpointTime   time.Time
+	checkpointInProgress bool
 }
 
 // IsCancelled returns true if the upload is canceled.
@@ -76,7 +120,7 @@ func (u *Uploader) cancelReason() string {
 	return ""
 }
 
-func (u *Uploader) uploadFileInternal(ctx context.Context, f fs.File) entryResult {
+func (u *Uploader) uploadFileInternal(ctx context.Context, dp *dirProgress, f fs.File) entryResult {
 	file, err := f.Open(ctx)
 	if err != nil {
 		return entryResult{err: errors.Wrap(err, "unable to open file")}
@@ -88,7 +132,7 @@ func (u *Uploader) uploadFileInternal(ctx context.Context, f fs.File) entryResul
 	})
 	defer writer.Close() //nolint:errcheck
 
-	written, err := u.copyWithProgress(writer, file, 0, f.Size())
+	written, err := u.copyWithProgress(dp, writer, file, 0, f.Size())
 	if err != nil {
 		return entryResult{err: err}
 	}
@@ -109,10 +153,13 @@ func (u *Uploader) uploadFileInternal(ctx context.Context, f fs.File) entryResul
 	}
 	de.FileSize = written
 
+	// cache attributes the file had before it was read, so that changes made while reading are detected.
+	u.addToHashCache(f, r)
+
 	return entryResult{de: de}
 }
 
-func (u *Uploader) uploadSymlinkInternal(ctx context.Context, f fs.Symlink) entryResult {
+func (u *Uploader) uploadSymlinkInternal(ctx context.Context, dp *dirProgress, f fs.Symlink) entryResult {
 	target, err := f.Readlink(ctx)
 	if err != nil {
 		return entryResult{err: errors.Wrap(err, "unable to read symlink")}
@@ -123,7 +170,7 @@ func (u *Uploader) uploadSymlinkInternal(ctx context.Context, f fs.Symlink) entr
 	})
 	defer writer.Close() //nolint:errcheck
 
-	written, err := u.copyWithProgress(writer, bytes.NewBufferString(target), 0, f.Size())
+	written, err := u.copyWithProgress(dp, writer, bytes.NewBufferString(target), 0, f.Size())
 	if err != nil {
 		return entryResult{err: err}
 	}
@@ -141,26 +188,68 @@ func (u *Uploader) uploadSymlinkInternal(ctx context.Context, f fs.Symlink) entr
 	return entryResult{de: de}
 }
 
-func (u *Uploader) addDirProgress(length int64) {
+// dirProgress tracks progress of uploading files of a single directory, there can be multiple
+// directories in progress at the same time, each of them is reported under its own path.
+type dirProgress struct {
+	relativePath string
+	numFiles     int
+	totalSize    int64
+	completed    int64 // protected by Uploader.progressMutex
+	finished     bool  // protected by Uploader.progressMutex, no reports are made after completion
+}
+
+func newDirProgress(relativePath string, entries fs.Entries) *dirProgress {
+	dp := &dirProgress{relativePath: relativePath}
+
+	for _, entry := range entries {
+		if _, ok := entry.(fs.File); !ok {
+			// skip directories
+			continue
+		}
+
+		dp.numFiles++
+		dp.totalSize += entry.Size()
+	}
+
+	return dp
+}
+
+// addDirProgress records progress of the directory and reports it. Reports are made while holding
+// progressMutex, so that they are delivered in order and completion of each directory is reported once.
+func (u *Uploader) addDirProgress(dp *dirProgress, length int64) {
 	u.progressMutex.Lock()
-	u.currentDirCompleted += length
-	c := u.currentDirCompleted
+	defer u.progressMutex.Unlock()
+
+	if dp.finished {
+		return
+	}
+
+	dp.completed += length
 	shouldReport := false
 	if time.Now().After(u.nextProgressReportTime) {
 		shouldReport = true
 		u.nextProgressReportTime = time.Now().Add(100 * time.Millisecond)
 	}
-	if c == u.currentDirTotalSize {
+	if dp.completed >= dp.totalSize {
 		shouldReport = true
+		dp.finished = true
 	}
-	u.progressMutex.Unlock()
 
 	if shouldReport {
-		u.Progress.Progress(u.currentProgressDir, u.currentDirNumFiles, c, u.currentDirTotalSize, &u.stats)
+		stats := u.currentStats()
+		u.Progress.Progress(dp.relativePath, dp.numFiles, dp.completed, dp.totalSize, &stats)
 	}
 }
 
-func (u *Uploader) copyWithProgress(dst io.Writer, src io.Reader, completed, length int64) (int64, error) {
+// currentStats returns a copy of statistics of the upload in progress.
+func (u *Uploader) currentStats() snapshot.Stats {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	return u.stats
+}
+
+func (u *Uploader) copyWithProgress(dp *dirProgress, dst io.Writer, src io.Reader, completed, length int64) (int64, error) {
 	uploadBuf := make([]byte, 128*1024) // 128 KB buffer
 
 	var written int64
@@ -176,7 +265,7 @@ func (u *Uploader) copyWithProgress(dst io.Writer, src io.Reader, completed, len
 			if wroteBytes > 0 {
 				written += int64(wroteBytes)
 				completed += int64(wroteBytes)
-				u.addDirProgress(int64(wroteBytes))
+				u.addDirProgress(dp, int64(wroteBytes))
 				if length < completed {
 					length = completed
 				}
@@ -229,7 +318,7 @@ func newDirEntry(md fs.Entry, oid object.ID) (*snapshot.DirEntry, error) {
 
 // uploadFile uploads the specified File to the repository.
 func (u *Uploader) uploadFile(ctx context.Context, file fs.File) (*snapshot.DirEntry, error) {
-	res := u.uploadFileInternal(ctx, file)
+	res := u.uploadFileInternal(ctx, &dirProgress{relativePath: ".", numFiles: 1, totalSize: file.Size()}, file)
 	if res.err != nil {
 		return nil, res.err
 	}
@@ -250,8 +339,8 @@ func (u *Uploader) uploadFile(ctx context.Context, file fs.File) (*snapshot.DirE
 // uploadDir uploads the specified Directory to the repository.
 // An optional ID of a hash-cache object may be provided, in which case the Uploader will use its
 // contents to avoid hashing
-func (u *Uploader) uploadDir(ctx context.Context, rootDir fs.Directory, previousDirs []fs.Directory) (*snapshot.DirEntry, error) {
-	oid, summ, err := uploadDirInternal(ctx, u, rootDir, previousDirs, ".")
+func (u *Uploader) uploadDir(ctx context.Context, rootDir fs.Directory, previousDirs []fs.Directory, baseDir fs.Directory) (*snapshot.DirEntry, error) {
+	oid, summ, err := uploadDirInternal(ctx, u, nil, rootDir, previousDirs, baseDir, ".")
 	if err != nil {
 		return nil, err
 	}
@@ -286,14 +375,36 @@ type entryResult struct {
 	de  *snapshot.DirEntry
 }
 
-func (u *Uploader) processSubdirectories(ctx context.Context, relativePath string, entries fs.Entries, previousEntries []fs.Entries, dirManifest *snapshot.DirManifest, summ *fs.DirectorySummary) error {
-	return u.foreachEntryUnlessCancelled(relativePath, entries, func(entry fs.Entry, entryRelativePath string) error {
+// subdirResult is the result of uploading a subdirectory.
+type subdirResult struct {
+	name string
+	de   *snapshot.DirEntry
+	summ fs.DirectorySummary
+	err  error
+}
+
+// processSubdirectories uploads subdirectories of a directory in progress, in parallel if there are idle
+// directory workers, and returns failed entries found in them. Entries of subdirectories are added to the
+// directory manifest in the order of entries regardless of the order in which their uploads complete.
+func (u *Uploader) processSubdirectories(ctx context.Context, parent *checkpointDir, entries fs.Entries, previousEntries []fs.Entries, baseEntries fs.Entries) ([]*fs.EntryWithError, error) {
+	var (
+		wg      sync.WaitGroup
+		results []*subdirResult
+		failed  int32
+	)
+
+	err := u.foreachEntryUnlessCancelled(parent.relativePath, entries, func(entry fs.Entry, entryRelativePath string) error {
 		dir, ok := entry.(fs.Directory)
 		if !ok {
 			// skip non-directories
 			return nil
 		}
 
+		if atomic.LoadInt32(&failed) != 0 {
+			// don't start any more subdirectories, the error will be reported below.
+			return nil
+		}
+
 		var previousDirs []fs.Directory
 		for _, e := range previousEntries {
 			if d, _ := e.FindByName(entry.Name()).(fs.Directory); d != nil {
@@ -303,50 +414,109 @@ func (u *Uploader) processSubdirectories(ctx context.Context, relativePath strin
 
 		previousDirs = uniqueDirectories(previousDirs)
 
-		oid, subdirsumm, err := uploadDirInternal(ctx, u, dir, previousDirs, entryRelativePath)
-		if err == errCancelled {
-			return err
-		}
+		baseDir, _ := baseEntries.FindByName(entry.Name()).(fs.Directory)
 
-		summ.TotalFileCount += subdirsumm.TotalFileCount
-		summ.TotalFileSize += subdirsumm.TotalFileSize
-		summ.TotalDirCount += subdirsumm.TotalDirCount
-		if subdirsumm.MaxModTime.After(summ.MaxModTime) {
-			summ.MaxModTime = subdirsumm.MaxModTime
-		}
+		res := &subdirResult{name: entry.Name()}
+		results = append(results, res)
+
+		process := func() {
+			res.de, res.summ, res.err = u.uploadSubdirectory(ctx, parent, dir, previousDirs, baseDir, entryRelativePath)
+			if res.err != nil && res.err != errCancelled {
+				atomic.StoreInt32(&failed, 1)
+			}
 
-		if err != nil {
-			return errors.Errorf("unable to process directory %q: %s", entry.Name(), err)
+			u.maybeCheckpoint(ctx)
 		}
 
-		de, err := newDirEntry(dir, oid)
-		if err != nil {
-			return errors.Wrap(err, "unable to create dir entry")
+		if !u.tryAcquireDirWorker() {
+			process()
+			return nil
 		}
 
-		de.DirSummary = &subdirsumm
-		dirManifest.Entries = append(dirManifest.Entries, de)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer u.releaseDirWorker()
+
+			process()
+		}()
+
 		return nil
 	})
-}
 
-func (u *Uploader) prepareProgress(relativePath string, entries fs.Entries) {
-	u.currentProgressDir = relativePath
-	u.currentDirTotalSize = 0
-	u.currentDirCompleted = 0
-	u.currentDirNumFiles = 0
+	wg.Wait()
 
-	// Phase #2 - compute the total size of files in current directory
-	_ = u.foreachEntryUnlessCancelled(relativePath, entries, func(entry fs.Entry, entryRelativePath string) error {
-		if _, ok := entry.(fs.File); !ok {
-			// skip directories
-			return nil
+	var (
+		dirEntries    []*snapshot.DirEntry
+		failedEntries []*fs.EntryWithError
+	)
+
+	for _, res := range results {
+		if res.err == errCancelled {
+			err = errCancelled
+			continue
 		}
 
-		u.currentDirNumFiles++
-		u.currentDirTotalSize += entry.Size()
-		return nil
-	})
+		if res.err != nil {
+			return nil, errors.Errorf("unable to process directory %q: %s", res.name, res.err)
+		}
+
+		dirEntries = append(dirEntries, res.de)
+		failedEntries = append(failedEntries, res.summ.FailedEntries...)
+	}
+
+	// replace entries added as uploads completed with the same entries in deterministic order.
+	u.mu.Lock()
+	parent.dirManifest.Entries = dirEntries
+	u.mu.Unlock()
+
+	return failedEntries, err
+}
+
+// uploadSubdirectory uploads a subdirectory and adds it to the manifest and summary of its parent.
+func (u *Uploader) uploadSubdirectory(ctx context.Context, parent *checkpointDir, dir fs.Directory, previousDirs []fs.Directory, baseDir fs.Directory, dirRelativePath string) (*snapshot.DirEntry, fs.DirectorySummary, error) {
+	oid, summ, err := uploadDirInternal(ctx, u, parent, dir, previousDirs, baseDir, dirRelativePath)
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	// the subdirectory is no longer in progress, from now on checkpoints use its entry in the parent.
+	delete(parent.subdirs, dir.Name())
+
+	if err != nil {
+		return nil, summ, err
+	}
+
+	de, err := newDirEntry(dir, oid)
+	if err != nil {
+		return nil, summ, errors.Wrap(err, "unable to create dir entry")
+	}
+
+	de.DirSummary = &summ
+	parent.dirManifest.Entries = append(parent.dirManifest.Entries, de)
+
+	parent.summ.TotalFileCount += summ.TotalFileCount
+	parent.summ.TotalFileSize += summ.TotalFileSize
+	parent.summ.TotalDirCount += summ.TotalDirCount
+	parent.summ.NumFailed += summ.NumFailed
+	if summ.MaxModTime.After(parent.summ.MaxModTime) {
+		parent.summ.MaxModTime = summ.MaxModTime
+	}
+
+	return de, summ, nil
+}
+
+func (u *Uploader) tryAcquireDirWorker() bool {
+	select {
+	case u.dirWorkers <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+func (u *Uploader) releaseDirWorker() {
+	<-u.dirWorkers
 }
 
 type uploadWorkItem struct {
@@ -393,8 +563,19 @@ func objectIDPercent(obj object.ID) int {
 	return int(h.Sum32() % 100)
 }
 
-func (u *Uploader) maybeIgnoreCachedEntry(ent fs.Entry) fs.Entry {
+func (u *Uploader) maybeIgnoreCachedEntry(entry, ent fs.Entry, mode changedetection.Mode) fs.Entry {
 	if h, ok := ent.(object.HasObjectID); ok {
+		switch mode {
+		case changedetection.AlwaysHash:
+			return nil
+
+		case changedetection.TrustChangeTime:
+			if !u.hashCacheConfirms(entry, h.ObjectID()) {
+				log.Debugf("hash cache does not confirm cached object for %v", entry.Name())
+				return nil
+			}
+		}
+
 		if objectIDPercent(h.ObjectID()) < u.ForceHashPercentage {
 			log.Debugf("ignoring valid cached object: %v", h.ObjectID())
 			return nil
@@ -405,9 +586,11 @@ func (u *Uploader) maybeIgnoreCachedEntry(ent fs.Entry) fs.Entry {
 	return nil
 }
 
-func (u *Uploader) prepareWorkItems(ctx context.Context, dirRelativePath string, entries fs.Entries, prevEntries []fs.Entries, summ *fs.DirectorySummary) ([]*uploadWorkItem, error) {
+func (u *Uploader) prepareWorkItems(ctx context.Context, dp *dirProgress, dirRelativePath string, entries fs.Entries, prevEntries []fs.Entries, summ *fs.DirectorySummary) ([]*uploadWorkItem, error) {
 	var result []*uploadWorkItem
 
+	mode := u.ChangeDetection.ModeForPath(dirRelativePath)
+
 	resultErr := u.foreachEntryUnlessCancelled(dirRelativePath, entries, func(entry fs.Entry, entryRelativePath string) error {
 		if _, ok := entry.(fs.Directory); ok {
 			// skip directories
@@ -416,6 +599,7 @@ func (u *Uploader) prepareWorkItems(ctx context.Context, dirRelativePath string,
 
 		// regular file
 		if entry, ok := entry.(fs.File); ok {
+			u.mu.Lock()
 			u.stats.TotalFileCount++
 			u.stats.TotalFileSize += entry.Size()
 			summ.TotalFileCount++
@@ -423,15 +607,25 @@ func (u *Uploader) prepareWorkItems(ctx context.Context, dirRelativePath string,
 			if entry.ModTime().After(summ.MaxModTime) {
 				summ.MaxModTime = entry.ModTime()
 			}
+			u.mu.Unlock()
 		}
 
 		// See if we had this name during either of previous passes.
-		if cachedEntry := u.maybeIgnoreCachedEntry(findCachedEntry(entry, prevEntries)); cachedEntry != nil {
+		if cachedEntry := u.maybeIgnoreCachedEntry(entry, findCachedEntry(entry, prevEntries), mode); cachedEntry != nil {
+			u.mu.Lock()
 			u.stats.CachedFiles++
-			u.addDirProgress(entry.Size())
+			u.mu.Unlock()
+			u.addDirProgress(dp, entry.Size())
+
+			oid := cachedEntry.(object.HasObjectID).ObjectID()
+
+			// keep entries confirmed by the hash cache, files reused only based on their modification time are not added.
+			if u.hashCacheConfirms(entry, oid) {
+				u.addToHashCache(entry, oid)
+			}
 
 			// compute entryResult now, cachedEntry is short-lived
-			cachedDirEntry, err := newDirEntry(entry, cachedEntry.(object.HasObjectID).ObjectID())
+			cachedDirEntry, err := newDirEntry(entry, oid)
 			if err != nil {
 				return errors.Wrap(err, "unable to create dir entry")
 			}
@@ -451,17 +645,19 @@ func (u *Uploader) prepareWorkItems(ctx context.Context, dirRelativePath string,
 					entry:             entry,
 					entryRelativePath: entryRelativePath,
 					uploadFunc: func() entryResult {
-						return u.uploadSymlinkInternal(ctx, entry)
+						return u.uploadSymlinkInternal(ctx, dp, entry)
 					},
 				})
 
 			case fs.File:
+				u.mu.Lock()
 				u.stats.NonCachedFiles++
+				u.mu.Unlock()
 				result = append(result, &uploadWorkItem{
 					entry:             entry,
 					entryRelativePath: entryRelativePath,
 					uploadFunc: func() entryResult {
-						return u.uploadFileInternal(ctx, entry)
+						return u.uploadFileInternal(ctx, dp, entry)
 					},
 				})
 
@@ -488,31 +684,40 @@ func toChannel(items []*uploadWorkItem) <-chan *uploadWorkItem {
 	return ch
 }
 
+func (u *Uploader) parallelUploads() int {
+	if u.ParallelUploads <= 0 {
+		return runtime.NumCPU()
+	}
+
+	return u.ParallelUploads
+}
+
 func (u *Uploader) launchWorkItems(workItems []*uploadWorkItem, wg *sync.WaitGroup) {
 	// allocate result channel for each work item.
 	for _, it := range workItems {
 		it.resultChan = make(chan entryResult, 1)
 	}
 
-	workerCount := u.ParallelUploads
-	if workerCount == 0 {
-		workerCount = runtime.NumCPU()
-	}
-
+	// each directory has its own workers, but they only process as many files as there are slots
+	// in the global budget, so traversing directories in parallel does not multiply it.
 	ch := toChannel(workItems)
-	for i := 0; i < workerCount; i++ {
+	for i := 0; i < u.parallelUploads(); i++ {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
 
 			for it := range ch {
-				it.resultChan <- it.uploadFunc()
+				u.fileWorkers <- struct{}{}
+				result := it.uploadFunc()
+				<-u.fileWorkers
+
+				it.resultChan <- result
 			}
 		}()
 	}
 }
 
-func (u *Uploader) processUploadWorkItems(workItems []*uploadWorkItem, dirManifest *snapshot.DirManifest) error {
+func (u *Uploader) processUploadWorkItems(ctx context.Context, workItems []*uploadWorkItem, dirManifest *snapshot.DirManifest, summ *fs.DirectorySummary) error {
 	var wg sync.WaitGroup
 	u.launchWorkItems(workItems, &wg)
 
@@ -526,14 +731,31 @@ func (u *Uploader) processUploadWorkItems(workItems []*uploadWorkItem, dirManife
 
 		if result.err != nil {
 			if u.IgnoreFileErrors {
-				u.stats.ReadErrors++
 				log.Warningf("unable to hash file %q: %s, ignoring", it.entryRelativePath, result.err)
+
+				u.mu.Lock()
+				u.stats.ReadErrors++
+				readErrors := u.stats.ReadErrors
+				summ.NumFailed++
+				summ.FailedEntries = appendFailedEntries(summ.FailedEntries, &fs.EntryWithError{
+					EntryPath: it.entryRelativePath,
+					Error:     result.err.Error(),
+				})
+				u.mu.Unlock()
+
+				if u.MaxFileErrors >= 0 && readErrors > u.MaxFileErrors {
+					return errors.Errorf("too many file errors (%v, maximum allowed %v), last one: unable to process %q: %s", readErrors, u.MaxFileErrors, it.entryRelativePath, result.err)
+				}
+
 				continue
 			}
 			return errors.Errorf("unable to process %q: %s", it.entryRelativePath, result.err)
 		}
 
+		u.mu.Lock()
 		dirManifest.Entries = append(dirManifest.Entries, result.de)
+		u.mu.Unlock()
+		u.maybeCheckpoint(ctx)
 	}
 
 	// wait for workers, this is technically not needed, but let's make sure we don't leak goroutines
@@ -542,6 +764,19 @@ func (u *Uploader) processUploadWorkItems(workItems []*uploadWorkItem, dirManife
 	return nil
 }
 
+// appendFailedEntries appends failed entries to the provided list, up to maxFailedEntriesPerDirectorySummary.
+func appendFailedEntries(list []*fs.EntryWithError, entries ...*fs.EntryWithError) []*fs.EntryWithError {
+	for _, e := range entries {
+		if len(list) >= maxFailedEntriesPerDirectorySummary {
+			break
+		}
+
+		list = append(list, e)
+	}
+
+	return list
+}
+
 func maybeReadDirectoryEntries(ctx context.Context, dir fs.Directory) fs.Entries {
 	if dir == nil {
 		return nil
@@ -580,19 +815,24 @@ func uniqueDirectories(dirs []fs.Directory) []fs.Directory {
 func uploadDirInternal(
 	ctx context.Context,
 	u *Uploader,
+	parent *checkpointDir,
 	directory fs.Directory,
 	previousDirs []fs.Directory,
+	baseDir fs.Directory,
 	dirRelativePath string,
 ) (object.ID, fs.DirectorySummary, error) {
+	if oid, summ := u.reuseUnchangedDirectory(dirRelativePath, baseDir); summ != nil {
+		log.Debugf("reusing unchanged directory %v", dirRelativePath)
+		return oid, *summ, nil
+	}
+
+	u.mu.Lock()
 	u.stats.TotalDirectoryCount++
+	u.mu.Unlock()
 
 	var summ fs.DirectorySummary
 	summ.TotalDirCount = 1
 
-	defer func() {
-		summ.IncompleteReason = u.cancelReason()
-	}()
-
 	log.Debugf("reading directory %v", dirRelativePath)
 	entries, direrr := directory.Readdir(ctx)
 	log.Debugf("finished reading directory %v", dirRelativePath)
@@ -610,47 +850,93 @@ func uploadDirInternal(
 		summ.MaxModTime = directory.ModTime()
 	}
 
+	var baseEntries fs.Entries
+	if u.ChangeJournal != nil {
+		baseEntries = maybeReadDirectoryEntries(ctx, baseDir)
+	}
+
 	dirManifest := &snapshot.DirManifest{
 		StreamType: directoryStreamType,
 	}
 
-	if err := u.processSubdirectories(ctx, dirRelativePath, entries, prevEntries, dirManifest, &summ); err != nil && err != errCancelled {
+	cd := &checkpointDir{
+		dir:          directory,
+		relativePath: dirRelativePath,
+		dirManifest:  dirManifest,
+		summ:         &summ,
+		subdirs:      map[string]*checkpointDir{},
+	}
+
+	u.mu.Lock()
+	u.addCheckpointDir(parent, cd)
+	u.mu.Unlock()
+
+	subdirFailedEntries, err := u.processSubdirectories(ctx, cd, entries, prevEntries, baseEntries)
+	if err != nil && err != errCancelled {
 		return "", fs.DirectorySummary{}, err
 	}
-	u.prepareProgress(dirRelativePath, entries)
+
+	dp := newDirProgress(dirRelativePath, entries)
 
 	log.Debugf("preparing work items %v", dirRelativePath)
-	workItems, workItemErr := u.prepareWorkItems(ctx, dirRelativePath, entries, prevEntries, &summ)
+	workItems, workItemErr := u.prepareWorkItems(ctx, dp, dirRelativePath, entries, prevEntries, &summ)
 	log.Debugf("finished preparing work items %v", dirRelativePath)
 	if workItemErr != nil && workItemErr != errCancelled {
 		return "", fs.DirectorySummary{}, workItemErr
 	}
-	if err := u.processUploadWorkItems(workItems, dirManifest); err != nil && err != errCancelled {
-		return "", fs.DirectorySummary{}, err
+	if uploadErr := u.processUploadWorkItems(ctx, workItems, dirManifest, &summ); uploadErr != nil && uploadErr != errCancelled {
+		return "", fs.DirectorySummary{}, uploadErr
 	}
 	log.Debugf("finished processing uploads %v", dirRelativePath)
+
+	// failures of files in this directory are recorded before failures found in subdirectories.
+	// the summary may be read concurrently by checkpoints of other directories.
+	u.mu.Lock()
+	summ.FailedEntries = appendFailedEntries(summ.FailedEntries, subdirFailedEntries...)
+	summ.IncompleteReason = u.cancelReason()
 	dirManifest.Summary = &summ
+	u.mu.Unlock()
 
+	oid, err := u.writeDirManifest(ctx, dirRelativePath, dirManifest)
+	if err != nil {
+		return "", fs.DirectorySummary{}, err
+	}
+
+	return oid, summ, nil
+}
+
+func (u *Uploader) writeDirManifest(ctx context.Context, dirRelativePath string, dirManifest *snapshot.DirManifest) (object.ID, error) {
 	writer := u.repo.Objects.NewWriter(ctx, object.WriterOptions{
 		Description: "DIR:" + dirRelativePath,
 		Prefix:      "k",
 	})
+	defer writer.Close() //nolint:errcheck
 
 	if err := json.NewEncoder(writer).Encode(&dirManifest); err != nil {
-		return "", fs.DirectorySummary{}, errors.Wrap(err, "unable to encode directory JSON")
+		return "", errors.Wrap(err, "unable to encode directory JSON")
 	}
 
-	oid, err := writer.Result()
-	return oid, summ, err
+	return writer.Result()
+}
+
+func (u *Uploader) parallelDirectories() int {
+	if u.ParallelDirectories <= 0 {
+		return runtime.NumCPU()
+	}
+
+	return u.ParallelDirectories
 }
 
 // NewUploader creates new Uploader object for a given repository.
 func NewUploader(r *repo.Repository) *Uploader {
 	return &Uploader{
-		repo:             r,
-		Progress:         &nullUploadProgress{},
-		IgnoreFileErrors: true,
-		ParallelUploads:  1,
+		repo:                r,
+		Progress:            &nullUploadProgress{},
+		IgnoreFileErrors:    true,
+		MaxFileErrors:       -1,
+		ParallelUploads:     1,
+		ParallelDirectories: 1,
+		CheckpointInterval:  DefaultCheckpointInterval,
 	}
 }
 
@@ -689,17 +975,23 @@ func (u *Uploader) Upload(
 ) (*snapshot.Manifest, error) {
 	log.Debugf("Uploading %v", sourceInfo)
 	s := &snapshot.Manifest{
-		Source: sourceInfo,
+		Source:            sourceInfo,
+		PolicyFingerprint: u.PolicyFingerprint,
 	}
 
 	defer u.Progress.UploadFinished()
 
 	u.stats = snapshot.Stats{}
+	u.dirWorkers = make(chan struct{}, u.parallelDirectories()-1)
+	u.fileWorkers = make(chan struct{}, u.parallelUploads())
 
 	var err error
 
 	s.StartTime = time.Now()
 
+	u.resetCheckpoints(s)
+	defer u.resetCheckpoints(nil)
+
 	switch entry := source.(type) {
 	case fs.Directory:
 		var previousDirs []fs.Directory
@@ -709,10 +1001,17 @@ func (u *Uploader) Upload(
 			}
 		}
 
+		var baseDir fs.Directory
+		if u.ChangeJournal != nil {
+			baseDir = u.maybeOpenChangeJournalBase()
+		}
+
 		entry = ignorefs.New(entry, u.FilesPolicy, ignorefs.ReportIgnoredFiles(func(_ string, md fs.Entry) {
+			u.mu.Lock()
 			u.stats.AddExcluded(md)
+			u.mu.Unlock()
 		}))
-		s.RootEntry, err = u.uploadDir(ctx, entry, previousDirs)
+		s.RootEntry, err = u.uploadDir(ctx, entry, previousDirs, baseDir)
 
 	case fs.File:
 		s.RootEntry, err = u.uploadFile(ctx, entry)
@@ -730,5 +1029,12 @@ func (u *Uploader) Upload(
 	s.Stats = u.stats
 	s.Stats.Content = u.repo.Content.Stats()
 
+	if u.HashCache != nil {
+		// files in directories which were not read are kept in the cache.
+		if err := u.HashCache.Save(s.IncompleteReason == "" && u.stats.UnchangedDirCount == 0); err != nil {
+			log.Warningf("unable to save hash cache: %v", err)
+		}
+	}
+
 	return s, nil
 }
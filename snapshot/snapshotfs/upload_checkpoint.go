@@ -0,0 +1,190 @@
+package snapshotfs
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/repo/manifest"
+	"github.com/kopia/kopia/snapshot"
+)
+
+// DefaultCheckpointInterval is the default interval between checkpoint manifests written during upload.
+const DefaultCheckpointInterval = 45 * time.Minute
+
+// checkpointDir is a directory whose upload is in progress, all fields are protected by Uploader.mu
+// except in copies made by clone().
+type checkpointDir struct {
+	dir          fs.Directory
+	relativePath string
+	dirManifest  *snapshot.DirManifest     // entries completed so far
+	summ         *fs.DirectorySummary      // summary of entries completed so far
+	subdirs      map[string]*checkpointDir // subdirectories in progress by name
+}
+
+// addCheckpointDir adds a directory whose upload has started to its parent, the root has no parent.
+// Subdirectories are removed when their entries are added to the parent manifest.
+func (u *Uploader) addCheckpointDir(parent, cd *checkpointDir) {
+	if parent == nil {
+		u.checkpointRoot = cd
+		return
+	}
+
+	parent.subdirs[cd.dir.Name()] = cd
+}
+
+// clone returns a copy of the directory in progress and its subdirectories in progress, which can be
+// written without holding Uploader.mu. Entries are only ever appended, so copying slices is sufficient.
+// It must be called while holding Uploader.mu.
+func (cd *checkpointDir) clone() *checkpointDir {
+	summ := *cd.summ
+
+	result := &checkpointDir{
+		dir:          cd.dir,
+		relativePath: cd.relativePath,
+		dirManifest: &snapshot.DirManifest{
+			Entries: append([]*snapshot.DirEntry(nil), cd.dirManifest.Entries...),
+		},
+		summ:    &summ,
+		subdirs: map[string]*checkpointDir{},
+	}
+
+	for name, sd := range cd.subdirs {
+		result.subdirs[name] = sd.clone()
+	}
+
+	return result
+}
+
+// maybeCheckpoint writes the checkpoint manifest if it's due. It may be called from any goroutine
+// traversing the directory tree, the state of the upload is copied while holding Uploader.mu and
+// written after releasing it, so that other goroutines are not blocked. Only one checkpoint is written at a time.
+func (u *Uploader) maybeCheckpoint(ctx context.Context) {
+	u.mu.Lock()
+
+	if u.CheckpointInterval <= 0 || u.checkpointManifest == nil || u.checkpointRoot == nil || u.checkpointInProgress {
+		u.mu.Unlock()
+		return
+	}
+
+	if time.Now().Before(u.nextCheckpointTime) {
+		u.mu.Unlock()
+		return
+	}
+
+	man := *u.checkpointManifest
+	man.Stats = u.stats
+	root := u.checkpointRoot.clone()
+	prev := u.lastCheckpointID
+	u.checkpointInProgress = true
+
+	u.mu.Unlock()
+
+	id, err := u.writeCheckpoint(ctx, &man, root, prev)
+	if err != nil {
+		log.Warningf("unable to write checkpoint: %v", err)
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if id != "" {
+		u.lastCheckpointID = id
+	}
+
+	u.checkpointInProgress = false
+	u.nextCheckpointTime = time.Now().Add(u.CheckpointInterval)
+}
+
+// writeCheckpoint writes partial manifests of the provided copy of directories being uploaded and saves
+// the snapshot manifest pointing at them, replacing the previous checkpoint of the same upload.
+// It returns the ID of the saved manifest, even if the previous checkpoint could not be deleted.
+func (u *Uploader) writeCheckpoint(ctx context.Context, man *snapshot.Manifest, root *checkpointDir, prev manifest.ID) (manifest.ID, error) {
+	rootEntry, err := u.writeCheckpointDir(ctx, root)
+	if err != nil {
+		return "", err
+	}
+
+	man.RootEntry = rootEntry
+	man.EndTime = time.Now()
+	man.IncompleteReason = snapshot.IncompleteReasonCheckpoint
+	man.Stats.Content = u.repo.Content.Stats()
+
+	id, err := snapshot.SaveSnapshot(ctx, u.repo, man)
+	if err != nil {
+		return "", errors.Wrap(err, "unable to save checkpoint manifest")
+	}
+
+	if prev != "" {
+		if err := u.repo.Manifests.Delete(ctx, prev); err != nil {
+			return id, errors.Wrapf(err, "unable to delete previous checkpoint %v", prev)
+		}
+	}
+
+	// checkpoint is only useful if contents it refers to are persisted.
+	if err := u.repo.Flush(ctx); err != nil {
+		return id, errors.Wrap(err, "unable to flush repository")
+	}
+
+	log.Debugf("wrote checkpoint %v of %v", id, man.Source)
+
+	return id, nil
+}
+
+// writeCheckpointDir writes the partial manifest of a directory in progress, which consists of entries
+// completed so far and partial manifests of its subdirectories in progress, using a copy made by clone().
+func (u *Uploader) writeCheckpointDir(ctx context.Context, cd *checkpointDir) (*snapshot.DirEntry, error) {
+	summ := *cd.summ
+	summ.IncompleteReason = snapshot.IncompleteReasonCheckpoint
+
+	dm := &snapshot.DirManifest{
+		StreamType: directoryStreamType,
+		Entries:    append([]*snapshot.DirEntry(nil), cd.dirManifest.Entries...),
+		Summary:    &summ,
+	}
+
+	var names []string
+	for name := range cd.subdirs {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		de, err := u.writeCheckpointDir(ctx, cd.subdirs[name])
+		if err != nil {
+			return nil, err
+		}
+
+		dm.Entries = append(dm.Entries, de)
+	}
+
+	oid, err := u.writeDirManifest(ctx, cd.relativePath, dm)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to write checkpoint of %v", cd.relativePath)
+	}
+
+	de, err := newDirEntry(cd.dir, oid)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to create dir entry")
+	}
+
+	de.DirSummary = &summ
+
+	return de, nil
+}
+
+// resetCheckpoints prepares checkpointing of the upload described by the provided manifest.
+func (u *Uploader) resetCheckpoints(man *snapshot.Manifest) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	u.checkpointManifest = man
+	u.checkpointRoot = nil
+	u.lastCheckpointID = ""
+	u.checkpointInProgress = false
+	u.nextCheckpointTime = time.Now().Add(u.CheckpointInterval)
+}
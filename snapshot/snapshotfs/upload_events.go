@@ -0,0 +1,183 @@
+package snapshotfs
+
+import (
+	"sync"
+	"time"
+
+	"github.com/kopia/kopia/repo/content"
+	"github.com/kopia/kopia/repo/manifest"
+	"github.com/kopia/kopia/snapshot"
+)
+
+// UploadEventType is the type of UploadEvent.
+type UploadEventType string
+
+// Supported upload event types.
+const (
+	UploadEventStarted  UploadEventType = "started"  // upload has started
+	UploadEventProgress UploadEventType = "progress" // periodic progress of the upload
+	UploadEventFinished UploadEventType = "finished" // upload has finished, includes final statistics
+	UploadEventError    UploadEventType = "error"    // upload has failed
+)
+
+// DefaultUploadEventInterval is the default minimum interval between progress events.
+const DefaultUploadEventInterval = time.Second
+
+// UploadEvent describes the state of snapshot upload at a point in time.
+type UploadEvent struct {
+	Type   UploadEventType     `json:"type"`
+	Time   time.Time           `json:"time"`
+	Source snapshot.SourceInfo `json:"source"`
+
+	Path          string `json:"path,omitempty"` // directory currently being uploaded
+	PathCompleted int64  `json:"pathCompleted,omitempty"`
+	PathTotal     int64  `json:"pathTotal,omitempty"`
+
+	HashedFiles    int   `json:"hashedFiles"`    // files that were not found in previous snapshots
+	HashedBytes    int64 `json:"hashedBytes"`    // bytes hashed by the repository
+	CachedFiles    int   `json:"cachedFiles"`    // files reused from previous snapshots
+	ProcessedBytes int64 `json:"processedBytes"` // bytes of files hashed or reused so far
+	UploadedBytes  int64 `json:"uploadedBytes"`  // bytes written to the repository
+
+	EstimatedBytes int64   `json:"estimatedBytes,omitempty"` // total bytes expected, based on previous snapshot
+	ETASeconds     float64 `json:"etaSeconds,omitempty"`     // estimated time remaining
+
+	SnapshotID manifest.ID     `json:"snapshotID,omitempty"` // set for finished events
+	RootID     string          `json:"rootID,omitempty"`     // set for finished events
+	Stats      *snapshot.Stats `json:"stats,omitempty"`      // set for finished events
+	Error      string          `json:"error,omitempty"`      // set for error events
+}
+
+// UploadEventTracker implements UploadProgress and converts progress reports of the uploader into
+// a stream of UploadEvents, with progress events delivered no more frequently than Interval.
+type UploadEventTracker struct {
+	Source         snapshot.SourceInfo
+	EstimatedBytes int64 // total size of files, usually taken from previous snapshot
+	Interval       time.Duration
+
+	contentStats func() content.Stats
+	emit         func(*UploadEvent)
+
+	mu            sync.Mutex
+	startTime     time.Time
+	nextEventTime time.Time
+	last          UploadEvent
+	doneBytes     int64            // bytes processed in completed directories
+	inProgress    map[string]int64 // bytes processed so far in directories in progress
+}
+
+// NewUploadEventTracker returns UploadEventTracker for the provided source which invokes emit for each event.
+// Repository statistics are retrieved using the provided function.
+func NewUploadEventTracker(src snapshot.SourceInfo, contentStats func() content.Stats, emit func(*UploadEvent)) *UploadEventTracker {
+	return &UploadEventTracker{
+		Source:       src,
+		Interval:     DefaultUploadEventInterval,
+		contentStats: contentStats,
+		emit:         emit,
+	}
+}
+
+// Started emits the event marking the start of the upload.
+func (t *UploadEventTracker) Started() {
+	t.mu.Lock()
+	t.startTime = time.Now()
+	t.nextEventTime = t.startTime.Add(t.Interval)
+	t.doneBytes = 0
+	t.inProgress = map[string]int64{}
+	t.last = UploadEvent{Source: t.Source, EstimatedBytes: t.EstimatedBytes}
+	ev := t.newEventLocked(UploadEventStarted)
+	t.mu.Unlock()
+
+	t.emit(ev)
+}
+
+// Progress implements UploadProgress.
+func (t *UploadEventTracker) Progress(path string, numFiles int, pathCompleted, pathTotal int64, stats *snapshot.Stats) {
+	t.mu.Lock()
+
+	if pathCompleted >= pathTotal {
+		delete(t.inProgress, path)
+		t.doneBytes += pathCompleted
+	} else {
+		t.inProgress[path] = pathCompleted
+	}
+
+	t.last.Path = path
+	t.last.PathCompleted = pathCompleted
+	t.last.PathTotal = pathTotal
+	t.last.HashedFiles = stats.NonCachedFiles
+	t.last.CachedFiles = stats.CachedFiles
+	t.last.ProcessedBytes = t.doneBytes
+
+	for _, b := range t.inProgress {
+		t.last.ProcessedBytes += b
+	}
+
+	now := time.Now()
+	if now.Before(t.nextEventTime) {
+		t.mu.Unlock()
+		return
+	}
+
+	t.nextEventTime = now.Add(t.Interval)
+	ev := t.newEventLocked(UploadEventProgress)
+	t.mu.Unlock()
+
+	t.emit(ev)
+}
+
+// UploadFinished implements UploadProgress.
+func (t *UploadEventTracker) UploadFinished() {
+}
+
+// Finished emits the final event with statistics of the uploaded snapshot.
+func (t *UploadEventTracker) Finished(snapshotID manifest.ID, man *snapshot.Manifest) {
+	t.mu.Lock()
+	ev := t.newEventLocked(UploadEventFinished)
+	t.mu.Unlock()
+
+	stats := man.Stats
+	ev.Path = ""
+	ev.PathCompleted = 0
+	ev.PathTotal = 0
+	ev.ETASeconds = 0
+	ev.SnapshotID = snapshotID
+	ev.RootID = man.RootObjectID().String()
+	ev.Stats = &stats
+	ev.HashedFiles = stats.NonCachedFiles
+	ev.CachedFiles = stats.CachedFiles
+	ev.ProcessedBytes = stats.TotalFileSize
+
+	t.emit(ev)
+}
+
+// Failed emits the event describing upload failure.
+func (t *UploadEventTracker) Failed(err error) {
+	t.mu.Lock()
+	ev := t.newEventLocked(UploadEventError)
+	t.mu.Unlock()
+
+	ev.Error = err.Error()
+
+	t.emit(ev)
+}
+
+func (t *UploadEventTracker) newEventLocked(typ UploadEventType) *UploadEvent {
+	ev := t.last
+	ev.Type = typ
+	ev.Time = time.Now().UTC()
+
+	if t.contentStats != nil {
+		cs := t.contentStats()
+		ev.HashedBytes = cs.HashedBytes
+		ev.UploadedBytes = cs.WrittenBytes
+	}
+
+	if elapsed := ev.Time.Sub(t.startTime).Seconds(); elapsed > 0 && ev.ProcessedBytes > 0 && ev.EstimatedBytes > ev.ProcessedBytes {
+		ev.ETASeconds = float64(ev.EstimatedBytes-ev.ProcessedBytes) * elapsed / float64(ev.ProcessedBytes)
+	}
+
+	return &ev
+}
+
+var _ UploadProgress = (*UploadEventTracker)(nil)
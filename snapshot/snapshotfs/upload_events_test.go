@@ -0,0 +1,92 @@
+package snapshotfs
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo/content"
+	"github.com/kopia/kopia/snapshot"
+)
+
+func TestUploadEventTracker(t *testing.T) {
+	var events []*UploadEvent
+
+	src := snapshot.SourceInfo{Host: "host", UserName: "user", Path: "/path"}
+	tr := NewUploadEventTracker(src, func() content.Stats {
+		return content.Stats{HashedBytes: 300, WrittenBytes: 200}
+	}, func(ev *UploadEvent) {
+		events = append(events, ev)
+	})
+	tr.Interval = 0
+	tr.EstimatedBytes = 1000
+
+	tr.Started()
+	tr.Progress("dir1", 2, 100, 200, &snapshot.Stats{CachedFiles: 1})
+	tr.Progress("dir1", 2, 200, 200, &snapshot.Stats{CachedFiles: 1, NonCachedFiles: 1})
+	tr.Progress("dir2", 1, 50, 100, &snapshot.Stats{CachedFiles: 1, NonCachedFiles: 2})
+	tr.Finished("snap1", &snapshot.Manifest{Stats: snapshot.Stats{TotalFileSize: 300, NonCachedFiles: 2, CachedFiles: 1}})
+
+	if got, want := len(events), 5; got != want {
+		t.Fatalf("unexpected number of events: %v, want %v", got, want)
+	}
+
+	if events[0].Type != UploadEventStarted || events[4].Type != UploadEventFinished {
+		t.Errorf("unexpected event types: %v %v", events[0].Type, events[4].Type)
+	}
+
+	p := events[3]
+	if p.Type != UploadEventProgress || p.Path != "dir2" || p.ProcessedBytes != 250 || p.HashedFiles != 2 || p.CachedFiles != 1 {
+		t.Errorf("unexpected progress event: %+v", p)
+	}
+
+	if p.UploadedBytes != 200 || p.HashedBytes != 300 {
+		t.Errorf("unexpected repository statistics in progress event: %+v", p)
+	}
+
+	if p.ETASeconds <= 0 {
+		t.Errorf("missing ETA in progress event: %+v", p)
+	}
+
+	f := events[4]
+	if f.SnapshotID != "snap1" || f.Stats == nil || f.ProcessedBytes != 300 || f.Path != "" || f.ETASeconds != 0 {
+		t.Errorf("unexpected finished event: %+v", f)
+	}
+
+	tr.Failed(errors.New("some error"))
+
+	if e := events[len(events)-1]; e.Type != UploadEventError || e.Error != "some error" {
+		t.Errorf("unexpected error event: %+v", e)
+	}
+}
+
+func TestUploadEventTracker_InterleavedDirectories(t *testing.T) {
+	var last *UploadEvent
+
+	tr := NewUploadEventTracker(snapshot.SourceInfo{}, nil, func(ev *UploadEvent) {
+		last = ev
+	})
+	tr.Interval = 0
+
+	tr.Started()
+
+	cases := []struct {
+		path                     string
+		completed, total, wantPB int64
+	}{
+		{"dir1", 100, 200, 100},
+		{"dir2", 50, 100, 150},
+		{"dir1", 150, 200, 200},
+		{"dir2", 100, 100, 250},
+		{"dir1", 200, 200, 300},
+		{"dir3", 0, 0, 300},
+	}
+
+	for _, tc := range cases {
+		tr.Progress(tc.path, 1, tc.completed, tc.total, &snapshot.Stats{})
+
+		if last.Path != tc.path || last.ProcessedBytes != tc.wantPB {
+			t.Errorf("unexpected progress after %v %v/%v: %v %v, want %v", tc.path, tc.completed, tc.total, last.Path, last.ProcessedBytes, tc.wantPB)
+		}
+	}
+}
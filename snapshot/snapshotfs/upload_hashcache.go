@@ -0,0 +1,24 @@
+package snapshotfs
+
+import (
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/repo/object"
+)
+
+// hashCacheConfirms returns true if the local hash cache confirms that the file has not changed
+// since its contents were hashed into the provided object.
+func (u *Uploader) hashCacheConfirms(e fs.Entry, oid object.ID) bool {
+	if u.HashCache == nil {
+		return false
+	}
+
+	return u.HashCache.Get(e) == oid
+}
+
+func (u *Uploader) addToHashCache(e fs.Entry, oid object.ID) {
+	if u.HashCache == nil {
+		return
+	}
+
+	u.HashCache.Put(e, oid)
+}
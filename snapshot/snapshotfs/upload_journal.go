@@ -0,0 +1,59 @@
+package snapshotfs
+
+import (
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/repo/object"
+)
+
+// ChangeJournal reports directories which have not changed since a given point in time.
+type ChangeJournal interface {
+	// IsUnchanged returns true if neither the directory at the provided path relative to the upload root
+	// ("." for the root itself, "./a/b" for subdirectories) nor any of its subdirectories have changed.
+	IsUnchanged(relativePath string) bool
+}
+
+// maybeOpenChangeJournalBase returns the root directory of the base snapshot of the change journal, unless
+// it was taken with different policies, which may cause unchanged directories to be snapshotted differently.
+func (u *Uploader) maybeOpenChangeJournalBase() fs.Directory {
+	base := u.ChangeJournalBase
+	if base == nil {
+		return nil
+	}
+
+	if u.PolicyFingerprint == "" || base.PolicyFingerprint != u.PolicyFingerprint {
+		log.Infof("policies of %v changed since the previous snapshot, scanning all directories", base.Source)
+		return nil
+	}
+
+	return u.maybeOpenDirectoryFromManifest(base)
+}
+
+// reuseUnchangedDirectory returns the object ID and summary of the directory from the base snapshot
+// if the change journal indicates that it has not changed since the base snapshot was started.
+func (u *Uploader) reuseUnchangedDirectory(dirRelativePath string, baseDir fs.Directory) (object.ID, *fs.DirectorySummary) {
+	if u.ChangeJournal == nil || baseDir == nil || !u.ChangeJournal.IsUnchanged(dirRelativePath) {
+		return "", nil
+	}
+
+	h, ok := baseDir.(object.HasObjectID)
+	if !ok {
+		return "", nil
+	}
+
+	// directories which were not completely uploaded or had errors must be uploaded again.
+	summ := baseDir.Summary()
+	if summ == nil || summ.IncompleteReason != "" || summ.NumFailed > 0 {
+		return "", nil
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	u.stats.TotalDirectoryCount += int(summ.TotalDirCount)
+	u.stats.TotalFileCount += int(summ.TotalFileCount)
+	u.stats.TotalFileSize += summ.TotalFileSize
+	u.stats.CachedFiles += int(summ.TotalFileCount)
+	u.stats.UnchangedDirCount += int(summ.TotalDirCount)
+
+	return h.ObjectID(), summ
+}
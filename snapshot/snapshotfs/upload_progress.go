@@ -3,6 +3,8 @@ package snapshotfs
 import "github.com/kopia/kopia/snapshot"
 
 // UploadProgress is invoked by by uploader to report status of file and directory uploads.
+// Progress of multiple directories may be interleaved, calls are not made concurrently and the last
+// call for each directory has pathCompleted >= pathTotal.
 type UploadProgress interface {
 	Progress(path string, numFiles int, pathCompleted, pathTotal int64, stats *snapshot.Stats)
 	UploadFinished()
@@ -1,20 +1,27 @@
 package snapshotfs
 
 import (
+	"bytes"
 	"context"
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"reflect"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/pkg/errors"
 
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/internal/hashcache"
 	"github.com/kopia/kopia/internal/mockfs"
 	"github.com/kopia/kopia/repo"
 	"github.com/kopia/kopia/repo/blob/filesystem"
 	"github.com/kopia/kopia/repo/object"
 	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/changedetection"
 )
 
 const (
@@ -180,6 +187,25 @@ func TestUpload(t *testing.T) {
 }
 
 func TestUpload_Cancel(t *testing.T) {
+	ctx := context.Background()
+	th := newUploadTestHarness()
+	defer th.cleanup()
+
+	u := NewUploader(th.repo)
+	u.Cancel()
+
+	s, err := u.Upload(ctx, th.sourceDir, snapshot.SourceInfo{})
+	if err != nil {
+		t.Fatalf("upload failed: %v", err)
+	}
+
+	if got, want := s.IncompleteReason, "canceled"; got != want {
+		t.Errorf("unexpected incomplete reason: %v, want %v", got, want)
+	}
+
+	if got, want := s.RootEntry.DirSummary.IncompleteReason, "canceled"; got != want {
+		t.Errorf("unexpected incomplete reason of root directory: %v, want %v", got, want)
+	}
 }
 
 func TestUpload_TopLevelDirectoryReadFailure(t *testing.T) {
@@ -229,11 +255,454 @@ func TestUpload_SubdirectoryBecameFile(t *testing.T) {
 }
 
 func TestUpload_FileReadFailure(t *testing.T) {
+	ctx := context.Background()
+	th := newUploadTestHarness()
+	defer th.cleanup()
+
+	th.sourceDir.AddFile("f4", []byte{1}, defaultPermissions).FailOpen(errTest)
+	th.sourceDir.AddFile("d1/d2/f3", []byte{1}, defaultPermissions).FailOpen(errTest)
+
+	u := NewUploader(th.repo)
+	s1, err := u.Upload(ctx, th.sourceDir, snapshot.SourceInfo{})
+	if err != nil {
+		t.Fatalf("upload failed: %v", err)
+	}
+
+	if got, want := s1.Stats.ReadErrors, 2; got != want {
+		t.Errorf("unexpected read errors: %v, want %v", got, want)
+	}
+
+	if got, want := s1.RootEntry.DirSummary.NumFailed, 2; got != want {
+		t.Errorf("unexpected number of failed entries: %v, want %v", got, want)
+	}
+
+	root, err := SnapshotRoot(th.repo, s1)
+	if err != nil {
+		t.Fatalf("unable to open snapshot root: %v", err)
+	}
+
+	failed, err := FailedEntries(ctx, root.(fs.Directory))
+	if err != nil {
+		t.Fatalf("unable to list failed entries: %v", err)
+	}
+
+	var failedPaths []string
+	for _, e := range failed {
+		failedPaths = append(failedPaths, e.EntryPath)
+	}
+
+	if got, want := failedPaths, []string{"./f4", "./d1/d2/f3"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected failed entries: %v, want %v", got, want)
+	}
+
+	// exceeding the limit of file errors fails the upload.
+	u.MaxFileErrors = 1
+	if _, err := u.Upload(ctx, th.sourceDir, snapshot.SourceInfo{}); err == nil {
+		t.Errorf("expected error")
+	}
+
+	u.MaxFileErrors = 2
+	if _, err := u.Upload(ctx, th.sourceDir, snapshot.SourceInfo{}); err != nil {
+		t.Errorf("upload failed: %v", err)
+	}
 }
 
 func TestUpload_FileUploadFailure(t *testing.T) {
 }
 
+// fakeChangeJournal reports all directories except the provided ones (and their ancestors) as unchanged.
+type fakeChangeJournal map[string]bool
+
+func (j fakeChangeJournal) IsUnchanged(relativePath string) bool {
+	return !j[relativePath]
+}
+
+func TestUpload_ChangeJournal(t *testing.T) {
+	ctx := context.Background()
+	th := newUploadTestHarness()
+	defer th.cleanup()
+
+	u := NewUploader(th.repo)
+	u.PolicyFingerprint = "policy-1"
+
+	s1, err := u.Upload(ctx, th.sourceDir, snapshot.SourceInfo{})
+	if err != nil {
+		t.Fatalf("upload failed: %v", err)
+	}
+
+	if got, want := s1.PolicyFingerprint, u.PolicyFingerprint; got != want {
+		t.Errorf("unexpected policy fingerprint: %v, want %v", got, want)
+	}
+
+	th.sourceDir.AddFile("d1/d2/f3", []byte{1, 2, 3, 4, 5, 6}, defaultPermissions)
+
+	// unchanged directories must not be read at all.
+	th.sourceDir.Subdir("d2").FailReaddir(errTest)
+	th.sourceDir.Subdir("d1", "d1").FailReaddir(errTest)
+
+	u.ChangeJournal = fakeChangeJournal{".": true, "./d1": true, "./d1/d2": true}
+	u.ChangeJournalBase = s1
+
+	s2, err := u.Upload(ctx, th.sourceDir, snapshot.SourceInfo{})
+	if err != nil {
+		t.Fatalf("upload failed: %v", err)
+	}
+
+	// ./d1/d1, ./d2 and ./d2/d1
+	if got, want := s2.Stats.UnchangedDirCount, 3; got != want {
+		t.Errorf("unexpected number of unchanged directories: %v, want %v", got, want)
+	}
+
+	if got, want := s2.Stats.TotalFileCount, s1.Stats.TotalFileCount+1; got != want {
+		t.Errorf("unexpected file count: %v, want %v", got, want)
+	}
+
+	if got, want := s2.RootEntry.DirSummary.TotalFileCount, int64(s2.Stats.TotalFileCount); got != want {
+		t.Errorf("unexpected root summary file count: %v, want %v", got, want)
+	}
+
+	// the journal is not used when policies changed since the base snapshot, so all directories are read.
+	u.PolicyFingerprint = "policy-2"
+	if _, err := u.Upload(ctx, th.sourceDir, snapshot.SourceInfo{}); err == nil {
+		t.Errorf("expected error")
+	}
+
+	u.PolicyFingerprint = ""
+	if _, err := u.Upload(ctx, th.sourceDir, snapshot.SourceInfo{}); err == nil {
+		t.Errorf("expected error")
+	}
+
+	// without the journal the upload fails.
+	u.PolicyFingerprint = "policy-1"
+	u.ChangeJournal = nil
+	if _, err := u.Upload(ctx, th.sourceDir, snapshot.SourceInfo{}); err == nil {
+		t.Errorf("expected error")
+	}
+}
+
+func TestUpload_Checkpoint(t *testing.T) {
+	ctx := context.Background()
+	th := newUploadTestHarness()
+	defer th.cleanup()
+
+	src := snapshot.SourceInfo{Host: "host", UserName: "user", Path: "/path"}
+
+	u := NewUploader(th.repo)
+	u.CheckpointInterval = time.Nanosecond
+
+	s1, err := u.Upload(ctx, th.sourceDir, src)
+	if err != nil {
+		t.Fatalf("upload failed: %v", err)
+	}
+
+	snapshots, err := snapshot.ListSnapshots(ctx, th.repo, src)
+	if err != nil {
+		t.Fatalf("unable to list snapshots: %v", err)
+	}
+
+	// each checkpoint replaces the previous one.
+	if got, want := len(snapshots), 1; got != want {
+		t.Fatalf("unexpected number of checkpoints: %v, want %v", got, want)
+	}
+
+	checkpoint := snapshots[0]
+	if got, want := checkpoint.IncompleteReason, snapshot.IncompleteReasonCheckpoint; got != want {
+		t.Errorf("unexpected incomplete reason: %v, want %v", got, want)
+	}
+
+	// upload resumed from the checkpoint does not need to hash any files.
+	u.CheckpointInterval = 0
+	s2, err := u.Upload(ctx, th.sourceDir, src, checkpoint)
+	if err != nil {
+		t.Fatalf("upload failed: %v", err)
+	}
+
+	if got, want := s2.Stats.CachedFiles, s1.Stats.TotalFileCount; got != want {
+		t.Errorf("unexpected cached files: %v, want %v", got, want)
+	}
+
+	if got, want := s2.RootObjectID(), s1.RootObjectID(); got != want {
+		t.Errorf("unexpected root object ID: %v, want %v", got, want)
+	}
+
+	if _, err := snapshot.SaveSnapshot(ctx, th.repo, s2); err != nil {
+		t.Fatalf("unable to save snapshot: %v", err)
+	}
+
+	if err := snapshot.DeleteCheckpoints(ctx, th.repo, s2); err != nil {
+		t.Fatalf("unable to delete checkpoints: %v", err)
+	}
+
+	snapshots, err = snapshot.ListSnapshots(ctx, th.repo, src)
+	if err != nil {
+		t.Fatalf("unable to list snapshots: %v", err)
+	}
+
+	if got, want := len(snapshots), 1; got != want || snapshots[0].IncompleteReason != "" {
+		t.Errorf("unexpected snapshots after deleting checkpoints: %v, want %v complete", got, want)
+	}
+}
+
+func TestUpload_ParallelDirectories(t *testing.T) {
+	ctx := context.Background()
+	th := newUploadTestHarness()
+	defer th.cleanup()
+
+	for i := 0; i < 10; i++ {
+		th.sourceDir.AddDir(fmt.Sprintf("p%v", i), defaultPermissions)
+
+		for j := 0; j < 5; j++ {
+			th.sourceDir.AddDir(fmt.Sprintf("p%v/q%v", i, j), defaultPermissions)
+			th.sourceDir.AddFile(fmt.Sprintf("p%v/q%v/f", i, j), []byte{byte(i), byte(j)}, defaultPermissions)
+		}
+
+		th.sourceDir.AddFile(fmt.Sprintf("p%v/f", i), []byte{byte(i)}, defaultPermissions).FailOpen(errTest)
+	}
+
+	u := NewUploader(th.repo)
+	s1, err := u.Upload(ctx, th.sourceDir, snapshot.SourceInfo{})
+	if err != nil {
+		t.Fatalf("upload failed: %v", err)
+	}
+
+	u.ParallelDirectories = 8
+	s2, err := u.Upload(ctx, th.sourceDir, snapshot.SourceInfo{})
+	if err != nil {
+		t.Fatalf("upload failed: %v", err)
+	}
+
+	// output does not depend on the order in which directories complete.
+	if got, want := s2.RootObjectID(), s1.RootObjectID(); got != want {
+		t.Errorf("unexpected root object ID: %v, want %v", got, want)
+	}
+
+	if !reflect.DeepEqual(s2.RootEntry.DirSummary, s1.RootEntry.DirSummary) {
+		t.Errorf("unexpected root summary: %+v, want %+v", s2.RootEntry.DirSummary, s1.RootEntry.DirSummary)
+	}
+
+	s1.Stats.Content = s2.Stats.Content
+	if !reflect.DeepEqual(s2.Stats, s1.Stats) {
+		t.Errorf("unexpected stats: %+v, want %+v", s2.Stats, s1.Stats)
+	}
+
+	s3, err := u.Upload(ctx, th.sourceDir, snapshot.SourceInfo{}, s2)
+	if err != nil {
+		t.Fatalf("upload failed: %v", err)
+	}
+
+	// files that failed to open are not in the previous manifest and are not cached.
+	if got, want := s3.Stats.CachedFiles, s2.Stats.NonCachedFiles-s2.Stats.ReadErrors; got != want {
+		t.Errorf("unexpected cached files: %v, want %v", got, want)
+	}
+
+	// failure of one of the subdirectories fails the upload.
+	th.sourceDir.Subdir("p3", "q2").FailReaddir(errTest)
+
+	if _, err := u.Upload(ctx, th.sourceDir, snapshot.SourceInfo{}); err == nil {
+		t.Errorf("expected error")
+	}
+}
+
+// trackedReader decrements the number of open files when closed.
+type trackedReader struct {
+	*bytes.Reader
+	open *int32
+}
+
+func (r trackedReader) Close() error {
+	atomic.AddInt32(r.open, -1)
+	return nil
+}
+
+func TestUpload_ParallelUploadsLimitIsGlobal(t *testing.T) {
+	ctx := context.Background()
+	th := newUploadTestHarness()
+	defer th.cleanup()
+
+	var open, maxOpen int32
+
+	for i := 0; i < 8; i++ {
+		th.sourceDir.AddDir(fmt.Sprintf("p%v", i), defaultPermissions)
+
+		for j := 0; j < 4; j++ {
+			f := th.sourceDir.AddFile(fmt.Sprintf("p%v/f%v", i, j), nil, defaultPermissions)
+			contents := []byte{byte(i), byte(j)}
+
+			f.SetSource(func() (mockfs.ReaderSeekerCloser, error) {
+				n := atomic.AddInt32(&open, 1)
+				for {
+					m := atomic.LoadInt32(&maxOpen)
+					if n <= m || atomic.CompareAndSwapInt32(&maxOpen, m, n) {
+						break
+					}
+				}
+
+				time.Sleep(5 * time.Millisecond)
+
+				return trackedReader{bytes.NewReader(contents), &open}, nil
+			})
+		}
+	}
+
+	u := NewUploader(th.repo)
+	u.ParallelDirectories = 8
+	u.ParallelUploads = 2
+
+	if _, err := u.Upload(ctx, th.sourceDir, snapshot.SourceInfo{}); err != nil {
+		t.Fatalf("upload failed: %v", err)
+	}
+
+	if got, want := atomic.LoadInt32(&maxOpen), int32(u.ParallelUploads); got > want {
+		t.Errorf("too many files uploaded in parallel: %v, want at most %v", got, want)
+	}
+}
+
+func TestUpload_ParallelDirectoriesCheckpoint(t *testing.T) {
+	ctx := context.Background()
+	th := newUploadTestHarness()
+	defer th.cleanup()
+
+	src := snapshot.SourceInfo{Host: "host", UserName: "user", Path: "/path"}
+
+	u := NewUploader(th.repo)
+	u.ParallelDirectories = 4
+	u.CheckpointInterval = time.Nanosecond
+
+	s1, err := u.Upload(ctx, th.sourceDir, src)
+	if err != nil {
+		t.Fatalf("upload failed: %v", err)
+	}
+
+	snapshots, err := snapshot.ListSnapshots(ctx, th.repo, src)
+	if err != nil {
+		t.Fatalf("unable to list snapshots: %v", err)
+	}
+
+	if got, want := len(snapshots), 1; got != want {
+		t.Fatalf("unexpected number of checkpoints: %v, want %v", got, want)
+	}
+
+	u.CheckpointInterval = 0
+	s2, err := u.Upload(ctx, th.sourceDir, src, snapshots[0])
+	if err != nil {
+		t.Fatalf("upload failed: %v", err)
+	}
+
+	if got, want := s2.RootObjectID(), s1.RootObjectID(); got != want {
+		t.Errorf("unexpected root object ID: %v, want %v", got, want)
+	}
+}
+
+func TestCheckpointDirClone(t *testing.T) {
+	th := newUploadTestHarness()
+	defer th.cleanup()
+
+	root := &checkpointDir{
+		dir:         th.sourceDir,
+		dirManifest: &snapshot.DirManifest{Entries: []*snapshot.DirEntry{{Name: "a"}}},
+		summ:        &fs.DirectorySummary{TotalFileCount: 1},
+		subdirs:     map[string]*checkpointDir{},
+	}
+
+	sub := &checkpointDir{
+		dir:         th.sourceDir.Subdir("d1"),
+		dirManifest: &snapshot.DirManifest{},
+		summ:        &fs.DirectorySummary{},
+		subdirs:     map[string]*checkpointDir{},
+	}
+	root.subdirs["d1"] = sub
+
+	c := root.clone()
+
+	// upload progress after the copy was made does not affect it.
+	root.dirManifest.Entries = append(root.dirManifest.Entries, &snapshot.DirEntry{Name: "b"})
+	root.summ.TotalFileCount++
+	sub.dirManifest.Entries = append(sub.dirManifest.Entries, &snapshot.DirEntry{Name: "c"})
+	delete(root.subdirs, "d1")
+
+	if got := len(c.dirManifest.Entries); got != 1 {
+		t.Errorf("unexpected number of entries in copy: %v", got)
+	}
+
+	if got := c.summ.TotalFileCount; got != 1 {
+		t.Errorf("unexpected file count in copy: %v", got)
+	}
+
+	csub := c.subdirs["d1"]
+	if csub == nil || len(csub.dirManifest.Entries) != 0 {
+		t.Errorf("unexpected subdirectory in copy: %+v", csub)
+	}
+}
+
+func TestUpload_ChangeDetection(t *testing.T) {
+	ctx := context.Background()
+	th := newUploadTestHarness()
+	defer th.cleanup()
+
+	entries, err := th.sourceDir.Readdir(ctx)
+	if err != nil {
+		t.Fatalf("readdir failed: %v", err)
+	}
+
+	t0 := time.Now().Add(-time.Hour)
+
+	for i, name := range []string{"f1", "f2", "f3"} {
+		entries.FindByName(name).(*mockfs.File).SetLocalFilesystemInfo(&fs.LocalFilesystemInfo{Device: 1, Inode: uint64(i + 1), ChangeTime: t0})
+	}
+
+	hc, err := hashcache.Open(filepath.Join(th.repoDir, "hashcache.json"))
+	if err != nil {
+		t.Fatalf("unable to open hash cache: %v", err)
+	}
+
+	u := NewUploader(th.repo)
+	u.HashCache = hc
+	u.ChangeDetection = changedetection.Map{
+		".":    changedetection.TrustChangeTime,
+		"./d1": changedetection.AlwaysHash,
+		"./d2": changedetection.TrustModTime,
+	}
+
+	s1, err := u.Upload(ctx, th.sourceDir, snapshot.SourceInfo{})
+	if err != nil {
+		t.Fatalf("upload failed: %v", err)
+	}
+
+	// f1 is modified preserving its size and modification time, which only changes its change time.
+	f1 := entries.FindByName("f1").(*mockfs.File)
+	f1.SetContents([]byte{4, 5, 6})
+	f1.SetLocalFilesystemInfo(&fs.LocalFilesystemInfo{Device: 1, Inode: 1, ChangeTime: t0.Add(time.Second)})
+
+	s2, err := u.Upload(ctx, th.sourceDir, snapshot.SourceInfo{}, s1)
+	if err != nil {
+		t.Fatalf("upload failed: %v", err)
+	}
+
+	// f1 and all files in ./d1 are hashed, f2 and f3 are confirmed by the hash cache and files in ./d2 trust their modification time.
+	if got, want := s2.Stats.NonCachedFiles, 6; got != want {
+		t.Errorf("unexpected non-cached files: %v, want %v", got, want)
+	}
+
+	if got, want := s2.Stats.CachedFiles, 4; got != want {
+		t.Errorf("unexpected cached files: %v, want %v", got, want)
+	}
+
+	// trusting modification time does not detect the change.
+	u.ChangeDetection = nil
+	s3, err := u.Upload(ctx, th.sourceDir, snapshot.SourceInfo{}, s1)
+	if err != nil {
+		t.Fatalf("upload failed: %v", err)
+	}
+
+	if got, want := s3.Stats.CachedFiles, s1.Stats.TotalFileCount; got != want {
+		t.Errorf("unexpected cached files: %v, want %v", got, want)
+	}
+
+	if s2.RootObjectID() == s3.RootObjectID() {
+		t.Errorf("change of f1 was not detected")
+	}
+}
+
 func TestUpload_FileDeleted(t *testing.T) {
 }
 
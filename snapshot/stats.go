@@ -20,6 +20,9 @@ type Stats struct {
 	CachedFiles    int `json:"cachedFiles"`
 	NonCachedFiles int `json:"nonCachedFiles"`
 
+	// directories reused from previous snapshot without reading them, based on the change journal
+	UnchangedDirCount int `json:"unchangedDirCount,omitempty"`
+
 	ReadErrors int `json:"readErrors"`
 }
 
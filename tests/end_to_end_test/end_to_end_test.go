@@ -3,6 +3,7 @@ package endtoend_test
 import (
 	cryptorand "crypto/rand"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -16,6 +17,10 @@ import (
 	"time"
 
 	"github.com/kylelemons/godebug/pretty"
+
+	"github.com/kopia/kopia/internal/serverapi"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/content"
 )
 
 const repoPassword = "qWQPJ2hiiLgWRRCr" // nolint:gosec
@@ -120,6 +125,14 @@ func TestEndToEnd(t *testing.T) {
 		e.runAndExpectSuccess(t, "repo", "status")
 	})
 
+	t.Run("ReconnectWithRetryPolicy", func(t *testing.T) {
+		e.runAndExpectSuccess(t, "repo", "disconnect")
+		e.runAndExpectFailure(t, "repo", "connect", "filesystem", "--path", e.repoDir, "--retry-jitter=2")
+		e.runAndExpectSuccess(t, "repo", "connect", "filesystem", "--path", e.repoDir,
+			"--retry-max-attempts=3", "--retry-initial-backoff=100ms", "--retry-max-backoff=1s", "--retry-jitter=0.2")
+		e.runAndExpectSuccess(t, "repo", "status")
+	})
+
 	t.Run("ReconnectUsingToken", func(t *testing.T) {
 		lines := e.runAndExpectSuccess(t, "repo", "status", "-t", "-s")
 		prefix := "$ kopia "
@@ -221,6 +234,33 @@ func TestEndToEnd(t *testing.T) {
 	})
 }
 
+func TestRecoveryKitResetPassword(t *testing.T) {
+	e := newTestEnv(t)
+	defer e.cleanup(t)
+	defer e.runAndExpectSuccess(t, "repo", "disconnect")
+
+	e.runAndExpectSuccess(t, "repo", "create", "filesystem", "--path", e.repoDir)
+	dir1 := filepath.Join(e.dataDir, "dir1")
+	createDirectory(t, dir1, 1)
+	e.runAndExpectSuccess(t, "snapshot", "create", dir1)
+
+	kitNoKeys := filepath.Join(e.configDir, "kit-no-keys.txt")
+	kit := filepath.Join(e.configDir, "kit.txt")
+
+	e.runAndExpectSuccess(t, "repo", "export-recovery-kit", "--output", kitNoKeys)
+	e.runAndExpectSuccess(t, "repo", "export-recovery-kit", "--output", kit, "--include-keys", "--recovery-passphrase=recovery")
+	e.runAndExpectSuccess(t, "repo", "disconnect")
+
+	// the password can only be reset using the key material.
+	e.runAndExpectFailure(t, "repo", "recover", "--kit", kitNoKeys, "--reset-password", "--password=new-password", "filesystem", "--path", e.repoDir)
+	e.runAndExpectFailure(t, "repo", "recover", "--kit", kit, "--reset-password", "--recovery-passphrase=wrong", "--password=new-password", "filesystem", "--path", e.repoDir)
+	e.runAndExpectSuccess(t, "repo", "recover", "--kit", kit, "--reset-password", "--recovery-passphrase=recovery", "--password=new-password", "filesystem", "--path", e.repoDir)
+
+	e.runAndExpectFailure(t, "repo", "connect", "filesystem", "--path", e.repoDir)
+	e.runAndExpectSuccess(t, "repo", "connect", "filesystem", "--path", e.repoDir, "--password=new-password")
+	e.runAndVerifyOutputLineCount(t, 2, "snapshot", "list", dir1)
+}
+
 func TestDiff(t *testing.T) {
 	e := newTestEnv(t)
 	defer e.cleanup(t)
@@ -279,6 +319,55 @@ canary
 	}
 }
 
+func TestJSONOutput(t *testing.T) {
+	e := newTestEnv(t)
+	defer e.cleanup(t)
+	defer e.runAndExpectSuccess(t, "repo", "disconnect")
+
+	e.runAndExpectSuccess(t, "repo", "create", "filesystem", "--path", e.repoDir)
+
+	dir1 := filepath.Join(e.dataDir, "dir1")
+	createDirectory(t, dir1, 2)
+	e.runAndExpectSuccess(t, "snapshot", "create", dir1)
+
+	// each line of listing commands must be a JSON object matching the documented schema.
+	cases := []struct {
+		args    []string
+		newItem func() interface{}
+		single  bool
+	}{
+		{[]string{"snapshot", "list", "--json"}, func() interface{} { return &serverapi.Snapshot{} }, false},
+		{[]string{"policy", "show", "--global", "--json"}, func() interface{} { return &serverapi.EffectivePolicyResponse{} }, false},
+		{[]string{"content", "list", "--json"}, func() interface{} { return &content.Info{} }, false},
+		{[]string{"blob", "list", "--json"}, func() interface{} { return &blob.Metadata{} }, false},
+		{[]string{"index", "list", "--json"}, func() interface{} { return &blob.Metadata{} }, false},
+		{[]string{"cache", "info", "--json"}, func() interface{} { return &serverapi.CacheInfoResponse{} }, true},
+		{[]string{"repository", "status", "--json"}, func() interface{} { return &serverapi.StatusResponse{} }, true},
+	}
+
+	for _, tc := range cases {
+		lines := e.runAndExpectSuccess(t, tc.args...)
+		if len(lines) == 0 {
+			t.Errorf("no output from 'kopia %v'", strings.Join(tc.args, " "))
+			continue
+		}
+
+		items := lines
+		if tc.single {
+			items = []string{strings.Join(lines, "\n")}
+		}
+
+		for _, it := range items {
+			dec := json.NewDecoder(strings.NewReader(it))
+			dec.DisallowUnknownFields()
+
+			if err := dec.Decode(tc.newItem()); err != nil {
+				t.Errorf("invalid output of 'kopia %v': %v in %q", strings.Join(tc.args, " "), err, it)
+			}
+		}
+	}
+}
+
 func (e *testenv) runAndExpectSuccess(t *testing.T, args ...string) []string {
 	t.Helper()
 	stdout, err := e.run(t, args...)